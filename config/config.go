@@ -1,6 +1,8 @@
 package config
 
 import (
+	"time"
+
 	"github.com/NYTimes/gizmo/config"
 	"github.com/NYTimes/gizmo/server"
 	"github.com/NYTimes/video-transcoding-api/db/redis/storage"
@@ -11,14 +13,30 @@ import (
 // Transcoding API.
 type Config struct {
 	Server                 *server.Config
-	SwaggerManifest        string `envconfig:"SWAGGER_MANIFEST_PATH"`
-	DefaultSegmentDuration uint   `envconfig:"DEFAULT_SEGMENT_DURATION" default:"5"`
+	SwaggerManifest        string   `envconfig:"SWAGGER_MANIFEST_PATH"`
+	DefaultSegmentDuration uint     `envconfig:"DEFAULT_SEGMENT_DURATION" default:"5"`
+	ProbeSources           bool     `envconfig:"PROBE_SOURCES"`
+	VerifyOutputs          bool     `envconfig:"VERIFY_OUTPUTS"`
+	AllowedDestinations    []string `envconfig:"ALLOWED_DESTINATIONS"`
+	OutputPathTemplate     string   `envconfig:"OUTPUT_PATH_TEMPLATE"`
+	FileNameTemplate       string   `envconfig:"OUTPUT_FILENAME_TEMPLATE"`
 	Redis                  *storage.Config
 	EncodingCom            *EncodingCom
 	ElasticTranscoder      *ElasticTranscoder
 	ElementalConductor     *ElementalConductor
 	Zencoder               *Zencoder
+	KeyServer              *KeyServer
+	URLSigner              *URLSigner
 	GCPCredentials         *envconfigfromfile.EnvConfigFromFile `envconfig:"GCP_CREDENTIALS_FILE"`
+
+	// interval between checks of the presets stored in the providers
+	// against their canonical versions. Periodic checks are disabled when
+	// the interval is zero
+	PresetDriftCheckInterval time.Duration `envconfig:"PRESET_DRIFT_CHECK_INTERVAL"`
+
+	// rungs available for adaptive bitrate ladders, in the format
+	// height:videoBitrate:audioBitrate
+	ABRLadder []string `envconfig:"ABR_LADDER" default:"1080:5000000:128000,720:3000000:128000,480:1500000:96000,360:800000:96000,240:400000:64000"`
 }
 
 // EncodingCom represents the set of configurations for the Encoding.com
@@ -59,6 +77,38 @@ type ElementalConductor struct {
 	Destination     string `envconfig:"ELEMENTALCONDUCTOR_DESTINATION"`
 }
 
+// KeyServer represents the set of configurations for storing and serving the
+// keys used in encrypted streaming jobs.
+type KeyServer struct {
+	// secret used for encrypting the keys before storing them
+	Secret string `envconfig:"KEY_SERVER_SECRET"`
+
+	// token that the key server must send when retrieving keys
+	Token string `envconfig:"KEY_SERVER_TOKEN"`
+}
+
+// URLSigner represents the set of configurations for generating signed
+// download URLs for the outputs of jobs.
+type URLSigner struct {
+	// signing backend (s3 or cloudfront). Signed URLs are disabled when
+	// no backend is configured
+	Backend string `envconfig:"URL_SIGNER"`
+
+	// default and maximum expiration of signed URLs, in seconds
+	Expiration    uint `envconfig:"URL_SIGNER_EXPIRATION" default:"3600"`
+	MaxExpiration uint `envconfig:"URL_SIGNER_MAX_EXPIRATION" default:"604800"`
+
+	// credentials used by the s3 backend
+	AccessKeyID     string `envconfig:"URL_SIGNER_AWS_ACCESS_KEY_ID"`
+	SecretAccessKey string `envconfig:"URL_SIGNER_AWS_SECRET_ACCESS_KEY"`
+	Region          string `envconfig:"URL_SIGNER_AWS_REGION"`
+
+	// distribution and key pair used by the cloudfront backend
+	CloudFrontDomain         string `envconfig:"URL_SIGNER_CLOUDFRONT_DOMAIN"`
+	CloudFrontKeyPairID      string `envconfig:"URL_SIGNER_CLOUDFRONT_KEY_PAIR_ID"`
+	CloudFrontPrivateKeyPath string `envconfig:"URL_SIGNER_CLOUDFRONT_PRIVATE_KEY_PATH"`
+}
+
 // LoadConfig loads the configuration of the API using environment variables.
 func LoadConfig() *Config {
 	cfg := Config{
@@ -66,10 +116,12 @@ func LoadConfig() *Config {
 		EncodingCom:        new(EncodingCom),
 		ElasticTranscoder:  new(ElasticTranscoder),
 		ElementalConductor: new(ElementalConductor),
+		KeyServer:          new(KeyServer),
+		URLSigner:          new(URLSigner),
 		Server:             new(server.Config),
 	}
 	config.LoadEnvConfig(&cfg)
-	loadFromEnv(cfg.Redis, cfg.EncodingCom, cfg.ElasticTranscoder, cfg.ElementalConductor, cfg.Server)
+	loadFromEnv(cfg.Redis, cfg.EncodingCom, cfg.ElasticTranscoder, cfg.ElementalConductor, cfg.KeyServer, cfg.URLSigner, cfg.Server)
 	return &cfg
 }
 
@@ -5,6 +5,7 @@ import (
 	"os"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/NYTimes/gizmo/server"
 	"github.com/NYTimes/video-transcoding-api/db/redis/storage"
@@ -39,16 +40,36 @@ func TestLoadConfigFromEnv(t *testing.T) {
 		"ELEMENTALCONDUCTOR_AWS_ACCESS_KEY_ID":     "AKIANOTREALLY",
 		"ELEMENTALCONDUCTOR_AWS_SECRET_ACCESS_KEY": "secret-key",
 		"ELEMENTALCONDUCTOR_DESTINATION":           "https://safe-stuff",
+		"KEY_SERVER_SECRET":                        "super-secret",
+		"KEY_SERVER_TOKEN":                         "key-server-token",
+		"URL_SIGNER":                               "cloudfront",
+		"URL_SIGNER_EXPIRATION":                    "600",
+		"URL_SIGNER_MAX_EXPIRATION":                "86400",
+		"URL_SIGNER_CLOUDFRONT_DOMAIN":             "d111111abcdef8.cloudfront.net",
+		"URL_SIGNER_CLOUDFRONT_KEY_PAIR_ID":        "APKANOTREALLY",
+		"URL_SIGNER_CLOUDFRONT_PRIVATE_KEY_PATH":   "/etc/cloudfront/pk.pem",
 		"SWAGGER_MANIFEST_PATH":                    "/opt/video-transcoding-api-swagger.json",
 		"HTTP_ACCESS_LOG":                          accessLog,
 		"HTTP_PORT":                                "8080",
 		"DEFAULT_SEGMENT_DURATION":                 "3",
+		"PROBE_SOURCES":                            "true",
+		"VERIFY_OUTPUTS":                           "true",
+		"ALLOWED_DESTINATIONS":                     "s3://team-a/videos,s3://team-b",
+		"OUTPUT_PATH_TEMPLATE":                     "{date}/{jobId}",
+		"OUTPUT_FILENAME_TEMPLATE":                 "{sourceBase}-{preset}.{ext}",
+		"PRESET_DRIFT_CHECK_INTERVAL":              "1h",
+		"ABR_LADDER":                               "720:2500000:128000,360:600000:64000",
 		"GCP_CREDENTIALS_FILE":                     gcpCredsTestFilePath,
 	})
 	cfg := LoadConfig()
 	expectedCfg := Config{
 		SwaggerManifest:        "/opt/video-transcoding-api-swagger.json",
 		DefaultSegmentDuration: 3,
+		ProbeSources:           true,
+		VerifyOutputs:          true,
+		AllowedDestinations:    []string{"s3://team-a/videos", "s3://team-b"},
+		OutputPathTemplate:     "{date}/{jobId}",
+		FileNameTemplate:       "{sourceBase}-{preset}.{ext}",
 		Redis: &storage.Config{
 			SentinelAddrs:      "10.10.10.10:26379,10.10.10.11:26379,10.10.10.12:26379",
 			SentinelMasterName: "supermaster",
@@ -79,6 +100,18 @@ func TestLoadConfigFromEnv(t *testing.T) {
 			SecretAccessKey: "secret-key",
 			Destination:     "https://safe-stuff",
 		},
+		KeyServer: &KeyServer{
+			Secret: "super-secret",
+			Token:  "key-server-token",
+		},
+		URLSigner: &URLSigner{
+			Backend:                  "cloudfront",
+			Expiration:               600,
+			MaxExpiration:            86400,
+			CloudFrontDomain:         "d111111abcdef8.cloudfront.net",
+			CloudFrontKeyPairID:      "APKANOTREALLY",
+			CloudFrontPrivateKeyPath: "/etc/cloudfront/pk.pem",
+		},
 		Server: &server.Config{
 			HTTPPort:      8080,
 			HTTPAccessLog: &accessLog,
@@ -87,6 +120,8 @@ func TestLoadConfigFromEnv(t *testing.T) {
 			FilePath: gcpCredsTestFilePath,
 			Value:    string(gcpCredsTestFileContents),
 		},
+		PresetDriftCheckInterval: time.Hour,
+		ABRLadder:                []string{"720:2500000:128000", "360:600000:64000"},
 	}
 	if cfg.SwaggerManifest != expectedCfg.SwaggerManifest {
 		t.Errorf("LoadConfig(): wrong swagger manifest. Want %q. Got %q", expectedCfg.SwaggerManifest, cfg.SwaggerManifest)
@@ -94,6 +129,27 @@ func TestLoadConfigFromEnv(t *testing.T) {
 	if cfg.DefaultSegmentDuration != expectedCfg.DefaultSegmentDuration {
 		t.Errorf("LoadConfig(): wrong default segment duration. Want %q. Got %q", expectedCfg.DefaultSegmentDuration, cfg.DefaultSegmentDuration)
 	}
+	if cfg.ProbeSources != expectedCfg.ProbeSources {
+		t.Errorf("LoadConfig(): wrong probe sources flag. Want %v. Got %v", expectedCfg.ProbeSources, cfg.ProbeSources)
+	}
+	if cfg.VerifyOutputs != expectedCfg.VerifyOutputs {
+		t.Errorf("LoadConfig(): wrong verify outputs flag. Want %v. Got %v", expectedCfg.VerifyOutputs, cfg.VerifyOutputs)
+	}
+	if !reflect.DeepEqual(cfg.AllowedDestinations, expectedCfg.AllowedDestinations) {
+		t.Errorf("LoadConfig(): wrong allowed destinations. Want %#v. Got %#v", expectedCfg.AllowedDestinations, cfg.AllowedDestinations)
+	}
+	if cfg.OutputPathTemplate != expectedCfg.OutputPathTemplate {
+		t.Errorf("LoadConfig(): wrong output path template. Want %q. Got %q", expectedCfg.OutputPathTemplate, cfg.OutputPathTemplate)
+	}
+	if cfg.PresetDriftCheckInterval != expectedCfg.PresetDriftCheckInterval {
+		t.Errorf("LoadConfig(): wrong preset drift check interval. Want %s. Got %s", expectedCfg.PresetDriftCheckInterval, cfg.PresetDriftCheckInterval)
+	}
+	if !reflect.DeepEqual(cfg.ABRLadder, expectedCfg.ABRLadder) {
+		t.Errorf("LoadConfig(): wrong ABR ladder. Want %#v. Got %#v", expectedCfg.ABRLadder, cfg.ABRLadder)
+	}
+	if cfg.FileNameTemplate != expectedCfg.FileNameTemplate {
+		t.Errorf("LoadConfig(): wrong file name template. Want %q. Got %q", expectedCfg.FileNameTemplate, cfg.FileNameTemplate)
+	}
 	if !reflect.DeepEqual(*cfg.Redis, *expectedCfg.Redis) {
 		t.Errorf("LoadConfig(): wrong Redis config returned. Want %#v. Got %#v.", *expectedCfg.Redis, *cfg.Redis)
 	}
@@ -106,6 +162,12 @@ func TestLoadConfigFromEnv(t *testing.T) {
 	if !reflect.DeepEqual(*cfg.ElementalConductor, *expectedCfg.ElementalConductor) {
 		t.Errorf("LoadConfig(): wrong Elemental Conductor config returned. Want %#v. Got %#v.", *expectedCfg.ElementalConductor, *cfg.ElementalConductor)
 	}
+	if !reflect.DeepEqual(*cfg.KeyServer, *expectedCfg.KeyServer) {
+		t.Errorf("LoadConfig(): wrong KeyServer config returned. Want %#v. Got %#v.", *expectedCfg.KeyServer, *cfg.KeyServer)
+	}
+	if !reflect.DeepEqual(*cfg.URLSigner, *expectedCfg.URLSigner) {
+		t.Errorf("LoadConfig(): wrong URLSigner config returned. Want %#v. Got %#v.", *expectedCfg.URLSigner, *cfg.URLSigner)
+	}
 	if !reflect.DeepEqual(*cfg.GCPCredentials, *expectedCfg.GCPCredentials) {
 		t.Errorf("LoadConfig(): Wrong GCPCredentials returned. Want %#v. Got %#v.", *expectedCfg.GCPCredentials, *cfg.GCPCredentials)
 	}
@@ -144,6 +206,8 @@ func TestLoadConfigFromEnvWithDefauts(t *testing.T) {
 	expectedCfg := Config{
 		SwaggerManifest:        "/opt/video-transcoding-api-swagger.json",
 		DefaultSegmentDuration: 5,
+		ProbeSources:           false,
+		VerifyOutputs:          false,
 		Redis: &storage.Config{
 			SentinelAddrs:      "10.10.10.10:26379,10.10.10.11:26379,10.10.10.12:26379",
 			SentinelMasterName: "supermaster",
@@ -175,6 +239,10 @@ func TestLoadConfigFromEnvWithDefauts(t *testing.T) {
 			SecretAccessKey: "secret-key",
 			Destination:     "https://safe-stuff",
 		},
+		URLSigner: &URLSigner{
+			Expiration:    3600,
+			MaxExpiration: 604800,
+		},
 		Server: &server.Config{
 			HTTPPort:      8080,
 			HTTPAccessLog: &accessLog,
@@ -186,6 +254,21 @@ func TestLoadConfigFromEnvWithDefauts(t *testing.T) {
 	if cfg.DefaultSegmentDuration != expectedCfg.DefaultSegmentDuration {
 		t.Errorf("LoadConfig(): wrong default segment duration. Want %q. Got %q", expectedCfg.DefaultSegmentDuration, cfg.DefaultSegmentDuration)
 	}
+	if cfg.ProbeSources != expectedCfg.ProbeSources {
+		t.Errorf("LoadConfig(): wrong probe sources flag. Want %v. Got %v", expectedCfg.ProbeSources, cfg.ProbeSources)
+	}
+	if cfg.VerifyOutputs != expectedCfg.VerifyOutputs {
+		t.Errorf("LoadConfig(): wrong verify outputs flag. Want %v. Got %v", expectedCfg.VerifyOutputs, cfg.VerifyOutputs)
+	}
+	if !reflect.DeepEqual(cfg.AllowedDestinations, expectedCfg.AllowedDestinations) {
+		t.Errorf("LoadConfig(): wrong allowed destinations. Want %#v. Got %#v", expectedCfg.AllowedDestinations, cfg.AllowedDestinations)
+	}
+	if cfg.OutputPathTemplate != expectedCfg.OutputPathTemplate {
+		t.Errorf("LoadConfig(): wrong output path template. Want %q. Got %q", expectedCfg.OutputPathTemplate, cfg.OutputPathTemplate)
+	}
+	if cfg.FileNameTemplate != expectedCfg.FileNameTemplate {
+		t.Errorf("LoadConfig(): wrong file name template. Want %q. Got %q", expectedCfg.FileNameTemplate, cfg.FileNameTemplate)
+	}
 	if !reflect.DeepEqual(*cfg.Redis, *expectedCfg.Redis) {
 		t.Errorf("LoadConfig(): wrong Redis config returned. Want %#v. Got %#v.", *expectedCfg.Redis, *cfg.Redis)
 	}
@@ -198,6 +281,9 @@ func TestLoadConfigFromEnvWithDefauts(t *testing.T) {
 	if !reflect.DeepEqual(*cfg.ElementalConductor, *expectedCfg.ElementalConductor) {
 		t.Errorf("LoadConfig(): wrong Elemental Conductor config returned. Want %#v. Got %#v.", *expectedCfg.ElementalConductor, *cfg.ElementalConductor)
 	}
+	if !reflect.DeepEqual(*cfg.URLSigner, *expectedCfg.URLSigner) {
+		t.Errorf("LoadConfig(): wrong URLSigner config returned. Want %#v. Got %#v.", *expectedCfg.URLSigner, *cfg.URLSigner)
+	}
 	if !reflect.DeepEqual(*cfg.Server, *expectedCfg.Server) {
 		t.Errorf("LoadConfig(): wrong Server config returned. Want %#v. Got %#v.", *expectedCfg.Server, *cfg.Server)
 	}
@@ -10,7 +10,10 @@ import (
 type fakeRepository struct {
 	triggerError bool
 	presetmaps   map[string]*db.PresetMap
+	versions     map[string][]*db.PresetVersion
+	versionSeqs  map[string]uint
 	localpresets map[string]*db.LocalPreset
+	keys         map[string]*db.EncryptionKey
 	jobs         []*db.Job
 }
 
@@ -21,7 +24,10 @@ func NewFakeRepository(triggerError bool) db.Repository {
 	return &fakeRepository{
 		triggerError: triggerError,
 		presetmaps:   make(map[string]*db.PresetMap),
+		versions:     make(map[string][]*db.PresetVersion),
+		versionSeqs:  make(map[string]uint),
 		localpresets: make(map[string]*db.LocalPreset),
+		keys:         make(map[string]*db.EncryptionKey),
 	}
 }
 
@@ -36,6 +42,18 @@ func (d *fakeRepository) CreateJob(job *db.Job) error {
 	return nil
 }
 
+func (d *fakeRepository) UpdateJob(job *db.Job) error {
+	if d.triggerError {
+		return errors.New("database error")
+	}
+	index, err := d.findJob(job.ID)
+	if err != nil {
+		return err
+	}
+	d.jobs[index] = job
+	return nil
+}
+
 func (d *fakeRepository) DeleteJob(job *db.Job) error {
 	if d.triggerError {
 		return errors.New("database error")
@@ -95,6 +113,19 @@ func (d *fakeRepository) ListJobs(filter db.JobFilter) ([]db.Job, error) {
 	return jobs, nil
 }
 
+func (d *fakeRepository) ListActiveJobs(preset string) ([]db.Job, error) {
+	if d.triggerError {
+		return nil, errors.New("database error")
+	}
+	var jobs []db.Job
+	for _, job := range d.jobs {
+		if job.Active() && contains(job.Presets, preset) {
+			jobs = append(jobs, *job)
+		}
+	}
+	return jobs, nil
+}
+
 func (d *fakeRepository) CreatePresetMap(presetmap *db.PresetMap) error {
 	if d.triggerError {
 		return errors.New("database error")
@@ -120,6 +151,21 @@ func (d *fakeRepository) UpdatePresetMap(presetmap *db.PresetMap) error {
 	return nil
 }
 
+func (d *fakeRepository) ReplacePresetMap(presetmap *db.PresetMap, version uint) error {
+	if d.triggerError {
+		return errors.New("database error")
+	}
+	current, ok := d.presetmaps[presetmap.Name]
+	if !ok {
+		return db.ErrPresetMapNotFound
+	}
+	if current.Version != version {
+		return db.ErrPresetMapChanged
+	}
+	d.presetmaps[presetmap.Name] = presetmap
+	return nil
+}
+
 func (d *fakeRepository) GetPresetMap(name string) (*db.PresetMap, error) {
 	if d.triggerError {
 		return nil, errors.New("database error")
@@ -152,6 +198,82 @@ func (d *fakeRepository) ListPresetMaps() ([]db.PresetMap, error) {
 	return presetmaps, nil
 }
 
+func (d *fakeRepository) NextPresetVersion(name string) (uint, error) {
+	if d.triggerError {
+		return 0, errors.New("database error")
+	}
+	d.versionSeqs[name]++
+	return d.versionSeqs[name], nil
+}
+
+func (d *fakeRepository) CreatePresetVersion(version *db.PresetVersion) error {
+	if d.triggerError {
+		return errors.New("database error")
+	}
+	if version.Name == "" {
+		return errors.New("preset name is required")
+	}
+	if version.Version == 0 {
+		return errors.New("version number is required")
+	}
+	if _, err := d.GetPresetVersion(version.Name, version.Version); err == nil {
+		return db.ErrPresetVersionAlreadyExists
+	}
+	if version.CreationTime.IsZero() {
+		version.CreationTime = time.Now().UTC()
+	}
+	versions := d.versions[version.Name]
+	index := len(versions)
+	for i, v := range versions {
+		if v.Version > version.Version {
+			index = i
+			break
+		}
+	}
+	versions = append(versions, nil)
+	copy(versions[index+1:], versions[index:])
+	versions[index] = version
+	d.versions[version.Name] = versions
+	return nil
+}
+
+func (d *fakeRepository) GetPresetVersion(name string, version uint) (*db.PresetVersion, error) {
+	if d.triggerError {
+		return nil, errors.New("database error")
+	}
+	for _, v := range d.versions[name] {
+		if v.Version == version {
+			return v, nil
+		}
+	}
+	return nil, db.ErrPresetVersionNotFound
+}
+
+func (d *fakeRepository) DeletePresetVersion(name string, version uint) error {
+	if d.triggerError {
+		return errors.New("database error")
+	}
+	versions := d.versions[name]
+	for i, v := range versions {
+		if v.Version == version {
+			d.versions[name] = append(versions[:i], versions[i+1:]...)
+			return nil
+		}
+	}
+	return db.ErrPresetVersionNotFound
+}
+
+func (d *fakeRepository) ListPresetVersions(name string) ([]db.PresetVersion, error) {
+	if d.triggerError {
+		return nil, errors.New("database error")
+	}
+	versions := make([]db.PresetVersion, len(d.versions[name]))
+	for i, version := range d.versions[name] {
+		versions[i] = *version
+	}
+	return versions, nil
+}
+
 func (d *fakeRepository) CreateLocalPreset(preset *db.LocalPreset) error {
 	if d.triggerError {
 		return errors.New("database error")
@@ -198,3 +320,44 @@ func (d *fakeRepository) DeleteLocalPreset(preset *db.LocalPreset) error {
 	delete(d.localpresets, preset.Name)
 	return nil
 }
+
+func (d *fakeRepository) CreateEncryptionKey(key *db.EncryptionKey) error {
+	if d.triggerError {
+		return errors.New("database error")
+	}
+	if key.JobID == "" {
+		return errors.New("job id is required")
+	}
+	d.keys[key.JobID] = key
+	return nil
+}
+
+func (d *fakeRepository) GetEncryptionKey(jobID string) (*db.EncryptionKey, error) {
+	if d.triggerError {
+		return nil, errors.New("database error")
+	}
+	if key, ok := d.keys[jobID]; ok {
+		return key, nil
+	}
+	return nil, db.ErrEncryptionKeyNotFound
+}
+
+func (d *fakeRepository) DeleteEncryptionKey(jobID string) error {
+	if d.triggerError {
+		return errors.New("database error")
+	}
+	if _, ok := d.keys[jobID]; !ok {
+		return db.ErrEncryptionKeyNotFound
+	}
+	delete(d.keys, jobID)
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -205,6 +205,43 @@ func TestListJobsLimit(t *testing.T) {
 	}
 }
 
+func TestListActiveJobs(t *testing.T) {
+	jobs := []db.Job{
+		{ID: "job-1", ProviderName: "encodingcom", Presets: []string{"mp4_720p"}, Status: "started"},
+		{ID: "job-2", ProviderName: "encodingcom", Presets: []string{"mp4_720p"}, Status: "finished"},
+		{ID: "job-3", ProviderName: "encodingcom", Presets: []string{"webm_720p"}, Status: "started"},
+		{ID: "job-4", ProviderName: "encodingcom", Presets: []string{"webm_720p", "mp4_720p"}},
+	}
+	repo := NewFakeRepository(false)
+	for i, job := range jobs {
+		job := job
+		err := repo.CreateJob(&job)
+		if err != nil {
+			t.Fatal(err)
+		}
+		jobs[i] = job
+	}
+	gotJobs, err := repo.ListActiveJobs("mp4_720p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedJobs := []db.Job{jobs[0], jobs[3]}
+	if !reflect.DeepEqual(gotJobs, expectedJobs) {
+		t.Errorf("ListActiveJobs: wrong list returned. Want %#v. Got %#v", expectedJobs, gotJobs)
+	}
+}
+
+func TestListActiveJobsDBError(t *testing.T) {
+	repo := NewFakeRepository(true)
+	jobs, err := repo.ListActiveJobs("mp4_720p")
+	if len(jobs) > 0 {
+		t.Errorf("Got unexpected non-empty job list: %v", jobs)
+	}
+	if err.Error() != dbErrorMsg {
+		t.Errorf("Wrong error message returned. Want %q. Got %q", dbErrorMsg, err.Error())
+	}
+}
+
 func TestListJobsDBError(t *testing.T) {
 	repo := NewFakeRepository(true)
 	jobs, err := repo.ListJobs(db.JobFilter{})
@@ -309,6 +346,54 @@ func TestUpdatePresetMapDBError(t *testing.T) {
 	}
 }
 
+func TestReplacePresetMap(t *testing.T) {
+	repo := NewFakeRepository(false)
+	preset := db.PresetMap{Name: "mypreset", Version: 1}
+	err := repo.CreatePresetMap(&preset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newPresetMap := preset
+	newPresetMap.Version = 2
+	newPresetMap.ProviderMapping = map[string]string{"some": "provider"}
+	err = repo.ReplacePresetMap(&newPresetMap, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedPresetMaps := map[string]*db.PresetMap{"mypreset": &newPresetMap}
+	presetmaps := repo.(*fakeRepository).presetmaps
+	if !reflect.DeepEqual(presetmaps, expectedPresetMaps) {
+		t.Errorf("Wrong internal preset registry. Want %#v. Got %#v", expectedPresetMaps, presetmaps)
+	}
+}
+
+func TestReplacePresetMapChanged(t *testing.T) {
+	repo := NewFakeRepository(false)
+	preset := db.PresetMap{Name: "mypreset", Version: 2}
+	err := repo.CreatePresetMap(&preset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newPresetMap := preset
+	newPresetMap.Version = 3
+	err = repo.ReplacePresetMap(&newPresetMap, 1)
+	if err != db.ErrPresetMapChanged {
+		t.Errorf("ReplacePresetMap: wrong error. Want %#v. Got %#v", db.ErrPresetMapChanged, err)
+	}
+	if presetmap := repo.(*fakeRepository).presetmaps["mypreset"]; presetmap.Version != 2 {
+		t.Errorf("ReplacePresetMap: wrong version stored. Want 2. Got %d", presetmap.Version)
+	}
+}
+
+func TestReplacePresetMapNotFound(t *testing.T) {
+	repo := NewFakeRepository(false)
+	preset := db.PresetMap{Name: "mypreset"}
+	err := repo.ReplacePresetMap(&preset, 0)
+	if err != db.ErrPresetMapNotFound {
+		t.Errorf("ReplacePresetMap: wrong error. Want %#v. Got %#v", db.ErrPresetMapNotFound, err)
+	}
+}
+
 func TestGetPresetMap(t *testing.T) {
 	repo := NewFakeRepository(false)
 	preset := db.PresetMap{Name: "mypreset"}
@@ -419,6 +504,115 @@ func TestListPresetMapsDBError(t *testing.T) {
 	}
 }
 
+func TestNextPresetVersion(t *testing.T) {
+	repo := NewFakeRepository(false)
+	for _, expected := range []uint{1, 2, 3} {
+		version, err := repo.NextPresetVersion("mypreset")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if version != expected {
+			t.Errorf("NextPresetVersion: wrong version returned. Want %d. Got %d", expected, version)
+		}
+	}
+	version, err := repo.NextPresetVersion("otherpreset")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 1 {
+		t.Errorf("NextPresetVersion: wrong version returned for other preset. Want 1. Got %d", version)
+	}
+}
+
+func TestCreatePresetVersion(t *testing.T) {
+	repo := NewFakeRepository(false)
+	version := db.PresetVersion{Name: "mypreset", Version: 1, Author: "gopher"}
+	err := repo.CreatePresetVersion(&version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version.CreationTime.IsZero() {
+		t.Error("CreatePresetVersion: should set the creation time of the version, but did not")
+	}
+	gotVersion, err := repo.GetPresetVersion("mypreset", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*gotVersion, version) {
+		t.Errorf("GetPresetVersion: wrong version returned. Want %#v. Got %#v", version, *gotVersion)
+	}
+}
+
+func TestCreatePresetVersionDuplicate(t *testing.T) {
+	repo := NewFakeRepository(false)
+	version := db.PresetVersion{Name: "mypreset", Version: 1}
+	err := repo.CreatePresetVersion(&version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = repo.CreatePresetVersion(&version)
+	if err != db.ErrPresetVersionAlreadyExists {
+		t.Errorf("CreatePresetVersion: wrong error returned. Want %#v. Got %#v", db.ErrPresetVersionAlreadyExists, err)
+	}
+}
+
+func TestGetPresetVersionNotFound(t *testing.T) {
+	repo := NewFakeRepository(false)
+	version, err := repo.GetPresetVersion("mypreset", 1)
+	if err != db.ErrPresetVersionNotFound {
+		t.Errorf("GetPresetVersion: wrong error returned. Want %#v. Got %#v", db.ErrPresetVersionNotFound, err)
+	}
+	if version != nil {
+		t.Errorf("GetPresetVersion: got unexpected non-nil version: %#v", version)
+	}
+}
+
+func TestDeletePresetVersion(t *testing.T) {
+	repo := NewFakeRepository(false)
+	for _, number := range []uint{1, 2} {
+		err := repo.CreatePresetVersion(&db.PresetVersion{Name: "mypreset", Version: number})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := repo.DeletePresetVersion("mypreset", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = repo.GetPresetVersion("mypreset", 2); err != db.ErrPresetVersionNotFound {
+		t.Errorf("GetPresetVersion: wrong error returned. Want %#v. Got %#v", db.ErrPresetVersionNotFound, err)
+	}
+	if _, err = repo.GetPresetVersion("mypreset", 1); err != nil {
+		t.Errorf("DeletePresetVersion: should not delete other versions. Got %#v", err)
+	}
+	err = repo.DeletePresetVersion("mypreset", 2)
+	if err != db.ErrPresetVersionNotFound {
+		t.Errorf("DeletePresetVersion: wrong error returned. Want %#v. Got %#v", db.ErrPresetVersionNotFound, err)
+	}
+}
+
+func TestListPresetVersions(t *testing.T) {
+	repo := NewFakeRepository(false)
+	for _, number := range []uint{3, 1, 2} {
+		err := repo.CreatePresetVersion(&db.PresetVersion{Name: "mypreset", Version: number})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	versions, err := repo.ListPresetVersions("mypreset")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var numbers []uint
+	for _, version := range versions {
+		numbers = append(numbers, version.Version)
+	}
+	expectedNumbers := []uint{1, 2, 3}
+	if !reflect.DeepEqual(numbers, expectedNumbers) {
+		t.Errorf("ListPresetVersions: wrong versions returned. Want %#v. Got %#v", expectedNumbers, numbers)
+	}
+}
+
 func TestCreateLocalPreset(t *testing.T) {
 	repo := NewFakeRepository(false)
 	preset := db.LocalPreset{Name: "mypreset"}
@@ -573,3 +767,54 @@ func TestDeleteLocalPresetDBError(t *testing.T) {
 		t.Errorf("DeleteLocalPreset: wrong error message. Want %q. Got %q", dbErrorMsg, err.Error())
 	}
 }
+
+func TestCreateEncryptionKey(t *testing.T) {
+	repo := NewFakeRepository(false)
+	key := db.EncryptionKey{JobID: "j-123", Method: "aes-128", Key: "MDEyMzQ1Njc4OWFiY2RlZg=="}
+	err := repo.CreateEncryptionKey(&key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotKey, err := repo.GetEncryptionKey("j-123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*gotKey, key) {
+		t.Errorf("GetEncryptionKey: wrong key returned. Want %#v. Got %#v", key, *gotKey)
+	}
+}
+
+func TestGetEncryptionKeyNotFound(t *testing.T) {
+	repo := NewFakeRepository(false)
+	_, err := repo.GetEncryptionKey("j-123")
+	if err != db.ErrEncryptionKeyNotFound {
+		t.Errorf("GetEncryptionKey: wrong error. Want %#v. Got %#v", db.ErrEncryptionKeyNotFound, err)
+	}
+}
+
+func TestCreateEncryptionKeyDBError(t *testing.T) {
+	repo := NewFakeRepository(true)
+	err := repo.CreateEncryptionKey(&db.EncryptionKey{JobID: "j-123"})
+	if err == nil {
+		t.Fatal("Unexpected <nil> error")
+	}
+	if err.Error() != dbErrorMsg {
+		t.Errorf("CreateEncryptionKey: wrong error message. Want %q. Got %q", dbErrorMsg, err.Error())
+	}
+}
+
+func TestDeleteEncryptionKey(t *testing.T) {
+	repo := NewFakeRepository(false)
+	err := repo.CreateEncryptionKey(&db.EncryptionKey{JobID: "j-123", Method: "aes-128", Key: "MDEyMzQ1Njc4OWFiY2RlZg=="})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = repo.DeleteEncryptionKey("j-123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = repo.DeleteEncryptionKey("j-123")
+	if err != db.ErrEncryptionKeyNotFound {
+		t.Errorf("DeleteEncryptionKey: wrong error. Want %#v. Got %#v", db.ErrEncryptionKeyNotFound, err)
+	}
+}
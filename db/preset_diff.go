@@ -0,0 +1,83 @@
+package db
+
+import (
+	"strconv"
+	"strings"
+)
+
+// FieldDiff describes a field of a preset whose value differs from the
+// expected one.
+type FieldDiff struct {
+	// name of the field, in the same format used by FieldError
+	Field string `json:"field"`
+
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+}
+
+// presetFields lists the fields compared by Diff, along with functions that
+// return their values as strings.
+var presetFields = []struct {
+	name  string
+	value func(p *Preset) string
+}{
+	{"container", func(p *Preset) string { return p.Container }},
+	{"profile", func(p *Preset) string { return p.Profile }},
+	{"profileLevel", func(p *Preset) string { return p.ProfileLevel }},
+	{"rateControl", func(p *Preset) string { return p.RateControl }},
+	{"video.codec", func(p *Preset) string { return p.Video.Codec }},
+	{"video.width", func(p *Preset) string { return p.Video.Width }},
+	{"video.height", func(p *Preset) string { return p.Video.Height }},
+	{"video.bitrate", func(p *Preset) string { return p.Video.Bitrate }},
+	{"video.gopSize", func(p *Preset) string { return p.Video.GopSize }},
+	{"video.gopMode", func(p *Preset) string { return p.Video.GopMode }},
+	{"video.interlaceMode", func(p *Preset) string { return p.Video.InterlaceMode }},
+	{"video.frameRate", func(p *Preset) string { return p.Video.FrameRate }},
+	{"video.alignKeyframes", func(p *Preset) string { return formatBool(p.Video.AlignKeyframes) }},
+	{"video.bFrames", func(p *Preset) string { return p.Video.BFrames }},
+	{"video.referenceFrames", func(p *Preset) string { return p.Video.ReferenceFrames }},
+	{"video.twoPass", func(p *Preset) string { return formatBool(p.Video.TwoPass) }},
+	{"video.maxBitrate", func(p *Preset) string { return p.Video.MaxBitrate }},
+	{"video.bufferSize", func(p *Preset) string { return p.Video.BufferSize }},
+	{"audio.codec", func(p *Preset) string { return normalizeAudioCodec(p.Audio.Codec) }},
+	{"audio.bitrate", func(p *Preset) string { return p.Audio.Bitrate }},
+	{"audio.channels", func(p *Preset) string { return p.Audio.Channels }},
+	{"audio.sampleRate", func(p *Preset) string { return p.Audio.SampleRate }},
+}
+
+// Diff compares the preset with a version of it stored in a provider,
+// returning the fields whose values differ. Values are compared ignoring
+// case.
+//
+// Providers can't report all fields of a preset, so the fields they don't
+// report should be given as ignored, and are not compared.
+func (p *Preset) Diff(actual Preset, ignored ...string) []FieldDiff {
+	var diffs []FieldDiff
+	for _, field := range presetFields {
+		if contains(ignored, field.name) {
+			continue
+		}
+		expectedValue, actualValue := field.value(p), field.value(&actual)
+		if strings.EqualFold(expectedValue, actualValue) {
+			continue
+		}
+		diffs = append(diffs, FieldDiff{Field: field.name, Expected: expectedValue, Actual: actualValue})
+	}
+	return diffs
+}
+
+func formatBool(value bool) string {
+	if !value {
+		return ""
+	}
+	return strconv.FormatBool(value)
+}
+
+// normalizeAudioCodec maps equivalent names of audio codecs to the same
+// value, so they're not reported as differences.
+func normalizeAudioCodec(codec string) string {
+	if codec == "libvorbis" {
+		return "vorbis"
+	}
+	return codec
+}
@@ -0,0 +1,91 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPresetDiff(t *testing.T) {
+	preset := Preset{
+		Name:      "mp4_720p",
+		Container: "mp4",
+		Profile:   "Main",
+		Video: VideoPreset{
+			Codec:   "h264",
+			Height:  "720",
+			Bitrate: "2500000",
+			GopSize: "90",
+			GopMode: "fixed",
+			TwoPass: true,
+		},
+		Audio: AudioPreset{Codec: "vorbis", Bitrate: "128000"},
+	}
+	var tests = []struct {
+		testCase string
+		actual   Preset
+		ignored  []string
+		expected []FieldDiff
+	}{
+		{
+			"same preset",
+			preset,
+			nil,
+			nil,
+		},
+		{
+			"fields not reported by the provider",
+			Preset{
+				Name:      "provider-id",
+				Container: "mp4",
+				Profile:   "main",
+				Video:     VideoPreset{Codec: "h264", Height: "720", Bitrate: "2500000"},
+				Audio:     AudioPreset{Codec: "libvorbis"},
+			},
+			[]string{"video.gopSize", "video.gopMode", "video.twoPass", "audio.bitrate"},
+			nil,
+		},
+		{
+			"fields removed in the provider",
+			Preset{
+				Name:      "mp4_720p",
+				Container: "mp4",
+				Profile:   "Main",
+				Video:     VideoPreset{Codec: "h264", Height: "720", Bitrate: "2500000", GopSize: "90", GopMode: "fixed"},
+				Audio:     AudioPreset{Codec: "vorbis", Bitrate: "128000"},
+			},
+			nil,
+			[]FieldDiff{
+				{Field: "video.twoPass", Expected: "true", Actual: ""},
+			},
+		},
+		{
+			"changed fields",
+			Preset{
+				Name:      "mp4_720p",
+				Container: "mp4",
+				Profile:   "high",
+				Video: VideoPreset{
+					Codec:   "h264",
+					Width:   "1280",
+					Height:  "720",
+					Bitrate: "5000000",
+					GopSize: "90",
+				},
+				Audio: AudioPreset{Codec: "vorbis", Bitrate: "64000"},
+			},
+			[]string{"video.gopMode", "video.twoPass"},
+			[]FieldDiff{
+				{Field: "profile", Expected: "Main", Actual: "high"},
+				{Field: "video.width", Expected: "", Actual: "1280"},
+				{Field: "video.bitrate", Expected: "2500000", Actual: "5000000"},
+				{Field: "audio.bitrate", Expected: "128000", Actual: "64000"},
+			},
+		},
+	}
+	for _, test := range tests {
+		diffs := preset.Diff(test.actual, test.ignored...)
+		if !reflect.DeepEqual(diffs, test.expected) {
+			t.Errorf("%s: wrong diff\nWant %#v\nGot  %#v", test.testCase, test.expected, diffs)
+		}
+	}
+}
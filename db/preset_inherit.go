@@ -0,0 +1,67 @@
+package db
+
+import "reflect"
+
+// Inherit returns the preset that results from overriding the fields of the
+// parent preset with the fields defined in the given preset. The name,
+// description and parent of the result are always taken from the given
+// preset.
+//
+// Only fields with non-zero values are overridden, so boolean options
+// enabled in the parent can't be disabled by the derived preset. Provider
+// options are merged, with the options of the given preset taking
+// precedence.
+func (p *Preset) Inherit(overrides Preset) Preset {
+	preset := *p
+	overrideFields(reflect.ValueOf(&preset).Elem(), reflect.ValueOf(overrides))
+	preset.ProviderOptions = p.ProviderOptions.Merge(overrides.ProviderOptions)
+	preset.Name = overrides.Name
+	preset.Description = overrides.Description
+	preset.Parent = overrides.Parent
+	return preset
+}
+
+// overrideFields sets the fields of dst to the non-zero values of the
+// fields in src, recursing into nested structs.
+func overrideFields(dst, src reflect.Value) {
+	for i := 0; i < src.NumField(); i++ {
+		field := src.Field(i)
+		if field.Kind() == reflect.Struct {
+			overrideFields(dst.Field(i), field)
+			continue
+		}
+		if field.Kind() == reflect.Map {
+			if field.Len() > 0 {
+				dst.Field(i).Set(field)
+			}
+			continue
+		}
+		if field.Interface() != reflect.Zero(field.Type()).Interface() {
+			dst.Field(i).Set(field)
+		}
+	}
+}
+
+// Merge returns the options that result from adding the given options to
+// these options, replacing the options with the same name. Neither of the
+// original maps are modified.
+func (o ProviderOptions) Merge(overrides ProviderOptions) ProviderOptions {
+	if len(overrides) == 0 {
+		return o
+	}
+	if len(o) == 0 {
+		return overrides
+	}
+	merged := make(ProviderOptions, len(o))
+	for _, options := range []ProviderOptions{o, overrides} {
+		for providerName, providerOptions := range options {
+			if merged[providerName] == nil {
+				merged[providerName] = make(map[string]interface{}, len(providerOptions))
+			}
+			for name, value := range providerOptions {
+				merged[providerName][name] = value
+			}
+		}
+	}
+	return merged
+}
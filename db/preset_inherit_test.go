@@ -0,0 +1,118 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPresetInherit(t *testing.T) {
+	parent := Preset{
+		Name:        "720p_base",
+		Description: "base preset for 720p outputs",
+		Container:   "mp4",
+		Profile:     "main",
+		Video: VideoPreset{
+			Codec:          "h264",
+			Height:         "720",
+			Bitrate:        "2500000",
+			GopSize:        "90",
+			AlignKeyframes: true,
+		},
+		Audio:   AudioPreset{Codec: "aac", Bitrate: "128000"},
+		Overlay: OverlayPreset{Position: "top-left"},
+	}
+	var tests = []struct {
+		testCase  string
+		overrides Preset
+		expected  Preset
+	}{
+		{
+			"only name",
+			Preset{Name: "720p_copy", Parent: "720p_base"},
+			Preset{
+				Name:      "720p_copy",
+				Parent:    "720p_base",
+				Container: "mp4",
+				Profile:   "main",
+				Video: VideoPreset{
+					Codec:          "h264",
+					Height:         "720",
+					Bitrate:        "2500000",
+					GopSize:        "90",
+					AlignKeyframes: true,
+				},
+				Audio:   AudioPreset{Codec: "aac", Bitrate: "128000"},
+				Overlay: OverlayPreset{Position: "top-left"},
+			},
+		},
+		{
+			"overridden fields",
+			Preset{
+				Name:        "720p_high",
+				Description: "720p with a higher bitrate",
+				Parent:      "720p_base",
+				Profile:     "high",
+				Video:       VideoPreset{Bitrate: "4000000", TwoPass: true},
+				Audio:       AudioPreset{Bitrate: "192000"},
+			},
+			Preset{
+				Name:        "720p_high",
+				Description: "720p with a higher bitrate",
+				Parent:      "720p_base",
+				Container:   "mp4",
+				Profile:     "high",
+				Video: VideoPreset{
+					Codec:          "h264",
+					Height:         "720",
+					Bitrate:        "4000000",
+					GopSize:        "90",
+					AlignKeyframes: true,
+					TwoPass:        true,
+				},
+				Audio:   AudioPreset{Codec: "aac", Bitrate: "192000"},
+				Overlay: OverlayPreset{Position: "top-left"},
+			},
+		},
+	}
+	for _, test := range tests {
+		preset := parent.Inherit(test.overrides)
+		if !reflect.DeepEqual(preset, test.expected) {
+			t.Errorf("%s: wrong preset\nWant %#v\nGot  %#v", test.testCase, test.expected, preset)
+		}
+	}
+	if parent.Video.Bitrate != "2500000" {
+		t.Errorf("parent preset was modified: %#v", parent)
+	}
+}
+
+func TestPresetInheritProviderOptions(t *testing.T) {
+	parent := Preset{
+		Name:      "720p_base",
+		Container: "mp4",
+		ProviderOptions: ProviderOptions{
+			"zencoder": {"speed": float64(2), "tuning": "film"},
+		},
+	}
+	preset := parent.Inherit(Preset{
+		Name:   "720p_fast",
+		Parent: "720p_base",
+		ProviderOptions: ProviderOptions{
+			"zencoder":          {"speed": float64(4)},
+			"elastictranscoder": {"ColorSpaceConversionMode": "Auto"},
+		},
+	})
+	expected := ProviderOptions{
+		"zencoder":          {"speed": float64(4), "tuning": "film"},
+		"elastictranscoder": {"ColorSpaceConversionMode": "Auto"},
+	}
+	if !reflect.DeepEqual(preset.ProviderOptions, expected) {
+		t.Errorf("wrong provider options\nWant %#v\nGot  %#v", expected, preset.ProviderOptions)
+	}
+	if speed := parent.ProviderOptions["zencoder"]["speed"]; speed != float64(2) {
+		t.Errorf("parent provider options were modified: %#v", parent.ProviderOptions)
+	}
+	preset = parent.Inherit(Preset{Name: "720p_copy", Parent: "720p_base"})
+	if !reflect.DeepEqual(preset.ProviderOptions, parent.ProviderOptions) {
+		t.Errorf("wrong inherited provider options\nWant %#v\nGot  %#v", parent.ProviderOptions, preset.ProviderOptions)
+	}
+}
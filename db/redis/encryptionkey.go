@@ -0,0 +1,104 @@
+package redis
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/db/redis/storage"
+)
+
+var errMissingKeyServerSecret = errors.New("missing key server secret. Please define the environment variable KEY_SERVER_SECRET")
+
+// CreateEncryptionKey stores the given key. The key is encrypted with the
+// secret defined in the key server configuration before reaching Redis.
+func (r *redisRepository) CreateEncryptionKey(key *db.EncryptionKey) error {
+	if key.JobID == "" {
+		return errors.New("job id is required")
+	}
+	sealedKey, err := r.sealKey(key.Key)
+	if err != nil {
+		return err
+	}
+	storedKey := *key
+	storedKey.Key = sealedKey
+	return r.storage.Save(r.encryptionKeyKey(key.JobID), &storedKey)
+}
+
+func (r *redisRepository) GetEncryptionKey(jobID string) (*db.EncryptionKey, error) {
+	key := db.EncryptionKey{JobID: jobID}
+	err := r.storage.Load(r.encryptionKeyKey(jobID), &key)
+	if err == storage.ErrNotFound {
+		return nil, db.ErrEncryptionKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	key.Key, err = r.openKey(key.Key)
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *redisRepository) DeleteEncryptionKey(jobID string) error {
+	err := r.storage.Delete(r.encryptionKeyKey(jobID))
+	if err == storage.ErrNotFound {
+		return db.ErrEncryptionKeyNotFound
+	}
+	return err
+}
+
+func (r *redisRepository) sealKey(key string) (string, error) {
+	aead, err := r.keyCipher()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(key), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (r *redisRepository) openKey(sealedKey string) (string, error) {
+	aead, err := r.keyCipher()
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(sealedKey)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", errors.New("invalid encryption key stored in the database")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	key, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(key), nil
+}
+
+func (r *redisRepository) keyCipher() (cipher.AEAD, error) {
+	if r.config.KeyServer == nil || r.config.KeyServer.Secret == "" {
+		return nil, errMissingKeyServerSecret
+	}
+	secret := sha256.Sum256([]byte(r.config.KeyServer.Secret))
+	block, err := aes.NewCipher(secret[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (r *redisRepository) encryptionKeyKey(jobID string) string {
+	return "encryptionkey:" + jobID
+}
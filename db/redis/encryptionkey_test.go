@@ -0,0 +1,120 @@
+package redis
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/NYTimes/video-transcoding-api/config"
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/db/redis/storage"
+)
+
+func TestCreateEncryptionKey(t *testing.T) {
+	err := cleanRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewRepository(&config.Config{
+		Redis:     new(storage.Config),
+		KeyServer: &config.KeyServer{Secret: "super-secret"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := db.EncryptionKey{
+		JobID:  "job-123",
+		Method: "aes-128",
+		Key:    "MDEyMzQ1Njc4OWFiY2RlZg==",
+		KeyURI: "https://keys.example.com/job-123",
+	}
+	err = repo.CreateEncryptionKey(&key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := repo.(*redisRepository).storage.RedisClient()
+	defer client.Close()
+	items, err := client.HGetAll("encryptionkey:job-123").Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if items["method"] != "aes-128" || items["keyuri"] != key.KeyURI {
+		t.Errorf("Wrong key hash returned from Redis: %#v", items)
+	}
+	if items["key"] == "" || strings.Contains(items["key"], key.Key) {
+		t.Errorf("Key stored in plain text: %q", items["key"])
+	}
+	gotKey, err := repo.GetEncryptionKey("job-123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*gotKey, key) {
+		t.Errorf("Wrong key returned. Want %#v. Got %#v", key, *gotKey)
+	}
+}
+
+func TestCreateEncryptionKeyMissingSecret(t *testing.T) {
+	err := cleanRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewRepository(&config.Config{Redis: new(storage.Config)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = repo.CreateEncryptionKey(&db.EncryptionKey{JobID: "job-123", Method: "aes-128", Key: "MDEyMzQ1Njc4OWFiY2RlZg=="})
+	if err != errMissingKeyServerSecret {
+		t.Errorf("Wrong error returned. Want %#v. Got %#v", errMissingKeyServerSecret, err)
+	}
+}
+
+func TestGetEncryptionKeyNotFound(t *testing.T) {
+	err := cleanRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewRepository(&config.Config{
+		Redis:     new(storage.Config),
+		KeyServer: &config.KeyServer{Secret: "super-secret"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := repo.GetEncryptionKey("job-123")
+	if err != db.ErrEncryptionKeyNotFound {
+		t.Errorf("Wrong error returned. Want %#v. Got %#v", db.ErrEncryptionKeyNotFound, err)
+	}
+	if key != nil {
+		t.Errorf("Unexpected non-nil key: %#v", key)
+	}
+}
+
+func TestDeleteEncryptionKey(t *testing.T) {
+	err := cleanRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewRepository(&config.Config{
+		Redis:     new(storage.Config),
+		KeyServer: &config.KeyServer{Secret: "super-secret"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = repo.CreateEncryptionKey(&db.EncryptionKey{JobID: "job-123", Method: "aes-128", Key: "MDEyMzQ1Njc4OWFiY2RlZg=="})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = repo.DeleteEncryptionKey("job-123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = repo.GetEncryptionKey("job-123")
+	if err != db.ErrEncryptionKeyNotFound {
+		t.Errorf("Wrong error returned. Want %#v. Got %#v", db.ErrEncryptionKeyNotFound, err)
+	}
+	err = repo.DeleteEncryptionKey("job-123")
+	if err != db.ErrEncryptionKeyNotFound {
+		t.Errorf("Wrong error returned. Want %#v. Got %#v", db.ErrEncryptionKeyNotFound, err)
+	}
+}
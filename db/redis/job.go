@@ -2,6 +2,7 @@ package redis
 
 import (
 	"errors"
+	"sort"
 	"strconv"
 	"time"
 
@@ -20,6 +21,13 @@ func (r *redisRepository) CreateJob(job *db.Job) error {
 	return r.saveJob(job)
 }
 
+func (r *redisRepository) UpdateJob(job *db.Job) error {
+	if _, err := r.GetJob(job.ID); err == db.ErrJobNotFound {
+		return err
+	}
+	return r.saveJob(job)
+}
+
 func (r *redisRepository) saveJob(job *db.Job) error {
 	fields, err := r.storage.FieldMap(job)
 	if err != nil {
@@ -31,18 +39,49 @@ func (r *redisRepository) saveJob(job *db.Job) error {
 		if err != nil {
 			return err
 		}
-		return tx.ZAddNX(jobsSetKey, redis.Z{Member: job.ID, Score: float64(job.CreationTime.UnixNano())}).Err()
+		err = tx.ZAddNX(jobsSetKey, redis.Z{Member: job.ID, Score: float64(job.CreationTime.UnixNano())}).Err()
+		if err != nil {
+			return err
+		}
+		return r.trackActiveJob(tx, job)
 	}, jobKey)
 }
 
+// trackActiveJob keeps the ID of the job in the set of active jobs of each
+// of its presets while its recorded status isn't final.
+func (r *redisRepository) trackActiveJob(tx *redis.Tx, job *db.Job) error {
+	for _, preset := range job.Presets {
+		var err error
+		if job.Active() {
+			err = tx.SAdd(r.activeJobsKey(preset), job.ID).Err()
+		} else {
+			err = tx.SRem(r.activeJobsKey(preset), job.ID).Err()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (r *redisRepository) DeleteJob(job *db.Job) error {
-	err := r.storage.Delete(r.jobKey(job.ID))
+	storedJob, err := r.GetJob(job.ID)
+	if err != nil {
+		return err
+	}
+	err = r.storage.Delete(r.jobKey(job.ID))
 	if err != nil {
 		if err == storage.ErrNotFound {
 			return db.ErrJobNotFound
 		}
 		return err
 	}
+	for _, preset := range storedJob.Presets {
+		err = r.storage.RedisClient().SRem(r.activeJobsKey(preset), job.ID).Err()
+		if err != nil {
+			return err
+		}
+	}
 	return r.storage.RedisClient().ZRem(jobsSetKey, job.ID).Err()
 }
 
@@ -82,6 +121,40 @@ func (r *redisRepository) ListJobs(filter db.JobFilter) ([]db.Job, error) {
 	return jobs, nil
 }
 
+func (r *redisRepository) ListActiveJobs(preset string) ([]db.Job, error) {
+	activeJobsKey := r.activeJobsKey(preset)
+	jobIDs, err := r.storage.RedisClient().SMembers(activeJobsKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	jobs := make([]db.Job, 0, len(jobIDs))
+	for _, id := range jobIDs {
+		job, err := r.GetJob(id)
+		if err == db.ErrJobNotFound {
+			r.storage.RedisClient().SRem(activeJobsKey, id)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if job.Active() {
+			jobs = append(jobs, *job)
+		}
+	}
+	sort.Sort(jobsByCreationTime(jobs))
+	return jobs, nil
+}
+
 func (r *redisRepository) jobKey(id string) string {
 	return "job:" + id
 }
+
+func (r *redisRepository) activeJobsKey(preset string) string {
+	return "activejobs:" + preset
+}
+
+type jobsByCreationTime []db.Job
+
+func (j jobsByCreationTime) Len() int           { return len(j) }
+func (j jobsByCreationTime) Less(a, b int) bool { return j[a].CreationTime.Before(j[b].CreationTime) }
+func (j jobsByCreationTime) Swap(a, b int)      { j[a], j[b] = j[b], j[a] }
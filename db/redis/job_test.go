@@ -28,6 +28,10 @@ func TestCreateJob(t *testing.T) {
 		ID:              "job1",
 		ProviderName:    "encoding.com",
 		StreamingParams: db.StreamingParams{SegmentDuration: 10, Protocol: "hls"},
+		Destination:     "s3://team-bucket/videos",
+		OutputPath:      "2016-10-18/video",
+		Presets:         []string{"mp4_720p", "webm_720p"},
+		PresetVersions:  map[string]string{"mp4_720p": "3"},
 	}
 	err = repo.CreateJob(&job)
 	if err != nil {
@@ -51,6 +55,10 @@ func TestCreateJob(t *testing.T) {
 		"providerJobID":                   "",
 		"streamingparams_segmentDuration": "10",
 		"streamingparams_protocol":        "hls",
+		"destination":                     "s3://team-bucket/videos",
+		"outputPath":                      "2016-10-18/video",
+		"presets":                         "mp4_720p%%%webm_720p",
+		"presetversions_mp4_720p":         "3",
 		"creationTime":                    creationTime.Format(time.RFC3339Nano),
 	}
 	if !reflect.DeepEqual(items, expected) {
@@ -114,6 +122,61 @@ func TestCreateJobNoID(t *testing.T) {
 	}
 }
 
+func TestUpdateJob(t *testing.T) {
+	err := cleanRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewRepository(&config.Config{Redis: new(storage.Config)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := db.Job{ID: "job1", ProviderName: "encoding.com", ProviderJobID: "abc-123"}
+	err = repo.CreateJob(&job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job.Verification = db.OutputVerification{
+		Status: db.VerificationFailed,
+		Errors: []string{`output file "s3://bucket/job1/video.mp4" is missing`, `output file "s3://bucket/job1/video.webm" is empty`},
+	}
+	err = repo.UpdateJob(&job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotJob, err := repo.GetJob(job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*gotJob, job) {
+		t.Errorf("Wrong job. Want %#v. Got %#v.", job, *gotJob)
+	}
+	client := repo.(*redisRepository).storage.RedisClient()
+	defer client.Close()
+	setEntries, err := client.ZRange(jobsSetKey, 0, -1).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expectedSetEntries := []string{job.ID}; !reflect.DeepEqual(setEntries, expectedSetEntries) {
+		t.Errorf("Wrong job set returned from Redis. Want %#v. Got %#v.", expectedSetEntries, setEntries)
+	}
+}
+
+func TestUpdateJobNotFound(t *testing.T) {
+	err := cleanRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewRepository(&config.Config{Redis: new(storage.Config)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = repo.UpdateJob(&db.Job{ID: "myjob"})
+	if err != db.ErrJobNotFound {
+		t.Errorf("Wrong error returned by UpdateJob. Want ErrJobNotFound. Got %#v.", err)
+	}
+}
+
 func TestDeleteJob(t *testing.T) {
 	err := cleanRedis()
 	if err != nil {
@@ -143,6 +206,31 @@ func TestDeleteJob(t *testing.T) {
 	}
 }
 
+func TestDeleteJobActive(t *testing.T) {
+	err := cleanRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewRepository(&config.Config{Redis: new(storage.Config)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := db.Job{ID: "myjob", Presets: []string{"mp4_720p"}, Status: "started"}
+	err = repo.CreateJob(&job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = repo.DeleteJob(&db.Job{ID: job.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := repo.(*redisRepository).storage.RedisClient()
+	members := client.SMembers("activejobs:mp4_720p").Val()
+	if len(members) != 0 {
+		t.Errorf("Unexpected value after delete call: %v", members)
+	}
+}
+
 func TestDeleteJobNotFound(t *testing.T) {
 	err := cleanRedis()
 	if err != nil {
@@ -167,7 +255,7 @@ func TestGetJob(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	job := db.Job{ID: "myjob"}
+	job := db.Job{ID: "myjob", PresetVersions: map[string]string{"mp4_720p": "3"}}
 	err = repo.CreateJob(&job)
 	if err != nil {
 		t.Fatal(err)
@@ -473,3 +561,99 @@ func TestListJobsFilteringAndLimit(t *testing.T) {
 		t.Errorf("ListJobs({}): wrong list returned. Want %#v. Got %#v", expectedJobs, gotJobs)
 	}
 }
+
+func TestListActiveJobs(t *testing.T) {
+	err := cleanRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cfg config.Config
+	cfg.Redis = new(storage.Config)
+	repo, err := NewRepository(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().UTC()
+	jobs := []db.Job{
+		{
+			ID:            "job-1",
+			ProviderName:  "encodingcom",
+			ProviderJobID: "1",
+			Presets:       []string{"mp4_720p"},
+			Status:        "started",
+			CreationTime:  now.Add(-30 * 24 * time.Hour),
+		},
+		{
+			ID:            "job-2",
+			ProviderName:  "encodingcom",
+			ProviderJobID: "2",
+			Presets:       []string{"mp4_720p"},
+			Status:        "finished",
+			CreationTime:  now.Add(-40 * time.Minute),
+		},
+		{
+			ID:            "job-3",
+			ProviderName:  "encodingcom",
+			ProviderJobID: "3",
+			Presets:       []string{"webm_720p"},
+			CreationTime:  now.Add(-10 * time.Minute),
+		},
+		{
+			ID:            "job-4",
+			ProviderName:  "encodingcom",
+			ProviderJobID: "4",
+			Presets:       []string{"webm_720p", "mp4_720p"},
+			CreationTime:  now.Add(-3 * time.Second),
+		},
+	}
+	redisRepo := repo.(*redisRepository)
+	for _, job := range jobs {
+		err = redisRepo.saveJob(&job)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	gotJobs, err := repo.ListActiveJobs("mp4_720p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedJobs := []db.Job{jobs[0], jobs[3]}
+	if !reflect.DeepEqual(gotJobs, expectedJobs) {
+		t.Errorf("ListActiveJobs: wrong list returned. Want %#v. Got %#v", expectedJobs, gotJobs)
+	}
+}
+
+func TestListActiveJobsFinalStatus(t *testing.T) {
+	err := cleanRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewRepository(&config.Config{Redis: new(storage.Config)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := db.Job{ID: "job-1", Presets: []string{"mp4_720p", "webm_720p"}, Status: "queued"}
+	err = repo.CreateJob(&job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job.Status = "failed"
+	err = repo.UpdateJob(&job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := repo.(*redisRepository).storage.RedisClient()
+	for _, key := range []string{"activejobs:mp4_720p", "activejobs:webm_720p"} {
+		members := client.SMembers(key).Val()
+		if len(members) != 0 {
+			t.Errorf("%s: unexpected members after final status: %v", key, members)
+		}
+	}
+	gotJobs, err := repo.ListActiveJobs("mp4_720p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gotJobs) != 0 {
+		t.Errorf("ListActiveJobs: unexpected non-empty job list: %#v", gotJobs)
+	}
+}
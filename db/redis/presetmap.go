@@ -1,6 +1,8 @@
 package redis
 
 import (
+	"strconv"
+
 	"github.com/NYTimes/video-transcoding-api/db"
 	"github.com/NYTimes/video-transcoding-api/db/redis/storage"
 	"gopkg.in/redis.v4"
@@ -22,6 +24,41 @@ func (r *redisRepository) UpdatePresetMap(presetMap *db.PresetMap) error {
 	return r.savePresetMap(presetMap)
 }
 
+// ReplacePresetMap updates the preset map in a transaction that watches its
+// key, so the update fails with db.ErrPresetMapChanged if the preset map is
+// saved by someone else after its version is checked.
+func (r *redisRepository) ReplacePresetMap(presetMap *db.PresetMap, version uint) error {
+	fields, err := r.storage.FieldMap(presetMap)
+	if err != nil {
+		return err
+	}
+	presetMapKey := r.presetMapKey(presetMap.Name)
+	err = r.storage.RedisClient().Watch(func(tx *redis.Tx) error {
+		current, err := tx.HGetAll(presetMapKey).Result()
+		if err != nil {
+			return err
+		}
+		if len(current) == 0 {
+			return db.ErrPresetMapNotFound
+		}
+		currentVersion := current["version"]
+		if currentVersion == "" {
+			currentVersion = "0"
+		}
+		if currentVersion != strconv.FormatUint(uint64(version), 10) {
+			return db.ErrPresetMapChanged
+		}
+		_, err = tx.MultiExec(func() error {
+			return r.writePresetMap(tx, presetMap.Name, fields, current)
+		})
+		return err
+	}, presetMapKey)
+	if err == redis.TxFailedErr {
+		return db.ErrPresetMapChanged
+	}
+	return err
+}
+
 func (r *redisRepository) savePresetMap(presetMap *db.PresetMap) error {
 	fields, err := r.storage.FieldMap(presetMap)
 	if err != nil {
@@ -29,14 +66,37 @@ func (r *redisRepository) savePresetMap(presetMap *db.PresetMap) error {
 	}
 	presetMapKey := r.presetMapKey(presetMap.Name)
 	return r.storage.RedisClient().Watch(func(tx *redis.Tx) error {
-		err := tx.HMSet(presetMapKey, fields).Err()
+		existing, err := tx.HGetAll(presetMapKey).Result()
 		if err != nil {
 			return err
 		}
-		return tx.SAdd(presetmapsSetKey, presetMap.Name).Err()
+		return r.writePresetMap(tx, presetMap.Name, fields, existing)
 	}, presetMapKey)
 }
 
+// writePresetMap stores the fields of the preset map, removing the existing
+// fields that are no longer set.
+func (r *redisRepository) writePresetMap(tx *redis.Tx, name string, fields, existing map[string]string) error {
+	presetMapKey := r.presetMapKey(name)
+	err := tx.HMSet(presetMapKey, fields).Err()
+	if err != nil {
+		return err
+	}
+	var stale []string
+	for key := range existing {
+		if _, ok := fields[key]; !ok {
+			stale = append(stale, key)
+		}
+	}
+	if len(stale) > 0 {
+		err = tx.HDel(presetMapKey, stale...).Err()
+		if err != nil {
+			return err
+		}
+	}
+	return tx.SAdd(presetmapsSetKey, name).Err()
+}
+
 func (r *redisRepository) DeletePresetMap(presetMap *db.PresetMap) error {
 	err := r.storage.Delete(r.presetMapKey(presetMap.Name))
 	if err != nil {
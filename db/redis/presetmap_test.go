@@ -110,6 +110,107 @@ func TestUpdatePresetMap(t *testing.T) {
 	}
 }
 
+func TestUpdatePresetMapRemovesStaleFields(t *testing.T) {
+	err := cleanRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewRepository(&config.Config{Redis: new(storage.Config)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	presetmap := db.PresetMap{
+		Name: "mypresetmap",
+		ProviderMapping: map[string]string{
+			"elemental":         "abc123",
+			"elastictranscoder": "def123",
+		},
+		OutputOpts: db.OutputOptions{Extension: "mp4"},
+		Preset: &db.Preset{
+			Name:      "mypresetmap",
+			Container: "mp4",
+			Video:     db.VideoPreset{Codec: "h264", Bitrate: "1000000"},
+		},
+	}
+	err = repo.CreatePresetMap(&presetmap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	presetmap.ProviderMapping = map[string]string{"elemental": "abc1234"}
+	presetmap.Preset = &db.Preset{Name: "mypresetmap", Container: "mp4", Video: db.VideoPreset{Codec: "h264"}}
+	err = repo.UpdatePresetMap(&presetmap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := repo.(*redisRepository).storage.RedisClient()
+	defer client.Close()
+	items, err := client.HGetAll("presetmap:" + presetmap.Name).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedItems := map[string]string{
+		"pmapping_elemental": "abc1234",
+		"output_extension":   "mp4",
+		"preset_name":        "mypresetmap",
+		"preset_container":   "mp4",
+		"preset_video_codec": "h264",
+	}
+	if !reflect.DeepEqual(items, expectedItems) {
+		t.Errorf("Wrong presetmap hash returned from Redis. Want %#v. Got %#v", expectedItems, items)
+	}
+}
+
+func TestReplacePresetMap(t *testing.T) {
+	err := cleanRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewRepository(&config.Config{Redis: new(storage.Config)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	presetmap := db.PresetMap{Name: "mypresetmap", ProviderMapping: map[string]string{"elemental": "abc123"}, Version: 1}
+	err = repo.CreatePresetMap(&presetmap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	presetmap.ProviderMapping = map[string]string{"elemental": "abc1234"}
+	presetmap.Version = 2
+	err = repo.ReplacePresetMap(&presetmap, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale := presetmap
+	stale.ProviderMapping = map[string]string{"elemental": "abc12345"}
+	stale.Version = 3
+	err = repo.ReplacePresetMap(&stale, 1)
+	if err != db.ErrPresetMapChanged {
+		t.Errorf("Wrong error returned by ReplacePresetMap. Want ErrPresetMapChanged. Got %#v.", err)
+	}
+	saved, err := repo.GetPresetMap(presetmap.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Version != 2 || !reflect.DeepEqual(saved.ProviderMapping, presetmap.ProviderMapping) {
+		t.Errorf("Wrong presetmap saved. Want version 2 with %#v. Got version %d with %#v", presetmap.ProviderMapping, saved.Version, saved.ProviderMapping)
+	}
+}
+
+func TestReplacePresetMapNotFound(t *testing.T) {
+	err := cleanRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewRepository(&config.Config{Redis: new(storage.Config)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = repo.ReplacePresetMap(&db.PresetMap{Name: "mypresetmap"}, 0)
+	if err != db.ErrPresetMapNotFound {
+		t.Errorf("Wrong error returned by ReplacePresetMap. Want ErrPresetMapNotFound. Got %#v.", err)
+	}
+}
+
 func TestUpdatePresetMapNotFound(t *testing.T) {
 	err := cleanRedis()
 	if err != nil {
@@ -196,6 +297,81 @@ func TestGetPresetMap(t *testing.T) {
 	}
 }
 
+func TestGetPresetMapWithOverrides(t *testing.T) {
+	err := cleanRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewRepository(&config.Config{Redis: new(storage.Config)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	presetmap := db.PresetMap{
+		Name:            "720p_high",
+		ProviderMapping: map[string]string{"elementalconductor": "abc-123"},
+		OutputOpts:      db.OutputOptions{Extension: "mp4"},
+		Preset: &db.Preset{
+			Name:      "720p_high",
+			Parent:    "720p_base",
+			Container: "mp4",
+			Video:     db.VideoPreset{Codec: "h264", Height: "720", Bitrate: "4000000"},
+		},
+		Overrides: &db.Preset{
+			Name:   "720p_high",
+			Parent: "720p_base",
+			Video:  db.VideoPreset{Bitrate: "4000000"},
+		},
+		Version: 1,
+	}
+	err = repo.CreatePresetMap(&presetmap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotPresetMap, err := repo.GetPresetMap(presetmap.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*gotPresetMap, presetmap) {
+		t.Errorf("Wrong preset. Want %#v. Got %#v.", presetmap, *gotPresetMap)
+	}
+}
+
+func TestGetPresetMapWithProviderOptions(t *testing.T) {
+	err := cleanRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewRepository(&config.Config{Redis: new(storage.Config)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	presetmap := db.PresetMap{
+		Name:            "720p_fast",
+		ProviderMapping: map[string]string{"zencoder": "720p_fast"},
+		OutputOpts:      db.OutputOptions{Extension: "mp4"},
+		Preset: &db.Preset{
+			Name:      "720p_fast",
+			Container: "mp4",
+			Video:     db.VideoPreset{Codec: "h264", Height: "720", Bitrate: "2500000"},
+			ProviderOptions: db.ProviderOptions{
+				"zencoder":          {"speed": float64(4), "tuning": "film"},
+				"elastictranscoder": {"InterlacedMode": "Progressive"},
+			},
+		},
+	}
+	err = repo.CreatePresetMap(&presetmap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotPresetMap, err := repo.GetPresetMap(presetmap.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*gotPresetMap, presetmap) {
+		t.Errorf("Wrong preset. Want %#v. Got %#v.", presetmap, *gotPresetMap)
+	}
+}
+
 func TestGetPresetMapNotFound(t *testing.T) {
 	err := cleanRedis()
 	if err != nil {
@@ -0,0 +1,103 @@
+package redis
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/db/redis/storage"
+	"gopkg.in/redis.v4"
+)
+
+// NextPresetVersion allocates the next version number of the given preset.
+// Numbers are never reused, even if the version isn't created.
+func (r *redisRepository) NextPresetVersion(name string) (uint, error) {
+	version, err := r.storage.RedisClient().Incr(r.presetVersionSeqKey(name)).Result()
+	if err != nil {
+		return 0, err
+	}
+	return uint(version), nil
+}
+
+func (r *redisRepository) CreatePresetVersion(version *db.PresetVersion) error {
+	if version.Name == "" {
+		return errors.New("preset name is required")
+	}
+	if version.Version == 0 {
+		return errors.New("version number is required")
+	}
+	if _, err := r.GetPresetVersion(version.Name, version.Version); err == nil {
+		return db.ErrPresetVersionAlreadyExists
+	}
+	version.CreationTime = time.Now().UTC()
+	fields, err := r.storage.FieldMap(version)
+	if err != nil {
+		return err
+	}
+	versionKey := r.presetVersionKey(version.Name, version.Version)
+	return r.storage.RedisClient().Watch(func(tx *redis.Tx) error {
+		err := tx.HMSet(versionKey, fields).Err()
+		if err != nil {
+			return err
+		}
+		member := redis.Z{Member: strconv.FormatUint(uint64(version.Version), 10), Score: float64(version.Version)}
+		return tx.ZAdd(r.presetVersionsSetKey(version.Name), member).Err()
+	}, versionKey)
+}
+
+func (r *redisRepository) GetPresetVersion(name string, version uint) (*db.PresetVersion, error) {
+	presetVersion := db.PresetVersion{Name: name, ProviderMapping: make(map[string]string)}
+	err := r.storage.Load(r.presetVersionKey(name, version), &presetVersion)
+	if err == storage.ErrNotFound {
+		return nil, db.ErrPresetVersionNotFound
+	}
+	return &presetVersion, err
+}
+
+func (r *redisRepository) DeletePresetVersion(name string, version uint) error {
+	err := r.storage.Delete(r.presetVersionKey(name, version))
+	if err != nil {
+		if err == storage.ErrNotFound {
+			return db.ErrPresetVersionNotFound
+		}
+		return err
+	}
+	return r.storage.RedisClient().ZRem(r.presetVersionsSetKey(name), strconv.FormatUint(uint64(version), 10)).Err()
+}
+
+// ListPresetVersions returns all versions of the given preset, from the
+// oldest to the newest.
+func (r *redisRepository) ListPresetVersions(name string) ([]db.PresetVersion, error) {
+	members, err := r.storage.RedisClient().ZRange(r.presetVersionsSetKey(name), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]db.PresetVersion, 0, len(members))
+	for _, member := range members {
+		number, err := strconv.ParseUint(member, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		version, err := r.GetPresetVersion(name, uint(number))
+		if err != nil && err != db.ErrPresetVersionNotFound {
+			return nil, err
+		}
+		if version != nil {
+			versions = append(versions, *version)
+		}
+	}
+	return versions, nil
+}
+
+func (r *redisRepository) presetVersionKey(name string, version uint) string {
+	return "presetversion:" + name + ":" + strconv.FormatUint(uint64(version), 10)
+}
+
+func (r *redisRepository) presetVersionSeqKey(name string) string {
+	return "presetversion:" + name + ":seq"
+}
+
+func (r *redisRepository) presetVersionsSetKey(name string) string {
+	return "presetversions:" + name
+}
@@ -0,0 +1,245 @@
+package redis
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/NYTimes/video-transcoding-api/config"
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/db/redis/storage"
+)
+
+func TestNextPresetVersion(t *testing.T) {
+	err := cleanRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewRepository(&config.Config{Redis: new(storage.Config)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []uint{1, 2, 3} {
+		version, err := repo.NextPresetVersion("mypreset")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if version != expected {
+			t.Errorf("Wrong version returned. Want %d. Got %d.", expected, version)
+		}
+	}
+	client := repo.(*redisRepository).storage.RedisClient()
+	defer client.Close()
+	seq, err := client.Get("presetversion:mypreset:seq").Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seq != "3" {
+		t.Errorf("Wrong sequence stored in Redis. Want %q. Got %q.", "3", seq)
+	}
+}
+
+func TestCreatePresetVersion(t *testing.T) {
+	err := cleanRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewRepository(&config.Config{Redis: new(storage.Config)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	version := db.PresetVersion{
+		Name:            "mypreset",
+		Version:         2,
+		Preset:          &db.Preset{Name: "mypreset", Container: "mp4"},
+		ProviderMapping: map[string]string{"elastictranscoder": "1281742-93939"},
+		OutputOpts:      db.OutputOptions{Extension: "mp4"},
+		Author:          "gopher",
+	}
+	err = repo.CreatePresetVersion(&version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version.CreationTime.IsZero() {
+		t.Error("Should set the creation time of the version, but did not")
+	}
+	client := repo.(*redisRepository).storage.RedisClient()
+	defer client.Close()
+	items, err := client.HGetAll("presetversion:mypreset:2").Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedItems := map[string]string{
+		"version":                    "2",
+		"preset_name":                "mypreset",
+		"preset_container":           "mp4",
+		"pmapping_elastictranscoder": "1281742-93939",
+		"output_extension":           "mp4",
+		"author":                     "gopher",
+		"creationTime":               version.CreationTime.Format(time.RFC3339Nano),
+	}
+	if !reflect.DeepEqual(items, expectedItems) {
+		t.Errorf("Wrong preset version hash returned from Redis. Want %#v. Got %#v", expectedItems, items)
+	}
+}
+
+func TestCreatePresetVersionDuplicate(t *testing.T) {
+	err := cleanRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewRepository(&config.Config{Redis: new(storage.Config)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	version := db.PresetVersion{
+		Name:            "mypreset",
+		Version:         1,
+		ProviderMapping: map[string]string{"elemental": "123"},
+	}
+	err = repo.CreatePresetVersion(&version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = repo.CreatePresetVersion(&version)
+	if err != db.ErrPresetVersionAlreadyExists {
+		t.Errorf("Got wrong error. Want %#v. Got %#v", db.ErrPresetVersionAlreadyExists, err)
+	}
+}
+
+func TestGetPresetVersion(t *testing.T) {
+	err := cleanRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewRepository(&config.Config{Redis: new(storage.Config)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	version := db.PresetVersion{
+		Name:            "mypreset",
+		Version:         1,
+		Preset:          &db.Preset{Name: "mypreset", Container: "webm", Video: db.VideoPreset{Codec: "vp8"}},
+		ProviderMapping: map[string]string{"elementalconductor": "abc-123"},
+		OutputOpts:      db.OutputOptions{Extension: "webm"},
+	}
+	err = repo.CreatePresetVersion(&version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotVersion, err := repo.GetPresetVersion("mypreset", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*gotVersion, version) {
+		t.Errorf("Wrong preset version. Want %#v. Got %#v.", version, *gotVersion)
+	}
+}
+
+func TestGetPresetVersionNotFound(t *testing.T) {
+	err := cleanRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewRepository(&config.Config{Redis: new(storage.Config)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotVersion, err := repo.GetPresetVersion("mypreset", 1)
+	if err != db.ErrPresetVersionNotFound {
+		t.Errorf("Wrong error returned. Want ErrPresetVersionNotFound. Got %#v.", err)
+	}
+	if gotVersion != nil {
+		t.Errorf("Unexpected non-nil preset version: %#v.", gotVersion)
+	}
+}
+
+func TestDeletePresetVersion(t *testing.T) {
+	err := cleanRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewRepository(&config.Config{Redis: new(storage.Config)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, number := range []uint{1, 2} {
+		err = repo.CreatePresetVersion(&db.PresetVersion{Name: "mypreset", Version: number})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = repo.DeletePresetVersion("mypreset", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	versions, err := repo.ListPresetVersions("mypreset")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 1 || versions[0].Version != 1 {
+		t.Errorf("Wrong versions returned after deleting version 2: %#v.", versions)
+	}
+	client := repo.(*redisRepository).storage.RedisClient()
+	defer client.Close()
+	members, err := client.ZRange("presetversions:mypreset", 0, -1).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(members, []string{"1"}) {
+		t.Errorf("Wrong members in the set of versions. Want %#v. Got %#v.", []string{"1"}, members)
+	}
+}
+
+func TestDeletePresetVersionNotFound(t *testing.T) {
+	err := cleanRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewRepository(&config.Config{Redis: new(storage.Config)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = repo.DeletePresetVersion("mypreset", 1)
+	if err != db.ErrPresetVersionNotFound {
+		t.Errorf("Wrong error returned. Want ErrPresetVersionNotFound. Got %#v.", err)
+	}
+}
+
+func TestListPresetVersions(t *testing.T) {
+	err := cleanRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewRepository(&config.Config{Redis: new(storage.Config)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, number := range []uint{10, 1, 2} {
+		version := db.PresetVersion{
+			Name:            "mypreset",
+			Version:         number,
+			ProviderMapping: map[string]string{"elementalconductor": "abc-123"},
+		}
+		err = repo.CreatePresetVersion(&version)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = repo.CreatePresetVersion(&db.PresetVersion{Name: "otherpreset", Version: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	versions, err := repo.ListPresetVersions("mypreset")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var numbers []uint
+	for _, version := range versions {
+		numbers = append(numbers, version.Version)
+	}
+	expectedNumbers := []uint{1, 2, 10}
+	if !reflect.DeepEqual(numbers, expectedNumbers) {
+		t.Errorf("Wrong versions returned. Want %#v. Got %#v.", expectedNumbers, numbers)
+	}
+}
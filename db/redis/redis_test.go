@@ -17,6 +17,22 @@ func cleanRedis() error {
 	if err != nil {
 		return err
 	}
+	err = deleteKeys("encryptionkey:*", client)
+	if err != nil {
+		return err
+	}
+	err = deleteKeys("presetversion:*", client)
+	if err != nil {
+		return err
+	}
+	err = deleteKeys("presetversions:*", client)
+	if err != nil {
+		return err
+	}
+	err = deleteKeys("activejobs:*", client)
+	if err != nil {
+		return err
+	}
 	err = deleteKeys(presetmapsSetKey, client)
 	if err != nil {
 		return err
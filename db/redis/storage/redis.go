@@ -2,6 +2,7 @@
 package storage
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"reflect"
@@ -77,7 +78,8 @@ func NewStorage(cfg *Config) (*Storage, error) {
 
 // Save creates the given key as a Redis hash.
 //
-// The given hash must be either a struct or map[string]string.
+// The given hash must be either a struct or map[string]string. Struct fields
+// holding maps that are not expanded are stored as JSON.
 func (s *Storage) Save(key string, hash interface{}) error {
 	fields, err := s.FieldMap(hash)
 	if err != nil {
@@ -137,6 +139,9 @@ func (s *Storage) structToFieldList(value reflect.Value, prefixes ...string) (ma
 		fieldValue := value.Field(i)
 		if len(parts) > 1 && parts[len(parts)-1] == "expand" {
 			if fieldValue.Kind() == reflect.Ptr {
+				if fieldValue.IsNil() {
+					continue
+				}
 				fieldValue = fieldValue.Elem()
 			}
 			myPrefixes := append(prefixes, parts[0])
@@ -171,9 +176,17 @@ func (s *Storage) structToFieldList(value reflect.Value, prefixes ...string) (ma
 				case []string:
 					strValue = strings.Join(v, "%%%")
 				default:
-					strValue = fmt.Sprintf("%v", v)
+					if fieldValue.Kind() == reflect.Map {
+						data, err := json.Marshal(v)
+						if err != nil {
+							return nil, err
+						}
+						strValue = string(data)
+					} else {
+						strValue = fmt.Sprintf("%v", v)
+					}
 				}
-				if parts[len(parts)-1] == "omitempty" && strValue == "" {
+				if parts[len(parts)-1] == "omitempty" && isEmptyValue(fieldValue, strValue) {
 					continue
 				}
 				fields[key] = strValue
@@ -183,6 +196,22 @@ func (s *Storage) structToFieldList(value reflect.Value, prefixes ...string) (ma
 	return fields, nil
 }
 
+// isEmptyValue reports whether the given value should be omitted when its
+// field is tagged with omitempty.
+func isEmptyValue(value reflect.Value, strValue string) bool {
+	switch value.Kind() {
+	case reflect.Bool:
+		return !value.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return value.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return value.Uint() == 0
+	case reflect.Map:
+		return value.Len() == 0
+	}
+	return strValue == ""
+}
+
 // Load loads the given key in the given output. The output must be a pointer
 // to a struct or a map[string]string.
 func (s *Storage) Load(key string, out interface{}) error {
@@ -241,10 +270,22 @@ func (s *Storage) loadStruct(in map[string]string, out reflect.Value, prefixes .
 		if len(parts) > 1 && parts[len(parts)-1] == "expand" {
 			myPrefixes := append(prefixes, parts[0])
 			if fieldValue.Kind() == reflect.Ptr {
+				if fieldValue.IsNil() {
+					if !hasPrefix(in, myPrefixes) {
+						continue
+					}
+					fieldValue.Set(reflect.New(fieldValue.Type().Elem()))
+				}
 				fieldValue = fieldValue.Elem()
 			}
 			switch fieldValue.Kind() {
 			case reflect.Map:
+				if fieldValue.IsNil() {
+					if !hasPrefix(in, myPrefixes) {
+						continue
+					}
+					fieldValue.Set(reflect.MakeMap(fieldValue.Type()))
+				}
 				err := s.loadMap(in, fieldValue, myPrefixes...)
 				if err != nil {
 					return err
@@ -284,6 +325,11 @@ func (s *Storage) loadStruct(in map[string]string, out reflect.Value, prefixes .
 						return err
 					}
 					fieldValue.SetUint(uintValue)
+				case reflect.Map:
+					err := json.Unmarshal([]byte(value), fieldValue.Addr().Interface())
+					if err != nil {
+						return err
+					}
 				case reflect.Struct:
 					if reflect.TypeOf(time.Time{}).AssignableTo(fieldValue.Type()) {
 						timeValue, err := time.Parse(time.RFC3339Nano, value)
@@ -301,6 +347,16 @@ func (s *Storage) loadStruct(in map[string]string, out reflect.Value, prefixes .
 	return nil
 }
 
+func hasPrefix(in map[string]string, prefixes []string) bool {
+	prefix := strings.Join(prefixes, "_") + "_"
+	for k := range in {
+		if strings.HasPrefix(k, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
 // Delete deletes the given key from redis, returning ErrNotFound when it
 // doesn't exist.
 func (s *Storage) Delete(key string) error {
@@ -123,6 +123,8 @@ func TestSavePointer(t *testing.T) {
 		Age:             29,
 		BirthTime:       time.Now().Add(-29 * 365 * 24 * time.Hour),
 		PreferredColors: []string{"red", "blue", "yellow"},
+		Verified:        true,
+		Children:        2,
 		Address: Address{
 			Data:   map[string]string{"first_line": "secret"},
 			Number: -2,
@@ -152,6 +154,8 @@ func TestSavePointer(t *testing.T) {
 		"age":                     "29",
 		"birth":                   person.BirthTime.Format(time.RFC3339Nano),
 		"colors":                  "red%%%blue%%%yellow",
+		"verified":                "true",
+		"children":                "2",
 		"address_city_name":       "nyc",
 		"address_data_first_line": "secret",
 		"address_number":          "-2",
@@ -162,6 +166,44 @@ func TestSavePointer(t *testing.T) {
 	}
 }
 
+func TestSaveNilPointer(t *testing.T) {
+	person := Person{
+		Name: "gopher",
+		Age:  29,
+		Address: Address{
+			Data:   map[string]string{"first_line": "secret"},
+			Number: 10,
+		},
+	}
+	storage, err := NewStorage(&Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = storage.Save("person:test", person)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := storage.RedisClient()
+	defer client.Close()
+	defer client.Del("person:test")
+	data, err := client.HGetAll("person:test").Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"name":                    "gopher",
+		"age":                     "29",
+		"birth":                   person.BirthTime.Format(time.RFC3339Nano),
+		"colors":                  "",
+		"address_data_first_line": "secret",
+		"address_number":          "10",
+		"address_main":            "false",
+	}
+	if !reflect.DeepEqual(data, expected) {
+		t.Errorf("Did not save properly.\nWant %#v\nGot  %#v", expected, data)
+	}
+}
+
 func TestSaveMap(t *testing.T) {
 	storage, err := NewStorage(&Config{})
 	if err != nil {
@@ -272,6 +314,119 @@ func TestLoadStruct(t *testing.T) {
 	}
 }
 
+func TestLoadStructNilPointer(t *testing.T) {
+	storage, err := NewStorage(&Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := storage.RedisClient()
+	defer client.Close()
+	err = storage.Save("test-key", map[string]string{
+		"name":              "Gopher",
+		"address_city_name": "New York",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Del("test-key")
+	var person Person
+	err = storage.Load("test-key", &person)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if person.Address.City == nil || person.Address.City.Name != "New York" {
+		t.Errorf("Didn't load the pointer field. Got %#v.", person.Address.City)
+	}
+
+	client.Del("test-key")
+	err = storage.Save("test-key", map[string]string{"name": "Gopher"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	person = Person{}
+	err = storage.Load("test-key", &person)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if person.Address.City != nil {
+		t.Errorf("Unexpected non-nil pointer field. Got %#v.", person.Address.City)
+	}
+}
+
+func TestLoadStructNilMap(t *testing.T) {
+	storage, err := NewStorage(&Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := storage.RedisClient()
+	defer client.Close()
+	err = storage.Save("test-key", map[string]string{
+		"name":                    "Gopher",
+		"address_data_first_line": "secret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Del("test-key")
+	var person Person
+	err = storage.Load("test-key", &person)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedData := map[string]string{"first_line": "secret"}
+	if !reflect.DeepEqual(person.Address.Data, expectedData) {
+		t.Errorf("Didn't load the map field. Want %#v. Got %#v.", expectedData, person.Address.Data)
+	}
+
+	client.Del("test-key")
+	err = storage.Save("test-key", map[string]string{"name": "Gopher"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	person = Person{}
+	err = storage.Load("test-key", &person)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if person.Address.Data != nil {
+		t.Errorf("Unexpected non-nil map field. Got %#v.", person.Address.Data)
+	}
+}
+
+func TestSaveAndLoadJSONMap(t *testing.T) {
+	storage, err := NewStorage(&Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := storage.RedisClient()
+	defer client.Close()
+	person := Person{
+		Name: "Gopher",
+		Pets: map[string][]string{"dogs": {"Rex", "Toto"}, "cats": {"Tom"}},
+	}
+	err = storage.Save("test-key", &person)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Del("test-key")
+	data, err := client.HGet("test-key", "pets").Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedData := `{"cats":["Tom"],"dogs":["Rex","Toto"]}`
+	if data != expectedData {
+		t.Errorf("Didn't save the map as JSON. Want %q. Got %q.", expectedData, data)
+	}
+	var loaded Person
+	err = storage.Load("test-key", &loaded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded.Pets, person.Pets) {
+		t.Errorf("Didn't load the map field. Want %#v. Got %#v.", person.Pets, loaded.Pets)
+	}
+}
+
 func TestLoadMap(t *testing.T) {
 	storage, err := NewStorage(&Config{})
 	if err != nil {
@@ -3,12 +3,15 @@ package storage
 import "time"
 
 type Person struct {
-	ID               string    `redis-hash:"-"`
-	Name             string    `redis-hash:"name"`
-	Address          Address   `redis-hash:"address,expand"`
-	Age              uint      `redis-hash:"age"`
-	BirthTime        time.Time `redis-hash:"birth"`
-	PreferredColors  []string  `redis-hash:"colors"`
+	ID               string              `redis-hash:"-"`
+	Name             string              `redis-hash:"name"`
+	Address          Address             `redis-hash:"address,expand"`
+	Age              uint                `redis-hash:"age"`
+	BirthTime        time.Time           `redis-hash:"birth"`
+	PreferredColors  []string            `redis-hash:"colors"`
+	Verified         bool                `redis-hash:"verified,omitempty"`
+	Children         uint                `redis-hash:"children,omitempty"`
+	Pets             map[string][]string `redis-hash:"pets,omitempty"`
 	NonTagged        string
 	unexported       string
 	unexportedTagged string `redis-hash:"unexported"`
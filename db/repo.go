@@ -6,8 +6,8 @@ import (
 )
 
 var (
-	// ErrJobNotFound is the error returned when the job is not found on GetJob or
-	// DeleteJob.
+	// ErrJobNotFound is the error returned when the job is not found on GetJob,
+	// UpdateJob or DeleteJob.
 	ErrJobNotFound = errors.New("job not found")
 
 	// ErrPresetMapNotFound is the error returned when the presetmap is not found
@@ -18,6 +18,10 @@ var (
 	// exists.
 	ErrPresetMapAlreadyExists = errors.New("presetmap already exists")
 
+	// ErrPresetMapChanged is the error returned by ReplacePresetMap when the
+	// presetmap was saved by someone else since it was read.
+	ErrPresetMapChanged = errors.New("presetmap was changed by another request, try again")
+
 	// ErrLocalPresetNotFound is the error returned when the local preset is not found
 	// on GetPresetMap, UpdatePresetMap or DeletePresetMap.
 	ErrLocalPresetNotFound = errors.New("local preset not found")
@@ -25,22 +29,41 @@ var (
 	// ErrLocalPresetAlreadyExists is the error returned when the local preset already
 	// exists.
 	ErrLocalPresetAlreadyExists = errors.New("local preset already exists")
+
+	// ErrPresetVersionNotFound is the error returned when the version of a
+	// preset is not found on GetPresetVersion.
+	ErrPresetVersionNotFound = errors.New("preset version not found")
+
+	// ErrPresetVersionAlreadyExists is the error returned when the version
+	// of a preset already exists.
+	ErrPresetVersionAlreadyExists = errors.New("preset version already exists")
+
+	// ErrEncryptionKeyNotFound is the error returned when the encryption key
+	// of a job is not found on GetEncryptionKey or DeleteEncryptionKey.
+	ErrEncryptionKeyNotFound = errors.New("encryption key not found")
 )
 
 // Repository represents the repository for persisting types of the API.
 type Repository interface {
 	JobRepository
 	PresetMapRepository
+	PresetVersionRepository
 	LocalPresetRepository
+	EncryptionKeyRepository
 }
 
 // JobRepository is the interface that defines the set of methods for managing Job
 // persistence.
 type JobRepository interface {
 	CreateJob(*Job) error
+	UpdateJob(*Job) error
 	DeleteJob(*Job) error
 	GetJob(id string) (*Job, error)
 	ListJobs(JobFilter) ([]Job, error)
+
+	// ListActiveJobs returns the jobs that use the given preset and whose
+	// recorded status isn't final.
+	ListActiveJobs(preset string) ([]Job, error)
 }
 
 // JobFilter contains a set of parameters for filtering the list of jobs in
@@ -58,11 +81,26 @@ type JobFilter struct {
 type PresetMapRepository interface {
 	CreatePresetMap(*PresetMap) error
 	UpdatePresetMap(*PresetMap) error
+	// ReplacePresetMap updates the presetmap only if its stored version is
+	// still the given version, returning ErrPresetMapChanged otherwise.
+	ReplacePresetMap(presetMap *PresetMap, version uint) error
 	DeletePresetMap(*PresetMap) error
 	GetPresetMap(name string) (*PresetMap, error)
 	ListPresetMaps() ([]PresetMap, error)
 }
 
+// PresetVersionRepository is the interface that defines the set of methods
+// for managing the revisions of preset maps. Versions are kept when the
+// preset map is deleted, so jobs can always be traced back to the settings
+// they used.
+type PresetVersionRepository interface {
+	NextPresetVersion(name string) (uint, error)
+	CreatePresetVersion(*PresetVersion) error
+	GetPresetVersion(name string, version uint) (*PresetVersion, error)
+	DeletePresetVersion(name string, version uint) error
+	ListPresetVersions(name string) ([]PresetVersion, error)
+}
+
 // LocalPresetRepository provides an interface that defines the set of methods for
 // managing presets when the provider don't have the ability to store/manage it.
 type LocalPresetRepository interface {
@@ -71,3 +109,11 @@ type LocalPresetRepository interface {
 	DeleteLocalPreset(*LocalPreset) error
 	GetLocalPreset(name string) (*LocalPreset, error)
 }
+
+// EncryptionKeyRepository is the interface that defines the set of methods for
+// managing the persistence of the keys used in encrypted streaming jobs.
+type EncryptionKeyRepository interface {
+	CreateEncryptionKey(*EncryptionKey) error
+	GetEncryptionKey(jobID string) (*EncryptionKey, error)
+	DeleteEncryptionKey(jobID string) error
+}
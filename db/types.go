@@ -32,12 +32,105 @@ type Job struct {
 	// required: false
 	StreamingParams StreamingParams `redis-hash:"streamingparams,expand" json:"streamingParams,omitempty"`
 
+	// base destination of the outputs of the job, overriding the
+	// destination configured for the provider
+	//
+	// required: false
+	Destination string `redis-hash:"destination,omitempty" json:"destination,omitempty"`
+
+	// path of the directory that holds the outputs of the job, relative
+	// to its destination
+	//
+	// required: false
+	OutputPath string `redis-hash:"outputPath,omitempty" json:"outputPath,omitempty"`
+
+	// languages of the caption sources attached to the outputs of the job
+	//
+	// required: false
+	CaptionLanguages []string `redis-hash:"captionlanguages,omitempty" json:"captionLanguages,omitempty"`
+
+	// result of the verification of the outputs of the job, performed
+	// once the job is finished
+	//
+	// required: false
+	Verification OutputVerification `redis-hash:"verification,expand" json:"verification"`
+
+	// names of the presets used by the outputs of the job
+	//
+	// required: false
+	Presets []string `redis-hash:"presets,omitempty" json:"presets,omitempty"`
+
+	// version of each preset used by the job, indexed by the name of
+	// the preset. Presets without versions are not included.
+	//
+	// required: false
+	PresetVersions map[string]string `redis-hash:"presetversions,expand" json:"presetVersions,omitempty"`
+
+	// last status of the job reported by the provider, recorded when
+	// the job is created and whenever its status is retrieved
+	//
+	// required: false
+	Status string `redis-hash:"status,omitempty" json:"status,omitempty"`
+
+	// options passed verbatim to the request sent to the provider of
+	// the job, indexed by the name of the provider
+	//
+	// required: false
+	ProviderOptions ProviderOptions `redis-hash:"provideroptions,omitempty" json:"providerOptions,omitempty"`
+
 	// Time of the creation of the job in the API
 	//
 	// required: true
 	CreationTime time.Time `redis-hash:"creationTime" json:"creationTime"`
 }
 
+// OutputDirectory returns the path of the directory that holds the outputs
+// of the job, relative to its destination. Jobs created before the
+// introduction of output path templates store their outputs in a directory
+// named after the job, unless they have a custom destination.
+func (j *Job) OutputDirectory() string {
+	if j.OutputPath == "" && j.Destination == "" {
+		return j.ID
+	}
+	return j.OutputPath
+}
+
+// FinalJobStatuses are the statuses, as reported by the providers, of jobs
+// that won't use their presets anymore.
+var FinalJobStatuses = []string{"finished", "failed", "canceled"}
+
+// Active returns whether the job may still use its presets, that is, whether
+// its recorded status isn't final.
+func (j *Job) Active() bool {
+	return !contains(FinalJobStatuses, j.Status)
+}
+
+// OutputVerification is the result of the verification of the outputs of a
+// finished job.
+//
+// swagger:model
+type OutputVerification struct {
+	// status of the verification: pending, passed, failed or skipped
+	// (when none of the outputs could be verified). Empty when the
+	// outputs haven't been verified yet
+	Status string `redis-hash:"status,omitempty" json:"status,omitempty"`
+
+	// problems found in the outputs, like missing or empty files
+	Errors []string `redis-hash:"errors,omitempty" json:"errors,omitempty"`
+
+	// outputs that couldn't be read, like files in private buckets when
+	// no URL signer is configured
+	Unverifiable []string `redis-hash:"unverifiable,omitempty" json:"unverifiable,omitempty"`
+}
+
+// Possible statuses of the verification of the outputs of a job.
+const (
+	VerificationPending = "pending"
+	VerificationPassed  = "passed"
+	VerificationFailed  = "failed"
+	VerificationSkipped = "skipped"
+)
+
 // StreamingParams represents the params necessary to create Adaptive Streaming jobs
 //
 // swagger:model
@@ -51,6 +144,27 @@ type StreamingParams struct {
 	//
 	// required: true
 	Protocol string `redis-hash:"protocol" json:"protocol"`
+
+	// format of the segments in HLS jobs (ts or fmp4)
+	SegmentFormat string `redis-hash:"segmentFormat,omitempty" json:"segmentFormat,omitempty"`
+
+	// method used for encrypting the segments (aes-128 or sample-aes)
+	EncryptionMethod string `redis-hash:"encryptionMethod,omitempty" json:"encryptionMethod,omitempty"`
+}
+
+// EncryptionKey is the key used for encrypting the segments of a streaming
+// job. It's stored apart from the job, and only served to the key server.
+type EncryptionKey struct {
+	JobID string `redis-hash:"-" json:"jobId"`
+
+	// the encryption method (aes-128 or sample-aes)
+	Method string `redis-hash:"method" json:"method"`
+
+	// the base64-encoded 128-bit key
+	Key string `redis-hash:"key" json:"key"`
+
+	// URI that players use for retrieving the key
+	KeyURI string `redis-hash:"keyuri" json:"keyUri"`
 }
 
 // LocalPreset is a struct to persist encoding configurations. Some providers don't have
@@ -71,16 +185,27 @@ type LocalPreset struct {
 
 // Preset define the set of parameters of a given preset
 type Preset struct {
-	Name         string      `json:"name,omitempty" redis-hash:"name"`
-	Description  string      `json:"description,omitempty" redis-hash:"description,omitempty"`
-	Container    string      `json:"container,omitempty" redis-hash:"container,omitempty"`
-	Profile      string      `json:"profile,omitempty" redis-hash:"profile,omitempty"`
-	ProfileLevel string      `json:"profileLevel,omitempty" redis-hash:"profilelevel,omitempty"`
-	RateControl  string      `json:"rateControl,omitempty" redis-hash:"ratecontrol,omitempty"`
-	Video        VideoPreset `json:"video" redis-hash:"video,expand"`
-	Audio        AudioPreset `json:"audio" redis-hash:"audio,expand"`
+	Name         string        `json:"name,omitempty" redis-hash:"name"`
+	Description  string        `json:"description,omitempty" redis-hash:"description,omitempty"`
+	Parent       string        `json:"parent,omitempty" redis-hash:"parent,omitempty"`
+	Container    string        `json:"container,omitempty" redis-hash:"container,omitempty"`
+	Profile      string        `json:"profile,omitempty" redis-hash:"profile,omitempty"`
+	ProfileLevel string        `json:"profileLevel,omitempty" redis-hash:"profilelevel,omitempty"`
+	RateControl  string        `json:"rateControl,omitempty" redis-hash:"ratecontrol,omitempty"`
+	Video        VideoPreset   `json:"video" redis-hash:"video,expand"`
+	Audio        AudioPreset   `json:"audio" redis-hash:"audio,expand"`
+	Overlay      OverlayPreset `json:"overlay" redis-hash:"overlay,expand"`
+
+	// options passed verbatim to the presets of each provider, overriding
+	// the values translated from the other fields of the preset.
+	ProviderOptions ProviderOptions `json:"providerOptions,omitempty" redis-hash:"provideroptions,omitempty"`
 }
 
+// ProviderOptions holds options that are passed verbatim to the requests
+// sent to the providers, indexed by the name of the provider and then by the
+// name of the option in the API of the provider.
+type ProviderOptions map[string]map[string]interface{}
+
 // VideoPreset define the set of parameters for video on a given preset
 type VideoPreset struct {
 	Width         string `json:"width,omitempty" redis-hash:"width,omitempty"`
@@ -90,12 +215,68 @@ type VideoPreset struct {
 	GopSize       string `json:"gopSize,omitempty" redis-hash:"gopsize,omitempty"`
 	GopMode       string `json:"gopMode,omitempty" redis-hash:"gopmode,omitempty"`
 	InterlaceMode string `json:"interlaceMode,omitempty" redis-hash:"interlacemode,omitempty"`
+
+	// frame rate of the output, in frames per second. The frame rate of
+	// the source is kept when it's not defined.
+	FrameRate string `json:"frameRate,omitempty" redis-hash:"framerate,omitempty"`
+
+	// whether keyframes should be placed at the same positions in all
+	// outputs, as required for switching between renditions of adaptive
+	// streaming outputs.
+	AlignKeyframes bool `json:"alignKeyframes,omitempty" redis-hash:"alignkeyframes,omitempty"`
+
+	// number of B-frames between reference frames.
+	BFrames string `json:"bFrames,omitempty" redis-hash:"bframes,omitempty"`
+
+	// maximum number of frames that may be referenced by a frame.
+	ReferenceFrames string `json:"referenceFrames,omitempty" redis-hash:"referenceframes,omitempty"`
+
+	// whether the video should be encoded in two passes.
+	TwoPass bool `json:"twoPass,omitempty" redis-hash:"twopass,omitempty"`
+
+	// maximum bitrate and the size of the decoder buffer, in bits,
+	// used for constraining the bitrate of VBR outputs.
+	MaxBitrate string `json:"maxBitrate,omitempty" redis-hash:"maxbitrate,omitempty"`
+	BufferSize string `json:"bufferSize,omitempty" redis-hash:"buffersize,omitempty"`
 }
 
 // AudioPreset define the set of parameters for audio on a given preset
 type AudioPreset struct {
 	Codec   string `json:"codec,omitempty" redis-hash:"codec,omitempty"`
 	Bitrate string `json:"bitrate,omitempty" redis-hash:"bitrate,omitempty"`
+
+	// number of audio channels and the sample rate, in Hz. The values of
+	// the source are kept when they're not defined.
+	Channels   string `json:"channels,omitempty" redis-hash:"channels,omitempty"`
+	SampleRate string `json:"sampleRate,omitempty" redis-hash:"samplerate,omitempty"`
+}
+
+// OverlayPreset define the placement of an image overlay (watermark) on a
+// given preset. The image itself is defined when creating the job, so the same
+// preset can be used with different images.
+type OverlayPreset struct {
+	// corner of the video where the image is placed: top-left,
+	// top-right, bottom-left or bottom-right. An empty position disables
+	// the overlay.
+	Position string `json:"position,omitempty" redis-hash:"position,omitempty"`
+
+	// offsets from the corner, in pixels.
+	OffsetX string `json:"offsetX,omitempty" redis-hash:"offsetx,omitempty"`
+	OffsetY string `json:"offsetY,omitempty" redis-hash:"offsety,omitempty"`
+
+	// opacity of the image, in percentage (0-100).
+	Opacity string `json:"opacity,omitempty" redis-hash:"opacity,omitempty"`
+
+	// time range for displaying the image, in seconds. When not
+	// defined, the image is displayed for the whole duration of the
+	// output.
+	StartTime string `json:"startTime,omitempty" redis-hash:"starttime,omitempty"`
+	EndTime   string `json:"endTime,omitempty" redis-hash:"endtime,omitempty"`
+}
+
+// Enabled returns whether the overlay is defined.
+func (o *OverlayPreset) Enabled() bool {
+	return o.Position != ""
 }
 
 // PresetMap represents the preset that is persisted in the repository of the
@@ -121,6 +302,52 @@ type PresetMap struct {
 	//
 	// required: true
 	OutputOpts OutputOptions `redis-hash:"output,expand" json:"output"`
+
+	// canonical preset used to create the presets on each provider.
+	//
+	// It's only available for presets created through the presets API.
+	Preset *Preset `redis-hash:"preset,expand" json:"preset,omitempty"`
+
+	// fields defined by the preset itself, for presets that inherit from
+	// a parent preset. The canonical preset is the parent preset with
+	// these fields overridden.
+	Overrides *Preset `redis-hash:"overrides,expand" json:"overrides,omitempty"`
+
+	// current version of the preset map, incremented every time it's
+	// saved. Preset maps saved before versioning was introduced have
+	// version 0.
+	Version uint `redis-hash:"version,omitempty" json:"version,omitempty"`
+}
+
+// PresetVersion is a revision of a preset map, recorded every time the
+// preset map is saved.
+//
+// swagger:model
+type PresetVersion struct {
+	// name of the preset map
+	Name string `redis-hash:"-" json:"name"`
+
+	// number of the version, starting at 1
+	Version uint `redis-hash:"version" json:"version"`
+
+	// canonical preset of the version, when available
+	Preset *Preset `redis-hash:"preset,expand" json:"preset,omitempty"`
+
+	// fields overridden by the version, for presets that inherit from a
+	// parent preset
+	Overrides *Preset `redis-hash:"overrides,expand" json:"overrides,omitempty"`
+
+	// mapping of provider name to provider's internal preset id.
+	ProviderMapping map[string]string `redis-hash:"pmapping,expand" json:"providerMapping"`
+
+	// set of options in the output file for this version.
+	OutputOpts OutputOptions `redis-hash:"output,expand" json:"output"`
+
+	// author of the change, as identified in the request that made it
+	Author string `redis-hash:"author,omitempty" json:"author,omitempty"`
+
+	// time of the creation of the version
+	CreationTime time.Time `redis-hash:"creationTime" json:"creationTime"`
 }
 
 // OutputOptions is the set of options for the output file.
@@ -138,6 +365,13 @@ type OutputOptions struct {
 	//
 	// required: true
 	Extension string `redis-hash:"extension" json:"extension"`
+
+	// template for the name of outputs using this preset that don't
+	// specify one. See the documentation of the job creation endpoint
+	// for the list of supported placeholders.
+	//
+	// required: false
+	FileNameTemplate string `redis-hash:"fileNameTemplate,omitempty" json:"fileNameTemplate,omitempty"`
 }
 
 // Validate checks that the OutputOptions object is properly defined.
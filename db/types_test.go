@@ -32,3 +32,57 @@ func TestOutputOptionsValidation(t *testing.T) {
 		}
 	}
 }
+
+func TestJobOutputDirectory(t *testing.T) {
+	var tests = []struct {
+		testCase string
+		job      Job
+		expected string
+	}{
+		{
+			"job created before output path templates",
+			Job{ID: "job-123"},
+			"job-123",
+		},
+		{
+			"job with custom destination",
+			Job{ID: "job-123", Destination: "s3://team-bucket/videos"},
+			"",
+		},
+		{
+			"job with output path",
+			Job{ID: "job-123", OutputPath: "2016/10/18/job-123"},
+			"2016/10/18/job-123",
+		},
+		{
+			"job with custom destination and output path",
+			Job{ID: "job-123", Destination: "s3://team-bucket/videos", OutputPath: "job-123"},
+			"job-123",
+		},
+	}
+	for _, test := range tests {
+		if got := test.job.OutputDirectory(); got != test.expected {
+			t.Errorf("%s: wrong output directory. Want %q. Got %q", test.testCase, test.expected, got)
+		}
+	}
+}
+
+func TestJobActive(t *testing.T) {
+	var tests = []struct {
+		status   string
+		expected bool
+	}{
+		{"", true},
+		{"queued", true},
+		{"started", true},
+		{"finished", false},
+		{"failed", false},
+		{"canceled", false},
+	}
+	for _, test := range tests {
+		job := Job{ID: "job-123", Status: test.status}
+		if got := job.Active(); got != test.expected {
+			t.Errorf("%q: wrong result. Want %v. Got %v", test.status, test.expected, got)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	videoCodecs = []string{"av1", "h264", "h265", "vp8", "vp9"}
+	audioCodecs = []string{"aac", "libvorbis", "vorbis"}
+
+	// videoCodecAliases maps alternative names of video codecs to the name
+	// used in presets.
+	videoCodecAliases = map[string]string{"hevc": "h265"}
+
+	// containerCodecs lists the video and audio codecs that may be used in
+	// each container.
+	containerCodecs = map[string]struct{ video, audio []string }{
+		"mp4":  {[]string{"h264", "h265", "av1"}, []string{"aac"}},
+		"m3u8": {[]string{"h264", "h265"}, []string{"aac"}},
+		"mpd":  {[]string{"h264", "h265", "av1"}, []string{"aac"}},
+		"ts":   {[]string{"h264", "h265"}, []string{"aac"}},
+		"webm": {[]string{"vp8", "vp9", "av1"}, []string{"libvorbis", "vorbis"}},
+	}
+
+	h264Profiles = []string{"baseline", "main", "high"}
+	h264Levels   = []string{"1", "1b", "1.1", "1.2", "1.3", "2", "2.1", "2.2", "3", "3.1", "3.2", "4", "4.1", "4.2", "5", "5.1", "5.2"}
+	gopModes     = []string{"fixed", "variable"}
+)
+
+// FieldError describes a problem with the value of a field in a preset.
+type FieldError struct {
+	// name of the field, in the same format used in the JSON
+	// representation of the preset (for example, "video.bitrate")
+	Field string `json:"field"`
+
+	Message string `json:"message"`
+}
+
+// PresetValidationError is the error returned when a preset is not valid. It
+// includes all problems found in the preset.
+type PresetValidationError struct {
+	Fields []FieldError
+}
+
+func (e *PresetValidationError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		messages[i] = field.Field + ": " + field.Message
+	}
+	return "invalid preset: " + strings.Join(messages, "; ")
+}
+
+func (e *PresetValidationError) add(field, format string, args ...interface{}) {
+	e.Fields = append(e.Fields, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// NormalizeVideoCodec replaces alternative names of the video codec of the
+// preset, like "hevc", with the name used by providers, like "h265".
+func (p *Preset) NormalizeVideoCodec() {
+	if codec, ok := videoCodecAliases[strings.ToLower(p.Video.Codec)]; ok {
+		p.Video.Codec = codec
+	}
+}
+
+// Validate checks that the values of the fields in the preset are valid and
+// compatible with each other, returning a *PresetValidationError when they
+// aren't. Optional fields are validated only when defined.
+func (p *Preset) Validate() error {
+	var verr PresetValidationError
+	if p.Name == "" {
+		verr.add("name", "is required")
+	}
+	codecs, knownContainer := containerCodecs[p.Container]
+	if p.Container == "" {
+		verr.add("container", "is required")
+	} else if !knownContainer {
+		verr.add("container", "unsupported container %q. Supported containers are: %s", p.Container, strings.Join(containers(), ", "))
+	}
+	if p.Video.Codec != "" {
+		if !contains(videoCodecs, p.Video.Codec) {
+			verr.add("video.codec", "unsupported codec %q. Supported codecs are: %s", p.Video.Codec, strings.Join(videoCodecs, ", "))
+		} else if knownContainer && !contains(codecs.video, p.Video.Codec) {
+			verr.add("video.codec", "codec %q can't be used in %s outputs", p.Video.Codec, p.Container)
+		}
+	}
+	if p.Audio.Codec != "" {
+		if !contains(audioCodecs, p.Audio.Codec) {
+			verr.add("audio.codec", "unsupported codec %q. Supported codecs are: %s", p.Audio.Codec, strings.Join(audioCodecs, ", "))
+		} else if knownContainer && !contains(codecs.audio, p.Audio.Codec) {
+			verr.add("audio.codec", "codec %q can't be used in %s outputs", p.Audio.Codec, p.Container)
+		}
+	}
+	p.validateH264(&verr)
+	positiveIntegers := []struct{ field, value string }{
+		{"video.width", p.Video.Width},
+		{"video.height", p.Video.Height},
+		{"video.bitrate", p.Video.Bitrate},
+		{"video.gopSize", p.Video.GopSize},
+		{"video.referenceFrames", p.Video.ReferenceFrames},
+		{"video.maxBitrate", p.Video.MaxBitrate},
+		{"video.bufferSize", p.Video.BufferSize},
+		{"audio.bitrate", p.Audio.Bitrate},
+		{"audio.channels", p.Audio.Channels},
+		{"audio.sampleRate", p.Audio.SampleRate},
+	}
+	for _, f := range positiveIntegers {
+		if f.value == "" {
+			continue
+		}
+		if n, err := strconv.ParseUint(f.value, 10, 32); err != nil || n == 0 {
+			verr.add(f.field, "invalid value %q. It must be a positive integer", f.value)
+		}
+	}
+	if p.Video.BFrames != "" {
+		if _, err := strconv.ParseUint(p.Video.BFrames, 10, 32); err != nil {
+			verr.add("video.bFrames", "invalid value %q. It must be a non-negative integer", p.Video.BFrames)
+		}
+	}
+	if p.Video.FrameRate != "" {
+		if n, err := strconv.ParseFloat(p.Video.FrameRate, 64); err != nil || n <= 0 {
+			verr.add("video.frameRate", "invalid value %q. It must be a positive number", p.Video.FrameRate)
+		}
+	}
+	if p.Video.GopMode != "" && !contains(gopModes, p.Video.GopMode) {
+		verr.add("video.gopMode", "invalid value %q. Valid values are: %s", p.Video.GopMode, strings.Join(gopModes, ", "))
+	}
+	if len(verr.Fields) > 0 {
+		return &verr
+	}
+	return nil
+}
+
+func (p *Preset) validateH264(verr *PresetValidationError) {
+	if p.Profile == "" && p.ProfileLevel == "" {
+		return
+	}
+	if p.Video.Codec != "h264" {
+		if p.Profile != "" {
+			verr.add("profile", "profiles are only supported by the h264 codec")
+		}
+		if p.ProfileLevel != "" {
+			verr.add("profileLevel", "profile levels are only supported by the h264 codec")
+		}
+		return
+	}
+	profile := strings.ToLower(p.Profile)
+	if p.Profile != "" && !contains(h264Profiles, profile) {
+		verr.add("profile", "unsupported H.264 profile %q. Supported profiles are: %s", p.Profile, strings.Join(h264Profiles, ", "))
+	}
+	if p.ProfileLevel != "" {
+		if !contains(h264Levels, p.ProfileLevel) {
+			verr.add("profileLevel", "unsupported H.264 level %q", p.ProfileLevel)
+		} else if p.ProfileLevel == "1b" && profile == "high" {
+			verr.add("profileLevel", "level %q is not supported by the H.264 %s profile", p.ProfileLevel, p.Profile)
+		}
+	}
+}
+
+func containers() []string {
+	names := make([]string, 0, len(containerCodecs))
+	for name := range containerCodecs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
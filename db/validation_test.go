@@ -0,0 +1,246 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPresetValidate(t *testing.T) {
+	var tests = []struct {
+		testCase string
+		preset   Preset
+		expected []FieldError
+	}{
+		{
+			"valid h264 preset",
+			Preset{
+				Name:         "mp4_720p",
+				Container:    "mp4",
+				Profile:      "Main",
+				ProfileLevel: "3.1",
+				Video: VideoPreset{
+					Codec:           "h264",
+					Width:           "1280",
+					Height:          "720",
+					Bitrate:         "2500000",
+					GopSize:         "90",
+					GopMode:         "fixed",
+					FrameRate:       "29.97",
+					AlignKeyframes:  true,
+					BFrames:         "0",
+					ReferenceFrames: "3",
+					TwoPass:         true,
+					MaxBitrate:      "3000000",
+					BufferSize:      "6000000",
+				},
+				Audio: AudioPreset{Codec: "aac", Bitrate: "128000", Channels: "2", SampleRate: "48000"},
+			},
+			nil,
+		},
+		{
+			"valid webm preset",
+			Preset{
+				Name:      "webm_720p",
+				Container: "webm",
+				Video:     VideoPreset{Codec: "vp9", Height: "720"},
+				Audio:     AudioPreset{Codec: "libvorbis"},
+			},
+			nil,
+		},
+		{
+			"valid hevc preset",
+			Preset{Name: "hls_2160p", Container: "m3u8", Video: VideoPreset{Codec: "h265", Height: "2160"}},
+			nil,
+		},
+		{
+			"valid av1 preset",
+			Preset{Name: "webm_av1", Container: "webm", Video: VideoPreset{Codec: "av1"}, Audio: AudioPreset{Codec: "vorbis"}},
+			nil,
+		},
+		{
+			"missing name and container",
+			Preset{},
+			[]FieldError{
+				{Field: "name", Message: "is required"},
+				{Field: "container", Message: "is required"},
+			},
+		},
+		{
+			"unsupported container and codecs",
+			Preset{
+				Name:      "avi",
+				Container: "avi",
+				Video:     VideoPreset{Codec: "mpeg2"},
+				Audio:     AudioPreset{Codec: "pcm"},
+			},
+			[]FieldError{
+				{Field: "container", Message: `unsupported container "avi". Supported containers are: m3u8, mp4, mpd, ts, webm`},
+				{Field: "video.codec", Message: `unsupported codec "mpeg2". Supported codecs are: av1, h264, h265, vp8, vp9`},
+				{Field: "audio.codec", Message: `unsupported codec "pcm". Supported codecs are: aac, libvorbis, vorbis`},
+			},
+		},
+		{
+			"codecs incompatible with the container",
+			Preset{
+				Name:      "webm_h264",
+				Container: "webm",
+				Video:     VideoPreset{Codec: "h264"},
+				Audio:     AudioPreset{Codec: "aac"},
+			},
+			[]FieldError{
+				{Field: "video.codec", Message: `codec "h264" can't be used in webm outputs`},
+				{Field: "audio.codec", Message: `codec "aac" can't be used in webm outputs`},
+			},
+		},
+		{
+			"hevc in webm",
+			Preset{Name: "webm_h265", Container: "webm", Video: VideoPreset{Codec: "h265"}},
+			[]FieldError{
+				{Field: "video.codec", Message: `codec "h265" can't be used in webm outputs`},
+			},
+		},
+		{
+			"av1 in hls",
+			Preset{Name: "hls_av1", Container: "m3u8", Video: VideoPreset{Codec: "av1"}},
+			[]FieldError{
+				{Field: "video.codec", Message: `codec "av1" can't be used in m3u8 outputs`},
+			},
+		},
+		{
+			"invalid numbers",
+			Preset{
+				Name:      "mp4",
+				Container: "mp4",
+				Video:     VideoPreset{Width: "-1", Height: "0", Bitrate: "2500k", GopSize: "2.5"},
+				Audio:     AudioPreset{Bitrate: "128 000"},
+			},
+			[]FieldError{
+				{Field: "video.width", Message: `invalid value "-1". It must be a positive integer`},
+				{Field: "video.height", Message: `invalid value "0". It must be a positive integer`},
+				{Field: "video.bitrate", Message: `invalid value "2500k". It must be a positive integer`},
+				{Field: "video.gopSize", Message: `invalid value "2.5". It must be a positive integer`},
+				{Field: "audio.bitrate", Message: `invalid value "128 000". It must be a positive integer`},
+			},
+		},
+		{
+			"invalid encoding parameters",
+			Preset{
+				Name:      "mp4",
+				Container: "mp4",
+				Video: VideoPreset{
+					FrameRate:       "-30",
+					BFrames:         "-1",
+					ReferenceFrames: "0",
+					MaxBitrate:      "3M",
+					BufferSize:      "big",
+				},
+				Audio: AudioPreset{Channels: "stereo", SampleRate: "44.1"},
+			},
+			[]FieldError{
+				{Field: "video.referenceFrames", Message: `invalid value "0". It must be a positive integer`},
+				{Field: "video.maxBitrate", Message: `invalid value "3M". It must be a positive integer`},
+				{Field: "video.bufferSize", Message: `invalid value "big". It must be a positive integer`},
+				{Field: "audio.channels", Message: `invalid value "stereo". It must be a positive integer`},
+				{Field: "audio.sampleRate", Message: `invalid value "44.1". It must be a positive integer`},
+				{Field: "video.bFrames", Message: `invalid value "-1". It must be a non-negative integer`},
+				{Field: "video.frameRate", Message: `invalid value "-30". It must be a positive number`},
+			},
+		},
+		{
+			"invalid gop mode",
+			Preset{Name: "mp4", Container: "mp4", Video: VideoPreset{GopMode: "dynamic"}},
+			[]FieldError{
+				{Field: "video.gopMode", Message: `invalid value "dynamic". Valid values are: fixed, variable`},
+			},
+		},
+		{
+			"invalid h264 profile and level",
+			Preset{
+				Name:         "mp4",
+				Container:    "mp4",
+				Profile:      "extended",
+				ProfileLevel: "6",
+				Video:        VideoPreset{Codec: "h264"},
+			},
+			[]FieldError{
+				{Field: "profile", Message: `unsupported H.264 profile "extended". Supported profiles are: baseline, main, high`},
+				{Field: "profileLevel", Message: `unsupported H.264 level "6"`},
+			},
+		},
+		{
+			"level not supported by the profile",
+			Preset{
+				Name:         "mp4",
+				Container:    "mp4",
+				Profile:      "High",
+				ProfileLevel: "1b",
+				Video:        VideoPreset{Codec: "h264"},
+			},
+			[]FieldError{
+				{Field: "profileLevel", Message: `level "1b" is not supported by the H.264 High profile`},
+			},
+		},
+		{
+			"profile without h264",
+			Preset{
+				Name:         "webm",
+				Container:    "webm",
+				Profile:      "main",
+				ProfileLevel: "3.1",
+				Video:        VideoPreset{Codec: "vp8"},
+			},
+			[]FieldError{
+				{Field: "profile", Message: "profiles are only supported by the h264 codec"},
+				{Field: "profileLevel", Message: "profile levels are only supported by the h264 codec"},
+			},
+		},
+	}
+	for _, test := range tests {
+		err := test.preset.Validate()
+		if test.expected == nil {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %s", test.testCase, err)
+			}
+			continue
+		}
+		verr, ok := err.(*PresetValidationError)
+		if !ok {
+			t.Errorf("%s: wrong error returned. Want *PresetValidationError. Got %#v", test.testCase, err)
+			continue
+		}
+		if !reflect.DeepEqual(verr.Fields, test.expected) {
+			t.Errorf("%s: wrong field errors\nWant %#v\nGot  %#v", test.testCase, test.expected, verr.Fields)
+		}
+	}
+}
+
+func TestPresetNormalizeVideoCodec(t *testing.T) {
+	var tests = []struct {
+		codec    string
+		expected string
+	}{
+		{"hevc", "h265"},
+		{"HEVC", "h265"},
+		{"h265", "h265"},
+		{"av1", "av1"},
+		{"", ""},
+	}
+	for _, test := range tests {
+		preset := Preset{Video: VideoPreset{Codec: test.codec}}
+		preset.NormalizeVideoCodec()
+		if preset.Video.Codec != test.expected {
+			t.Errorf("NormalizeVideoCodec(%q): want %q. Got %q", test.codec, test.expected, preset.Video.Codec)
+		}
+	}
+}
+
+func TestPresetValidationErrorMessage(t *testing.T) {
+	err := PresetValidationError{Fields: []FieldError{
+		{Field: "name", Message: "is required"},
+		{Field: "video.bitrate", Message: `invalid value "0". It must be a positive integer`},
+	}}
+	expected := `invalid preset: name: is required; video.bitrate: invalid value "0". It must be a positive integer`
+	if err.Error() != expected {
+		t.Errorf("wrong error message\nWant %q\nGot  %q", expected, err.Error())
+	}
+}
@@ -10,6 +10,8 @@ import (
 	_ "github.com/NYTimes/video-transcoding-api/provider/encodingcom"
 	_ "github.com/NYTimes/video-transcoding-api/provider/zencoder"
 	"github.com/NYTimes/video-transcoding-api/service"
+	_ "github.com/NYTimes/video-transcoding-api/urlsigner/cloudfront"
+	_ "github.com/NYTimes/video-transcoding-api/urlsigner/s3signer"
 	"github.com/knq/sdhook"
 )
 
@@ -35,6 +37,9 @@ func main() {
 	if err != nil {
 		server.Log.Fatal("unable to initialize service: ", err)
 	}
+	if cfg.PresetDriftCheckInterval > 0 {
+		go service.MonitorPresetDrift(cfg.PresetDriftCheckInterval)
+	}
 	err = server.Register(service)
 	if err != nil {
 		server.Log.Fatal("unable to register service: ", err)
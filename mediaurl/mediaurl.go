@@ -0,0 +1,212 @@
+// Package mediaurl parses the URLs of source media files and output
+// destinations into typed locations.
+//
+// Providers translate locations into the format expected by their APIs, and
+// reject the schemes they don't support with an UnsupportedSchemeError, so
+// that invalid URLs are reported in the same way regardless of the provider.
+package mediaurl
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Scheme identifies the storage that holds a media file.
+type Scheme string
+
+// Supported schemes.
+const (
+	S3     Scheme = "s3"
+	GCS    Scheme = "gs"
+	HTTP   Scheme = "http"
+	HTTPS  Scheme = "https"
+	FTP    Scheme = "ftp"
+	Akamai Scheme = "akamai"
+)
+
+var (
+	// ErrMissingScheme is the error returned when parsing a URL without a
+	// scheme.
+	ErrMissingScheme = errors.New("missing scheme")
+
+	// ErrMissingHost is the error returned when parsing a URL without a
+	// host, or without a bucket in the case of S3 and Google Cloud Storage
+	// URLs.
+	ErrMissingHost = errors.New("missing host")
+
+	schemes = []Scheme{S3, GCS, HTTP, HTTPS, FTP, Akamai}
+
+	s3HostRegexp = regexp.MustCompile(`^([^/_.]+)\.s3\.amazonaws\.com$`)
+)
+
+// UnsupportedSchemeError is the error returned when a URL uses a scheme
+// that is either unknown or not supported in the context where it's used.
+type UnsupportedSchemeError struct {
+	Scheme    Scheme
+	Supported []Scheme
+}
+
+func (err *UnsupportedSchemeError) Error() string {
+	supported := make([]string, len(err.Supported))
+	for i, scheme := range err.Supported {
+		supported[i] = string(scheme)
+	}
+	return fmt.Sprintf("unsupported scheme %q. Supported schemes are: %s", err.Scheme, strings.Join(supported, ", "))
+}
+
+// Location is the parsed representation of a media URL.
+type Location struct {
+	Scheme Scheme
+
+	// Host is the host of the server holding the file, or the name of
+	// the bucket in S3 and Google Cloud Storage locations.
+	Host string
+
+	// Path is the path of the file in the host, without the leading
+	// slash.
+	Path string
+
+	RawQuery string
+
+	// Username and Password are the credentials included in the URL,
+	// used mostly by FTP and Akamai NetStorage locations.
+	Username string
+	Password string
+}
+
+// Parse parses the given URL.
+//
+// Paths are kept as they are, without any decoding, so object keys
+// containing characters that would have to be escaped in regular URLs are
+// preserved.
+func Parse(rawURL string) (*Location, error) {
+	i := strings.Index(rawURL, "://")
+	if i < 1 {
+		return nil, ErrMissingScheme
+	}
+	loc := Location{Scheme: Scheme(strings.ToLower(rawURL[:i]))}
+	if !loc.Scheme.valid() {
+		return nil, &UnsupportedSchemeError{Scheme: loc.Scheme, Supported: schemes}
+	}
+	remaining := rawURL[i+3:]
+	if i = strings.Index(remaining, "?"); i > -1 {
+		loc.RawQuery = remaining[i+1:]
+		remaining = remaining[:i]
+	}
+	authority := remaining
+	if i = strings.Index(remaining, "/"); i > -1 {
+		authority = remaining[:i]
+		loc.Path = remaining[i+1:]
+	}
+	if i = strings.LastIndex(authority, "@"); i > -1 {
+		userInfo, err := url.Parse("//" + authority[:i+1] + "host")
+		if err != nil || userInfo.User == nil {
+			return nil, fmt.Errorf("invalid credentials in %s URL", loc.Scheme)
+		}
+		loc.Username = userInfo.User.Username()
+		loc.Password, _ = userInfo.User.Password()
+		authority = authority[i+1:]
+	}
+	if authority == "" {
+		return nil, ErrMissingHost
+	}
+	loc.Host = authority
+	return &loc, nil
+}
+
+func (s Scheme) valid() bool {
+	for _, scheme := range schemes {
+		if s == scheme {
+			return true
+		}
+	}
+	return false
+}
+
+// Require returns an UnsupportedSchemeError if the location doesn't use one
+// of the given schemes.
+func (l *Location) Require(schemes ...Scheme) error {
+	for _, scheme := range schemes {
+		if l.Scheme == scheme {
+			return nil
+		}
+	}
+	return &UnsupportedSchemeError{Scheme: l.Scheme, Supported: schemes}
+}
+
+// S3 returns the S3 location equivalent to the given location, which may be
+// either a S3 URL or a HTTP URL that uses the virtual-hosted style endpoint
+// of the bucket. The second returned value is false when the location isn't
+// in S3.
+func (l *Location) S3() (*Location, bool) {
+	switch l.Scheme {
+	case S3:
+		return l, true
+	case HTTP, HTTPS:
+		parts := s3HostRegexp.FindStringSubmatch(l.Host)
+		if len(parts) == 0 {
+			return nil, false
+		}
+		return &Location{Scheme: S3, Host: parts[1], Path: l.Path, RawQuery: l.RawQuery}, true
+	}
+	return nil, false
+}
+
+// HTTP returns the HTTPS location of files stored in S3 or Google Cloud
+// Storage. Other locations are returned unchanged.
+func (l *Location) HTTP() *Location {
+	switch l.Scheme {
+	case S3:
+		return &Location{Scheme: HTTPS, Host: l.Host + ".s3.amazonaws.com", Path: l.Path, RawQuery: l.RawQuery}
+	case GCS:
+		return &Location{Scheme: HTTPS, Host: "storage.googleapis.com", Path: l.Host + "/" + l.Path, RawQuery: l.RawQuery}
+	}
+	return l
+}
+
+// Join returns a copy of the location with the given elements appended to
+// its path.
+func (l *Location) Join(elem ...string) *Location {
+	loc := *l
+	parts := make([]string, 0, len(elem)+1)
+	if path := strings.Trim(l.Path, "/"); path != "" {
+		parts = append(parts, path)
+	}
+	for _, e := range elem {
+		if e = strings.Trim(e, "/"); e != "" {
+			parts = append(parts, e)
+		}
+	}
+	loc.Path = strings.Join(parts, "/")
+	return &loc
+}
+
+// WithoutCredentials returns a copy of the location without the username
+// and the password.
+func (l *Location) WithoutCredentials() *Location {
+	loc := *l
+	loc.Username = ""
+	loc.Password = ""
+	return &loc
+}
+
+// String returns the URL of the location, including its credentials.
+func (l *Location) String() string {
+	result := string(l.Scheme) + "://"
+	if l.Password != "" {
+		result += url.UserPassword(l.Username, l.Password).String() + "@"
+	} else if l.Username != "" {
+		result += url.User(l.Username).String() + "@"
+	}
+	result += l.Host
+	if l.Path != "" {
+		result += "/" + l.Path
+	}
+	if l.RawQuery != "" {
+		result += "?" + l.RawQuery
+	}
+	return result
+}
@@ -0,0 +1,209 @@
+package probe
+
+import (
+	"bytes"
+	"errors"
+)
+
+const h264SPSNALType = 7
+
+var errInvalidSPS = errors.New("invalid H.264 sequence parameter set")
+
+// h264HighProfiles contains the profiles that include chroma format and
+// scaling matrix information in the sequence parameter set.
+var h264HighProfiles = map[uint]bool{
+	44: true, 83: true, 86: true, 100: true, 110: true, 118: true,
+	122: true, 128: true, 134: true, 135: true, 138: true, 139: true,
+	244: true,
+}
+
+// findH264SPS returns the payload of the first sequence parameter set NAL
+// unit in the given Annex B byte stream, with emulation prevention bytes
+// removed.
+func findH264SPS(data []byte) []byte {
+	startCode := []byte{0, 0, 1}
+	for {
+		i := bytes.Index(data, startCode)
+		if i < 0 || i+3 >= len(data) {
+			return nil
+		}
+		data = data[i+3:]
+		if data[0]&0x1f != h264SPSNALType {
+			continue
+		}
+		nal := data[1:]
+		if end := bytes.Index(nal, startCode); end >= 0 {
+			nal = nal[:end]
+		}
+		return bytes.Replace(nal, []byte{0, 0, 3}, []byte{0, 0}, -1)
+	}
+}
+
+// parseH264SPS extracts the dimensions and the frame rate of the video from
+// a sequence parameter set.
+func parseH264SPS(sps []byte, info *MediaInfo) error {
+	r := bitReader{data: sps}
+	profile := r.bits(8)
+	r.bits(16) // constraint flags and level
+	r.ue()     // seq_parameter_set_id
+	chromaFormat := uint(1)
+	separateColourPlane := false
+	if h264HighProfiles[profile] {
+		chromaFormat = r.ue()
+		if chromaFormat == 3 {
+			separateColourPlane = r.bit()
+		}
+		r.ue() // bit_depth_luma_minus8
+		r.ue() // bit_depth_chroma_minus8
+		r.bit()
+		if r.bit() {
+			lists := 8
+			if chromaFormat == 3 {
+				lists = 12
+			}
+			for i := 0; i < lists; i++ {
+				if r.bit() {
+					size := 16
+					if i >= 6 {
+						size = 64
+					}
+					r.skipScalingList(size)
+				}
+			}
+		}
+	}
+	r.ue() // log2_max_frame_num_minus4
+	switch r.ue() {
+	case 0:
+		r.ue()
+	case 1:
+		r.bit()
+		r.se()
+		r.se()
+		for n := r.ue(); n > 0 && r.err == nil; n-- {
+			r.se()
+		}
+	}
+	r.ue() // max_num_ref_frames
+	r.bit()
+	widthInMbs := r.ue() + 1
+	heightInMapUnits := r.ue() + 1
+	frameMbsOnly := uint(0)
+	if r.bit() {
+		frameMbsOnly = 1
+	} else {
+		r.bit()
+	}
+	r.bit()
+	var cropLeft, cropRight, cropTop, cropBottom uint
+	if r.bit() {
+		cropLeft, cropRight, cropTop, cropBottom = r.ue(), r.ue(), r.ue(), r.ue()
+	}
+	if r.err != nil {
+		return errInvalidSPS
+	}
+	cropUnitX, cropUnitY := uint(1), 2-frameMbsOnly
+	if chromaFormat != 0 && !separateColourPlane {
+		if chromaFormat < 3 {
+			cropUnitX = 2
+		}
+		if chromaFormat == 1 {
+			cropUnitY *= 2
+		}
+	}
+	info.Width = widthInMbs*16 - cropUnitX*(cropLeft+cropRight)
+	info.Height = (2-frameMbsOnly)*heightInMapUnits*16 - cropUnitY*(cropTop+cropBottom)
+	if r.bit() {
+		info.FrameRate = parseH264Timing(&r)
+	}
+	return nil
+}
+
+// parseH264Timing returns the frame rate defined in the VUI parameters, or
+// zero when it's not present.
+func parseH264Timing(r *bitReader) float64 {
+	if r.bit() {
+		if r.bits(8) == 255 {
+			r.bits(32) // sar_width and sar_height
+		}
+	}
+	if r.bit() {
+		r.bit()
+	}
+	if r.bit() {
+		r.bits(4)
+		if r.bit() {
+			r.bits(24)
+		}
+	}
+	if r.bit() {
+		r.ue()
+		r.ue()
+	}
+	if !r.bit() {
+		return 0
+	}
+	unitsInTick := r.bits(32)
+	timeScale := r.bits(32)
+	if r.err != nil || unitsInTick == 0 {
+		return 0
+	}
+	return roundFrameRate(float64(timeScale) / float64(2*unitsInTick))
+}
+
+// bitReader reads Exp-Golomb coded values from a byte slice. Reading past
+// the end of the data sets err.
+type bitReader struct {
+	data []byte
+	pos  uint
+	err  error
+}
+
+func (r *bitReader) bit() bool {
+	return r.bits(1) == 1
+}
+
+func (r *bitReader) bits(n uint) uint {
+	var value uint
+	for i := uint(0); i < n; i++ {
+		if r.pos >= uint(len(r.data))*8 {
+			r.err = errInvalidSPS
+			return 0
+		}
+		value = value<<1 | uint(r.data[r.pos/8]>>(7-r.pos%8)&1)
+		r.pos++
+	}
+	return value
+}
+
+func (r *bitReader) ue() uint {
+	var zeros uint
+	for !r.bit() {
+		if r.err != nil || zeros > 31 {
+			r.err = errInvalidSPS
+			return 0
+		}
+		zeros++
+	}
+	return 1<<zeros - 1 + r.bits(zeros)
+}
+
+func (r *bitReader) se() int {
+	value := r.ue()
+	if value%2 == 1 {
+		return int(value+1) / 2
+	}
+	return -int(value / 2)
+}
+
+func (r *bitReader) skipScalingList(size int) {
+	last, next := 8, 8
+	for i := 0; i < size && r.err == nil; i++ {
+		if next != 0 {
+			next = (last + r.se() + 256) % 256
+		}
+		if next != 0 {
+			last = next
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package probe
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestParseH264SPS(t *testing.T) {
+	var tests = []struct {
+		testCase   string
+		nal        string
+		wantWidth  uint
+		wantHeight uint
+		wantRate   float64
+		wantError  error
+	}{
+		{
+			"high profile with cropping and timing info",
+			"0000000167640028acd940780227e584000003000400000300ca10",
+			1920,
+			1080,
+			25,
+			nil,
+		},
+		{
+			"truncated sps",
+			"0000000167640028acd9",
+			0,
+			0,
+			0,
+			errInvalidSPS,
+		},
+	}
+	for _, test := range tests {
+		data, err := hex.DecodeString(test.nal)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sps := findH264SPS(data)
+		if sps == nil {
+			t.Errorf("%s: sps not found", test.testCase)
+			continue
+		}
+		var info MediaInfo
+		err = parseH264SPS(sps, &info)
+		if err != test.wantError {
+			t.Errorf("%s: wrong error returned. Want %v. Got %v", test.testCase, test.wantError, err)
+		}
+		if info.Width != test.wantWidth || info.Height != test.wantHeight || info.FrameRate != test.wantRate {
+			t.Errorf("%s: wrong info. Want %dx%d@%g. Got %dx%d@%g", test.testCase, test.wantWidth, test.wantHeight, test.wantRate, info.Width, info.Height, info.FrameRate)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package probe
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// maxMoovSize is the maximum size of the moov atom that will be loaded when
+// probing MP4 and QuickTime files.
+const maxMoovSize = 64 << 20
+
+var (
+	errMissingMoov   = errors.New("moov atom not found")
+	errTruncatedMoov = errors.New("moov atom is truncated")
+	errMoovTooLarge  = errors.New("moov atom is too large")
+
+	mp4TopLevelBoxes = map[string]bool{
+		"ftyp": true, "moov": true, "mdat": true, "free": true,
+		"skip": true, "wide": true, "pnot": true,
+	}
+
+	mp4VideoCodecs = map[string]string{
+		"avc1": "h264", "avc3": "h264",
+		"hvc1": "hevc", "hev1": "hevc",
+		"av01": "av1",
+		"vp08": "vp8", "vp09": "vp9",
+		"apch": "prores", "apcn": "prores", "apcs": "prores",
+		"apco": "prores", "ap4h": "prores", "ap4x": "prores",
+		"mp4v": "mpeg4",
+		"jpeg": "mjpeg", "mjpa": "mjpeg", "mjpb": "mjpeg",
+	}
+
+	mp4AudioCodecs = map[string]string{
+		"mp4a": "aac",
+		"ac-3": "ac3", "ec-3": "eac3",
+		"Opus": "opus",
+		"fLaC": "flac",
+		"alac": "alac",
+		"lpcm": "pcm", "sowt": "pcm", "twos": "pcm",
+		"in24": "pcm", "in32": "pcm", "fl32": "pcm",
+	}
+)
+
+func isMP4(header []byte) bool {
+	return len(header) >= 8 && mp4TopLevelBoxes[string(header[4:8])]
+}
+
+// probeMP4 walks through the top level boxes of the file, loading and
+// parsing the moov atom, that may be either at the beginning or at the end
+// of the file.
+func probeMP4(r io.ReaderAt, size int64) (*MediaInfo, error) {
+	info := MediaInfo{Container: "mov"}
+	var offset int64
+	header := make([]byte, 16)
+	for size < 0 || offset < size {
+		n, err := r.ReadAt(header, offset)
+		if n < 8 {
+			if err == io.EOF || err == nil {
+				break
+			}
+			return nil, err
+		}
+		boxSize := int64(binary.BigEndian.Uint32(header))
+		boxType := string(header[4:8])
+		headerLen := int64(8)
+		switch boxSize {
+		case 0:
+			if size < 0 {
+				return nil, errMissingMoov
+			}
+			boxSize = size - offset
+		case 1:
+			if n < 16 {
+				return nil, fmt.Errorf("invalid %s box", boxType)
+			}
+			boxSize = int64(binary.BigEndian.Uint64(header[8:]))
+			headerLen = 16
+		}
+		if boxSize < headerLen {
+			return nil, fmt.Errorf("invalid %s box", boxType)
+		}
+		switch boxType {
+		case "ftyp":
+			if n >= 12 && string(header[8:12]) != "qt  " {
+				info.Container = "mp4"
+			}
+		case "moov":
+			if boxSize-headerLen > maxMoovSize {
+				return nil, errMoovTooLarge
+			}
+			moov := make([]byte, boxSize-headerLen)
+			n, err = r.ReadAt(moov, offset+headerLen)
+			if n < len(moov) {
+				if err == io.EOF || err == nil {
+					err = errTruncatedMoov
+				}
+				return nil, err
+			}
+			if err = parseMoov(moov, &info); err != nil {
+				return nil, err
+			}
+			return &info, nil
+		}
+		offset += boxSize
+	}
+	return nil, errMissingMoov
+}
+
+// walkBoxes calls fn for each box found in data, which should contain a
+// sequence of boxes.
+func walkBoxes(data []byte, fn func(boxType string, body []byte) error) error {
+	for len(data) >= 8 {
+		boxSize := uint64(binary.BigEndian.Uint32(data))
+		boxType := string(data[4:8])
+		headerLen := uint64(8)
+		switch boxSize {
+		case 0:
+			boxSize = uint64(len(data))
+		case 1:
+			if len(data) < 16 {
+				return fmt.Errorf("invalid %s box", boxType)
+			}
+			boxSize = binary.BigEndian.Uint64(data[8:])
+			headerLen = 16
+		}
+		if boxSize < headerLen || boxSize > uint64(len(data)) {
+			return fmt.Errorf("invalid %s box", boxType)
+		}
+		if err := fn(boxType, data[headerLen:boxSize]); err != nil {
+			return err
+		}
+		data = data[boxSize:]
+	}
+	return nil
+}
+
+func parseMoov(moov []byte, info *MediaInfo) error {
+	return walkBoxes(moov, func(boxType string, body []byte) error {
+		switch boxType {
+		case "mvhd":
+			timescale, duration, err := parseTimeHeader(boxType, body)
+			if err != nil {
+				return err
+			}
+			info.Duration = mediaDuration(duration, timescale)
+		case "trak":
+			return parseTrak(body, info)
+		}
+		return nil
+	})
+}
+
+// mp4Track contains the information found in the boxes of a trak atom.
+type mp4Track struct {
+	handler     string
+	format      string
+	width       uint
+	height      uint
+	timescale   uint64
+	sampleCount uint64
+	sampleTime  uint64
+}
+
+func parseTrak(trak []byte, info *MediaInfo) error {
+	var track mp4Track
+	var walk func(boxType string, body []byte) error
+	walk = func(boxType string, body []byte) error {
+		var err error
+		switch boxType {
+		case "mdia", "minf", "stbl":
+			return walkBoxes(body, walk)
+		case "mdhd":
+			track.timescale, _, err = parseTimeHeader(boxType, body)
+		case "hdlr":
+			if len(body) < 12 {
+				return fmt.Errorf("invalid %s box", boxType)
+			}
+			track.handler = string(body[8:12])
+		case "stsd":
+			err = parseSampleDescription(body, &track)
+		case "stts":
+			err = parseTimeToSample(body, &track)
+		}
+		return err
+	}
+	if err := walkBoxes(trak, walk); err != nil {
+		return err
+	}
+	switch track.handler {
+	case "vide":
+		if info.VideoCodec != "" {
+			return nil
+		}
+		info.VideoCodec = codecName(mp4VideoCodecs, track.format)
+		info.Width = track.width
+		info.Height = track.height
+		if track.sampleTime > 0 {
+			info.FrameRate = roundFrameRate(float64(track.sampleCount) * float64(track.timescale) / float64(track.sampleTime))
+		}
+	case "soun":
+		if info.AudioCodec == "" {
+			info.AudioCodec = codecName(mp4AudioCodecs, track.format)
+		}
+	}
+	return nil
+}
+
+// parseTimeHeader parses the timescale and the duration from a mvhd or a
+// mdhd box.
+func parseTimeHeader(boxType string, body []byte) (timescale, duration uint64, err error) {
+	if len(body) < 20 {
+		return 0, 0, fmt.Errorf("invalid %s box", boxType)
+	}
+	if body[0] == 1 {
+		if len(body) < 32 {
+			return 0, 0, fmt.Errorf("invalid %s box", boxType)
+		}
+		return uint64(binary.BigEndian.Uint32(body[20:])), binary.BigEndian.Uint64(body[24:]), nil
+	}
+	return uint64(binary.BigEndian.Uint32(body[12:])), uint64(binary.BigEndian.Uint32(body[16:])), nil
+}
+
+// parseSampleDescription parses the format of the first entry in a stsd
+// box, along with the dimensions of the frames in visual sample entries.
+func parseSampleDescription(body []byte, track *mp4Track) error {
+	if len(body) < 16 || binary.BigEndian.Uint32(body[4:]) == 0 {
+		return errors.New("invalid stsd box")
+	}
+	track.format = string(body[12:16])
+	if track.handler == "vide" && len(body) >= 44 {
+		track.width = uint(binary.BigEndian.Uint16(body[40:]))
+		track.height = uint(binary.BigEndian.Uint16(body[42:]))
+	}
+	return nil
+}
+
+func parseTimeToSample(body []byte, track *mp4Track) error {
+	if len(body) < 8 {
+		return errors.New("invalid stts box")
+	}
+	entries := body[8:]
+	count := int(binary.BigEndian.Uint32(body[4:]))
+	if count*8 > len(entries) {
+		return errors.New("invalid stts box")
+	}
+	for i := 0; i < count; i++ {
+		sampleCount := uint64(binary.BigEndian.Uint32(entries[i*8:]))
+		sampleDelta := uint64(binary.BigEndian.Uint32(entries[i*8+4:]))
+		track.sampleCount += sampleCount
+		track.sampleTime += sampleCount * sampleDelta
+	}
+	return nil
+}
+
+func codecName(codecs map[string]string, format string) string {
+	if codec, ok := codecs[format]; ok {
+		return codec
+	}
+	return strings.ToLower(fourCC([]byte(format)))
+}
+
+func mediaDuration(duration, timescale uint64) time.Duration {
+	if timescale == 0 {
+		return 0
+	}
+	return time.Duration(float64(duration) / float64(timescale) * float64(time.Second))
+}
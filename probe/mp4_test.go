@@ -0,0 +1,46 @@
+package probe
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestProbeMP4InvalidFiles(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/video.mov")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tests = []struct {
+		testCase  string
+		data      []byte
+		wantError string
+	}{
+		{
+			"missing moov atom",
+			data[:28],
+			"moov atom not found",
+		},
+		{
+			"truncated moov atom",
+			data[:200],
+			"moov atom is truncated",
+		},
+		{
+			"invalid box size",
+			append(data[:28:28], 0, 0, 0, 4, 'm', 'o', 'o', 'v'),
+			"invalid moov box",
+		},
+		{
+			"invalid trak box",
+			append(data[:28:28], 0, 0, 0, 24, 'm', 'o', 'o', 'v', 0, 0, 0, 16, 't', 'r', 'a', 'k', 0, 0, 0, 255, 'm', 'd', 'i', 'a'),
+			"invalid mdia box",
+		},
+	}
+	for _, test := range tests {
+		_, err := probeReader(bytes.NewReader(test.data), test.data, int64(len(test.data)))
+		if err == nil || err.Error() != test.wantError {
+			t.Errorf("%s: wrong error returned. Want %q. Got %v", test.testCase, test.wantError, err)
+		}
+	}
+}
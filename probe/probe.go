@@ -0,0 +1,163 @@
+// Package probe inspects source media files before they're sent to
+// transcoding providers.
+//
+// It reads only the parts of the file needed for extracting information
+// about the media, like the moov atom of MP4 and QuickTime files or the
+// program tables and the first and last packets of MPEG transport streams,
+// using HTTP range requests.
+package probe
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	headerSize     = 1024
+	defaultTimeout = 30 * time.Second
+)
+
+var (
+	// ErrUnsupportedScheme is the error returned when the source URL uses
+	// a scheme that can't be probed. Only HTTP and HTTPS sources are
+	// probed.
+	ErrUnsupportedScheme = errors.New("source scheme is not supported for probing")
+
+	// ErrUnknownFormat is the error returned when the container of the
+	// source can't be identified.
+	ErrUnknownFormat = errors.New("unknown media format")
+
+	defaultProber = Prober{Client: &http.Client{Timeout: defaultTimeout}}
+)
+
+// MediaInfo contains the information extracted from a source media file.
+type MediaInfo struct {
+	Container  string        `json:"container"`
+	Duration   time.Duration `json:"duration"`
+	Width      uint          `json:"width,omitempty"`
+	Height     uint          `json:"height,omitempty"`
+	VideoCodec string        `json:"videoCodec,omitempty"`
+	AudioCodec string        `json:"audioCodec,omitempty"`
+	FrameRate  float64       `json:"frameRate,omitempty"`
+}
+
+// Prober probes media files available through HTTP.
+type Prober struct {
+	// Client is the HTTP client used for reading the files. When nil,
+	// http.DefaultClient is used.
+	Client *http.Client
+}
+
+// Probe probes the given source using a Prober with the default timeout.
+func Probe(source string) (*MediaInfo, error) {
+	return defaultProber.Probe(source)
+}
+
+// Probe reads the media file identified by the given URL and returns
+// information about it.
+func (p *Prober) Probe(source string) (*MediaInfo, error) {
+	sourceURL, err := url.Parse(source)
+	if err != nil {
+		return nil, err
+	}
+	if sourceURL.Scheme != "http" && sourceURL.Scheme != "https" {
+		return nil, ErrUnsupportedScheme
+	}
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	r := &httpReader{client: client, url: source}
+	header := make([]byte, headerSize)
+	n, err := r.ReadAt(header, 0)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return probeReader(r, header[:n], r.size)
+}
+
+func probeReader(r io.ReaderAt, header []byte, size int64) (*MediaInfo, error) {
+	switch {
+	case isMP4(header):
+		return probeMP4(r, size)
+	case isTS(header):
+		return probeTS(r, size)
+	}
+	return nil, ErrUnknownFormat
+}
+
+// httpReader is an io.ReaderAt that reads a remote file using HTTP range
+// requests.
+type httpReader struct {
+	client *http.Client
+	url    string
+	size   int64
+}
+
+func (r *httpReader) ReadAt(p []byte, off int64) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	req, err := http.NewRequest("GET", r.url, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", off, off+int64(len(p))-1))
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		r.size = contentRangeSize(resp.Header.Get("Content-Range"))
+	case http.StatusOK:
+		r.size = resp.ContentLength
+		if _, err = io.CopyN(ioutil.Discard, resp.Body, off); err != nil {
+			return 0, err
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		return 0, io.EOF
+	default:
+		return 0, fmt.Errorf("failed to read source: %s", resp.Status)
+	}
+	n, err := io.ReadFull(resp.Body, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// contentRangeSize extracts the complete length of the file from the value
+// of a Content-Range header, returning -1 when it's unknown.
+func contentRangeSize(contentRange string) int64 {
+	i := strings.LastIndex(contentRange, "/")
+	if i < 0 {
+		return -1
+	}
+	size, err := strconv.ParseInt(contentRange[i+1:], 10, 64)
+	if err != nil {
+		return -1
+	}
+	return size
+}
+
+// roundFrameRate rounds the given frame rate to three decimal places.
+func roundFrameRate(frameRate float64) float64 {
+	return math.Floor(frameRate*1000+0.5) / 1000
+}
+
+// fourCC returns the given four-character code as a string, without
+// trailing spaces.
+func fourCC(code []byte) string {
+	return string(bytes.TrimRight(code, " \x00"))
+}
@@ -0,0 +1,129 @@
+package probe
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestProbe(t *testing.T) {
+	server := httptest.NewServer(http.FileServer(http.Dir("testdata")))
+	defer server.Close()
+	var tests = []struct {
+		testCase  string
+		source    string
+		wantInfo  *MediaInfo
+		wantError string
+	}{
+		{
+			"mp4 file with moov atom at the end",
+			server.URL + "/video.mp4",
+			&MediaInfo{
+				Container:  "mp4",
+				Duration:   10 * time.Second,
+				Width:      1280,
+				Height:     720,
+				VideoCodec: "h264",
+				AudioCodec: "aac",
+				FrameRate:  29.97,
+			},
+			"",
+		},
+		{
+			"quicktime file",
+			server.URL + "/video.mov",
+			&MediaInfo{
+				Container:  "mov",
+				Duration:   10 * time.Second,
+				Width:      1920,
+				Height:     1080,
+				VideoCodec: "prores",
+				AudioCodec: "pcm",
+				FrameRate:  25,
+			},
+			"",
+		},
+		{
+			"transport stream",
+			server.URL + "/video.ts",
+			&MediaInfo{
+				Container:  "ts",
+				Duration:   9960 * time.Millisecond,
+				Width:      1920,
+				Height:     1080,
+				VideoCodec: "h264",
+				AudioCodec: "aac",
+				FrameRate:  25,
+			},
+			"",
+		},
+		{
+			"unknown format",
+			server.URL + "/unknown.txt",
+			nil,
+			"unknown media format",
+		},
+		{
+			"missing file",
+			server.URL + "/missing.mp4",
+			nil,
+			"failed to read source: 404 Not Found",
+		},
+		{
+			"unsupported scheme",
+			"s3://bucket/video.mp4",
+			nil,
+			"source scheme is not supported for probing",
+		},
+	}
+	for _, test := range tests {
+		info, err := Probe(test.source)
+		if test.wantError != "" {
+			if err == nil || err.Error() != test.wantError {
+				t.Errorf("%s: wrong error returned. Want %q. Got %v", test.testCase, test.wantError, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.testCase, err)
+			continue
+		}
+		if !reflect.DeepEqual(info, test.wantInfo) {
+			t.Errorf("%s: wrong info returned\nWant %#v\nGot  %#v", test.testCase, test.wantInfo, info)
+		}
+	}
+}
+
+func TestProbeWithoutRangeSupport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Header.Del("Range")
+		http.ServeFile(w, r, "testdata/video.mp4")
+	}))
+	defer server.Close()
+	var prober Prober
+	info, err := prober.Probe(server.URL + "/video.mp4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.VideoCodec != "h264" || info.Width != 1280 || info.Height != 720 {
+		t.Errorf("wrong info returned: %#v", info)
+	}
+}
+
+func TestContentRangeSize(t *testing.T) {
+	var tests = []struct {
+		contentRange string
+		want         int64
+	}{
+		{"bytes 0-1023/146515", 146515},
+		{"bytes 0-1023/*", -1},
+		{"", -1},
+	}
+	for _, test := range tests {
+		if got := contentRangeSize(test.contentRange); got != test.want {
+			t.Errorf("contentRangeSize(%q): want %d. Got %d", test.contentRange, test.want, got)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package probe
+
+import (
+	"errors"
+	"io"
+	"time"
+)
+
+const (
+	tsPacketSize = 188
+	tsSyncByte   = 0x47
+
+	// tsProbeSize is the amount of data read from the beginning and from
+	// the end of transport streams.
+	tsProbeSize = 2048 * tsPacketSize
+
+	// maxSPSSearchSize is the maximum amount of video data inspected when
+	// looking for the sequence parameter set of H.264 streams.
+	maxSPSSearchSize = 512 << 10
+
+	ptsClockRate = 90000
+	ptsMask      = 1<<33 - 1
+)
+
+var (
+	errMissingPMT = errors.New("program map table not found in transport stream")
+
+	tsVideoCodecs = map[byte]string{
+		0x01: "mpeg1",
+		0x02: "mpeg2",
+		0x10: "mpeg4",
+		0x1b: "h264",
+		0x24: "hevc",
+		0xea: "vc1",
+	}
+
+	tsAudioCodecs = map[byte]string{
+		0x03: "mp3",
+		0x04: "mp3",
+		0x0f: "aac",
+		0x11: "aac",
+		0x81: "ac3",
+		0x87: "eac3",
+	}
+
+	// tsAudioDescriptors maps the tags of descriptors used for identifying
+	// audio codecs in private data streams.
+	tsAudioDescriptors = map[byte]string{
+		0x6a: "ac3",
+		0x7a: "eac3",
+	}
+)
+
+func isTS(header []byte) bool {
+	return len(header) > tsPacketSize && header[0] == tsSyncByte && header[tsPacketSize] == tsSyncByte
+}
+
+// tsStream contains the state of the probing of a transport stream.
+type tsStream struct {
+	pmtPID   int
+	videoPID int
+	audioPID int
+	firstPTS int64
+	lastPTS  int64
+	video    []byte
+}
+
+func probeTS(r io.ReaderAt, size int64) (*MediaInfo, error) {
+	head := make([]byte, tsProbeSize)
+	n, err := r.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	stream := tsStream{pmtPID: -1, videoPID: -1, audioPID: -1, firstPTS: -1, lastPTS: -1}
+	info := MediaInfo{Container: "ts"}
+	walkTSPackets(head[:n], func(pid int, unitStart bool, payload []byte) {
+		stream.handlePacket(pid, unitStart, payload, &info)
+	})
+	if stream.videoPID < 0 && stream.audioPID < 0 {
+		return nil, errMissingPMT
+	}
+	if info.VideoCodec == "h264" {
+		if sps := findH264SPS(stream.video); sps != nil {
+			if err = parseH264SPS(sps, &info); err != nil {
+				return nil, err
+			}
+		}
+	}
+	readAll := n < len(head) || size == int64(n)
+	if !readAll && size > int64(n) {
+		tailOffset := size - tsProbeSize
+		if tailOffset < int64(n) {
+			tailOffset = int64(n)
+		}
+		tail := make([]byte, size-tailOffset)
+		n, err = r.ReadAt(tail, tailOffset)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		walkTSPackets(alignTS(tail[:n]), func(pid int, unitStart bool, payload []byte) {
+			stream.handlePacket(pid, unitStart, payload, &info)
+		})
+		readAll = true
+	}
+	if readAll && stream.firstPTS >= 0 {
+		info.Duration = time.Duration((stream.lastPTS-stream.firstPTS)&ptsMask) * time.Second / ptsClockRate
+	}
+	return &info, nil
+}
+
+func (s *tsStream) handlePacket(pid int, unitStart bool, payload []byte, info *MediaInfo) {
+	switch {
+	case pid == 0 && unitStart && s.pmtPID < 0:
+		s.pmtPID = parsePAT(payload)
+	case pid == s.pmtPID && unitStart && s.videoPID < 0 && s.audioPID < 0:
+		s.parsePMT(payload, info)
+	case pid == s.timingPID():
+		if unitStart {
+			if pts := parsePTS(payload); pts >= 0 {
+				if s.firstPTS < 0 {
+					s.firstPTS = pts
+				}
+				s.lastPTS = pts
+			}
+		}
+		if pid == s.videoPID && len(s.video) < maxSPSSearchSize {
+			s.video = append(s.video, payload...)
+		}
+	}
+}
+
+// timingPID returns the PID of the elementary stream used for calculating
+// the duration of the media, favoring the video stream.
+func (s *tsStream) timingPID() int {
+	if s.videoPID >= 0 {
+		return s.videoPID
+	}
+	return s.audioPID
+}
+
+// walkTSPackets calls fn with the payload of each packet in data.
+func walkTSPackets(data []byte, fn func(pid int, unitStart bool, payload []byte)) {
+	for ; len(data) >= tsPacketSize; data = data[tsPacketSize:] {
+		packet := data[:tsPacketSize]
+		if packet[0] != tsSyncByte {
+			continue
+		}
+		pid := int(packet[1]&0x1f)<<8 | int(packet[2])
+		unitStart := packet[1]&0x40 != 0
+		adaptationControl := packet[3] >> 4 & 0x03
+		if adaptationControl&0x01 == 0 {
+			continue
+		}
+		payloadStart := 4
+		if adaptationControl&0x02 != 0 {
+			payloadStart += 1 + int(packet[4])
+		}
+		if payloadStart >= tsPacketSize {
+			continue
+		}
+		fn(pid, unitStart, packet[payloadStart:])
+	}
+}
+
+// alignTS skips the bytes before the first complete packet in data.
+func alignTS(data []byte) []byte {
+	for i := 0; i+tsPacketSize < len(data) && i < tsPacketSize; i++ {
+		if data[i] == tsSyncByte && data[i+tsPacketSize] == tsSyncByte {
+			return data[i:]
+		}
+	}
+	return nil
+}
+
+// psiSection returns the section in the payload of a packet carrying
+// program specific information, without the trailing CRC.
+func psiSection(payload []byte, tableID byte) []byte {
+	if len(payload) < 1 {
+		return nil
+	}
+	pointer := int(payload[0])
+	if 1+pointer+3 > len(payload) {
+		return nil
+	}
+	section := payload[1+pointer:]
+	if section[0] != tableID {
+		return nil
+	}
+	length := int(section[1]&0x0f)<<8 | int(section[2])
+	if length < 9 || 3+length > len(section) {
+		return nil
+	}
+	return section[:3+length-4]
+}
+
+// parsePAT returns the PID of the first program map table listed in the
+// program association table.
+func parsePAT(payload []byte) int {
+	section := psiSection(payload, 0x00)
+	for i := 8; i+4 <= len(section); i += 4 {
+		programNumber := int(section[i])<<8 | int(section[i+1])
+		if programNumber != 0 {
+			return int(section[i+2]&0x1f)<<8 | int(section[i+3])
+		}
+	}
+	return -1
+}
+
+func (s *tsStream) parsePMT(payload []byte, info *MediaInfo) {
+	section := psiSection(payload, 0x02)
+	if len(section) < 12 {
+		return
+	}
+	i := 12 + (int(section[10]&0x0f)<<8 | int(section[11]))
+	for i+5 <= len(section) {
+		streamType := section[i]
+		pid := int(section[i+1]&0x1f)<<8 | int(section[i+2])
+		infoLength := int(section[i+3]&0x0f)<<8 | int(section[i+4])
+		descriptors := section[i+5:]
+		if infoLength < len(descriptors) {
+			descriptors = descriptors[:infoLength]
+		}
+		i += 5 + infoLength
+		if codec, ok := tsVideoCodecs[streamType]; ok && s.videoPID < 0 {
+			s.videoPID = pid
+			info.VideoCodec = codec
+			continue
+		}
+		codec, ok := tsAudioCodecs[streamType]
+		if !ok && streamType == 0x06 {
+			codec, ok = descriptorCodec(descriptors)
+		}
+		if ok && s.audioPID < 0 {
+			s.audioPID = pid
+			info.AudioCodec = codec
+		}
+	}
+}
+
+func descriptorCodec(descriptors []byte) (string, bool) {
+	for len(descriptors) >= 2 {
+		if codec, ok := tsAudioDescriptors[descriptors[0]]; ok {
+			return codec, true
+		}
+		next := 2 + int(descriptors[1])
+		if next > len(descriptors) {
+			break
+		}
+		descriptors = descriptors[next:]
+	}
+	return "", false
+}
+
+// parsePTS returns the presentation timestamp in the header of the PES
+// packet starting in the given payload, or -1 when it's not present.
+func parsePTS(payload []byte) int64 {
+	if len(payload) < 14 || payload[0] != 0 || payload[1] != 0 || payload[2] != 1 {
+		return -1
+	}
+	if payload[7]&0x80 == 0 {
+		return -1
+	}
+	p := payload[9:14]
+	return int64(p[0]>>1&0x07)<<30 | int64(p[1])<<22 | int64(p[2]>>1)<<15 | int64(p[3])<<7 | int64(p[4]>>1)
+}
@@ -0,0 +1,42 @@
+package probe
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestProbeTSReadsTail(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/video.ts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nullPacket := make([]byte, tsPacketSize)
+	nullPacket[0], nullPacket[1], nullPacket[2], nullPacket[3] = tsSyncByte, 0x1f, 0xff, 0x10
+	body, last := data[:len(data)-tsPacketSize], data[len(data)-tsPacketSize:]
+	stream := append([]byte{}, body...)
+	for i := 0; i < 3*tsProbeSize/tsPacketSize; i++ {
+		stream = append(stream, nullPacket...)
+	}
+	stream = append(stream, last...)
+	info, err := probeReader(bytes.NewReader(stream), stream[:headerSize], int64(len(stream)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := 9960 * time.Millisecond; info.Duration != want {
+		t.Errorf("wrong duration. Want %s. Got %s", want, info.Duration)
+	}
+}
+
+func TestProbeTSMissingPMT(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/video.ts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = data[2*tsPacketSize:]
+	_, err = probeReader(bytes.NewReader(data), data[:headerSize], int64(len(data)))
+	if err != errMissingPMT {
+		t.Errorf("wrong error returned. Want %v. Got %v", errMissingPMT, err)
+	}
+}
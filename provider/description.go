@@ -13,11 +13,20 @@ type Description struct {
 
 // Capabilities describes the available features in the provider. It specificie
 // which input and output formats the provider supports, along with
-// supported destinations.
+// supported video codecs, destinations, caption formats and HLS segment
+// formats and encryption methods. It also lists the preset fields that the
+// provider doesn't report in normalized presets, which are ignored when
+// checking presets for drift.
 type Capabilities struct {
-	InputFormats  []string `json:"input"`
-	OutputFormats []string `json:"output"`
-	Destinations  []string `json:"destinations"`
+	InputFormats         []string `json:"input"`
+	OutputFormats        []string `json:"output"`
+	VideoCodecs          []string `json:"videoCodecs"`
+	Destinations         []string `json:"destinations"`
+	CaptionFormats       []string `json:"captions,omitempty"`
+	HLSSegmentFormats    []string `json:"hlsSegments,omitempty"`
+	HLSEncryptionMethods []string `json:"hlsEncryption,omitempty"`
+
+	UnreportedPresetFields []string `json:"unreportedPresetFields,omitempty"`
 }
 
 // Health describes the current health status of the provider. If indicates
@@ -16,22 +16,30 @@
 package elastictranscoder
 
 import (
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"path/filepath"
-	"regexp"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/NYTimes/video-transcoding-api/config"
 	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/mediaurl"
 	"github.com/NYTimes/video-transcoding-api/provider"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/elastictranscoder"
 	"github.com/aws/aws-sdk-go/service/elastictranscoder/elastictranscoderiface"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
 )
 
 const (
@@ -41,11 +49,68 @@ const (
 
 	defaultAWSRegion = "us-east-1"
 	hlsPlayList      = "HLSv3"
+	dashPlayList     = "MPEG-DASH"
+
+	captionMergePolicy = "Override"
+	captionPattern     = "captions/{language}"
+
+	overlayWatermarkID = "overlay"
+
+	// maximum number of reference frames used in H.264 presets that
+	// don't define it.
+	defaultMaxReferenceFrames = "2"
+
+	// keys are served by the API, so Elastic Transcoder must not store them
+	// along with the playlists.
+	keyStoragePolicy = "NoStore"
+
+	// keys are encrypted with the KMS key of the pipeline, or with the
+	// default key of Elastic Transcoder for pipelines that don't define
+	// one. Elastic Transcoder decrypts them using its service name as the
+	// encryption context.
+	defaultKMSKeyID      = "alias/aws/elastictranscoder"
+	kmsEncryptionContext = "elastictranscoder.amazonaws.com"
+
+	// size of the keys and initialization vectors of aes-128 encryption,
+	// in bytes.
+	encryptionBlockSize = 16
 )
 
 var (
 	errAWSInvalidConfig = errors.New("invalid Elastic Transcoder config. Please define the configuration entries in the config file or environment variables")
-	s3Pattern           = regexp.MustCompile(`^s3://`)
+
+	// streamingPlaylists maps the containers of adaptive streaming presets
+	// to the format of the playlist that groups them.
+	streamingPlaylists = map[string]string{
+		"ts":   hlsPlayList,
+		"fmp4": dashPlayList,
+	}
+
+	// playlistExtensions maps the formats of playlists to the extension
+	// of the files generated by Elastic Transcoder.
+	playlistExtensions = map[string]string{
+		hlsPlayList:  "m3u8",
+		dashPlayList: "mpd",
+	}
+
+	// videoCodecs maps the video codecs of presets to the codecs of
+	// Elastic Transcoder presets.
+	videoCodecs = provider.VideoCodecs{"h264": "H.264", "vp8": "vp8", "vp9": "vp9"}
+
+	// playlistSuffixes contains the suffixes added to the names of
+	// playlists that don't match the protocol of jobs that generate
+	// playlists in multiple formats.
+	playlistSuffixes = map[string]string{
+		hlsPlayList:  "-hls",
+		dashPlayList: "-dash",
+	}
+
+	captionExtensions = map[string]string{
+		"dfxp":   "dfxp",
+		"scc":    "scc",
+		"srt":    "srt",
+		"webvtt": "vtt",
+	}
 )
 
 func init() {
@@ -54,12 +119,27 @@ func init() {
 
 type awsProvider struct {
 	c      elastictranscoderiface.ElasticTranscoderAPI
+	kms    kmsiface.KMSAPI
 	config *config.ElasticTranscoder
 }
 
 func (p *awsProvider) Transcode(job *db.Job, transcodeProfile provider.TranscodeProfile) (*provider.JobStatus, error) {
-	var adaptiveStreamingOutputs []provider.TranscodeOutput
-	source := p.normalizeSource(transcodeProfile.SourceMedia)
+	var playlistFormats []string
+	adaptiveStreamingOutputs := make(map[string][]provider.TranscodeOutput)
+	captionOutputIndex := -1
+	// HLS playlists of Elastic Transcoder only reference TS segments (HLSv4
+	// playlists included), so fmp4 segments can't be generated.
+	if format := transcodeProfile.StreamingParams.SegmentFormat; format != "" && format != "ts" {
+		return nil, fmt.Errorf("segment format %q is not supported by Elastic Transcoder", format)
+	}
+	source, err := p.inputKey(transcodeProfile.SourceMedia)
+	if err != nil {
+		return nil, err
+	}
+	keyPrefix, err := p.outputKeyPrefix(job)
+	if err != nil {
+		return nil, err
+	}
 	params := elastictranscoder.CreateJobInput{
 		PipelineId: aws.String(p.config.PipelineID),
 		Input:      &elastictranscoder.JobInput{Key: aws.String(source)},
@@ -80,36 +160,92 @@ func (p *awsProvider) Transcode(job *db.Job, transcodeProfile provider.Transcode
 		if presetOutput.Preset == nil || presetOutput.Preset.Container == nil {
 			return nil, fmt.Errorf("misconfigured preset: %s", presetID)
 		}
-		var isAdaptiveStreamingPreset bool
-		if *presetOutput.Preset.Container == "ts" {
-			isAdaptiveStreamingPreset = true
-			adaptiveStreamingOutputs = append(adaptiveStreamingOutputs, output)
+		playlistFormat, isAdaptiveStreamingPreset := streamingPlaylists[*presetOutput.Preset.Container]
+		if isAdaptiveStreamingPreset {
+			if _, ok := adaptiveStreamingOutputs[playlistFormat]; !ok {
+				playlistFormats = append(playlistFormats, playlistFormat)
+			}
+			adaptiveStreamingOutputs[playlistFormat] = append(adaptiveStreamingOutputs[playlistFormat], output)
 		}
 		params.Outputs[i] = &elastictranscoder.CreateJobOutput{
 			PresetId: aws.String(presetID),
-			Key:      p.outputKey(job, output.FileName, isAdaptiveStreamingPreset),
+			Key:      p.outputKey(keyPrefix, output.FileName, isAdaptiveStreamingPreset),
+		}
+		if transcodeProfile.OverlayImage != "" && p.hasOverlay(presetOutput.Preset) {
+			overlayKey, err := p.inputKey(transcodeProfile.OverlayImage)
+			if err != nil {
+				return nil, err
+			}
+			params.Outputs[i].Watermarks = []*elastictranscoder.JobWatermark{
+				{
+					PresetWatermarkId: aws.String(overlayWatermarkID),
+					InputKey:          aws.String(overlayKey),
+				},
+			}
 		}
 		if isAdaptiveStreamingPreset {
 			params.Outputs[i].SegmentDuration = aws.String(strconv.Itoa(int(transcodeProfile.StreamingParams.SegmentDuration)))
+			if captionOutputIndex < 0 {
+				captionOutputIndex = i
+			}
 		}
 	}
 
-	if len(adaptiveStreamingOutputs) > 0 {
-		playlistFileName := transcodeProfile.StreamingParams.PlaylistFileName
-		playlistFileName = strings.TrimRight(playlistFileName, filepath.Ext(playlistFileName))
+	// Sidecar captions are generated only once per job, attached to the
+	// first adaptive streaming output (or to the first output when there's
+	// no adaptive streaming output).
+	if len(transcodeProfile.Captions) > 0 && len(params.Outputs) > 0 {
+		adaptive := captionOutputIndex >= 0
+		if !adaptive {
+			captionOutputIndex = 0
+		}
+		captions, err := p.buildCaptions(keyPrefix, transcodeProfile.Captions, adaptive)
+		if err != nil {
+			return nil, err
+		}
+		params.Outputs[captionOutputIndex].Captions = captions
+	}
+
+	playlistFileName := transcodeProfile.StreamingParams.PlaylistFileName
+	playlistFileName = strings.TrimSuffix(playlistFileName, filepath.Ext(playlistFileName))
+	for _, playlistFormat := range playlistFormats {
+		outputs := adaptiveStreamingOutputs[playlistFormat]
+		playlistName := keyPrefix + playlistFileName
+		if len(playlistFormats) > 1 && playlistFormat != p.mainPlaylistFormat(transcodeProfile.StreamingParams) {
+			playlistName += playlistSuffixes[playlistFormat]
+		}
 		jobPlaylist := elastictranscoder.CreateJobPlaylist{
-			Format: aws.String(hlsPlayList),
-			Name:   aws.String(job.ID + "/" + playlistFileName),
+			Format: aws.String(playlistFormat),
+			Name:   aws.String(playlistName),
+		}
+		if encryption := transcodeProfile.StreamingParams.Encryption; encryption != nil && playlistFormat != dashPlayList {
+			protection, err := p.hlsContentProtection(encryption)
+			if err != nil {
+				return nil, err
+			}
+			jobPlaylist.HlsContentProtection = protection
 		}
 
-		jobPlaylist.OutputKeys = make([]*string, len(adaptiveStreamingOutputs))
-		for i, output := range adaptiveStreamingOutputs {
-			jobPlaylist.OutputKeys[i] = p.outputKey(job, output.FileName, true)
+		jobPlaylist.OutputKeys = make([]*string, len(outputs))
+		for i, output := range outputs {
+			jobPlaylist.OutputKeys[i] = p.outputKey(keyPrefix, output.FileName, true)
 		}
 
-		params.Playlists = []*elastictranscoder.CreateJobPlaylist{&jobPlaylist}
+		params.Playlists = append(params.Playlists, &jobPlaylist)
+	}
+	unknown, err := provider.ApplyOptions(Name, &params, "", transcodeProfile.ProviderOptions[Name])
+	if err != nil {
+		return nil, err
+	}
+	var resp *elastictranscoder.CreateJobResponse
+	if len(unknown) > 0 {
+		var req *request.Request
+		req, resp = p.c.CreateJobRequest(&params)
+		req.Handlers.Build.PushBack(mergeJobOptions(unknown))
+		err = req.Send()
+	} else {
+		resp, err = p.c.CreateJob(&params)
 	}
-	resp, err := p.c.CreateJob(&params)
 	if err != nil {
 		return nil, err
 	}
@@ -120,20 +256,223 @@ func (p *awsProvider) Transcode(job *db.Job, transcodeProfile provider.Transcode
 	}, nil
 }
 
-func (p *awsProvider) normalizeSource(source string) string {
-	if s3Pattern.MatchString(source) {
-		source = strings.Replace(source, "s3://", "", 1)
-		parts := strings.SplitN(source, "/", 2)
-		return parts[len(parts)-1]
+// mergeJobOptions returns a build handler that merges the given provider
+// options, which CreateJobInput doesn't include, into the request body.
+func mergeJobOptions(options map[string]interface{}) func(*request.Request) {
+	return func(r *request.Request) {
+		if r.Error != nil {
+			return
+		}
+		if _, r.Error = r.Body.Seek(r.BodyStart, io.SeekStart); r.Error != nil {
+			return
+		}
+		data, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			r.Error = err
+			return
+		}
+		if data, r.Error = provider.MergeOptions(data, options); r.Error != nil {
+			return
+		}
+		r.SetBufferBody(data)
+	}
+}
+
+// hlsContentProtection returns the encryption settings of HLS playlists.
+// Elastic Transcoder only accepts keys encrypted with KMS, along with the MD5
+// digest of the plain key and the initialization vector of the segments.
+func (p *awsProvider) hlsContentProtection(encryption *provider.Encryption) (*elastictranscoder.HlsContentProtection, error) {
+	if encryption.Method != "aes-128" {
+		return nil, fmt.Errorf("encryption method %q is not supported by Elastic Transcoder", encryption.Method)
+	}
+	key, err := base64.StdEncoding.DecodeString(encryption.Key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption key: %s", err)
+	}
+	if len(key) != encryptionBlockSize {
+		return nil, fmt.Errorf("invalid encryption key: aes-128 keys must have %d bytes", encryptionBlockSize)
+	}
+	keyID, err := p.kmsKeyID()
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := p.kms.Encrypt(&kms.EncryptInput{
+		KeyId:             aws.String(keyID),
+		Plaintext:         key,
+		EncryptionContext: map[string]*string{"service": aws.String(kmsEncryptionContext)},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to encrypt the key with KMS: %s", err)
+	}
+	iv := make([]byte, encryptionBlockSize)
+	if _, err = rand.Read(iv); err != nil {
+		return nil, err
+	}
+	keyMD5 := md5.Sum(key)
+	return &elastictranscoder.HlsContentProtection{
+		Method:                aws.String(encryption.Method),
+		Key:                   aws.String(base64.StdEncoding.EncodeToString(encrypted.CiphertextBlob)),
+		KeyMd5:                aws.String(base64.StdEncoding.EncodeToString(keyMD5[:])),
+		InitializationVector:  aws.String(base64.StdEncoding.EncodeToString(iv)),
+		KeyStoragePolicy:      aws.String(keyStoragePolicy),
+		LicenseAcquisitionUrl: aws.String(encryption.KeyURI),
+	}, nil
+}
+
+// kmsKeyID returns the KMS key of the pipeline.
+func (p *awsProvider) kmsKeyID() (string, error) {
+	pipeline, err := p.c.ReadPipeline(&elastictranscoder.ReadPipelineInput{
+		Id: aws.String(p.config.PipelineID),
+	})
+	if err != nil {
+		return "", err
+	}
+	if keyID := aws.StringValue(pipeline.Pipeline.AwsKmsKeyArn); keyID != "" {
+		return keyID, nil
 	}
-	return source
+	return defaultKMSKeyID, nil
+}
+
+// mainPlaylistFormat returns the format of the playlist that matches the
+// protocol of the job. Elastic Transcoder requires unique playlist names, so
+// this is the only playlist named after the playlist file name in jobs that
+// generate playlists in multiple formats.
+func (p *awsProvider) mainPlaylistFormat(params provider.StreamingParams) string {
+	if params.Protocol == "dash" {
+		return dashPlayList
+	}
+	return hlsPlayList
 }
 
-func (p *awsProvider) outputKey(job *db.Job, fileName string, adaptive bool) *string {
+// buildCaptions returns the captions settings of the output that carries the
+// caption sidecars of the job. Elastic Transcoder only accepts WebVTT sidecars
+// in adaptive streaming outputs, while other outputs get sidecars in the
+// formats of the caption sources.
+func (p *awsProvider) buildCaptions(keyPrefix string, captions []provider.Caption, adaptive bool) (*elastictranscoder.Captions, error) {
+	var formats []string
 	if adaptive {
-		fileName = strings.TrimRight(fileName, filepath.Ext(fileName))
+		formats = append(formats, "webvtt")
 	}
-	return aws.String(job.ID + "/" + fileName)
+	sources := make([]*elastictranscoder.CaptionSource, len(captions))
+	for i, caption := range captions {
+		key, err := p.inputKey(caption.Source)
+		if err != nil {
+			return nil, err
+		}
+		sources[i] = &elastictranscoder.CaptionSource{
+			Key:      aws.String(key),
+			Language: aws.String(caption.Language),
+		}
+		if caption.Label != "" {
+			sources[i].Label = aws.String(caption.Label)
+		}
+		if adaptive {
+			continue
+		}
+		var found bool
+		for _, format := range formats {
+			if format == caption.Format {
+				found = true
+				break
+			}
+		}
+		if !found {
+			formats = append(formats, caption.Format)
+		}
+	}
+	captionFormats := make([]*elastictranscoder.CaptionFormat, len(formats))
+	for i, format := range formats {
+		captionFormats[i] = &elastictranscoder.CaptionFormat{
+			Format:  aws.String(format),
+			Pattern: aws.String(keyPrefix + captionPattern),
+		}
+	}
+	return &elastictranscoder.Captions{
+		MergePolicy:    aws.String(captionMergePolicy),
+		CaptionSources: sources,
+		CaptionFormats: captionFormats,
+	}, nil
+}
+
+func (p *awsProvider) hasOverlay(preset *elastictranscoder.Preset) bool {
+	if preset.Video == nil {
+		return false
+	}
+	for _, watermark := range preset.Video.Watermarks {
+		if aws.StringValue(watermark.Id) == overlayWatermarkID {
+			return true
+		}
+	}
+	return false
+}
+
+// inputKey returns the key of the given file in the input bucket of the
+// pipeline. Files may be referenced either by their S3 URL or by their key.
+func (p *awsProvider) inputKey(source string) (string, error) {
+	loc, err := mediaurl.Parse(source)
+	if err == mediaurl.ErrMissingScheme {
+		return source, nil
+	}
+	if err == nil {
+		s3Loc, ok := loc.S3()
+		if ok {
+			return s3Loc.Path, nil
+		}
+		err = loc.Require(mediaurl.S3)
+	}
+	return "", fmt.Errorf("invalid source %q: %s", source, err)
+}
+
+// outputKeyPrefix returns the prefix of the keys of the outputs of the job
+// in the output bucket of the pipeline. Jobs with a custom destination must
+// write to the output bucket of the pipeline, as Elastic Transcoder doesn't
+// support overriding it.
+func (p *awsProvider) outputKeyPrefix(job *db.Job) (string, error) {
+	var parts []string
+	if job.Destination != "" {
+		bucket, prefix, err := p.parseDestination(job.Destination)
+		if err != nil {
+			return "", err
+		}
+		pipeline, err := p.c.ReadPipeline(&elastictranscoder.ReadPipelineInput{
+			Id: aws.String(p.config.PipelineID),
+		})
+		if err != nil {
+			return "", err
+		}
+		if outputBucket := aws.StringValue(pipeline.Pipeline.OutputBucket); bucket != outputBucket {
+			return "", fmt.Errorf("invalid destination %q: Elastic Transcoder can only write to the output bucket of the pipeline (%q)", job.Destination, outputBucket)
+		}
+		if prefix != "" {
+			parts = append(parts, prefix)
+		}
+	}
+	if outputDir := job.OutputDirectory(); outputDir != "" {
+		parts = append(parts, outputDir)
+	}
+	if len(parts) == 0 {
+		return "", nil
+	}
+	return strings.Join(parts, "/") + "/", nil
+}
+
+func (p *awsProvider) parseDestination(destination string) (bucket, prefix string, err error) {
+	loc, err := mediaurl.Parse(destination)
+	if err == nil {
+		s3Loc, ok := loc.S3()
+		if ok {
+			return s3Loc.Host, strings.Trim(s3Loc.Path, "/"), nil
+		}
+		err = loc.Require(mediaurl.S3)
+	}
+	return "", "", fmt.Errorf("invalid destination %q: %s", destination, err)
+}
+
+func (p *awsProvider) outputKey(keyPrefix, fileName string, adaptive bool) *string {
+	if adaptive {
+		fileName = strings.TrimSuffix(fileName, filepath.Ext(fileName))
+	}
+	return aws.String(keyPrefix + fileName)
 }
 
 func (p *awsProvider) createVideoPreset(preset db.Preset) *elastictranscoder.VideoParameters {
@@ -147,9 +486,15 @@ func (p *awsProvider) createVideoPreset(preset db.Preset) *elastictranscoder.Vid
 		CodecOptions: map[string]*string{
 			"Profile":            aws.String(strings.ToLower(preset.Profile)),
 			"Level":              &preset.ProfileLevel,
-			"MaxReferenceFrames": aws.String("2"),
+			"MaxReferenceFrames": aws.String(defaultMaxReferenceFrames),
 		},
 	}
+	if preset.Video.FrameRate != "" {
+		videoPreset.FrameRate = &preset.Video.FrameRate
+	}
+	if preset.Video.ReferenceFrames != "" {
+		videoPreset.CodecOptions["MaxReferenceFrames"] = &preset.Video.ReferenceFrames
+	}
 	if preset.Video.Width != "" {
 		videoPreset.MaxWidth = &preset.Video.Width
 	} else {
@@ -163,23 +508,83 @@ func (p *awsProvider) createVideoPreset(preset db.Preset) *elastictranscoder.Vid
 	normalizedVideoBitRate, _ := strconv.Atoi(preset.Video.Bitrate)
 	videoBitrate := strconv.Itoa(normalizedVideoBitRate / 1000)
 	videoPreset.BitRate = &videoBitrate
+	videoPreset.Codec = aws.String(videoCodecs.ProviderCodec(preset.Video.Codec))
 	switch preset.Video.Codec {
-	case "h264":
-		videoPreset.Codec = aws.String("H.264")
 	case "vp8", "vp9":
-		videoPreset.Codec = aws.String(preset.Video.Codec)
 		delete(videoPreset.CodecOptions, "MaxReferenceFrames")
 		delete(videoPreset.CodecOptions, "Level")
 		// Recommended profile value is zero, based on:
 		// http://www.webmproject.org/docs/encoder-parameters/
 		videoPreset.CodecOptions["Profile"] = aws.String("0")
 	}
-	if preset.Video.GopMode == "fixed" {
+	if preset.Video.MaxBitrate != "" {
+		videoPreset.CodecOptions["MaxBitRate"] = aws.String(p.kbps(preset.Video.MaxBitrate))
+	}
+	if preset.Video.BufferSize != "" {
+		videoPreset.CodecOptions["BufferSize"] = aws.String(p.kbps(preset.Video.BufferSize))
+	}
+	if fixedGOP(preset) {
 		videoPreset.FixedGOP = aws.String("true")
 	}
+	for name, value := range preset.ProviderOptions[Name] {
+		videoPreset.CodecOptions[name] = aws.String(provider.OptionString(value))
+	}
 	return &videoPreset
 }
 
+// fixedGOP returns whether the preset uses a fixed GOP in Elastic
+// Transcoder, which is used both for the fixed GOP mode and for aligning
+// keyframes.
+func fixedGOP(preset db.Preset) bool {
+	return preset.Video.GopMode == "fixed" || preset.Video.AlignKeyframes
+}
+
+// kbps converts the given value in bits to kilobits, as used by Elastic
+// Transcoder.
+func (p *awsProvider) kbps(bits string) string {
+	value, _ := strconv.Atoi(bits)
+	return strconv.Itoa(value / 1000)
+}
+
+func (p *awsProvider) createWatermarkPreset(overlay db.OverlayPreset) (*elastictranscoder.PresetWatermark, error) {
+	if overlay.StartTime != "" || overlay.EndTime != "" {
+		return nil, errors.New("overlay time range is not supported by Elastic Transcoder")
+	}
+	watermark := elastictranscoder.PresetWatermark{
+		Id:           aws.String(overlayWatermarkID),
+		MaxWidth:     aws.String("100%"),
+		MaxHeight:    aws.String("100%"),
+		SizingPolicy: aws.String("ShrinkToFit"),
+		Target:       aws.String("Content"),
+		Opacity:      aws.String("100"),
+	}
+	switch overlay.Position {
+	case "top-left":
+		watermark.VerticalAlign, watermark.HorizontalAlign = aws.String("Top"), aws.String("Left")
+	case "top-right":
+		watermark.VerticalAlign, watermark.HorizontalAlign = aws.String("Top"), aws.String("Right")
+	case "bottom-left":
+		watermark.VerticalAlign, watermark.HorizontalAlign = aws.String("Bottom"), aws.String("Left")
+	case "bottom-right":
+		watermark.VerticalAlign, watermark.HorizontalAlign = aws.String("Bottom"), aws.String("Right")
+	default:
+		return nil, fmt.Errorf("invalid overlay position: %q", overlay.Position)
+	}
+	watermark.HorizontalOffset = aws.String(p.overlayOffset(overlay.OffsetX))
+	watermark.VerticalOffset = aws.String(p.overlayOffset(overlay.OffsetY))
+	if overlay.Opacity != "" {
+		watermark.Opacity = aws.String(overlay.Opacity)
+	}
+	return &watermark, nil
+}
+
+func (p *awsProvider) overlayOffset(offset string) string {
+	if offset == "" {
+		offset = "0"
+	}
+	return offset + "px"
+}
+
 func (p *awsProvider) createThumbsPreset(preset db.Preset) *elastictranscoder.Thumbnails {
 	thumbsPreset := &elastictranscoder.Thumbnails{
 		PaddingPolicy: aws.String("Pad"),
@@ -198,6 +603,12 @@ func (p *awsProvider) createAudioPreset(preset db.Preset) *elastictranscoder.Aud
 		Channels:   aws.String("auto"),
 		SampleRate: aws.String("auto"),
 	}
+	if preset.Audio.Channels != "" {
+		audioPreset.Channels = &preset.Audio.Channels
+	}
+	if preset.Audio.SampleRate != "" {
+		audioPreset.SampleRate = &preset.Audio.SampleRate
+	}
 
 	normalizedAudioBitRate, _ := strconv.Atoi(preset.Audio.Bitrate)
 	audioBitrate := strconv.Itoa(normalizedAudioBitRate / 1000)
@@ -214,16 +625,34 @@ func (p *awsProvider) createAudioPreset(preset db.Preset) *elastictranscoder.Aud
 }
 
 func (p *awsProvider) CreatePreset(preset db.Preset) (string, error) {
+	err := provider.CheckVideoCodec(Name, p.Capabilities(), preset)
+	if err != nil {
+		return "", err
+	}
+	err = provider.CheckPresetFields(Name, preset, "video.bFrames", "video.twoPass")
+	if err != nil {
+		return "", err
+	}
 	presetInput := elastictranscoder.CreatePresetInput{
 		Name:        &preset.Name,
 		Description: &preset.Description,
 	}
-	if preset.Container == "m3u8" {
+	switch preset.Container {
+	case "m3u8":
 		presetInput.Container = aws.String("ts")
-	} else {
+	case "mpd":
+		presetInput.Container = aws.String("fmp4")
+	default:
 		presetInput.Container = &preset.Container
 	}
 	presetInput.Video = p.createVideoPreset(preset)
+	if preset.Overlay.Enabled() {
+		watermark, err := p.createWatermarkPreset(preset.Overlay)
+		if err != nil {
+			return "", err
+		}
+		presetInput.Video.Watermarks = []*elastictranscoder.PresetWatermark{watermark}
+	}
 	presetInput.Audio = p.createAudioPreset(preset)
 	presetInput.Thumbnails = p.createThumbsPreset(preset)
 	presetOutput, err := p.c.CreatePreset(&presetInput)
@@ -244,6 +673,113 @@ func (p *awsProvider) GetPreset(presetID string) (interface{}, error) {
 	return readPresetOutput, err
 }
 
+func (p *awsProvider) GetNormalizedPreset(presetID string) (*db.Preset, error) {
+	readPresetOutput, err := p.c.ReadPreset(&elastictranscoder.ReadPresetInput{
+		Id: aws.String(presetID),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return p.normalizePreset(readPresetOutput.Preset), nil
+}
+
+// normalizePreset translates an Elastic Transcoder preset back into a
+// db.Preset, reverting the conversions made by CreatePreset.
+func (p *awsProvider) normalizePreset(etPreset *elastictranscoder.Preset) *db.Preset {
+	preset := db.Preset{
+		Name:        aws.StringValue(etPreset.Name),
+		Description: aws.StringValue(etPreset.Description),
+		Container:   aws.StringValue(etPreset.Container),
+	}
+	switch preset.Container {
+	case "ts":
+		preset.Container = "m3u8"
+	case "fmp4":
+		preset.Container = "mpd"
+	}
+	if video := etPreset.Video; video != nil {
+		preset.Video.Codec = strings.ToLower(videoCodecs.PresetCodec(aws.StringValue(video.Codec)))
+		if preset.Video.Codec == "h264" {
+			preset.Profile = aws.StringValue(video.CodecOptions["Profile"])
+			preset.ProfileLevel = aws.StringValue(video.CodecOptions["Level"])
+			if refs := aws.StringValue(video.CodecOptions["MaxReferenceFrames"]); refs != defaultMaxReferenceFrames {
+				preset.Video.ReferenceFrames = refs
+			}
+		}
+		preset.Video.Width = p.normalizeAuto(video.MaxWidth)
+		preset.Video.Height = p.normalizeAuto(video.MaxHeight)
+		preset.Video.FrameRate = p.normalizeAuto(video.FrameRate)
+		preset.Video.Bitrate = p.normalizeBitrate(video.BitRate)
+		preset.Video.MaxBitrate = p.normalizeBitrate(video.CodecOptions["MaxBitRate"])
+		preset.Video.BufferSize = p.normalizeBitrate(video.CodecOptions["BufferSize"])
+		preset.Video.GopSize = aws.StringValue(video.KeyframesMaxDist)
+		if aws.StringValue(video.FixedGOP) == "true" {
+			preset.Video.GopMode = "fixed"
+		}
+		for _, watermark := range video.Watermarks {
+			if aws.StringValue(watermark.Id) == overlayWatermarkID {
+				preset.Overlay = p.normalizeWatermark(watermark)
+			}
+		}
+	}
+	if audio := etPreset.Audio; audio != nil {
+		preset.Audio.Codec = strings.ToLower(aws.StringValue(audio.Codec))
+		if preset.Audio.Codec == "vorbis" {
+			preset.Audio.Codec = "libvorbis"
+		}
+		preset.Audio.Bitrate = p.normalizeBitrate(audio.BitRate)
+		preset.Audio.Channels = p.normalizeAuto(audio.Channels)
+		preset.Audio.SampleRate = p.normalizeAuto(audio.SampleRate)
+	}
+	return &preset
+}
+
+// NormalizePreset returns the preset as it's read back from Elastic
+// Transcoder: fixed GOPs are read as the fixed GOP mode, whether they were
+// defined by the GOP mode or by aligning keyframes, and codec options are
+// only read for H.264, without the default number of reference frames.
+func (p *awsProvider) NormalizePreset(preset db.Preset) db.Preset {
+	gopMode := ""
+	if fixedGOP(preset) {
+		gopMode = "fixed"
+	}
+	preset.Video.GopMode, preset.Video.AlignKeyframes = gopMode, false
+	if preset.Video.Codec != "h264" {
+		preset.Profile, preset.ProfileLevel, preset.Video.ReferenceFrames = "", "", ""
+	} else if preset.Video.ReferenceFrames == defaultMaxReferenceFrames {
+		preset.Video.ReferenceFrames = ""
+	}
+	return preset
+}
+
+func (p *awsProvider) normalizeWatermark(watermark *elastictranscoder.PresetWatermark) db.OverlayPreset {
+	return db.OverlayPreset{
+		Position: strings.ToLower(aws.StringValue(watermark.VerticalAlign) + "-" + aws.StringValue(watermark.HorizontalAlign)),
+		OffsetX:  strings.TrimSuffix(aws.StringValue(watermark.HorizontalOffset), "px"),
+		OffsetY:  strings.TrimSuffix(aws.StringValue(watermark.VerticalOffset), "px"),
+		Opacity:  aws.StringValue(watermark.Opacity),
+	}
+}
+
+// normalizeAuto returns the given value, or an empty string for
+// values that are automatically defined based on the source.
+func (p *awsProvider) normalizeAuto(value *string) string {
+	if v := aws.StringValue(value); v != "auto" {
+		return v
+	}
+	return ""
+}
+
+// normalizeBitrate converts bitrates in kbps, as used by Elastic
+// Transcoder, to bps.
+func (p *awsProvider) normalizeBitrate(bitrate *string) string {
+	kbps, err := strconv.Atoi(aws.StringValue(bitrate))
+	if err != nil || kbps == 0 {
+		return ""
+	}
+	return strconv.Itoa(kbps * 1000)
+}
+
 func (p *awsProvider) DeletePreset(presetID string) error {
 	presetInput := elastictranscoder.DeletePresetInput{
 		Id: &presetID,
@@ -277,6 +813,10 @@ func (p *awsProvider) JobStatus(job *db.Job) (*provider.JobStatus, error) {
 	if err != nil {
 		return nil, err
 	}
+	captionFiles, err := p.getCaptionFiles(resp.Job)
+	if err != nil {
+		return nil, err
+	}
 	var sourceInfo provider.SourceInfo
 	if resp.Job.Input.DetectedProperties != nil {
 		sourceInfo = provider.SourceInfo{
@@ -294,21 +834,26 @@ func (p *awsProvider) JobStatus(job *db.Job) (*provider.JobStatus, error) {
 		Output: provider.JobOutput{
 			Destination: outputDestination,
 			Files:       outputFiles,
+			Captions:    captionFiles,
 		},
 	}, nil
 }
 
 func (p *awsProvider) getOutputDestination(job *db.Job, awsJob *elastictranscoder.Job) (string, error) {
-	readPipelineOutput, err := p.c.ReadPipeline(&elastictranscoder.ReadPipelineInput{
-		Id: awsJob.PipelineId,
-	})
-	if err != nil {
-		return "", err
+	destination := strings.TrimRight(job.Destination, "/")
+	if destination == "" {
+		readPipelineOutput, err := p.c.ReadPipeline(&elastictranscoder.ReadPipelineInput{
+			Id: awsJob.PipelineId,
+		})
+		if err != nil {
+			return "", err
+		}
+		destination = "s3://" + aws.StringValue(readPipelineOutput.Pipeline.OutputBucket)
+	}
+	if outputDir := job.OutputDirectory(); outputDir != "" {
+		destination += "/" + outputDir
 	}
-	return fmt.Sprintf("s3://%s/%s",
-		aws.StringValue(readPipelineOutput.Pipeline.OutputBucket),
-		job.ID,
-	), nil
+	return destination, nil
 }
 
 func (p *awsProvider) getOutputFiles(job *elastictranscoder.Job) ([]provider.OutputFile, error) {
@@ -331,8 +876,10 @@ func (p *awsProvider) getOutputFiles(job *elastictranscoder.Job) ([]provider.Out
 			aws.StringValue(job.OutputKeyPrefix),
 			aws.StringValue(output.Key),
 		)
+		// segments of adaptive streaming outputs are listed through
+		// their playlists.
 		container := aws.StringValue(preset.Preset.Container)
-		if container == "ts" {
+		if _, ok := streamingPlaylists[container]; ok {
 			continue
 		}
 		file := provider.OutputFile{
@@ -345,12 +892,54 @@ func (p *awsProvider) getOutputFiles(job *elastictranscoder.Job) ([]provider.Out
 		files = append(files, file)
 	}
 	for _, playlist := range job.Playlists {
-		filePath := fmt.Sprintf("s3://%s/%s%s",
+		extension, ok := playlistExtensions[aws.StringValue(playlist.Format)]
+		if !ok {
+			continue
+		}
+		filePath := fmt.Sprintf("s3://%s/%s%s.%s",
 			aws.StringValue(pipeline.Pipeline.OutputBucket),
 			aws.StringValue(job.OutputKeyPrefix),
-			aws.StringValue(playlist.Name)+".m3u8",
+			aws.StringValue(playlist.Name),
+			extension,
 		)
-		files = append(files, provider.OutputFile{Path: filePath, Container: "m3u8"})
+		files = append(files, provider.OutputFile{Path: filePath, Container: extension})
+	}
+	return files, nil
+}
+
+func (p *awsProvider) getCaptionFiles(job *elastictranscoder.Job) ([]provider.CaptionFile, error) {
+	var files []provider.CaptionFile
+	var outputBucket string
+	for _, output := range job.Outputs {
+		if output.Captions == nil || len(output.Captions.CaptionFormats) == 0 {
+			continue
+		}
+		if outputBucket == "" {
+			pipeline, err := p.c.ReadPipeline(&elastictranscoder.ReadPipelineInput{
+				Id: job.PipelineId,
+			})
+			if err != nil {
+				return nil, err
+			}
+			outputBucket = aws.StringValue(pipeline.Pipeline.OutputBucket)
+		}
+		for _, captionFormat := range output.Captions.CaptionFormats {
+			format := aws.StringValue(captionFormat.Format)
+			for _, source := range output.Captions.CaptionSources {
+				language := aws.StringValue(source.Language)
+				key := strings.Replace(aws.StringValue(captionFormat.Pattern), "{language}", language, -1)
+				files = append(files, provider.CaptionFile{
+					Path: fmt.Sprintf("s3://%s/%s%s.%s",
+						outputBucket,
+						aws.StringValue(job.OutputKeyPrefix),
+						key,
+						captionExtensions[format],
+					),
+					Format:   format,
+					Language: language,
+				})
+			}
+		}
 	}
 	return files, nil
 }
@@ -384,9 +973,15 @@ func (p *awsProvider) Healthcheck() error {
 
 func (p *awsProvider) Capabilities() provider.Capabilities {
 	return provider.Capabilities{
-		InputFormats:  []string{"h264"},
-		OutputFormats: []string{"mp4", "hls", "webm"},
-		Destinations:  []string{"s3"},
+		InputFormats:         []string{"h264"},
+		OutputFormats:        []string{"mp4", "hls", "dash", "webm"},
+		VideoCodecs:          videoCodecs.Codecs(),
+		Destinations:         []string{"s3"},
+		CaptionFormats:       provider.CaptionFormats,
+		HLSSegmentFormats:    []string{"ts"},
+		HLSEncryptionMethods: []string{"aes-128"},
+
+		UnreportedPresetFields: []string{"rateControl", "video.interlaceMode"},
 	}
 }
 
@@ -402,6 +997,7 @@ func elasticTranscoderFactory(cfg *config.Config) (provider.TranscodingProvider,
 	awsSession := session.New(aws.NewConfig().WithCredentials(creds).WithRegion(region))
 	return &awsProvider{
 		c:      elastictranscoder.New(awsSession),
+		kms:    kms.New(awsSession),
 		config: cfg.ElasticTranscoder,
 	}, nil
 }
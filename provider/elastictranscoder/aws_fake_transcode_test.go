@@ -4,10 +4,17 @@ import (
 	"crypto/rand"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client/metadata"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/private/protocol/restjson"
 	"github.com/aws/aws-sdk-go/service/elastictranscoder"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
 )
 
 type failure struct {
@@ -18,7 +25,9 @@ type failure struct {
 type fakeElasticTranscoder struct {
 	*elastictranscoder.ElasticTranscoder
 	jobs         map[string]*elastictranscoder.CreateJobInput
+	jobBodies    map[string][]byte
 	canceledJobs []elastictranscoder.CancelJobInput
+	kmsKeyArn    string
 	failures     chan failure
 }
 
@@ -27,6 +36,7 @@ func newFakeElasticTranscoder() *fakeElasticTranscoder {
 		ElasticTranscoder: &elastictranscoder.ElasticTranscoder{},
 		failures:          make(chan failure, 1),
 		jobs:              make(map[string]*elastictranscoder.CreateJobInput),
+		jobBodies:         make(map[string][]byte),
 	}
 }
 
@@ -52,6 +62,31 @@ func (c *fakeElasticTranscoder) CreateJob(input *elastictranscoder.CreateJobInpu
 	}, nil
 }
 
+func (c *fakeElasticTranscoder) CreateJobRequest(input *elastictranscoder.CreateJobInput) (*request.Request, *elastictranscoder.CreateJobResponse) {
+	output := &elastictranscoder.CreateJobResponse{}
+	op := request.Operation{Name: "CreateJob", HTTPMethod: "POST", HTTPPath: "/2012-09-25/jobs"}
+	var handlers request.Handlers
+	handlers.Build.PushBack(restjson.Build)
+	handlers.Send.PushBack(func(r *request.Request) {
+		if _, r.Error = r.Body.Seek(r.BodyStart, io.SeekStart); r.Error != nil {
+			return
+		}
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			r.Error = err
+			return
+		}
+		resp, err := c.CreateJob(input)
+		if err != nil {
+			r.Error = err
+			return
+		}
+		c.jobBodies[aws.StringValue(resp.Job.Id)] = body
+		*output = *resp
+	})
+	return request.New(aws.Config{}, metadata.ClientInfo{}, handlers, nil, &op, input, output), output
+}
+
 func (c *fakeElasticTranscoder) CreatePreset(input *elastictranscoder.CreatePresetInput) (*elastictranscoder.CreatePresetOutput, error) {
 	var presetID = *input.Name + "-abc123"
 	return &elastictranscoder.CreatePresetOutput{
@@ -73,16 +108,23 @@ func (c *fakeElasticTranscoder) ReadPreset(input *elastictranscoder.ReadPresetIn
 	if strings.Contains(*input.Id, "hls") {
 		container = "ts"
 	}
+	if strings.Contains(*input.Id, "dash") {
+		container = "fmp4"
+	}
 	if strings.Contains(*input.Id, "webm") {
 		container = "webm"
 		codec = "VP8"
 	}
+	video := elastictranscoder.VideoParameters{Codec: aws.String(codec)}
+	if strings.Contains(*input.Id, "overlay") {
+		video.Watermarks = []*elastictranscoder.PresetWatermark{{Id: aws.String("overlay")}}
+	}
 	return &elastictranscoder.ReadPresetOutput{
 		Preset: &elastictranscoder.Preset{
 			Id:        input.Id,
 			Name:      input.Id,
 			Container: aws.String(container),
-			Video:     &elastictranscoder.VideoParameters{Codec: aws.String(codec)},
+			Video:     &video,
 		},
 	}, nil
 }
@@ -104,6 +146,7 @@ func (c *fakeElasticTranscoder) ReadJob(input *elastictranscoder.ReadJobInput) (
 			PresetId:     aws.String(fmt.Sprintf("preset-%s", aws.StringValue(createJobOutput.Key))),
 			Width:        aws.Int64(0),
 			Height:       aws.Int64(720),
+			Captions:     createJobOutput.Captions,
 		}
 	}
 	playlists := make([]*elastictranscoder.Playlist, len(createJobInput.Playlists))
@@ -135,6 +178,7 @@ func (c *fakeElasticTranscoder) ReadPipeline(input *elastictranscoder.ReadPipeli
 			Id:           input.Id,
 			Name:         aws.String("nice pipeline"),
 			OutputBucket: aws.String("some bucket"),
+			AwsKmsKeyArn: aws.String(c.kmsKeyArn),
 		},
 	}, nil
 }
@@ -168,3 +212,20 @@ func generateID() []byte {
 	rand.Read(b[:])
 	return b[:]
 }
+
+type fakeKMS struct {
+	kmsiface.KMSAPI
+	encryptInputs []kms.EncryptInput
+	err           error
+}
+
+func (c *fakeKMS) Encrypt(input *kms.EncryptInput) (*kms.EncryptOutput, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	c.encryptInputs = append(c.encryptInputs, *input)
+	return &kms.EncryptOutput{
+		CiphertextBlob: append([]byte("encrypted:"), input.Plaintext...),
+		KeyId:          input.KeyId,
+	}, nil
+}
@@ -1,6 +1,8 @@
 package elastictranscoder
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"os"
 	"reflect"
@@ -14,6 +16,7 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/service/elastictranscoder"
+	"github.com/aws/aws-sdk-go/service/kms"
 	"github.com/kr/pretty"
 )
 
@@ -57,6 +60,9 @@ func TestElasticTranscoderProvider(t *testing.T) {
 	if region != cfg.ElasticTranscoder.Region {
 		t.Errorf("ElasticTranscoderProvider: wrong region. Want %q. Got %q.", cfg.ElasticTranscoder.Region, region)
 	}
+	if kmsRegion := *elasticProvider.kms.(*kms.KMS).Config.Region; kmsRegion != cfg.ElasticTranscoder.Region {
+		t.Errorf("ElasticTranscoderProvider: wrong KMS region. Want %q. Got %q.", cfg.ElasticTranscoder.Region, kmsRegion)
+	}
 }
 
 func TestElasticTranscoderProviderDefaultRegion(t *testing.T) {
@@ -326,6 +332,335 @@ func TestAWSTranscodeAdaptiveStreaming(t *testing.T) {
 	}
 }
 
+func TestAWSTranscodeDASH(t *testing.T) {
+	fakeTranscoder := newFakeElasticTranscoder()
+	prov := &awsProvider{
+		c: fakeTranscoder,
+		config: &config.ElasticTranscoder{
+			AccessKeyID:     "AKIA",
+			SecretAccessKey: "secret",
+			Region:          "sa-east-1",
+			PipelineID:      "mypipeline",
+		},
+	}
+	outputs := []provider.TranscodeOutput{
+		{
+			FileName: "dash/video_360p.mpd",
+			Preset: db.PresetMap{
+				Name:            "dash_360p",
+				ProviderMapping: map[string]string{Name: "93239832-0001-dash"},
+				OutputOpts:      db.OutputOptions{Extension: "mpd"},
+			},
+		},
+		{
+			FileName: "dash/video_720p.mpd",
+			Preset: db.PresetMap{
+				Name:            "dash_720p",
+				ProviderMapping: map[string]string{Name: "93239832-0002-dash"},
+				OutputOpts:      db.OutputOptions{Extension: "mpd"},
+			},
+		},
+		{
+			FileName: "video_720p.mp4",
+			Preset: db.PresetMap{
+				Name:            "mp4_720p",
+				ProviderMapping: map[string]string{Name: "93239832-0003"},
+				OutputOpts:      db.OutputOptions{Extension: "mp4"},
+			},
+		},
+	}
+	transcodeProfile := provider.TranscodeProfile{
+		SourceMedia: "dir/file.mov",
+		Outputs:     outputs,
+		StreamingParams: provider.StreamingParams{
+			PlaylistFileName: "dash/index.mpd",
+			Protocol:         "dash",
+			SegmentDuration:  3,
+		},
+	}
+	jobStatus, err := prov.Transcode(&db.Job{ID: "job-123"}, transcodeProfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobInput := fakeTranscoder.jobs[jobStatus.ProviderJobID]
+	expectedOutputs := []*elastictranscoder.CreateJobOutput{
+		{PresetId: aws.String("93239832-0001-dash"), Key: aws.String("job-123/dash/video_360p"), SegmentDuration: aws.String("3")},
+		{PresetId: aws.String("93239832-0002-dash"), Key: aws.String("job-123/dash/video_720p"), SegmentDuration: aws.String("3")},
+		{PresetId: aws.String("93239832-0003"), Key: aws.String("job-123/video_720p.mp4")},
+	}
+	if !reflect.DeepEqual(jobInput.Outputs, expectedOutputs) {
+		t.Errorf("Elastic Transcoder: wrong outputs\nWant %#v\nGot  %#v", expectedOutputs, jobInput.Outputs)
+	}
+	expectedPlaylists := []*elastictranscoder.CreateJobPlaylist{
+		{
+			Format: aws.String("MPEG-DASH"),
+			Name:   aws.String("job-123/dash/index"),
+			OutputKeys: []*string{
+				aws.String("job-123/dash/video_360p"),
+				aws.String("job-123/dash/video_720p"),
+			},
+		},
+	}
+	if !reflect.DeepEqual(jobInput.Playlists, expectedPlaylists) {
+		t.Errorf("Elastic Transcoder: wrong playlists\nWant %#v\nGot  %#v", expectedPlaylists, jobInput.Playlists)
+	}
+}
+
+func TestAWSTranscodeHLSAndDASH(t *testing.T) {
+	fakeTranscoder := newFakeElasticTranscoder()
+	prov := &awsProvider{
+		c: fakeTranscoder,
+		config: &config.ElasticTranscoder{
+			AccessKeyID:     "AKIA",
+			SecretAccessKey: "secret",
+			Region:          "sa-east-1",
+			PipelineID:      "mypipeline",
+		},
+	}
+	outputs := []provider.TranscodeOutput{
+		{
+			FileName: "hls/video_360p.m3u8",
+			Preset: db.PresetMap{
+				Name:            "hls_360p",
+				ProviderMapping: map[string]string{Name: "93239832-0001-hls"},
+				OutputOpts:      db.OutputOptions{Extension: "m3u8"},
+			},
+		},
+		{
+			FileName: "dash/video_360p.mpd",
+			Preset: db.PresetMap{
+				Name:            "dash_360p",
+				ProviderMapping: map[string]string{Name: "93239832-0002-dash"},
+				OutputOpts:      db.OutputOptions{Extension: "mpd"},
+			},
+		},
+	}
+	transcodeProfile := provider.TranscodeProfile{
+		SourceMedia: "dir/file.mov",
+		Outputs:     outputs,
+		StreamingParams: provider.StreamingParams{
+			PlaylistFileName: "dash/index.mpd",
+			Protocol:         "dash",
+			SegmentDuration:  3,
+		},
+	}
+	jobStatus, err := prov.Transcode(&db.Job{ID: "job-123"}, transcodeProfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobInput := fakeTranscoder.jobs[jobStatus.ProviderJobID]
+	expectedPlaylists := []*elastictranscoder.CreateJobPlaylist{
+		{
+			Format:     aws.String("HLSv3"),
+			Name:       aws.String("job-123/dash/index-hls"),
+			OutputKeys: []*string{aws.String("job-123/hls/video_360p")},
+		},
+		{
+			Format:     aws.String("MPEG-DASH"),
+			Name:       aws.String("job-123/dash/index"),
+			OutputKeys: []*string{aws.String("job-123/dash/video_360p")},
+		},
+	}
+	if !reflect.DeepEqual(jobInput.Playlists, expectedPlaylists) {
+		t.Errorf("Elastic Transcoder: wrong playlists\nWant %#v\nGot  %#v", expectedPlaylists, jobInput.Playlists)
+	}
+	jobStatus, err = prov.JobStatus(&db.Job{ID: "job-123", ProviderJobID: jobStatus.ProviderJobID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedFiles := []provider.OutputFile{
+		{Path: "s3://some bucket/job-123/dash/index-hls.m3u8", Container: "m3u8"},
+		{Path: "s3://some bucket/job-123/dash/index.mpd", Container: "mpd"},
+	}
+	if !reflect.DeepEqual(jobStatus.Output.Files, expectedFiles) {
+		t.Errorf("Elastic Transcoder: wrong output files\nWant %#v\nGot  %#v", expectedFiles, jobStatus.Output.Files)
+	}
+}
+
+func TestAWSTranscodeHLSFragmentedMP4NotSupported(t *testing.T) {
+	fakeTranscoder := newFakeElasticTranscoder()
+	prov := &awsProvider{
+		c: fakeTranscoder,
+		config: &config.ElasticTranscoder{
+			AccessKeyID:     "AKIA",
+			SecretAccessKey: "secret",
+			Region:          "sa-east-1",
+			PipelineID:      "mypipeline",
+		},
+	}
+	outputs := []provider.TranscodeOutput{
+		{
+			FileName: "hls/video_360p.m3u8",
+			Preset: db.PresetMap{
+				Name:            "cmaf_360p",
+				ProviderMapping: map[string]string{Name: "93239832-0001-dash"},
+				OutputOpts:      db.OutputOptions{Extension: "m3u8"},
+			},
+		},
+		{
+			FileName: "hls/video_720p.m3u8",
+			Preset: db.PresetMap{
+				Name:            "cmaf_720p",
+				ProviderMapping: map[string]string{Name: "93239832-0002-dash"},
+				OutputOpts:      db.OutputOptions{Extension: "m3u8"},
+			},
+		},
+	}
+	transcodeProfile := provider.TranscodeProfile{
+		SourceMedia: "dir/file.mov",
+		Outputs:     outputs,
+		StreamingParams: provider.StreamingParams{
+			PlaylistFileName: "hls/index.m3u8",
+			Protocol:         "hls",
+			SegmentFormat:    "fmp4",
+			SegmentDuration:  3,
+		},
+	}
+	jobStatus, err := prov.Transcode(&db.Job{ID: "job-123"}, transcodeProfile)
+	expectedErr := `segment format "fmp4" is not supported by Elastic Transcoder`
+	if err == nil || err.Error() != expectedErr {
+		t.Errorf("Elastic Transcoder: wrong error returned. Want %q. Got %v", expectedErr, err)
+	}
+	if jobStatus != nil {
+		t.Errorf("Elastic Transcoder: got unexpected non-nil job status: %#v", jobStatus)
+	}
+	if len(fakeTranscoder.jobs) > 0 {
+		t.Errorf("Elastic Transcoder: unexpected jobs created: %#v", fakeTranscoder.jobs)
+	}
+}
+
+func TestAWSTranscodeEncryption(t *testing.T) {
+	var tests = []struct {
+		name          string
+		kmsKeyArn     string
+		expectedKeyID string
+	}{
+		{"pipeline key", "arn:aws:kms:sa-east-1:123456789012:key/abcd", "arn:aws:kms:sa-east-1:123456789012:key/abcd"},
+		{"default key", "", "alias/aws/elastictranscoder"},
+	}
+	for _, test := range tests {
+		fakeTranscoder := newFakeElasticTranscoder()
+		fakeTranscoder.kmsKeyArn = test.kmsKeyArn
+		fakeKMS := &fakeKMS{}
+		prov := &awsProvider{
+			c:   fakeTranscoder,
+			kms: fakeKMS,
+			config: &config.ElasticTranscoder{
+				AccessKeyID:     "AKIA",
+				SecretAccessKey: "secret",
+				Region:          "sa-east-1",
+				PipelineID:      "mypipeline",
+			},
+		}
+		outputs := []provider.TranscodeOutput{
+			{
+				FileName: "hls/video_360p.m3u8",
+				Preset: db.PresetMap{
+					Name:            "hls_360p",
+					ProviderMapping: map[string]string{Name: "93239832-0001-hls"},
+					OutputOpts:      db.OutputOptions{Extension: "m3u8"},
+				},
+			},
+		}
+		transcodeProfile := provider.TranscodeProfile{
+			SourceMedia: "dir/file.mov",
+			Outputs:     outputs,
+			StreamingParams: provider.StreamingParams{
+				PlaylistFileName: "hls/index.m3u8",
+				Protocol:         "hls",
+				SegmentDuration:  3,
+				Encryption: &provider.Encryption{
+					Method: "aes-128",
+					Key:    "MDEyMzQ1Njc4OWFiY2RlZg==",
+					KeyURI: "https://keys.example.com/job-123",
+				},
+			},
+		}
+		jobStatus, err := prov.Transcode(&db.Job{ID: "job-123"}, transcodeProfile)
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		expectedEncryptInputs := []kms.EncryptInput{
+			{
+				KeyId:             aws.String(test.expectedKeyID),
+				Plaintext:         []byte("0123456789abcdef"),
+				EncryptionContext: map[string]*string{"service": aws.String("elastictranscoder.amazonaws.com")},
+			},
+		}
+		if !reflect.DeepEqual(fakeKMS.encryptInputs, expectedEncryptInputs) {
+			t.Errorf("%s: wrong KMS requests\nWant %#v\nGot  %#v", test.name, expectedEncryptInputs, fakeKMS.encryptInputs)
+		}
+		jobInput := fakeTranscoder.jobs[jobStatus.ProviderJobID]
+		protection := jobInput.Playlists[0].HlsContentProtection
+		if protection == nil {
+			t.Fatalf("%s: missing content protection", test.name)
+		}
+		iv, err := base64.StdEncoding.DecodeString(aws.StringValue(protection.InitializationVector))
+		if err != nil || len(iv) != 16 {
+			t.Errorf("%s: invalid initialization vector %q", test.name, aws.StringValue(protection.InitializationVector))
+		}
+		expectedProtection := &elastictranscoder.HlsContentProtection{
+			Method:                aws.String("aes-128"),
+			Key:                   aws.String(base64.StdEncoding.EncodeToString([]byte("encrypted:0123456789abcdef"))),
+			KeyMd5:                aws.String("QDKvjWEDUSOQbljgZxQMxQ=="),
+			InitializationVector:  protection.InitializationVector,
+			KeyStoragePolicy:      aws.String("NoStore"),
+			LicenseAcquisitionUrl: aws.String("https://keys.example.com/job-123"),
+		}
+		if !reflect.DeepEqual(protection, expectedProtection) {
+			t.Errorf("%s: wrong content protection\nWant %#v\nGot  %#v", test.name, expectedProtection, protection)
+		}
+	}
+}
+
+func TestAWSTranscodeEncryptionErrors(t *testing.T) {
+	var tests = []struct {
+		name        string
+		method      string
+		key         string
+		kmsErr      error
+		expectedErr string
+	}{
+		{"sample-aes", "sample-aes", "MDEyMzQ1Njc4OWFiY2RlZg==", nil, `encryption method "sample-aes" is not supported by Elastic Transcoder`},
+		{"short key", "aes-128", "MDEyMzQ1Njc=", nil, "invalid encryption key: aes-128 keys must have 16 bytes"},
+		{"kms failure", "aes-128", "MDEyMzQ1Njc4OWFiY2RlZg==", errors.New("access denied"), "unable to encrypt the key with KMS: access denied"},
+	}
+	for _, test := range tests {
+		fakeTranscoder := newFakeElasticTranscoder()
+		prov := &awsProvider{
+			c:      fakeTranscoder,
+			kms:    &fakeKMS{err: test.kmsErr},
+			config: &config.ElasticTranscoder{PipelineID: "mypipeline"},
+		}
+		transcodeProfile := provider.TranscodeProfile{
+			SourceMedia: "dir/file.mov",
+			Outputs: []provider.TranscodeOutput{
+				{
+					FileName: "hls/video_360p.m3u8",
+					Preset: db.PresetMap{
+						Name:            "hls_360p",
+						ProviderMapping: map[string]string{Name: "93239832-0001-hls"},
+						OutputOpts:      db.OutputOptions{Extension: "m3u8"},
+					},
+				},
+			},
+			StreamingParams: provider.StreamingParams{
+				PlaylistFileName: "hls/index.m3u8",
+				Protocol:         "hls",
+				SegmentDuration:  3,
+				Encryption:       &provider.Encryption{Method: test.method, Key: test.key},
+			},
+		}
+		_, err := prov.Transcode(&db.Job{ID: "job-123"}, transcodeProfile)
+		if err == nil || err.Error() != test.expectedErr {
+			t.Errorf("%s: wrong error. Want %q. Got %v", test.name, test.expectedErr, err)
+		}
+		if len(fakeTranscoder.jobs) > 0 {
+			t.Errorf("%s: unexpected jobs created: %#v", test.name, fakeTranscoder.jobs)
+		}
+	}
+}
+
 func TestAWSTranscodeAdaptiveAndNonAdaptiveStreaming(t *testing.T) {
 	fakeTranscoder := newFakeElasticTranscoder()
 	prov := &awsProvider{
@@ -528,6 +863,53 @@ func TestAWSTranscodeNormalizedSource(t *testing.T) {
 	}
 }
 
+func TestAWSTranscodeInvalidSource(t *testing.T) {
+	var tests = []struct {
+		givenSource string
+		expectedErr string
+	}{
+		{
+			"http://nyt.net/video.mov",
+			`invalid source "http://nyt.net/video.mov": unsupported scheme "http". Supported schemes are: s3`,
+		},
+		{
+			"gs://bucketname/video.mov",
+			`invalid source "gs://bucketname/video.mov": unsupported scheme "gs". Supported schemes are: s3`,
+		},
+		{
+			"s3:///video.mov",
+			`invalid source "s3:///video.mov": missing host`,
+		},
+	}
+	for _, test := range tests {
+		fakeTranscoder := newFakeElasticTranscoder()
+		prov := &awsProvider{
+			c:      fakeTranscoder,
+			config: &config.ElasticTranscoder{PipelineID: "mypipeline"},
+		}
+		transcodeProfile := provider.TranscodeProfile{
+			SourceMedia: test.givenSource,
+			Outputs: []provider.TranscodeOutput{
+				{
+					FileName: "output_720p.mp4",
+					Preset: db.PresetMap{
+						Name:            "mp4_720p",
+						ProviderMapping: map[string]string{Name: "93239832-0001"},
+						OutputOpts:      db.OutputOptions{Extension: "mp4"},
+					},
+				},
+			},
+		}
+		_, err := prov.Transcode(&db.Job{ID: "job-1"}, transcodeProfile)
+		if err == nil || err.Error() != test.expectedErr {
+			t.Errorf("%q: wrong error returned. Want %q. Got %v", test.givenSource, test.expectedErr, err)
+		}
+		if len(fakeTranscoder.jobs) != 0 {
+			t.Errorf("%q: unexpected job sent to Elastic Transcoder", test.givenSource)
+		}
+	}
+}
+
 func TestAWSTranscodePresetNotFound(t *testing.T) {
 	fakeTranscoder := newFakeElasticTranscoder()
 	prov := &awsProvider{
@@ -700,7 +1082,7 @@ func TestAWSJobStatus(t *testing.T) {
 	}
 }
 
-func TestAWSJobStatusNoDetectedProperties(t *testing.T) {
+func TestAWSTranscodeCaptions(t *testing.T) {
 	fakeTranscoder := newFakeElasticTranscoder()
 	prov := &awsProvider{
 		c: fakeTranscoder,
@@ -715,77 +1097,68 @@ func TestAWSJobStatusNoDetectedProperties(t *testing.T) {
 		{
 			FileName: "output_720p.mp4",
 			Preset: db.PresetMap{
-				Name: "mp4_720p",
-				ProviderMapping: map[string]string{
-					Name:    "93239832-0001",
-					"other": "irrelevant",
-				},
-				OutputOpts: db.OutputOptions{Extension: "mp4"},
+				Name:            "mp4_720p",
+				ProviderMapping: map[string]string{Name: "93239832-0001"},
+				OutputOpts:      db.OutputOptions{Extension: "mp4"},
 			},
 		},
 		{
-			FileName: "output_720p.webm",
+			FileName: "hls/output_720p.m3u8",
 			Preset: db.PresetMap{
-				Name: "webm_720p",
-				ProviderMapping: map[string]string{
-					Name:    "93239832-0002",
-					"other": "irrelevant",
-				},
-				OutputOpts: db.OutputOptions{Extension: "webm"},
+				Name:            "hls_720p",
+				ProviderMapping: map[string]string{Name: "hls-93239832-0003"},
+				OutputOpts:      db.OutputOptions{Extension: "m3u8"},
 			},
 		},
 	}
-	source := "dir/file.mov"
 	transcodeProfile := provider.TranscodeProfile{
-		SourceMedia:     source,
-		Outputs:         outputs,
-		StreamingParams: provider.StreamingParams{},
+		SourceMedia: "dir/file.mov",
+		Outputs:     outputs,
+		StreamingParams: provider.StreamingParams{
+			PlaylistFileName: "hls/index.m3u8",
+			SegmentDuration:  3,
+			Protocol:         "hls",
+		},
+		Captions: []provider.Caption{
+			{Source: "s3://bucket/captions/en.srt", Format: "srt", Language: "en", Label: "English"},
+			{Source: "s3://bucket/captions/es.vtt", Format: "webvtt", Language: "es"},
+		},
 	}
 	jobStatus, err := prov.Transcode(&db.Job{ID: "job-123"}, transcodeProfile)
 	if err != nil {
 		t.Fatal(err)
 	}
-	fakeTranscoder.jobs[jobStatus.ProviderJobID].Input.DetectedProperties = nil
+	jobInput := fakeTranscoder.jobs[jobStatus.ProviderJobID]
+	if jobInput.Outputs[0].Captions != nil {
+		t.Errorf("Unexpected captions in non-adaptive output: %#v", jobInput.Outputs[0].Captions)
+	}
+	expectedCaptions := &elastictranscoder.Captions{
+		MergePolicy: aws.String("Override"),
+		CaptionSources: []*elastictranscoder.CaptionSource{
+			{Key: aws.String("captions/en.srt"), Language: aws.String("en"), Label: aws.String("English")},
+			{Key: aws.String("captions/es.vtt"), Language: aws.String("es")},
+		},
+		CaptionFormats: []*elastictranscoder.CaptionFormat{
+			{Format: aws.String("webvtt"), Pattern: aws.String("job-123/captions/{language}")},
+		},
+	}
+	if !reflect.DeepEqual(jobInput.Outputs[1].Captions, expectedCaptions) {
+		t.Errorf("Wrong captions\nWant %#v\nGot  %#v", expectedCaptions, jobInput.Outputs[1].Captions)
+	}
 	jobStatus, err = prov.JobStatus(&db.Job{ID: "job-123", ProviderJobID: jobStatus.ProviderJobID})
 	if err != nil {
 		t.Fatal(err)
 	}
-	expectedJobStatus := provider.JobStatus{
-		ProviderJobID: jobStatus.ProviderJobID,
-		Status:        provider.StatusFinished,
-		Progress:      100,
-		ProviderStatus: map[string]interface{}{
-			"outputs": map[string]interface{}{
-				"job-123/output_720p.mp4":  "it's finished!",
-				"job-123/output_720p.webm": "it's finished!",
-			},
-		},
-		Output: provider.JobOutput{
-			Destination: "s3://some bucket/job-123",
-			Files: []provider.OutputFile{
-				{
-					Path:       "s3://some bucket/job-123/output_720p.mp4",
-					Container:  "mp4",
-					VideoCodec: "H.264",
-					Width:      0,
-					Height:     720,
-				},
-				{
-					Path:       "s3://some bucket/job-123/output_720p.webm",
-					Container:  "webm",
-					VideoCodec: "VP8",
-					Width:      0,
-					Height:     720,
-				},
-			},
-		},
+	expectedCaptionFiles := []provider.CaptionFile{
+		{Path: "s3://some bucket/job-123/captions/en.vtt", Format: "webvtt", Language: "en"},
+		{Path: "s3://some bucket/job-123/captions/es.vtt", Format: "webvtt", Language: "es"},
 	}
-	if !reflect.DeepEqual(*jobStatus, expectedJobStatus) {
-		t.Errorf("Wrong JobStatus\nWant %#v\nGot  %#v", expectedJobStatus, *jobStatus)
+	if !reflect.DeepEqual(jobStatus.Output.Captions, expectedCaptionFiles) {
+		t.Errorf("Wrong caption files\nWant %#v\nGot  %#v", expectedCaptionFiles, jobStatus.Output.Captions)
 	}
 }
 
-func TestAWSCreatePreset(t *testing.T) {
+func TestAWSTranscodeCaptionsNoAdaptiveStreaming(t *testing.T) {
 	fakeTranscoder := newFakeElasticTranscoder()
 	prov := &awsProvider{
 		c: fakeTranscoder,
@@ -796,9 +1169,307 @@ func TestAWSCreatePreset(t *testing.T) {
 			PipelineID:      "mypipeline",
 		},
 	}
-
-	inputPreset := db.Preset{
-		Name:         "preset_name",
+	transcodeProfile := provider.TranscodeProfile{
+		SourceMedia: "dir/file.mov",
+		Outputs: []provider.TranscodeOutput{
+			{
+				FileName: "output_720p.mp4",
+				Preset: db.PresetMap{
+					Name:            "mp4_720p",
+					ProviderMapping: map[string]string{Name: "93239832-0001"},
+					OutputOpts:      db.OutputOptions{Extension: "mp4"},
+				},
+			},
+		},
+		Captions: []provider.Caption{
+			{Source: "s3://bucket/captions/en.srt", Format: "srt", Language: "en"},
+			{Source: "s3://bucket/captions/es.srt", Format: "srt", Language: "es"},
+			{Source: "s3://bucket/captions/fr.dfxp", Format: "dfxp", Language: "fr"},
+		},
+	}
+	jobStatus, err := prov.Transcode(&db.Job{ID: "job-123"}, transcodeProfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobInput := fakeTranscoder.jobs[jobStatus.ProviderJobID]
+	expectedFormats := []*elastictranscoder.CaptionFormat{
+		{Format: aws.String("srt"), Pattern: aws.String("job-123/captions/{language}")},
+		{Format: aws.String("dfxp"), Pattern: aws.String("job-123/captions/{language}")},
+	}
+	if captions := jobInput.Outputs[0].Captions; captions == nil || !reflect.DeepEqual(captions.CaptionFormats, expectedFormats) {
+		t.Errorf("Wrong caption formats\nWant %#v\nGot  %#v", expectedFormats, captions)
+	}
+}
+
+func TestAWSTranscodeOverlay(t *testing.T) {
+	fakeTranscoder := newFakeElasticTranscoder()
+	prov := &awsProvider{
+		c: fakeTranscoder,
+		config: &config.ElasticTranscoder{
+			AccessKeyID:     "AKIA",
+			SecretAccessKey: "secret",
+			Region:          "sa-east-1",
+			PipelineID:      "mypipeline",
+		},
+	}
+	outputs := []provider.TranscodeOutput{
+		{
+			FileName: "output_720p.mp4",
+			Preset: db.PresetMap{
+				Name:            "mp4_720p",
+				ProviderMapping: map[string]string{Name: "93239832-0001"},
+				OutputOpts:      db.OutputOptions{Extension: "mp4"},
+			},
+		},
+		{
+			FileName: "output_720p_logo.mp4",
+			Preset: db.PresetMap{
+				Name:            "mp4_720p_logo",
+				ProviderMapping: map[string]string{Name: "overlay-93239832-0002"},
+				OutputOpts:      db.OutputOptions{Extension: "mp4"},
+			},
+		},
+	}
+	transcodeProfile := provider.TranscodeProfile{
+		SourceMedia:  "dir/file.mov",
+		Outputs:      outputs,
+		OverlayImage: "s3://bucket/images/logo.png",
+	}
+	jobStatus, err := prov.Transcode(&db.Job{ID: "job-123"}, transcodeProfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobInput := fakeTranscoder.jobs[jobStatus.ProviderJobID]
+	if jobInput.Outputs[0].Watermarks != nil {
+		t.Errorf("Unexpected watermarks in output without overlay: %#v", jobInput.Outputs[0].Watermarks)
+	}
+	expectedWatermarks := []*elastictranscoder.JobWatermark{
+		{PresetWatermarkId: aws.String("overlay"), InputKey: aws.String("images/logo.png")},
+	}
+	if !reflect.DeepEqual(jobInput.Outputs[1].Watermarks, expectedWatermarks) {
+		t.Errorf("Wrong watermarks\nWant %#v\nGot  %#v", expectedWatermarks, jobInput.Outputs[1].Watermarks)
+	}
+}
+
+func TestAWSTranscodeDestination(t *testing.T) {
+	var tests = []struct {
+		givenTestCase     string
+		givenDestination  string
+		givenOutputPath   string
+		expectedOutputKey string
+		expectedErr       string
+	}{
+		{"no destination", "", "", "job-123/output_720p.mp4", ""},
+		{"output path", "", "2016-10-18/job-123", "2016-10-18/job-123/output_720p.mp4", ""},
+		{"destination in the pipeline bucket", "s3://some bucket/team/videos/", "", "team/videos/output_720p.mp4", ""},
+		{"destination with output path", "s3://some bucket/team/videos/", "job-123", "team/videos/job-123/output_720p.mp4", ""},
+		{"root of the pipeline bucket", "s3://some bucket", "", "output_720p.mp4", ""},
+		{
+			"destination in another bucket",
+			"s3://other-bucket/videos",
+			"",
+			"",
+			`invalid destination "s3://other-bucket/videos": Elastic Transcoder can only write to the output bucket of the pipeline ("some bucket")`,
+		},
+		{
+			"destination outside of S3",
+			"ftp://ftp.example.com/videos",
+			"",
+			"",
+			`invalid destination "ftp://ftp.example.com/videos": unsupported scheme "ftp". Supported schemes are: s3`,
+		},
+	}
+	for _, test := range tests {
+		fakeTranscoder := newFakeElasticTranscoder()
+		prov := &awsProvider{
+			c:      fakeTranscoder,
+			config: &config.ElasticTranscoder{PipelineID: "mypipeline"},
+		}
+		transcodeProfile := provider.TranscodeProfile{
+			SourceMedia: "dir/file.mov",
+			Outputs: []provider.TranscodeOutput{
+				{
+					FileName: "output_720p.mp4",
+					Preset: db.PresetMap{
+						Name:            "mp4_720p",
+						ProviderMapping: map[string]string{Name: "93239832-0001"},
+						OutputOpts:      db.OutputOptions{Extension: "mp4"},
+					},
+				},
+			},
+		}
+		jobStatus, err := prov.Transcode(&db.Job{ID: "job-123", Destination: test.givenDestination, OutputPath: test.givenOutputPath}, transcodeProfile)
+		if test.expectedErr != "" {
+			if err == nil || err.Error() != test.expectedErr {
+				t.Errorf("%s: wrong error returned. Want %q. Got %v", test.givenTestCase, test.expectedErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.givenTestCase, err)
+			continue
+		}
+		jobInput := fakeTranscoder.jobs[jobStatus.ProviderJobID]
+		if key := aws.StringValue(jobInput.Outputs[0].Key); key != test.expectedOutputKey {
+			t.Errorf("%s: wrong output key. Want %q. Got %q", test.givenTestCase, test.expectedOutputKey, key)
+		}
+	}
+}
+
+func TestAWSTranscodeProviderOptions(t *testing.T) {
+	fakeTranscoder := newFakeElasticTranscoder()
+	prov := &awsProvider{
+		c:      fakeTranscoder,
+		config: &config.ElasticTranscoder{PipelineID: "mypipeline"},
+	}
+	transcodeProfile := provider.TranscodeProfile{
+		SourceMedia: "dir/file.mov",
+		Outputs: []provider.TranscodeOutput{
+			{
+				FileName: "output_720p.mp4",
+				Preset: db.PresetMap{
+					Name:            "mp4_720p",
+					ProviderMapping: map[string]string{Name: "93239832-0001"},
+					OutputOpts:      db.OutputOptions{Extension: "mp4"},
+				},
+			},
+		},
+		ProviderOptions: db.ProviderOptions{
+			Name: {"UserMetadata": map[string]interface{}{"team": "video"}},
+		},
+	}
+	jobStatus, err := prov.Transcode(&db.Job{ID: "job-123"}, transcodeProfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobInput := fakeTranscoder.jobs[jobStatus.ProviderJobID]
+	if team := aws.StringValue(jobInput.UserMetadata["team"]); team != "video" {
+		t.Errorf("wrong user metadata. Want %q. Got %q", "video", team)
+	}
+	if pipeline := aws.StringValue(jobInput.PipelineId); pipeline != "mypipeline" {
+		t.Errorf("wrong pipeline. Want %q. Got %q", "mypipeline", pipeline)
+	}
+	transcodeProfile.ProviderOptions = db.ProviderOptions{Name: {"Metadata": "video"}}
+	jobStatus, err = prov.Transcode(&db.Job{ID: "job-123"}, transcodeProfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body map[string]interface{}
+	if err = json.Unmarshal(fakeTranscoder.jobBodies[jobStatus.ProviderJobID], &body); err != nil {
+		t.Fatal(err)
+	}
+	if body["Metadata"] != "video" {
+		t.Errorf("provider option Metadata was not merged into the request body: %#v", body)
+	}
+	if body["PipelineId"] != "mypipeline" {
+		t.Errorf("wrong pipeline in the request body. Want %q. Got %#v", "mypipeline", body["PipelineId"])
+	}
+	transcodeProfile.ProviderOptions = db.ProviderOptions{Name: {"Input": "dir/file.mov"}}
+	_, err = prov.Transcode(&db.Job{ID: "job-123"}, transcodeProfile)
+	if _, ok := err.(*provider.InvalidProviderOptionError); !ok {
+		t.Errorf("wrong error returned. Want *provider.InvalidProviderOptionError. Got %#v", err)
+	}
+}
+
+func TestAWSJobStatusNoDetectedProperties(t *testing.T) {
+	fakeTranscoder := newFakeElasticTranscoder()
+	prov := &awsProvider{
+		c: fakeTranscoder,
+		config: &config.ElasticTranscoder{
+			AccessKeyID:     "AKIA",
+			SecretAccessKey: "secret",
+			Region:          "sa-east-1",
+			PipelineID:      "mypipeline",
+		},
+	}
+	outputs := []provider.TranscodeOutput{
+		{
+			FileName: "output_720p.mp4",
+			Preset: db.PresetMap{
+				Name: "mp4_720p",
+				ProviderMapping: map[string]string{
+					Name:    "93239832-0001",
+					"other": "irrelevant",
+				},
+				OutputOpts: db.OutputOptions{Extension: "mp4"},
+			},
+		},
+		{
+			FileName: "output_720p.webm",
+			Preset: db.PresetMap{
+				Name: "webm_720p",
+				ProviderMapping: map[string]string{
+					Name:    "93239832-0002",
+					"other": "irrelevant",
+				},
+				OutputOpts: db.OutputOptions{Extension: "webm"},
+			},
+		},
+	}
+	source := "dir/file.mov"
+	transcodeProfile := provider.TranscodeProfile{
+		SourceMedia:     source,
+		Outputs:         outputs,
+		StreamingParams: provider.StreamingParams{},
+	}
+	jobStatus, err := prov.Transcode(&db.Job{ID: "job-123"}, transcodeProfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fakeTranscoder.jobs[jobStatus.ProviderJobID].Input.DetectedProperties = nil
+	jobStatus, err = prov.JobStatus(&db.Job{ID: "job-123", ProviderJobID: jobStatus.ProviderJobID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedJobStatus := provider.JobStatus{
+		ProviderJobID: jobStatus.ProviderJobID,
+		Status:        provider.StatusFinished,
+		Progress:      100,
+		ProviderStatus: map[string]interface{}{
+			"outputs": map[string]interface{}{
+				"job-123/output_720p.mp4":  "it's finished!",
+				"job-123/output_720p.webm": "it's finished!",
+			},
+		},
+		Output: provider.JobOutput{
+			Destination: "s3://some bucket/job-123",
+			Files: []provider.OutputFile{
+				{
+					Path:       "s3://some bucket/job-123/output_720p.mp4",
+					Container:  "mp4",
+					VideoCodec: "H.264",
+					Width:      0,
+					Height:     720,
+				},
+				{
+					Path:       "s3://some bucket/job-123/output_720p.webm",
+					Container:  "webm",
+					VideoCodec: "VP8",
+					Width:      0,
+					Height:     720,
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(*jobStatus, expectedJobStatus) {
+		t.Errorf("Wrong JobStatus\nWant %#v\nGot  %#v", expectedJobStatus, *jobStatus)
+	}
+}
+
+func TestAWSCreatePreset(t *testing.T) {
+	fakeTranscoder := newFakeElasticTranscoder()
+	prov := &awsProvider{
+		c: fakeTranscoder,
+		config: &config.ElasticTranscoder{
+			AccessKeyID:     "AKIA",
+			SecretAccessKey: "secret",
+			Region:          "sa-east-1",
+			PipelineID:      "mypipeline",
+		},
+	}
+
+	inputPreset := db.Preset{
+		Name:         "preset_name",
 		Description:  "description here",
 		Container:    "mp4",
 		Profile:      "Main",
@@ -825,6 +1496,197 @@ func TestAWSCreatePreset(t *testing.T) {
 	}
 }
 
+func TestAWSCreatePresetLadderRung(t *testing.T) {
+	prov := &awsProvider{
+		c: newFakeElasticTranscoder(),
+		config: &config.ElasticTranscoder{
+			AccessKeyID:     "AKIA",
+			SecretAccessKey: "secret",
+			Region:          "sa-east-1",
+			PipelineID:      "mypipeline",
+		},
+	}
+	preset := db.Preset{
+		Name:        "mp4_h264_854x480_1500k_gop60",
+		Description: "Generated for adaptive bitrate ladders",
+		Container:   "mp4",
+		Video:       db.VideoPreset{Codec: "h264", Width: "854", Height: "480", Bitrate: "1500000", GopSize: "60", AlignKeyframes: true},
+		Audio:       db.AudioPreset{Codec: "aac", Bitrate: "96000"},
+	}
+	presetID, err := prov.CreatePreset(preset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if presetID != "mp4_h264_854x480_1500k_gop60-abc123" {
+		t.Errorf("wrong preset id. Want %q. Got %q", "mp4_h264_854x480_1500k_gop60-abc123", presetID)
+	}
+	video := prov.createVideoPreset(preset)
+	if aws.StringValue(video.MaxWidth) != "854" || aws.StringValue(video.MaxHeight) != "480" {
+		t.Errorf("wrong dimensions. Want 854x480. Got %sx%s", aws.StringValue(video.MaxWidth), aws.StringValue(video.MaxHeight))
+	}
+	if aws.StringValue(video.KeyframesMaxDist) != "60" || aws.StringValue(video.FixedGOP) != "true" {
+		t.Errorf("wrong keyframes. Want fixed GOP of 60. Got %s (fixed: %s)", aws.StringValue(video.KeyframesMaxDist), aws.StringValue(video.FixedGOP))
+	}
+}
+
+func TestAWSCreatePresetUnsupportedFields(t *testing.T) {
+	prov := &awsProvider{c: newFakeElasticTranscoder()}
+	_, err := prov.CreatePreset(db.Preset{
+		Name:      "preset_name",
+		Container: "mp4",
+		Video:     db.VideoPreset{Codec: "h264", BFrames: "2", TwoPass: true},
+	})
+	expectedMsg := "preset fields not supported by elastictranscoder: video.bFrames, video.twoPass"
+	if err == nil || err.Error() != expectedMsg {
+		t.Errorf("wrong error returned. Want %q. Got %v", expectedMsg, err)
+	}
+}
+
+func TestAWSCreatePresetUnsupportedCodec(t *testing.T) {
+	prov := &awsProvider{c: newFakeElasticTranscoder()}
+	_, err := prov.CreatePreset(db.Preset{
+		Name:      "preset_name",
+		Container: "mp4",
+		Video:     db.VideoPreset{Codec: "h265"},
+	})
+	expectedMsg := `video codec "h265" is not supported by elastictranscoder. Supported codecs are: h264, vp8, vp9`
+	if err == nil || err.Error() != expectedMsg {
+		t.Errorf("wrong error returned. Want %q. Got %v", expectedMsg, err)
+	}
+}
+
+func TestAWSVideoCodecs(t *testing.T) {
+	prov := &awsProvider{c: newFakeElasticTranscoder()}
+	var tests = []struct {
+		givenCodec    string
+		expectedCodec string
+	}{
+		{"h264", "H.264"},
+		{"vp8", "vp8"},
+		{"vp9", "vp9"},
+	}
+	for _, test := range tests {
+		videoPreset := prov.createVideoPreset(db.Preset{
+			Container: "mp4",
+			Video:     db.VideoPreset{Codec: test.givenCodec, Bitrate: "2500000", GopSize: "90"},
+		})
+		if codec := aws.StringValue(videoPreset.Codec); codec != test.expectedCodec {
+			t.Errorf("%s: wrong codec. Want %q. Got %q", test.givenCodec, test.expectedCodec, codec)
+		}
+		preset := prov.normalizePreset(&elastictranscoder.Preset{Video: videoPreset})
+		if preset.Video.Codec != test.givenCodec {
+			t.Errorf("%s: wrong normalized codec. Want %q. Got %q", test.givenCodec, test.givenCodec, preset.Video.Codec)
+		}
+	}
+}
+
+func TestAWSNormalizePresetRoundTrip(t *testing.T) {
+	prov := &awsProvider{c: newFakeElasticTranscoder()}
+	var tests = []struct {
+		givenTestCase string
+		givenVideo    db.VideoPreset
+		wantGopMode   string
+	}{
+		{"fixed GOP mode", db.VideoPreset{Codec: "h264", GopSize: "90", GopMode: "fixed"}, "fixed"},
+		{"aligned keyframes", db.VideoPreset{Codec: "h264", GopSize: "90", AlignKeyframes: true}, "fixed"},
+		{"variable GOP", db.VideoPreset{Codec: "h264", GopSize: "90", GopMode: "variable"}, ""},
+		{"default reference frames", db.VideoPreset{Codec: "h264", ReferenceFrames: defaultMaxReferenceFrames}, ""},
+		{"vp8", db.VideoPreset{Codec: "vp8", GopSize: "90", ReferenceFrames: "4"}, ""},
+	}
+	for _, test := range tests {
+		preset := db.Preset{
+			Name:         "preset_name",
+			Container:    "mp4",
+			Profile:      "main",
+			ProfileLevel: "3.1",
+			RateControl:  "VBR",
+			Video:        test.givenVideo,
+		}
+		expected := prov.NormalizePreset(preset)
+		if expected.Video.GopMode != test.wantGopMode {
+			t.Errorf("%s: wrong GOP mode. Want %q. Got %q", test.givenTestCase, test.wantGopMode, expected.Video.GopMode)
+		}
+		actual := prov.normalizePreset(&elastictranscoder.Preset{
+			Name:      aws.String(preset.Name),
+			Container: aws.String(preset.Container),
+			Video:     prov.createVideoPreset(preset),
+		})
+		if diffs := expected.Diff(*actual, prov.Capabilities().UnreportedPresetFields...); len(diffs) > 0 {
+			t.Errorf("%s: unexpected differences: %#v", test.givenTestCase, diffs)
+		}
+	}
+}
+
+func TestCreateWatermarkPreset(t *testing.T) {
+	var tests = []struct {
+		givenTestCase     string
+		overlay           db.OverlayPreset
+		expectedWatermark *elastictranscoder.PresetWatermark
+		expectedErr       string
+	}{
+		{
+			"bottom-right overlay",
+			db.OverlayPreset{Position: "bottom-right", OffsetX: "10", OffsetY: "20", Opacity: "50"},
+			&elastictranscoder.PresetWatermark{
+				Id:               aws.String("overlay"),
+				HorizontalAlign:  aws.String("Right"),
+				HorizontalOffset: aws.String("10px"),
+				VerticalAlign:    aws.String("Bottom"),
+				VerticalOffset:   aws.String("20px"),
+				Opacity:          aws.String("50"),
+				MaxWidth:         aws.String("100%"),
+				MaxHeight:        aws.String("100%"),
+				SizingPolicy:     aws.String("ShrinkToFit"),
+				Target:           aws.String("Content"),
+			},
+			"",
+		},
+		{
+			"top-left overlay with default offsets and opacity",
+			db.OverlayPreset{Position: "top-left"},
+			&elastictranscoder.PresetWatermark{
+				Id:               aws.String("overlay"),
+				HorizontalAlign:  aws.String("Left"),
+				HorizontalOffset: aws.String("0px"),
+				VerticalAlign:    aws.String("Top"),
+				VerticalOffset:   aws.String("0px"),
+				Opacity:          aws.String("100"),
+				MaxWidth:         aws.String("100%"),
+				MaxHeight:        aws.String("100%"),
+				SizingPolicy:     aws.String("ShrinkToFit"),
+				Target:           aws.String("Content"),
+			},
+			"",
+		},
+		{
+			"invalid position",
+			db.OverlayPreset{Position: "center"},
+			nil,
+			`invalid overlay position: "center"`,
+		},
+		{
+			"time range",
+			db.OverlayPreset{Position: "top-left", StartTime: "10"},
+			nil,
+			"overlay time range is not supported by Elastic Transcoder",
+		},
+	}
+	var prov awsProvider
+	for _, test := range tests {
+		watermark, err := prov.createWatermarkPreset(test.overlay)
+		var errMsg string
+		if err != nil {
+			errMsg = err.Error()
+		}
+		if errMsg != test.expectedErr {
+			t.Errorf("%s: wrong error. Want %q. Got %q", test.givenTestCase, test.expectedErr, errMsg)
+		}
+		if !reflect.DeepEqual(watermark, test.expectedWatermark) {
+			t.Errorf("%s: wrong watermark\nWant %#v\nGot  %#v", test.givenTestCase, test.expectedWatermark, watermark)
+		}
+	}
+}
+
 func TestCreateVideoPreset(t *testing.T) {
 	fakeTranscoder := newFakeElasticTranscoder()
 	prov := &awsProvider{
@@ -916,6 +1778,43 @@ func TestCreateVideoPreset(t *testing.T) {
 				SizingPolicy:       aws.String("Fill"),
 			},
 		},
+		{
+			"H.264 preset with encoding parameters",
+			db.Preset{
+				Container:    "m3u8",
+				Profile:      "High",
+				ProfileLevel: "4.1",
+				Video: db.VideoPreset{
+					Codec:           "h264",
+					Bitrate:         "2500000",
+					GopSize:         "60",
+					FrameRate:       "29.97",
+					AlignKeyframes:  true,
+					ReferenceFrames: "4",
+					MaxBitrate:      "3000000",
+					BufferSize:      "6000000",
+				},
+			},
+			&elastictranscoder.VideoParameters{
+				BitRate: aws.String("2500"),
+				Codec:   aws.String("H.264"),
+				CodecOptions: map[string]*string{
+					"MaxReferenceFrames": aws.String("4"),
+					"Profile":            aws.String("high"),
+					"Level":              aws.String("4.1"),
+					"MaxBitRate":         aws.String("3000"),
+					"BufferSize":         aws.String("6000"),
+				},
+				DisplayAspectRatio: aws.String("auto"),
+				FixedGOP:           aws.String("true"),
+				FrameRate:          aws.String("29.97"),
+				KeyframesMaxDist:   aws.String("60"),
+				MaxHeight:          aws.String("auto"),
+				MaxWidth:           aws.String("auto"),
+				PaddingPolicy:      aws.String("Pad"),
+				SizingPolicy:       aws.String("Fill"),
+			},
+		},
 		{
 			"MP4 preset",
 			db.Preset{
@@ -944,6 +1843,40 @@ func TestCreateVideoPreset(t *testing.T) {
 				SizingPolicy:       aws.String("Fill"),
 			},
 		},
+		{
+			"H.264 preset with provider options",
+			db.Preset{
+				Container:    "mp4",
+				Profile:      "Main",
+				ProfileLevel: "3.1",
+				Video: db.VideoPreset{
+					Codec:   "h264",
+					GopSize: "90",
+				},
+				ProviderOptions: db.ProviderOptions{
+					Name:       {"Profile": "baseline", "InterlacedMode": "Progressive", "MaxBitRate": float64(5000)},
+					"zencoder": {"speed": float64(4)},
+				},
+			},
+			&elastictranscoder.VideoParameters{
+				BitRate: aws.String("0"),
+				Codec:   aws.String("H.264"),
+				CodecOptions: map[string]*string{
+					"MaxReferenceFrames": aws.String("2"),
+					"Profile":            aws.String("baseline"),
+					"Level":              aws.String("3.1"),
+					"InterlacedMode":     aws.String("Progressive"),
+					"MaxBitRate":         aws.String("5000"),
+				},
+				DisplayAspectRatio: aws.String("auto"),
+				FrameRate:          aws.String("auto"),
+				KeyframesMaxDist:   aws.String("90"),
+				MaxHeight:          aws.String("auto"),
+				MaxWidth:           aws.String("auto"),
+				PaddingPolicy:      aws.String("Pad"),
+				SizingPolicy:       aws.String("Fill"),
+			},
+		},
 	}
 	for _, test := range tests {
 		videoParams := prov.createVideoPreset(test.givenPreset)
@@ -998,6 +1931,23 @@ func TestCreateAudioPreset(t *testing.T) {
 				SampleRate: aws.String("auto"),
 			},
 		},
+		{
+			"AAC preset with channels and sample rate",
+			db.Preset{
+				Audio: db.AudioPreset{
+					Codec:      "aac",
+					Bitrate:    "128000",
+					Channels:   "2",
+					SampleRate: "48000",
+				},
+			},
+			&elastictranscoder.AudioParameters{
+				BitRate:    aws.String("128"),
+				Channels:   aws.String("2"),
+				Codec:      aws.String("AAC"),
+				SampleRate: aws.String("48000"),
+			},
+		},
 	}
 	for _, test := range tests {
 		audioParams := prov.createAudioPreset(test.givenPreset)
@@ -1008,6 +1958,134 @@ func TestCreateAudioPreset(t *testing.T) {
 	}
 }
 
+func TestAWSNormalizePreset(t *testing.T) {
+	var prov awsProvider
+	var tests = []struct {
+		givenTestCase  string
+		givenContainer string
+		givenPreset    db.Preset
+		expectedPreset db.Preset
+	}{
+		{
+			"H.264 preset with overlay",
+			"ts",
+			db.Preset{
+				Name:         "hls_720p",
+				Description:  "my preset",
+				Container:    "m3u8",
+				Profile:      "Main",
+				ProfileLevel: "3.1",
+				RateControl:  "VBR",
+				Video: db.VideoPreset{
+					Height:        "720",
+					Codec:         "h264",
+					Bitrate:       "2500000",
+					GopSize:       "90",
+					GopMode:       "fixed",
+					InterlaceMode: "progressive",
+				},
+				Audio:   db.AudioPreset{Codec: "aac", Bitrate: "64000"},
+				Overlay: db.OverlayPreset{Position: "bottom-right", OffsetX: "10", OffsetY: "20", Opacity: "50"},
+			},
+			db.Preset{
+				Name:         "hls_720p",
+				Description:  "my preset",
+				Container:    "m3u8",
+				Profile:      "main",
+				ProfileLevel: "3.1",
+				Video: db.VideoPreset{
+					Height:  "720",
+					Codec:   "h264",
+					Bitrate: "2500000",
+					GopSize: "90",
+					GopMode: "fixed",
+				},
+				Audio:   db.AudioPreset{Codec: "aac", Bitrate: "64000"},
+				Overlay: db.OverlayPreset{Position: "bottom-right", OffsetX: "10", OffsetY: "20", Opacity: "50"},
+			},
+		},
+		{
+			"H.264 preset with encoding parameters",
+			"mp4",
+			db.Preset{
+				Name:      "mp4_1080p",
+				Container: "mp4",
+				Video: db.VideoPreset{
+					Codec:           "h264",
+					Bitrate:         "5000000",
+					FrameRate:       "30",
+					ReferenceFrames: "3",
+					MaxBitrate:      "6000000",
+					BufferSize:      "12000000",
+				},
+				Audio: db.AudioPreset{Codec: "aac", Bitrate: "128000", Channels: "2", SampleRate: "44100"},
+			},
+			db.Preset{
+				Name:      "mp4_1080p",
+				Container: "mp4",
+				Video: db.VideoPreset{
+					Codec:           "h264",
+					Bitrate:         "5000000",
+					FrameRate:       "30",
+					ReferenceFrames: "3",
+					MaxBitrate:      "6000000",
+					BufferSize:      "12000000",
+				},
+				Audio: db.AudioPreset{Codec: "aac", Bitrate: "128000", Channels: "2", SampleRate: "44100"},
+			},
+		},
+		{
+			"VP8 preset",
+			"webm",
+			db.Preset{
+				Name:      "webm_720p",
+				Container: "webm",
+				Profile:   "Main",
+				Video:     db.VideoPreset{Width: "1280", Codec: "vp8", Bitrate: "1000000", GopSize: "90"},
+				Audio:     db.AudioPreset{Codec: "libvorbis", Bitrate: "128000"},
+			},
+			db.Preset{
+				Name:      "webm_720p",
+				Container: "webm",
+				Video:     db.VideoPreset{Width: "1280", Codec: "vp8", Bitrate: "1000000", GopSize: "90"},
+				Audio:     db.AudioPreset{Codec: "libvorbis", Bitrate: "128000"},
+			},
+		},
+	}
+	for _, test := range tests {
+		etPreset := elastictranscoder.Preset{
+			Name:        aws.String(test.givenPreset.Name),
+			Description: aws.String(test.givenPreset.Description),
+			Container:   aws.String(test.givenContainer),
+			Video:       prov.createVideoPreset(test.givenPreset),
+			Audio:       prov.createAudioPreset(test.givenPreset),
+		}
+		if test.givenPreset.Overlay.Enabled() {
+			watermark, err := prov.createWatermarkPreset(test.givenPreset.Overlay)
+			if err != nil {
+				t.Fatal(err)
+			}
+			etPreset.Video.Watermarks = []*elastictranscoder.PresetWatermark{watermark}
+		}
+		preset := prov.normalizePreset(&etPreset)
+		if !reflect.DeepEqual(*preset, test.expectedPreset) {
+			t.Errorf("%s: wrong preset returned\nWant %#v\nGot  %#v", test.givenTestCase, test.expectedPreset, *preset)
+		}
+	}
+}
+
+func TestAWSGetNormalizedPreset(t *testing.T) {
+	prov := &awsProvider{c: newFakeElasticTranscoder()}
+	preset, err := prov.GetNormalizedPreset("dash_webm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := db.Preset{Name: "dash_webm", Container: "webm", Video: db.VideoPreset{Codec: "vp8"}}
+	if !reflect.DeepEqual(*preset, expected) {
+		t.Errorf("wrong preset returned\nWant %#v\nGot  %#v", expected, *preset)
+	}
+}
+
 func TestAWSJobStatusNotFound(t *testing.T) {
 	fakeTranscoder := newFakeElasticTranscoder()
 	provider := &awsProvider{
@@ -1153,9 +2231,15 @@ func TestHealthcheckFailure(t *testing.T) {
 func TestCapabilities(t *testing.T) {
 	var prov awsProvider
 	expected := provider.Capabilities{
-		InputFormats:  []string{"h264"},
-		OutputFormats: []string{"mp4", "hls", "webm"},
-		Destinations:  []string{"s3"},
+		InputFormats:         []string{"h264"},
+		OutputFormats:        []string{"mp4", "hls", "dash", "webm"},
+		VideoCodecs:          []string{"h264", "vp8", "vp9"},
+		Destinations:         []string{"s3"},
+		CaptionFormats:       []string{"srt", "webvtt", "scc", "dfxp"},
+		HLSSegmentFormats:    []string{"ts"},
+		HLSEncryptionMethods: []string{"aes-128"},
+
+		UnreportedPresetFields: []string{"rateControl", "video.interlaceMode"},
 	}
 	cap := prov.Capabilities()
 	if !reflect.DeepEqual(cap, expected) {
@@ -1,12 +1,28 @@
 package elementalconductor
 
-import "github.com/NYTimes/encoding-wrapper/elementalconductor"
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NYTimes/encoding-wrapper/elementalconductor"
+)
 
 type clientInterface interface {
 	GetPreset(presetID string) (*elementalconductor.Preset, error)
+	GetPresetXML(presetID string) ([]byte, error)
 	CreatePreset(preset *elementalconductor.Preset) (*elementalconductor.Preset, error)
+	CreatePresetXML(data []byte) (*elementalconductor.Preset, error)
 	DeletePreset(presetID string) error
 	CreateJob(job *elementalconductor.Job) (*elementalconductor.Job, error)
+	CreateJobXML(data []byte) (*elementalconductor.Job, error)
 	GetJob(jobID string) (*elementalconductor.Job, error)
 	CancelJob(jobID string) (*elementalconductor.Job, error)
 	GetNodes() ([]elementalconductor.Node, error)
@@ -15,3 +31,88 @@ type clientInterface interface {
 	GetSecretAccessKey() string
 	GetDestination() string
 }
+
+// conductorClient extends the Elemental Conductor client with the creation
+// of presets and jobs from raw XML, and the retrieval of the raw XML of
+// presets, used for settings that the types of the client don't include.
+type conductorClient struct {
+	*elementalconductor.Client
+	httpClient *http.Client
+}
+
+// GetPresetXML returns the XML representation of a preset.
+func (c *conductorClient) GetPresetXML(presetID string) ([]byte, error) {
+	data, err := c.doXML("GET", "/presets/"+presetID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get preset: %s", err)
+	}
+	return data, nil
+}
+
+// CreatePresetXML creates a preset from its XML representation.
+func (c *conductorClient) CreatePresetXML(data []byte) (*elementalconductor.Preset, error) {
+	var preset elementalconductor.Preset
+	if err := c.postXML("/presets", data, &preset); err != nil {
+		return nil, fmt.Errorf("failed to create preset: %s", err)
+	}
+	return &preset, nil
+}
+
+// CreateJobXML creates a job from its XML representation.
+func (c *conductorClient) CreateJobXML(data []byte) (*elementalconductor.Job, error) {
+	var job elementalconductor.Job
+	if err := c.postXML("/jobs", data, &job); err != nil {
+		return nil, fmt.Errorf("failed to create job: %s", err)
+	}
+	return &job, nil
+}
+
+// postXML sends the given XML to the given path of the API, decoding the
+// response into v.
+func (c *conductorClient) postXML(path string, data []byte, v interface{}) error {
+	body, err := c.doXML("POST", path, data)
+	if err != nil {
+		return err
+	}
+	return xml.Unmarshal(body, v)
+}
+
+// doXML sends a request with the given XML to the given path of the API,
+// returning the body of the response.
+func (c *conductorClient) doXML(method, path string, data []byte) ([]byte, error) {
+	req, err := http.NewRequest(method, strings.TrimRight(c.Host, "/")+"/api"+path, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	expires := strconv.FormatInt(time.Now().Add(time.Duration(c.AuthExpires)*time.Minute).Unix(), 10)
+	req.Header.Set("Accept", "application/xml")
+	req.Header.Set("Content-Type", "application/xml")
+	req.Header.Set("X-Auth-User", c.UserLogin)
+	req.Header.Set("X-Auth-Expires", expires)
+	req.Header.Set("X-Auth-Key", authKey(path, c.UserLogin, c.APIKey, expires))
+	httpClient := c.httpClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return nil, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
+
+// authKey returns the key used for authenticating requests to the given
+// path of the API.
+func authKey(path, userLogin, apiKey, expires string) string {
+	inner := md5.Sum([]byte(path + userLogin + apiKey + expires))
+	key := md5.Sum([]byte(apiKey + hex.EncodeToString(inner[:])))
+	return hex.EncodeToString(key[:])
+}
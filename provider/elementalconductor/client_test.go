@@ -0,0 +1,138 @@
+package elementalconductor
+
+import (
+	"testing"
+
+	"github.com/NYTimes/encoding-wrapper/elementalconductor"
+)
+
+func TestCreatePresetXML(t *testing.T) {
+	server := NewElementalServer(nil, nil)
+	defer server.Close()
+	client := conductorClient{Client: elementalconductor.NewClient(server.URL, "myuser", "secret-key", 30, "", "", "")}
+	data := []byte("<preset><name>mp4_720p</name><container>mp4</container></preset>")
+	preset, err := client.CreatePresetXML(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if preset.Name != "mp4_720p" {
+		t.Errorf("wrong preset returned. Want name %q. Got %#v", "mp4_720p", preset)
+	}
+	if string(server.presetXML) != string(data) {
+		t.Errorf("wrong preset sent. Want %q. Got %q", data, server.presetXML)
+	}
+	req := server.presetRequest
+	if req.Method != "POST" {
+		t.Errorf("wrong method. Want POST. Got %s", req.Method)
+	}
+	if contentType := req.Header.Get("Content-Type"); contentType != "application/xml" {
+		t.Errorf("wrong content type. Want %q. Got %q", "application/xml", contentType)
+	}
+	if user := req.Header.Get("X-Auth-User"); user != "myuser" {
+		t.Errorf("wrong auth user. Want %q. Got %q", "myuser", user)
+	}
+	expectedKey := authKey("/presets", "myuser", "secret-key", req.Header.Get("X-Auth-Expires"))
+	if key := req.Header.Get("X-Auth-Key"); key != expectedKey {
+		t.Errorf("wrong auth key. Want %q. Got %q", expectedKey, key)
+	}
+}
+
+func TestCreatePresetXMLError(t *testing.T) {
+	server := NewElementalServer(nil, nil)
+	defer server.Close()
+	client := conductorClient{Client: elementalconductor.NewClient(server.URL, "myuser", "secret-key", 30, "", "", "")}
+	preset, err := client.CreatePresetXML([]byte("<preset>"))
+	if preset != nil {
+		t.Errorf("unexpected non-nil preset: %#v", preset)
+	}
+	expectedMsg := "failed to create preset: 422 Unprocessable Entity: XML syntax error on line 1: unexpected EOF"
+	if err == nil || err.Error() != expectedMsg {
+		t.Errorf("wrong error returned. Want %q. Got %v", expectedMsg, err)
+	}
+}
+
+func TestGetPresetXML(t *testing.T) {
+	server := NewElementalServer(nil, nil)
+	defer server.Close()
+	data := []byte("<preset><name>mp4_720p</name><container>mp4</container></preset>")
+	server.presetXML = data
+	client := conductorClient{Client: elementalconductor.NewClient(server.URL, "myuser", "secret-key", 30, "", "", "")}
+	presetXML, err := client.GetPresetXML("mp4_720p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(presetXML) != string(data) {
+		t.Errorf("wrong preset returned. Want %q. Got %q", data, presetXML)
+	}
+	req := server.presetRequest
+	if req.Method != "GET" {
+		t.Errorf("wrong method. Want GET. Got %s", req.Method)
+	}
+	expectedKey := authKey("/presets/mp4_720p", "myuser", "secret-key", req.Header.Get("X-Auth-Expires"))
+	if key := req.Header.Get("X-Auth-Key"); key != expectedKey {
+		t.Errorf("wrong auth key. Want %q. Got %q", expectedKey, key)
+	}
+}
+
+func TestGetPresetXMLNotFound(t *testing.T) {
+	server := NewElementalServer(nil, nil)
+	defer server.Close()
+	client := conductorClient{Client: elementalconductor.NewClient(server.URL, "myuser", "secret-key", 30, "", "", "")}
+	presetXML, err := client.GetPresetXML("mp4_720p")
+	if presetXML != nil {
+		t.Errorf("unexpected non-nil preset: %q", presetXML)
+	}
+	expectedMsg := "failed to get preset: 404 Not Found: not found"
+	if err == nil || err.Error() != expectedMsg {
+		t.Errorf("wrong error returned. Want %q. Got %v", expectedMsg, err)
+	}
+}
+
+func TestAuthKey(t *testing.T) {
+	// md5("/presetsmyusersecret-key1480000000") is
+	// 2767cc0885d87170df86f6381ad792a8.
+	key := authKey("/presets", "myuser", "secret-key", "1480000000")
+	expected := "7b78d5813ca87a3fc5d710e6a13a4cf9"
+	if key != expected {
+		t.Errorf("wrong auth key. Want %q. Got %q", expected, key)
+	}
+}
+
+func TestCreateJobXML(t *testing.T) {
+	server := NewElementalServer(nil, nil)
+	defer server.Close()
+	client := conductorClient{Client: elementalconductor.NewClient(server.URL, "myuser", "secret-key", 30, "", "", "")}
+	data := []byte("<job><input><file_input><uri>http://some.nice/video.mov</uri></file_input></input></job>")
+	job, err := client.CreateJobXML(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.GetID() != "1" {
+		t.Errorf("wrong job returned. Want id %q. Got %#v", "1", job)
+	}
+	if string(server.jobXML) != string(data) {
+		t.Errorf("wrong job sent. Want %q. Got %q", data, server.jobXML)
+	}
+	req := server.jobRequest
+	if req.Method != "POST" {
+		t.Errorf("wrong method. Want POST. Got %s", req.Method)
+	}
+	expectedKey := authKey("/jobs", "myuser", "secret-key", req.Header.Get("X-Auth-Expires"))
+	if key := req.Header.Get("X-Auth-Key"); key != expectedKey {
+		t.Errorf("wrong auth key. Want %q. Got %q", expectedKey, key)
+	}
+}
+
+func TestCreateJobXMLError(t *testing.T) {
+	server := NewElementalServer(nil, nil)
+	defer server.Close()
+	client := conductorClient{Client: elementalconductor.NewClient(server.URL, "myuser", "secret-key", 30, "", "", "")}
+	job, err := client.CreateJobXML([]byte("<job>"))
+	if job != nil {
+		t.Errorf("unexpected non-nil job: %#v", job)
+	}
+	expectedMsg := "failed to create job: 422 Unprocessable Entity: XML syntax error on line 1: unexpected EOF"
+	if err == nil || err.Error() != expectedMsg {
+		t.Errorf("wrong error returned. Want %q. Got %v", expectedMsg, err)
+	}
+}
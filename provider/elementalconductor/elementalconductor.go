@@ -16,9 +16,15 @@
 package elementalconductor
 
 import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -26,6 +32,7 @@ import (
 	"github.com/NYTimes/encoding-wrapper/elementalconductor"
 	"github.com/NYTimes/video-transcoding-api/config"
 	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/mediaurl"
 	"github.com/NYTimes/video-transcoding-api/provider"
 )
 
@@ -33,9 +40,75 @@ import (
 // registry of providers.
 const Name = "elementalconductor"
 
-const defaultJobPriority = 50
+const (
+	defaultJobPriority = 50
 
-var errElementalConductorInvalidConfig = provider.InvalidConfigError("missing Elemental user login or api key. Please define the environment variables ELEMENTALCONDUCTOR_USER_LOGIN and ELEMENTALCONDUCTOR_API_KEY or set these values in the configuration file")
+	// captions are written as WebVTT by stream assemblies with names
+	// made of this prefix and the index of the caption in the job.
+	captionStreamPrefix     = "caption_"
+	captionDestinationType  = "WebVTT"
+	captionFormat           = "webvtt"
+	captionExtension        = "vtt"
+	captionContainer        = elementalconductor.Container("raw")
+	captionSelectorPrefix   = "caption_selector_"
+	captionOutputsDirectory = "captions"
+
+	// DASH renditions are written by outputs in DASH ISO output groups,
+	// which the output group type of the Elemental client doesn't include.
+	dashPresetContainer   = "mpd"
+	dashContainer         = elementalconductor.Container("m4f")
+	dashOutputGroupType   = elementalconductor.OutputGroupType("dash_iso_group_settings")
+	dashPlaylistExtension = ".mpd"
+
+	// keys of encrypted jobs are provided by the API.
+	staticKeyProviderType = "static_key"
+)
+
+var (
+	errElementalConductorInvalidConfig = provider.InvalidConfigError("missing Elemental user login or api key. Please define the environment variables ELEMENTALCONDUCTOR_USER_LOGIN and ELEMENTALCONDUCTOR_API_KEY or set these values in the configuration file")
+	errEncryptionWithoutHLS            = errors.New("encryption requires HLS outputs in Elemental Conductor jobs")
+
+	sourceSchemes      = []mediaurl.Scheme{mediaurl.S3, mediaurl.HTTP, mediaurl.HTTPS, mediaurl.FTP}
+	destinationSchemes = []mediaurl.Scheme{mediaurl.S3, mediaurl.FTP, mediaurl.Akamai}
+
+	// unsupportedPresetFields lists the preset fields that have no
+	// equivalent in Elemental Conductor presets.
+	unsupportedPresetFields = []string{
+		"video.frameRate", "video.bFrames", "video.twoPass", "audio.channels", "audio.sampleRate",
+	}
+
+	// videoCodecs maps the video codecs of presets to the names used in
+	// Elemental Conductor presets.
+	videoCodecs = provider.VideoCodecs{"h264": "h.264", "h265": "hevc"}
+
+	// encryptionTypes maps the HLS encryption methods to the encryption
+	// types of Apple Live output groups.
+	encryptionTypes = map[string]string{
+		"aes-128":    "aes128",
+		"sample-aes": "sample_aes",
+	}
+
+	// captionSourceTypes maps the formats of caption sources to the source
+	// types of caption selectors.
+	captionSourceTypes = map[string]string{
+		"srt":    "SRT",
+		"webvtt": "WebVTT",
+		"scc":    "SCC",
+		"dfxp":   "TTML",
+	}
+
+	codecSettingsEnd = regexp.MustCompile(`</\w+_settings>`)
+
+	// xmlElementName matches the names that provider options can use as
+	// XML elements.
+	xmlElementName = regexp.MustCompile(`^[A-Za-z_][\w.-]*$`)
+)
+
+// codecSetting is an element of the codec settings in the XML of a preset.
+type codecSetting struct {
+	name  string
+	value string
+}
 
 func init() {
 	provider.Register(Name, elementalConductorFactory)
@@ -51,26 +124,49 @@ func (p *elementalConductorProvider) DeletePreset(presetID string) error {
 }
 
 func (p *elementalConductorProvider) CreatePreset(preset db.Preset) (string, error) {
+	if preset.Overlay.Enabled() {
+		if _, err := buildImageInserter(preset.Overlay, elementalconductor.Location{}); err != nil {
+			return "", err
+		}
+	}
+	if err := provider.CheckVideoCodec(Name, p.Capabilities(), preset); err != nil {
+		return "", err
+	}
+	if err := provider.CheckPresetFields(Name, preset, unsupportedPresetFields...); err != nil {
+		return "", err
+	}
 	elementalConductorPreset := elementalconductor.Preset{
 		XMLName: xml.Name{Local: "preset"},
 	}
 	elementalConductorPreset.Name = preset.Name
 	elementalConductorPreset.Description = preset.Description
 	elementalConductorPreset.Container = preset.Container
+	if preset.Container == dashPresetContainer {
+		elementalConductorPreset.Container = string(dashContainer)
+	}
 	elementalConductorPreset.Profile = preset.Profile
 	elementalConductorPreset.ProfileLevel = preset.ProfileLevel
 	elementalConductorPreset.RateControl = preset.RateControl
 	elementalConductorPreset.Width = preset.Video.Width
 	elementalConductorPreset.Height = preset.Video.Height
-	elementalConductorPreset.VideoCodec = preset.Video.Codec
+	elementalConductorPreset.VideoCodec = videoCodecs.ProviderCodec(preset.Video.Codec)
 	elementalConductorPreset.VideoBitrate = preset.Video.Bitrate
 	elementalConductorPreset.GopSize = preset.Video.GopSize
 	elementalConductorPreset.GopMode = preset.Video.GopMode
 	elementalConductorPreset.InterlaceMode = preset.Video.InterlaceMode
 	elementalConductorPreset.AudioCodec = preset.Audio.Codec
 	elementalConductorPreset.AudioBitrate = preset.Audio.Bitrate
+	unknown, err := provider.ApplyOptions(Name, &elementalConductorPreset, "xml", preset.ProviderOptions[Name])
+	if err != nil {
+		return "", err
+	}
 
-	result, err := p.client.CreatePreset(&elementalConductorPreset)
+	var result *elementalconductor.Preset
+	if settings := codecSettings(preset); len(settings) > 0 || len(unknown) > 0 {
+		result, err = p.createPresetXML(&elementalConductorPreset, settings, unknown)
+	} else {
+		result, err = p.client.CreatePreset(&elementalConductorPreset)
+	}
 	if err != nil {
 		return "", err
 	}
@@ -78,6 +174,133 @@ func (p *elementalConductorProvider) CreatePreset(preset db.Preset) (string, err
 	return result.Name, nil
 }
 
+// codecSettings returns the codec settings of the preset that the preset
+// type of the Elemental client doesn't include.
+//
+// Keyframes are aligned by disabling scene change detection, so they're
+// placed only at the intervals defined by the GOP size.
+func codecSettings(preset db.Preset) []codecSetting {
+	var settings []codecSetting
+	if preset.Video.AlignKeyframes {
+		settings = append(settings, codecSetting{"scene_change_detect", "false"})
+	}
+	for _, setting := range []codecSetting{
+		{"num_ref_frames", preset.Video.ReferenceFrames},
+		{"max_bitrate", preset.Video.MaxBitrate},
+		{"buf_size", preset.Video.BufferSize},
+	} {
+		if setting.value != "" {
+			settings = append(settings, setting)
+		}
+	}
+	return settings
+}
+
+// createPresetXML creates the preset from its XML, adding the given settings
+// to the codec settings of the video description and the given options to
+// the preset as elements.
+func (p *elementalConductorProvider) createPresetXML(preset *elementalconductor.Preset, settings []codecSetting, options map[string]interface{}) (*elementalconductor.Preset, error) {
+	data, err := xml.Marshal(preset)
+	if err != nil {
+		return nil, err
+	}
+	if len(settings) > 0 {
+		if data, err = addCodecSettings(data, settings); err != nil {
+			return nil, err
+		}
+	}
+	if len(options) > 0 {
+		end := bytes.LastIndex(data, []byte("</preset>"))
+		if end < 0 {
+			return nil, errors.New("unable to add provider options: invalid preset")
+		}
+		elements, err := optionElements(options)
+		if err != nil {
+			return nil, err
+		}
+		data = insertBytes(data, end, elements)
+	}
+	return p.client.CreatePresetXML(data)
+}
+
+// optionElements returns the given provider options as XML elements, sorted
+// by name.
+func optionElements(options map[string]interface{}) ([]byte, error) {
+	var elements bytes.Buffer
+	for _, name := range sortedNames(options) {
+		if err := writeOptionElement(&elements, name, options[name]); err != nil {
+			return nil, &provider.InvalidProviderOptionError{Provider: Name, Option: name, Reason: err.Error()}
+		}
+	}
+	return elements.Bytes(), nil
+}
+
+// addCodecSettings adds the given settings to the codec settings of the
+// video description in the XML of a preset.
+func addCodecSettings(data []byte, settings []codecSetting) ([]byte, error) {
+	end := bytes.Index(data, []byte("</video_description>"))
+	if end < 0 {
+		return nil, errors.New("unable to add codec settings: the preset has no video description")
+	}
+	matches := codecSettingsEnd.FindAllIndex(data[:end], -1)
+	if len(matches) == 0 {
+		return nil, errors.New("unable to add codec settings: the preset has no codec settings")
+	}
+	var elements bytes.Buffer
+	for _, setting := range settings {
+		elements.WriteString("<" + setting.name + ">")
+		xml.EscapeText(&elements, []byte(setting.value))
+		elements.WriteString("</" + setting.name + ">")
+	}
+	return insertBytes(data, matches[len(matches)-1][0], elements.Bytes()), nil
+}
+
+// writeOptionElement writes a provider option as an XML element. Objects
+// become nested elements, and arrays repeat the element for each item.
+func writeOptionElement(buf *bytes.Buffer, name string, value interface{}) error {
+	if !xmlElementName.MatchString(name) {
+		return fmt.Errorf("%q is not a valid XML element name", name)
+	}
+	switch v := value.(type) {
+	case []interface{}:
+		for _, item := range v {
+			if err := writeOptionElement(buf, name, item); err != nil {
+				return err
+			}
+		}
+		return nil
+	case map[string]interface{}:
+		buf.WriteString("<" + name + ">")
+		for _, child := range sortedNames(v) {
+			if err := writeOptionElement(buf, child, v[child]); err != nil {
+				return err
+			}
+		}
+		buf.WriteString("</" + name + ">")
+		return nil
+	}
+	buf.WriteString("<" + name + ">")
+	xml.EscapeText(buf, []byte(provider.OptionString(value)))
+	buf.WriteString("</" + name + ">")
+	return nil
+}
+
+func insertBytes(data []byte, at int, elements []byte) []byte {
+	result := make([]byte, 0, len(data)+len(elements))
+	result = append(result, data[:at]...)
+	result = append(result, elements...)
+	return append(result, data[at:]...)
+}
+
+func sortedNames(values map[string]interface{}) []string {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 func (p *elementalConductorProvider) GetPreset(presetID string) (interface{}, error) {
 	preset, err := p.client.GetPreset(presetID)
 	if err != nil {
@@ -86,12 +309,91 @@ func (p *elementalConductorProvider) GetPreset(presetID string) (interface{}, er
 	return preset, err
 }
 
+func (p *elementalConductorProvider) GetNormalizedPreset(presetID string) (*db.Preset, error) {
+	preset, err := p.client.GetPreset(presetID)
+	if err != nil {
+		return nil, err
+	}
+	data, err := p.client.GetPresetXML(presetID)
+	if err != nil {
+		return nil, err
+	}
+	settings, err := readCodecSettings(data)
+	if err != nil {
+		return nil, err
+	}
+	container := preset.Container
+	if container == string(dashContainer) {
+		container = dashPresetContainer
+	}
+	return &db.Preset{
+		Name:         preset.Name,
+		Description:  preset.Description,
+		Container:    container,
+		Profile:      preset.Profile,
+		ProfileLevel: preset.ProfileLevel,
+		RateControl:  preset.RateControl,
+		Video: db.VideoPreset{
+			Width:         preset.Width,
+			Height:        preset.Height,
+			Codec:         videoCodecs.PresetCodec(preset.VideoCodec),
+			Bitrate:       preset.VideoBitrate,
+			GopSize:       preset.GopSize,
+			GopMode:       preset.GopMode,
+			InterlaceMode: preset.InterlaceMode,
+
+			AlignKeyframes:  settings["scene_change_detect"] == "false",
+			ReferenceFrames: settings["num_ref_frames"],
+			MaxBitrate:      settings["max_bitrate"],
+			BufferSize:      settings["buf_size"],
+		},
+		Audio: db.AudioPreset{
+			Codec:   preset.AudioCodec,
+			Bitrate: preset.AudioBitrate,
+		},
+	}, nil
+}
+
+// xmlElement is a generic XML element, used for reading settings that the
+// types of the Elemental client don't include.
+type xmlElement struct {
+	XMLName  xml.Name
+	Value    string       `xml:",chardata"`
+	Children []xmlElement `xml:",any"`
+}
+
+// readCodecSettings returns the codec settings of the video description in
+// the XML of a preset, reverting addCodecSettings.
+func readCodecSettings(data []byte) (map[string]string, error) {
+	var preset struct {
+		VideoDescription xmlElement `xml:"video_description"`
+	}
+	if err := xml.Unmarshal(data, &preset); err != nil {
+		return nil, fmt.Errorf("unable to read codec settings: %s", err)
+	}
+	settings := make(map[string]string)
+	for _, element := range preset.VideoDescription.Children {
+		if !strings.HasSuffix(element.XMLName.Local, "_settings") {
+			continue
+		}
+		for _, setting := range element.Children {
+			settings[setting.XMLName.Local] = strings.TrimSpace(setting.Value)
+		}
+	}
+	return settings, nil
+}
+
 func (p *elementalConductorProvider) Transcode(job *db.Job, transcodeProfile provider.TranscodeProfile) (*provider.JobStatus, error) {
-	newJob, err := p.newJob(job, transcodeProfile)
+	newJob, elements, err := p.newJob(job, transcodeProfile)
 	if err != nil {
 		return nil, err
 	}
-	resp, err := p.client.CreateJob(newJob)
+	var resp *elementalconductor.Job
+	if len(elements) > 0 {
+		resp, err = p.createJobXML(newJob, elements)
+	} else {
+		resp, err = p.client.CreateJob(newJob)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -123,6 +425,10 @@ func (p *elementalConductorProvider) JobStatus(job *db.Job) (*provider.JobStatus
 	if len(resp.ErrorMessages) > 0 {
 		providerStatus["error_messages"] = resp.ErrorMessages
 	}
+	destination, err := p.getOutputDestination(job)
+	if err != nil {
+		return nil, err
+	}
 	var duration time.Duration
 	if resp.ContentDuration != nil {
 		duration = time.Duration(resp.ContentDuration.InputDuration) * time.Second
@@ -140,14 +446,45 @@ func (p *elementalConductorProvider) JobStatus(job *db.Job) (*provider.JobStatus
 			Width:      resp.Input.InputInfo.Video.GetWidth(),
 		},
 		Output: provider.JobOutput{
-			Destination: p.getOutputDestination(job),
+			Destination: destination.WithoutCredentials().String(),
 			Files:       p.getOutputFiles(resp),
+			Captions:    p.getCaptionFiles(job, resp),
 		},
 	}, nil
 }
 
-func (p *elementalConductorProvider) getOutputDestination(job *db.Job) string {
-	return strings.TrimRight(p.config.ElementalConductor.Destination, "/") + "/" + job.ID
+// getOutputDestination returns the location of the directory that holds
+// the outputs of the job.
+func (p *elementalConductorProvider) getOutputDestination(job *db.Job) (*mediaurl.Location, error) {
+	destination := job.Destination
+	if destination == "" {
+		destination = p.config.ElementalConductor.Destination
+	}
+	loc, err := mediaurl.Parse(destination)
+	if err == nil {
+		err = loc.Require(destinationSchemes...)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid destination %q: %s", destination, err)
+	}
+	return loc.Join(job.OutputDirectory()), nil
+}
+
+// location translates the given media location to the format expected by
+// Elemental Conductor. Files in S3 are accessed using the AWS credentials
+// from the configuration, while other locations use the credentials
+// included in their URLs.
+func (p *elementalConductorProvider) location(loc *mediaurl.Location) elementalconductor.Location {
+	location := elementalconductor.Location{
+		URI:      loc.WithoutCredentials().String(),
+		Username: loc.Username,
+		Password: loc.Password,
+	}
+	if loc.Scheme == mediaurl.S3 {
+		location.Username = p.client.GetAccessKeyID()
+		location.Password = p.client.GetSecretAccessKey()
+	}
+	return location
 }
 
 func (p *elementalConductorProvider) getOutputFiles(job *elementalconductor.Job) []provider.OutputFile {
@@ -159,8 +496,18 @@ func (p *elementalConductorProvider) getOutputFiles(job *elementalconductor.Job)
 				Path:      outputGroup.AppleLiveGroupSettings.Destination.URI + ".m3u8",
 				Container: "m3u8",
 			})
+		} else if outputGroup.Type == dashOutputGroupType {
+			if len(outputGroup.Output) > 0 {
+				files = append(files, provider.OutputFile{
+					Path:      dashPlaylistPath(outputGroup.Output[0]),
+					Container: dashPresetContainer,
+				})
+			}
 		} else {
 			for _, output := range outputGroup.Output {
+				if isCaptionStream(output.StreamAssemblyName) {
+					continue
+				}
 				streamFiles[output.StreamAssemblyName] = provider.OutputFile{
 					Path:      output.FullURI,
 					Container: string(output.Container),
@@ -179,6 +526,39 @@ func (p *elementalConductorProvider) getOutputFiles(job *elementalconductor.Job)
 	return files
 }
 
+// getCaptionFiles returns the WebVTT files generated from the captions of
+// the job, either as sidecar files or as renditions of the HLS playlist.
+func (p *elementalConductorProvider) getCaptionFiles(job *db.Job, resp *elementalconductor.Job) []provider.CaptionFile {
+	var files []provider.CaptionFile
+	for _, outputGroup := range resp.OutputGroup {
+		for _, output := range outputGroup.Output {
+			if !isCaptionStream(output.StreamAssemblyName) {
+				continue
+			}
+			file := provider.CaptionFile{Path: output.FullURI, Format: captionFormat}
+			index, err := strconv.Atoi(strings.TrimPrefix(output.StreamAssemblyName, captionStreamPrefix))
+			if err == nil && index < len(job.CaptionLanguages) {
+				file.Language = job.CaptionLanguages[index]
+			}
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// dashPlaylistPath returns the path of the playlist of the DASH output group
+// of the given output. The client doesn't include the destination of DASH
+// output groups, so the path is taken from the URI of the output, named after
+// the destination with the name modifier of the output.
+func dashPlaylistPath(output elementalconductor.Output) string {
+	uri := strings.TrimSuffix(output.FullURI, filepath.Ext(output.FullURI))
+	return strings.TrimSuffix(uri, output.NameModifier) + dashPlaylistExtension
+}
+
+func isCaptionStream(streamAssemblyName string) bool {
+	return strings.HasPrefix(streamAssemblyName, captionStreamPrefix)
+}
+
 func (p *elementalConductorProvider) statusMap(elementalConductorStatus string) provider.Status {
 	switch strings.ToLower(elementalConductorStatus) {
 	case "pending":
@@ -198,6 +578,7 @@ func (p *elementalConductorProvider) statusMap(elementalConductorStatus string)
 
 func (p *elementalConductorProvider) buildOutputGroupAndStreamAssemblies(outputLocation elementalconductor.Location, transcodeProfile provider.TranscodeProfile) ([]elementalconductor.OutputGroup, []elementalconductor.StreamAssembly, error) {
 	var streamingOutputList []elementalconductor.Output
+	var dashOutputList []elementalconductor.Output
 	var streamAssemblyList []elementalconductor.StreamAssembly
 	var outputGroupList []elementalconductor.OutputGroup
 	var outputGroupOrder int
@@ -221,6 +602,11 @@ func (p *elementalConductorProvider) buildOutputGroupAndStreamAssemblies(outputL
 			out.Container = elementalconductor.AppleHTTPLiveStreaming
 			out.Order = streamingGroupOrder
 			streamingOutputList = append(streamingOutputList, out)
+		} else if presetStruct.Container == string(dashContainer) {
+			out.NameModifier = fmt.Sprintf("_%010d", len(dashOutputList)+1)
+			out.Container = dashContainer
+			out.Order = len(dashOutputList) + 1
+			dashOutputList = append(dashOutputList, out)
 		} else {
 			outputGroupOrder++
 			location := outputLocation
@@ -243,10 +629,39 @@ func (p *elementalConductorProvider) buildOutputGroupAndStreamAssemblies(outputL
 		}
 		streamAssemblyList = append(streamAssemblyList, streamAssembly)
 	}
+	// captions are added to the HLS playlist as WebVTT renditions or,
+	// in jobs without HLS outputs, written as WebVTT sidecar files.
+	for index, caption := range transcodeProfile.Captions {
+		streamAssemblyName := captionStreamPrefix + strconv.Itoa(index)
+		out := elementalconductor.Output{StreamAssemblyName: streamAssemblyName}
+		if len(streamingOutputList) > 0 {
+			streamingGroupOrder++
+			out.NameModifier = "_" + caption.Language
+			out.Container = elementalconductor.AppleHTTPLiveStreaming
+			out.Order = streamingGroupOrder
+			streamingOutputList = append(streamingOutputList, out)
+		} else {
+			outputGroupOrder++
+			location := outputLocation
+			location.URI += "/" + captionOutputsDirectory + "/" + caption.Language
+			out.Container = captionContainer
+			out.Extension = captionExtension
+			out.Order = 1
+			outputGroupList = append(outputGroupList, elementalconductor.OutputGroup{
+				Order:  outputGroupOrder,
+				Type:   elementalconductor.FileOutputGroupType,
+				Output: []elementalconductor.Output{out},
+				FileGroupSettings: &elementalconductor.FileGroupSettings{
+					Destination: &location,
+				},
+			})
+		}
+		streamAssemblyList = append(streamAssemblyList, elementalconductor.StreamAssembly{Name: streamAssemblyName})
+	}
 	if len(streamingOutputList) > 0 {
 		playlistFileName := transcodeProfile.StreamingParams.PlaylistFileName
 		location := outputLocation
-		location.URI += "/" + strings.TrimRight(playlistFileName, filepath.Ext(playlistFileName))
+		location.URI += "/" + strings.TrimSuffix(playlistFileName, filepath.Ext(playlistFileName))
 		outputGroupOrder++
 		streamingOutputGroup := elementalconductor.OutputGroup{
 			Order: outputGroupOrder,
@@ -260,26 +675,216 @@ func (p *elementalConductorProvider) buildOutputGroupAndStreamAssemblies(outputL
 		}
 		outputGroupList = append(outputGroupList, streamingOutputGroup)
 	}
+	if len(dashOutputList) > 0 {
+		outputGroupOrder++
+		outputGroupList = append(outputGroupList, elementalconductor.OutputGroup{
+			Order:  outputGroupOrder,
+			Type:   dashOutputGroupType,
+			Output: dashOutputList,
+		})
+	}
 	return outputGroupList, streamAssemblyList, nil
 }
 
-// newJob constructs a job spec from the given source and presets
-func (p *elementalConductorProvider) newJob(job *db.Job, transcodeProfile provider.TranscodeProfile) (*elementalconductor.Job, error) {
-	inputLocation := elementalconductor.Location{
-		URI:      transcodeProfile.SourceMedia,
-		Username: p.client.GetAccessKeyID(),
-		Password: p.client.GetSecretAccessKey(),
+// buildDASHGroupSettings returns the settings of the DASH output group of the
+// job, writing the playlist to the playlist file name of the job.
+func (p *elementalConductorProvider) buildDASHGroupSettings(outputLocation elementalconductor.Location, params provider.StreamingParams) (jobElement, error) {
+	location := outputLocation
+	location.URI += "/" + strings.TrimSuffix(params.PlaylistFileName, filepath.Ext(params.PlaylistFileName))
+	return newJobElement("output_group", "<type>"+string(dashOutputGroupType)+"</type>", dashISOGroupSettings{
+		Destination:   location,
+		SegmentLength: params.SegmentDuration,
+	})
+}
+
+// buildCaptions returns the elements that read the caption sources of the
+// job and write them as WebVTT in the stream assemblies of the captions.
+func (p *elementalConductorProvider) buildCaptions(captions []provider.Caption) ([]jobElement, error) {
+	elements := make([]jobElement, 0, 2*len(captions))
+	for index, caption := range captions {
+		sourceType, ok := captionSourceTypes[caption.Format]
+		if !ok {
+			return nil, fmt.Errorf("unsupported caption format %q", caption.Format)
+		}
+		source, err := mediaurl.Parse(caption.Source)
+		if err == nil {
+			err = source.Require(sourceSchemes...)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid caption source %q: %s", caption.Source, err)
+		}
+		selectorName := captionSelectorPrefix + strconv.Itoa(index)
+		selector, err := newJobElement("input", "", captionSelector{
+			Name:       selectorName,
+			SourceType: sourceType,
+			SourceFile: p.location(source),
+		})
+		if err != nil {
+			return nil, err
+		}
+		languageDescription := caption.Label
+		if languageDescription == "" {
+			languageDescription = caption.Language
+		}
+		description, err := streamAssemblyElement(captionStreamPrefix+strconv.Itoa(index), captionDescription{
+			CaptionSourceName:   selectorName,
+			DestinationType:     captionDestinationType,
+			LanguageDescription: languageDescription,
+		})
+		if err != nil {
+			return nil, err
+		}
+		elements = append(elements, selector, description)
+	}
+	return elements, nil
+}
+
+// buildEncryption returns the encryption settings of the Apple Live output
+// group of the job, which encrypts the segments with the static key of the
+// job.
+func buildEncryption(encryption *provider.Encryption, outputGroups []elementalconductor.OutputGroup) (jobElement, error) {
+	encryptionType, ok := encryptionTypes[encryption.Method]
+	if !ok {
+		return jobElement{}, fmt.Errorf("encryption method %q is not supported by Elemental Conductor", encryption.Method)
+	}
+	key, err := base64.StdEncoding.DecodeString(encryption.Key)
+	if err != nil {
+		return jobElement{}, fmt.Errorf("invalid encryption key: %s", err)
+	}
+	for _, group := range outputGroups {
+		if group.Type == elementalconductor.AppleLiveOutputGroupType {
+			return newJobSettings("apple_live_group_settings", "", hlsEncryption{
+				EncryptionType:  encryptionType,
+				KeyProviderType: staticKeyProviderType,
+				StaticKeyValue:  hex.EncodeToString(key),
+				KeyURI:          encryption.KeyURI,
+			})
+		}
+	}
+	return jobElement{}, errEncryptionWithoutHLS
+}
+
+// buildOverlays returns the image inserters of the stream assemblies of the
+// outputs whose presets define an overlay, placing the overlay image of the
+// job.
+func (p *elementalConductorProvider) buildOverlays(transcodeProfile provider.TranscodeProfile) ([]jobElement, error) {
+	if transcodeProfile.OverlayImage == "" {
+		return nil, nil
+	}
+	image, err := mediaurl.Parse(transcodeProfile.OverlayImage)
+	if err == nil {
+		err = image.Require(sourceSchemes...)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid overlay image %q: %s", transcodeProfile.OverlayImage, err)
 	}
-	baseLocation := strings.TrimRight(p.config.ElementalConductor.Destination, "/")
-	outputLocation := elementalconductor.Location{
-		URI:      baseLocation + "/" + job.ID,
-		Username: p.client.GetAccessKeyID(),
-		Password: p.client.GetSecretAccessKey(),
+	var elements []jobElement
+	for index, output := range transcodeProfile.Outputs {
+		preset := output.Preset.Preset
+		if preset == nil || !preset.Overlay.Enabled() {
+			continue
+		}
+		inserter, err := buildImageInserter(preset.Overlay, p.location(image))
+		if err != nil {
+			return nil, err
+		}
+		element, err := streamAssemblyElement("stream_"+strconv.Itoa(index), inserter)
+		if err != nil {
+			return nil, err
+		}
+		elements = append(elements, element)
 	}
+	return elements, nil
+}
+
+// buildImageInserter returns the image inserter that places the given image
+// as defined by the overlay of a preset. Elemental Conductor places images at
+// an offset from the top-left corner of the video.
+func buildImageInserter(overlay db.OverlayPreset, image elementalconductor.Location) (imageInserter, error) {
+	if overlay.Position != "top-left" {
+		return imageInserter{}, fmt.Errorf("overlay position %q is not supported by Elemental Conductor, only top-left", overlay.Position)
+	}
+	if overlay.StartTime != "" || overlay.EndTime != "" {
+		return imageInserter{}, errors.New("overlay time range is not supported by Elemental Conductor")
+	}
+	x, err := overlayValue(overlay.OffsetX, 0, "offset")
+	if err != nil {
+		return imageInserter{}, err
+	}
+	y, err := overlayValue(overlay.OffsetY, 0, "offset")
+	if err != nil {
+		return imageInserter{}, err
+	}
+	opacity, err := overlayValue(overlay.Opacity, 100, "opacity")
+	if err != nil {
+		return imageInserter{}, err
+	}
+	if opacity < 0 || opacity > 100 {
+		return imageInserter{}, fmt.Errorf("invalid overlay opacity %q", overlay.Opacity)
+	}
+	return imageInserter{
+		Enabled: true,
+		Image:   insertableImage{ImageX: x, ImageY: y, Opacity: opacity, Input: image},
+	}, nil
+}
+
+func overlayValue(value string, defaultValue int, name string) (int, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid overlay %s %q", name, value)
+	}
+	return n, nil
+}
+
+// newJob constructs a job spec from the given source and presets, along
+// with the elements that must be added to its XML for the settings that
+// the job type of the Elemental client doesn't include.
+func (p *elementalConductorProvider) newJob(job *db.Job, transcodeProfile provider.TranscodeProfile) (*elementalconductor.Job, []jobElement, error) {
+	source, err := mediaurl.Parse(transcodeProfile.SourceMedia)
+	if err == nil {
+		err = source.Require(sourceSchemes...)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid source %q: %s", transcodeProfile.SourceMedia, err)
+	}
+	destination, err := p.getOutputDestination(job)
+	if err != nil {
+		return nil, nil, err
+	}
+	inputLocation := p.location(source)
+	outputLocation := p.location(destination)
 	outputGroup, streamAssemblyList, err := p.buildOutputGroupAndStreamAssemblies(outputLocation, transcodeProfile)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	elements, err := p.buildCaptions(transcodeProfile.Captions)
+	if err != nil {
+		return nil, nil, err
 	}
+	for _, group := range outputGroup {
+		if group.Type == dashOutputGroupType {
+			settings, err := p.buildDASHGroupSettings(outputLocation, transcodeProfile.StreamingParams)
+			if err != nil {
+				return nil, nil, err
+			}
+			elements = append(elements, settings)
+		}
+	}
+	if encryption := transcodeProfile.StreamingParams.Encryption; encryption != nil {
+		settings, err := buildEncryption(encryption, outputGroup)
+		if err != nil {
+			return nil, nil, err
+		}
+		elements = append(elements, settings)
+	}
+	overlays, err := p.buildOverlays(transcodeProfile)
+	if err != nil {
+		return nil, nil, err
+	}
+	elements = append(elements, overlays...)
 	newJob := elementalconductor.Job{
 		XMLName: xml.Name{
 			Local: "job",
@@ -291,7 +896,18 @@ func (p *elementalConductorProvider) newJob(job *db.Job, transcodeProfile provid
 		OutputGroup:    outputGroup,
 		StreamAssembly: streamAssemblyList,
 	}
-	return &newJob, nil
+	unknown, err := provider.ApplyOptions(Name, &newJob, "xml", transcodeProfile.ProviderOptions[Name])
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(unknown) > 0 {
+		options, err := optionElements(unknown)
+		if err != nil {
+			return nil, nil, err
+		}
+		elements = append(elements, jobElement{parent: "job", data: options})
+	}
+	return &newJob, elements, nil
 }
 
 func (p *elementalConductorProvider) CancelJob(id string) error {
@@ -322,9 +938,13 @@ func (p *elementalConductorProvider) Healthcheck() error {
 
 func (p *elementalConductorProvider) Capabilities() provider.Capabilities {
 	return provider.Capabilities{
-		InputFormats:  []string{"prores", "h264"},
-		OutputFormats: []string{"mp4", "hls"},
-		Destinations:  []string{"akamai", "s3"},
+		InputFormats:         []string{"prores", "h264"},
+		OutputFormats:        []string{"mp4", "hls", "dash"},
+		VideoCodecs:          videoCodecs.Codecs(),
+		CaptionFormats:       provider.CaptionFormats,
+		HLSSegmentFormats:    []string{"ts"},
+		HLSEncryptionMethods: []string{"aes-128", "sample-aes"},
+		Destinations:         []string{"akamai", "ftp", "s3"},
 	}
 }
 
@@ -342,5 +962,5 @@ func elementalConductorFactory(cfg *config.Config) (provider.TranscodingProvider
 		cfg.ElementalConductor.SecretAccessKey,
 		cfg.ElementalConductor.Destination,
 	)
-	return &elementalConductorProvider{client: client, config: cfg}, nil
+	return &elementalConductorProvider{client: &conductorClient{Client: client}, config: cfg}, nil
 }
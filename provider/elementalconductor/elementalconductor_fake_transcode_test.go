@@ -1,6 +1,7 @@
 package elementalconductor
 
 import (
+	"encoding/xml"
 	"strings"
 
 	"github.com/NYTimes/encoding-wrapper/elementalconductor"
@@ -12,6 +13,9 @@ type fakeElementalConductorClient struct {
 	*elementalconductor.Client
 	jobs         map[string]elementalconductor.Job
 	canceledJobs []string
+	presets      []elementalconductor.Preset
+	presetXML    []byte
+	jobXML       []byte
 }
 
 func newFakeElementalConductorClient(cfg *config.Config) *fakeElementalConductorClient {
@@ -45,19 +49,62 @@ func (c *fakeElementalConductorClient) GetPreset(presetID string) (*elementalcon
 	container := elementalconductor.MPEG4
 	if strings.Contains(presetID, "hls") {
 		container = elementalconductor.AppleHTTPLiveStreaming
+	} else if strings.Contains(presetID, "dash") {
+		container = dashContainer
+	}
+	var videoCodec string
+	if strings.Contains(presetID, "hevc") {
+		videoCodec = "hevc"
 	}
 	return &elementalconductor.Preset{
-		Name:      presetID,
-		Container: string(container),
+		Name:       presetID,
+		Container:  string(container),
+		VideoCodec: videoCodec,
 	}, nil
 }
 
+// GetPresetXML returns the XML of the last preset created from XML when it
+// has the given name, or the XML of the preset returned by GetPreset.
+func (c *fakeElementalConductorClient) GetPresetXML(presetID string) ([]byte, error) {
+	if c.presetXML != nil {
+		var preset elementalconductor.Preset
+		if err := xml.Unmarshal(c.presetXML, &preset); err == nil && preset.Name == presetID {
+			return c.presetXML, nil
+		}
+	}
+	preset, err := c.GetPreset(presetID)
+	if err != nil {
+		return nil, err
+	}
+	return xml.Marshal(preset)
+}
+
 func (c *fakeElementalConductorClient) CreatePreset(preset *elementalconductor.Preset) (*elementalconductor.Preset, error) {
+	c.presets = append(c.presets, *preset)
 	return &elementalconductor.Preset{
 		Name: preset.Name,
 	}, nil
 }
 
+func (c *fakeElementalConductorClient) CreatePresetXML(data []byte) (*elementalconductor.Preset, error) {
+	c.presetXML = data
+	var preset elementalconductor.Preset
+	if err := xml.Unmarshal(data, &preset); err != nil {
+		return nil, err
+	}
+	return &elementalconductor.Preset{Name: preset.Name}, nil
+}
+
+func (c *fakeElementalConductorClient) CreateJobXML(data []byte) (*elementalconductor.Job, error) {
+	c.jobXML = data
+	var job elementalconductor.Job
+	if err := xml.Unmarshal(data, &job); err != nil {
+		return nil, err
+	}
+	job.Href = "/jobs/1"
+	return &job, nil
+}
+
 func (c *fakeElementalConductorClient) GetJob(jobID string) (*elementalconductor.Job, error) {
 	job := c.jobs[jobID]
 	return &job, nil
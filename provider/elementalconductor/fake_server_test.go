@@ -2,8 +2,10 @@ package elementalconductor
 
 import (
 	"encoding/xml"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 
 	"github.com/NYTimes/encoding-wrapper/elementalconductor"
 )
@@ -17,6 +19,15 @@ type ElementalServer struct {
 	*httptest.Server
 	nodes  *nodeList
 	config *elementalconductor.CloudConfig
+
+	// last request to create or get a preset, and the XML of the last
+	// preset created, returned for any preset
+	presetRequest *http.Request
+	presetXML     []byte
+
+	// last request to create a job
+	jobRequest *http.Request
+	jobXML     []byte
 }
 
 func NewElementalServer(config *elementalconductor.CloudConfig, nodes []elementalconductor.Node) *ElementalServer {
@@ -36,7 +47,36 @@ func (s *ElementalServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	case "/api/config/cloud":
 		w.Header().Set("Content-Type", "application/xml")
 		xml.NewEncoder(w).Encode(s.config)
+	case "/api/presets":
+		s.presetRequest = r
+		s.presetXML, _ = ioutil.ReadAll(r.Body)
+		var preset elementalconductor.Preset
+		if err := xml.Unmarshal(s.presetXML, &preset); err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		w.WriteHeader(http.StatusCreated)
+		xml.NewEncoder(w).Encode(&preset)
+	case "/api/jobs":
+		s.jobRequest = r
+		s.jobXML, _ = ioutil.ReadAll(r.Body)
+		var job elementalconductor.Job
+		if err := xml.Unmarshal(s.jobXML, &job); err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		job.Href = "/jobs/1"
+		w.Header().Set("Content-Type", "application/xml")
+		w.WriteHeader(http.StatusCreated)
+		xml.NewEncoder(w).Encode(&job)
 	default:
+		if strings.HasPrefix(r.URL.Path, "/api/presets/") && s.presetXML != nil {
+			s.presetRequest = r
+			w.Header().Set("Content-Type", "application/xml")
+			w.Write(s.presetXML)
+			return
+		}
 		http.Error(w, "not found", http.StatusNotFound)
 	}
 }
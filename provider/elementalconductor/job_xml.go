@@ -0,0 +1,148 @@
+package elementalconductor
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+
+	"github.com/NYTimes/encoding-wrapper/elementalconductor"
+)
+
+// jobElement is an XML element added to a job for settings that the job
+// type of the Elemental client doesn't include. The element is added to the
+// end of the first element of the job with the given parent name whose
+// content includes the given text.
+type jobElement struct {
+	parent  string
+	content string
+	data    []byte
+}
+
+// captionSelector is the element of the input of a job that reads a
+// sidecar caption file.
+type captionSelector struct {
+	XMLName    xml.Name                    `xml:"caption_selector"`
+	Name       string                      `xml:"name"`
+	SourceType string                      `xml:"source_type"`
+	SourceFile elementalconductor.Location `xml:"file_source_settings>source_file"`
+}
+
+// captionDescription is the element of a stream assembly that writes the
+// captions read by a caption selector.
+type captionDescription struct {
+	XMLName             xml.Name `xml:"caption_description"`
+	CaptionSourceName   string   `xml:"caption_source_name"`
+	DestinationType     string   `xml:"destination_type"`
+	LanguageDescription string   `xml:"language_description,omitempty"`
+}
+
+// newJobElement returns the element with the XML representation of v.
+func newJobElement(parent, content string, v interface{}) (jobElement, error) {
+	data, err := xml.Marshal(v)
+	if err != nil {
+		return jobElement{}, err
+	}
+	return jobElement{parent: parent, content: content, data: data}, nil
+}
+
+// newJobSettings returns the element with the XML representation of the
+// fields of v, which are added to the parent without the element of v.
+func newJobSettings(parent, content string, v interface{}) (jobElement, error) {
+	element, err := newJobElement(parent, content, v)
+	if err != nil {
+		return jobElement{}, err
+	}
+	start := bytes.IndexByte(element.data, '>') + 1
+	end := bytes.LastIndexByte(element.data, '<')
+	element.data = element.data[start:end]
+	return element, nil
+}
+
+// streamAssemblyElement returns an element added to the stream assembly
+// with the given name.
+func streamAssemblyElement(streamAssemblyName string, v interface{}) (jobElement, error) {
+	return newJobElement("stream_assembly", "<name>"+streamAssemblyName+"</name>", v)
+}
+
+// createJobXML creates the job from its XML, adding the given elements.
+func (p *elementalConductorProvider) createJobXML(job *elementalconductor.Job, elements []jobElement) (*elementalconductor.Job, error) {
+	data, err := jobXML(job, elements)
+	if err != nil {
+		return nil, err
+	}
+	return p.client.CreateJobXML(data)
+}
+
+// jobXML returns the XML representation of the job, including the given
+// elements.
+func jobXML(job *elementalconductor.Job, elements []jobElement) ([]byte, error) {
+	data, err := xml.Marshal(job)
+	if err != nil {
+		return nil, err
+	}
+	for _, element := range elements {
+		if data, err = insertElement(data, element); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// insertElement adds the element to the end of its parent in the given XML.
+func insertElement(data []byte, element jobElement) ([]byte, error) {
+	start := []byte("<" + element.parent + ">")
+	end := []byte("</" + element.parent + ">")
+	for offset := 0; ; {
+		i := bytes.Index(data[offset:], start)
+		if i < 0 {
+			break
+		}
+		i += offset
+		j := bytes.Index(data[i:], end)
+		if j < 0 {
+			break
+		}
+		j += i
+		if bytes.Contains(data[i:j], []byte(element.content)) {
+			return insertBytes(data, j, element.data), nil
+		}
+		offset = j + len(end)
+	}
+	return nil, fmt.Errorf("unable to add elements to the job: no %s element includes %q", element.parent, element.content)
+}
+
+// imageInserter is the element of a stream assembly that places an image
+// over its video. Its settings are added to the video settings of the preset
+// of the stream assembly.
+type imageInserter struct {
+	XMLName xml.Name        `xml:"video_description"`
+	Enabled bool            `xml:"video_preprocessors>image_inserter>enable_image_inserter"`
+	Image   insertableImage `xml:"video_preprocessors>image_inserter>insertable_image"`
+}
+
+// insertableImage is the image placed by an image inserter, at the given
+// offset from the top-left corner of the video.
+type insertableImage struct {
+	ImageX  int                         `xml:"image_x"`
+	ImageY  int                         `xml:"image_y"`
+	Opacity int                         `xml:"opacity"`
+	Input   elementalconductor.Location `xml:"image_inserter_input"`
+}
+
+// dashISOGroupSettings is the element of a DASH ISO output group that defines
+// where its playlist and segments are written.
+type dashISOGroupSettings struct {
+	XMLName       xml.Name                    `xml:"dash_iso_group_settings"`
+	Destination   elementalconductor.Location `xml:"destination"`
+	SegmentLength uint                        `xml:"segment_length,omitempty"`
+}
+
+// hlsEncryption contains the settings of an Apple Live output group that
+// encrypt its segments with a static key.
+type hlsEncryption struct {
+	XMLName         xml.Name `xml:"encryption"`
+	EncryptionType  string   `xml:"encryption_type"`
+	KeyProviderType string   `xml:"key_provider_type"`
+	StaticKeyValue  string   `xml:"static_key_settings>static_key_value"`
+	KeyURI          string   `xml:"static_key_settings>key_provider_server>uri"`
+}
@@ -0,0 +1,111 @@
+package encodingcom
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/NYTimes/encoding-wrapper/encodingcom"
+)
+
+// apiClient extends the Encoding.com client with the creation of medias and
+// presets from raw JSON formats, used for settings that the Format type of
+// the client doesn't include.
+type apiClient struct {
+	*encodingcom.Client
+	httpClient *http.Client
+}
+
+// AddMediaJSON creates a media with the given formats, each one the JSON
+// representation of a format.
+func (c *apiClient) AddMediaJSON(source []string, formats []json.RawMessage, region string) (*encodingcom.AddMediaResponse, error) {
+	query := map[string]interface{}{"action": "AddMedia", "source": source, "format": formats}
+	if region != "" {
+		query["region"] = region
+	}
+	var result map[string]*encodingcom.AddMediaResponse
+	if err := c.do(query, &result); err != nil {
+		return nil, err
+	}
+	resp := result["response"]
+	if resp == nil {
+		return nil, errors.New("invalid response returned by the Encoding.com API")
+	}
+	return resp, nil
+}
+
+// SavePresetJSON saves the preset with the given name and format, the JSON
+// representation of a format.
+func (c *apiClient) SavePresetJSON(name string, format json.RawMessage) (*encodingcom.SavePresetResponse, error) {
+	query := map[string]interface{}{"action": "SavePreset", "name": name, "format": []json.RawMessage{format}}
+	var result map[string]*encodingcom.SavePresetResponse
+	if err := c.do(query, &result); err != nil {
+		return nil, err
+	}
+	resp := result["response"]
+	if resp == nil {
+		return nil, errors.New("invalid response returned by the Encoding.com API")
+	}
+	return resp, nil
+}
+
+// do sends the given query to the API, decoding the response into out.
+func (c *apiClient) do(query map[string]interface{}, out interface{}) error {
+	query["userid"] = c.UserID
+	query["userkey"] = c.UserKey
+	data, err := json.Marshal(map[string]interface{}{"query": query})
+	if err != nil {
+		return err
+	}
+	params := url.Values{"json": {string(data)}}
+	req, err := http.NewRequest("POST", c.Endpoint, strings.NewReader(params.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	httpClient := c.httpClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	var errResp struct {
+		Response struct {
+			Errors struct {
+				Error json.RawMessage `json:"error"`
+			} `json:"errors"`
+		} `json:"response"`
+	}
+	if json.Unmarshal(body, &errResp) == nil && len(errResp.Response.Errors.Error) > 0 {
+		return errors.New(errorMessage(errResp.Response.Errors.Error))
+	}
+	return json.Unmarshal(body, out)
+}
+
+// errorMessage returns the message of an error returned by the API, which
+// may be either a string or a list of strings.
+func errorMessage(data json.RawMessage) string {
+	var messages []string
+	if json.Unmarshal(data, &messages) == nil {
+		return strings.Join(messages, "; ")
+	}
+	var message string
+	if json.Unmarshal(data, &message) == nil {
+		return message
+	}
+	return string(data)
+}
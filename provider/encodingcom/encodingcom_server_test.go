@@ -23,6 +23,10 @@ type request struct {
 	MediaID string               `json:"mediaid"`
 	Source  []string             `json:"source"`
 	Format  []encodingcom.Format `json:"format"`
+
+	// formats as sent in the request, including the fields that the
+	// Format type of the client doesn't include
+	RawFormat []map[string]interface{} `json:"-"`
 }
 
 type errorResponse struct {
@@ -87,6 +91,12 @@ func (s *encodingComFakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request
 		s.Error(w, err.Error())
 	}
 	req := m["query"]
+	var raw map[string]struct {
+		Format []map[string]interface{} `json:"format"`
+	}
+	if err = json.Unmarshal([]byte(requestData), &raw); err == nil {
+		req.RawFormat = raw["query"].Format
+	}
 	switch req.Action {
 	case "AddMedia":
 		s.addMedia(w, req)
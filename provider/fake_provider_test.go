@@ -26,6 +26,10 @@ func (*fakeProvider) GetPreset(string) (interface{}, error) {
 	return "", nil
 }
 
+func (*fakeProvider) GetNormalizedPreset(string) (*db.Preset, error) {
+	return nil, nil
+}
+
 func (*fakeProvider) DeletePreset(string) error {
 	return nil
 }
@@ -0,0 +1,100 @@
+package provider
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/NYTimes/video-transcoding-api/db"
+)
+
+// UnsupportedPresetFieldsError is the error returned when a preset defines
+// fields that can't be represented in the presets of a provider.
+type UnsupportedPresetFieldsError struct {
+	Provider string
+	Fields   []string
+}
+
+func (err *UnsupportedPresetFieldsError) Error() string {
+	return fmt.Sprintf("preset fields not supported by %s: %s", err.Provider, strings.Join(err.Fields, ", "))
+}
+
+// optionalPresetFields maps the name of the optional preset fields that may
+// not be supported by all providers to a function that checks whether the
+// field is defined in a preset.
+var optionalPresetFields = map[string]func(db.Preset) bool{
+	"video.frameRate":       func(p db.Preset) bool { return p.Video.FrameRate != "" },
+	"video.alignKeyframes":  func(p db.Preset) bool { return p.Video.AlignKeyframes },
+	"video.bFrames":         func(p db.Preset) bool { return p.Video.BFrames != "" },
+	"video.referenceFrames": func(p db.Preset) bool { return p.Video.ReferenceFrames != "" },
+	"video.twoPass":         func(p db.Preset) bool { return p.Video.TwoPass },
+	"video.maxBitrate":      func(p db.Preset) bool { return p.Video.MaxBitrate != "" },
+	"video.bufferSize":      func(p db.Preset) bool { return p.Video.BufferSize != "" },
+	"audio.channels":        func(p db.Preset) bool { return p.Audio.Channels != "" },
+	"audio.sampleRate":      func(p db.Preset) bool { return p.Audio.SampleRate != "" },
+}
+
+// VideoCodecs maps the video codecs accepted in presets to the codec names
+// expected by a provider. Providers advertise exactly the codecs listed in
+// their table.
+type VideoCodecs map[string]string
+
+// Codecs returns the sorted list of preset codecs in the table.
+func (c VideoCodecs) Codecs() []string {
+	codecs := make([]string, 0, len(c))
+	for codec := range c {
+		codecs = append(codecs, codec)
+	}
+	sort.Strings(codecs)
+	return codecs
+}
+
+// ProviderCodec returns the name used by the provider for the given preset
+// codec. Codecs missing from the table are returned unchanged.
+func (c VideoCodecs) ProviderCodec(codec string) string {
+	if providerCodec, ok := c[codec]; ok {
+		return providerCodec
+	}
+	return codec
+}
+
+// PresetCodec returns the preset codec for the given codec name reported by
+// the provider, ignoring case. Unknown names are returned unchanged.
+func (c VideoCodecs) PresetCodec(providerCodec string) string {
+	for codec, name := range c {
+		if strings.EqualFold(name, providerCodec) {
+			return codec
+		}
+	}
+	return providerCodec
+}
+
+// CheckVideoCodec returns an error if the video codec of the preset is not
+// listed in the capabilities of the provider. Presets without a video codec
+// are accepted.
+func CheckVideoCodec(providerName string, capabilities Capabilities, preset db.Preset) error {
+	if preset.Video.Codec == "" {
+		return nil
+	}
+	for _, codec := range capabilities.VideoCodecs {
+		if codec == preset.Video.Codec {
+			return nil
+		}
+	}
+	return fmt.Errorf("video codec %q is not supported by %s. Supported codecs are: %s", preset.Video.Codec, providerName, strings.Join(capabilities.VideoCodecs, ", "))
+}
+
+// CheckPresetFields returns an *UnsupportedPresetFieldsError listing the
+// given fields that are defined in the preset, or nil if none of them are.
+func CheckPresetFields(providerName string, preset db.Preset, unsupported ...string) error {
+	var defined []string
+	for _, field := range unsupported {
+		if isDefined, ok := optionalPresetFields[field]; ok && isDefined(preset) {
+			defined = append(defined, field)
+		}
+	}
+	if len(defined) > 0 {
+		return &UnsupportedPresetFieldsError{Provider: providerName, Fields: defined}
+	}
+	return nil
+}
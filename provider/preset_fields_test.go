@@ -0,0 +1,58 @@
+package provider
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/NYTimes/video-transcoding-api/db"
+)
+
+func TestCheckPresetFields(t *testing.T) {
+	preset := db.Preset{
+		Video: db.VideoPreset{FrameRate: "30", TwoPass: true},
+		Audio: db.AudioPreset{SampleRate: "48000"},
+	}
+	err := CheckPresetFields("fake", preset, "video.bFrames", "video.twoPass", "video.frameRate", "audio.channels")
+	fieldsErr, ok := err.(*UnsupportedPresetFieldsError)
+	if !ok {
+		t.Fatalf("wrong error returned. Want *UnsupportedPresetFieldsError. Got %#v", err)
+	}
+	expectedFields := []string{"video.twoPass", "video.frameRate"}
+	if !reflect.DeepEqual(fieldsErr.Fields, expectedFields) {
+		t.Errorf("wrong fields. Want %#v. Got %#v", expectedFields, fieldsErr.Fields)
+	}
+	expectedMsg := "preset fields not supported by fake: video.twoPass, video.frameRate"
+	if err.Error() != expectedMsg {
+		t.Errorf("wrong error message. Want %q. Got %q", expectedMsg, err.Error())
+	}
+	if err = CheckPresetFields("fake", preset, "video.bFrames", "audio.channels"); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+func TestVideoCodecs(t *testing.T) {
+	codecs := VideoCodecs{"h264": "H.264", "h265": "hevc"}
+	expectedCodecs := []string{"h264", "h265"}
+	if got := codecs.Codecs(); !reflect.DeepEqual(got, expectedCodecs) {
+		t.Errorf("wrong codecs. Want %#v. Got %#v", expectedCodecs, got)
+	}
+	var tests = []struct {
+		presetCodec   string
+		providerCodec string
+	}{
+		{"h264", "H.264"},
+		{"h265", "hevc"},
+		{"vp9", "vp9"},
+	}
+	for _, test := range tests {
+		if got := codecs.ProviderCodec(test.presetCodec); got != test.providerCodec {
+			t.Errorf("ProviderCodec(%q): want %q. Got %q", test.presetCodec, test.providerCodec, got)
+		}
+		if got := codecs.PresetCodec(test.providerCodec); got != test.presetCodec {
+			t.Errorf("PresetCodec(%q): want %q. Got %q", test.providerCodec, test.presetCodec, got)
+		}
+	}
+	if got := codecs.PresetCodec("h.264"); got != "h264" {
+		t.Errorf("PresetCodec should ignore case. Want %q. Got %q", "h264", got)
+	}
+}
@@ -24,6 +24,10 @@ var (
 	ErrPresetMapNotFound = errors.New("preset not found in provider")
 )
 
+// CaptionFormats is the list of caption formats that may be used as caption
+// sources in a transcoding job.
+var CaptionFormats = []string{"srt", "webvtt", "scc", "dfxp"}
+
 // TranscodingProvider represents a provider of transcoding.
 //
 // It defines a basic API for transcoding a media and query the status of a
@@ -37,6 +41,11 @@ type TranscodingProvider interface {
 	DeletePreset(presetID string) error
 	GetPreset(presetID string) (interface{}, error)
 
+	// GetNormalizedPreset returns the preset with the given ID translated
+	// back into the representation used by the API. Fields that can't be
+	// represented in the provider are left empty.
+	GetNormalizedPreset(presetID string) (*db.Preset, error)
+
 	// Healthcheck should return nil if the provider is currently available
 	// for transcoding videos, otherwise it should return an error
 	// explaining what's going on.
@@ -46,6 +55,23 @@ type TranscodingProvider interface {
 	Capabilities() Capabilities
 }
 
+// PresetUpdater is implemented by providers that are able to update an
+// existing preset in place, keeping its ID.
+//
+// Presets on providers that don't implement this interface are updated by
+// creating a new preset and replacing the old one in the preset map.
+type PresetUpdater interface {
+	UpdatePreset(presetID string, preset db.Preset) error
+}
+
+// PresetNormalizer is implemented by providers that store some fields of
+// presets in a lossy way. NormalizePreset returns the given preset as it's
+// expected to be returned by GetNormalizedPreset once stored in the
+// provider, so both can be compared.
+type PresetNormalizer interface {
+	NormalizePreset(preset db.Preset) db.Preset
+}
+
 // Factory is the function responsible for creating the instance of a
 // provider.
 type Factory func(cfg *config.Config) (TranscodingProvider, error)
@@ -79,12 +105,20 @@ type JobStatus struct {
 	ProviderStatus map[string]interface{} `json:"providerStatus,omitempty"`
 	Output         JobOutput              `json:"output"`
 	SourceInfo     SourceInfo             `json:"sourceInfo,omitempty"`
+
+	// result of the verification of the outputs of finished jobs
+	Verification *db.OutputVerification `json:"verification,omitempty"`
+
+	// version of each preset used by the job, indexed by the name of the
+	// preset
+	PresetVersions map[string]string `json:"presetVersions,omitempty"`
 }
 
 // JobOutput represents information about a job output.
 type JobOutput struct {
-	Destination string       `json:"destination,omitempty"`
-	Files       []OutputFile `json:"files,omitempty"`
+	Destination string        `json:"destination,omitempty"`
+	Files       []OutputFile  `json:"files,omitempty"`
+	Captions    []CaptionFile `json:"captions,omitempty"`
 }
 
 // OutputFile represents an output file in a given job.
@@ -94,6 +128,21 @@ type OutputFile struct {
 	VideoCodec string `json:"videoCodec"`
 	Height     int64  `json:"height"`
 	Width      int64  `json:"width"`
+
+	// time-limited HTTPS URL for downloading the file, included when
+	// signed URLs are requested
+	SignedURL string `json:"signedUrl,omitempty"`
+}
+
+// CaptionFile represents a caption file generated by a given job.
+type CaptionFile struct {
+	Path     string `json:"path"`
+	Format   string `json:"format"`
+	Language string `json:"language"`
+
+	// time-limited HTTPS URL for downloading the file, included when
+	// signed URLs are requested
+	SignedURL string `json:"signedUrl,omitempty"`
 }
 
 // SourceInfo contains information about media transcoded using the Transcoding
@@ -108,6 +157,9 @@ type SourceInfo struct {
 
 	// Codec used for video medias
 	VideoCodec string `json:"videoCodec,omitempty"`
+
+	// Frame rate of video medias, in frames per second
+	FrameRate float64 `json:"frameRate,omitempty"`
 }
 
 // StreamingParams contains all parameters related to the streaming protocol used.
@@ -115,6 +167,54 @@ type StreamingParams struct {
 	PlaylistFileName string `json:"playlistFileName,omitempty"`
 	SegmentDuration  uint   `json:"segmentDuration,omitempty"`
 	Protocol         string `json:"protocol,omitempty"`
+
+	// format of the segments in HLS jobs: ts (default) or fmp4. fmp4
+	// segments (CMAF) can be served to both HLS and DASH players, and
+	// are only available in providers that list them in the HLS segment
+	// formats of their capabilities.
+	SegmentFormat string `json:"segmentFormat,omitempty"`
+
+	// encryption of the segments in HLS jobs
+	Encryption *Encryption `json:"encryption,omitempty"`
+}
+
+// Encryption contains the parameters for encrypting the segments of HLS jobs.
+type Encryption struct {
+	// encryption method: aes-128 or sample-aes
+	Method string `json:"method"`
+
+	// base64-encoded 128-bit key. When it's not provided, the API
+	// generates a random key.
+	Key string `json:"key,omitempty"`
+
+	// URI that players use for retrieving the key
+	KeyURI string `json:"keyUri"`
+}
+
+// HLSEncryptionMethods is the list of methods that may be used for encrypting
+// the segments of HLS jobs.
+var HLSEncryptionMethods = []string{"aes-128", "sample-aes"}
+
+// HLSSegmentFormats is the list of segment formats that may be used in HLS
+// jobs.
+var HLSSegmentFormats = []string{"ts", "fmp4"}
+
+// Caption represents a caption source that should be attached to the outputs
+// of a transcoding job, either embedded in adaptive streaming outputs or as a
+// sidecar file.
+type Caption struct {
+	// URL of the caption source file.
+	Source string `json:"source"`
+
+	// format of the caption source (srt, webvtt, scc or dfxp).
+	Format string `json:"format"`
+
+	// language of the caption, in the ISO 639 format (for example "en"
+	// or "pt-BR").
+	Language string `json:"language"`
+
+	// human readable label for the caption.
+	Label string `json:"label,omitempty"`
 }
 
 // TranscodeProfile defines the set of inputs necessary for running a transcoding job.
@@ -122,6 +222,14 @@ type TranscodeProfile struct {
 	SourceMedia     string
 	Outputs         []TranscodeOutput
 	StreamingParams StreamingParams
+	Captions        []Caption
+
+	// URL of the image used in outputs whose preset defines an overlay.
+	OverlayImage string
+
+	// options passed verbatim to the request sent to the provider,
+	// indexed by the name of the provider.
+	ProviderOptions db.ProviderOptions
 }
 
 // TranscodeOutput represents a transcoding output. It's a combination of the
@@ -0,0 +1,142 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// InvalidProviderOptionError is the error returned when one of the provider
+// options of a preset or job can't be applied to the request sent to the
+// provider.
+type InvalidProviderOptionError struct {
+	Provider string
+	Option   string
+	Reason   string
+}
+
+func (err *InvalidProviderOptionError) Error() string {
+	return fmt.Sprintf("invalid %s option %q: %s", err.Provider, err.Option, err.Reason)
+}
+
+// ApplyOptions sets the fields of the struct pointed by dst to the values of
+// the given options, which are matched to the fields by the name defined in
+// the given struct tag (for example, "json" or "xml"), or by the name of the
+// fields when the tag is empty. Options whose values don't fit the field are
+// reported in an *InvalidProviderOptionError.
+//
+// Options that don't match any field are returned, so providers can merge
+// them into the request sent to the provider (see MergeOptions).
+func ApplyOptions(providerName string, dst interface{}, tag string, options map[string]interface{}) (map[string]interface{}, error) {
+	if len(options) == 0 {
+		return nil, nil
+	}
+	fields := make(map[string]reflect.Value)
+	taggedFields(reflect.ValueOf(dst).Elem(), tag, fields)
+	var unknown map[string]interface{}
+	for _, name := range optionNames(options) {
+		field, ok := fields[name]
+		if !ok {
+			if unknown == nil {
+				unknown = make(map[string]interface{})
+			}
+			unknown[name] = options[name]
+			continue
+		}
+		if err := setOption(field, options[name]); err != nil {
+			return nil, &InvalidProviderOptionError{Provider: providerName, Option: name, Reason: err.Error()}
+		}
+	}
+	return unknown, nil
+}
+
+// MergeOptions adds the given options to the JSON object in data, replacing
+// the fields with the same name.
+func MergeOptions(data []byte, options map[string]interface{}) ([]byte, error) {
+	if len(options) == 0 {
+		return data, nil
+	}
+	var object map[string]interface{}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return nil, err
+	}
+	if object == nil {
+		object = make(map[string]interface{}, len(options))
+	}
+	for name, value := range options {
+		object[name] = value
+	}
+	return json.Marshal(object)
+}
+
+// OptionString returns the string representation of the value of an
+// option, for providers that take all options as strings.
+func OptionString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprint(value)
+}
+
+// optionNames returns the names of the given options in alphabetical order.
+func optionNames(options map[string]interface{}) []string {
+	names := make([]string, 0, len(options))
+	for name := range options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// taggedFields adds the exported fields of the given struct to the given
+// map, indexed by the name defined in the given struct tag, or by their
+// names when the tag is empty. Fields of embedded structs without a name
+// are added as well.
+func taggedFields(value reflect.Value, tag string, fields map[string]reflect.Value) {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name := field.Name
+		if tag != "" {
+			name = strings.Split(field.Tag.Get(tag), ",")[0]
+		}
+		if (name == "" || tag == "") && field.Anonymous && field.Type.Kind() == reflect.Struct {
+			taggedFields(value.Field(i), tag, fields)
+			continue
+		}
+		if name != "" && name != "-" {
+			fields[name] = value.Field(i)
+		}
+	}
+}
+
+// setOption replaces the value of the given field with the given value,
+// decoding it as JSON so values of any type can be assigned to the field.
+// Values assigned to string fields are converted to strings first.
+func setOption(field reflect.Value, value interface{}) error {
+	fieldType := field.Type()
+	for fieldType.Kind() == reflect.Ptr {
+		fieldType = fieldType.Elem()
+	}
+	if fieldType.Kind() == reflect.String {
+		value = OptionString(value)
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	newValue := reflect.New(field.Type())
+	if err = json.Unmarshal(data, newValue.Interface()); err != nil {
+		return fmt.Errorf("can't use %s as %s", data, field.Type())
+	}
+	field.Set(newValue.Elem())
+	return nil
+}
@@ -0,0 +1,187 @@
+package provider
+
+import (
+	"reflect"
+	"testing"
+)
+
+type nativeSettings struct {
+	Label    string            `json:"label,omitempty"`
+	Bitrate  int32             `json:"video_bitrate,omitempty"`
+	Speed    *int              `json:"speed,omitempty"`
+	Upscale  bool              `json:"upscale,omitempty"`
+	Formats  []string          `json:"formats,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	Ignored  string            `json:"-"`
+	NativeBaseSettings
+}
+
+type NativeBaseSettings struct {
+	Region string `json:"region,omitempty"`
+}
+
+func TestApplyOptions(t *testing.T) {
+	speed := 3
+	tests := []struct {
+		givenTestCase string
+		givenOptions  map[string]interface{}
+		wantSettings  nativeSettings
+		wantUnknown   map[string]interface{}
+		wantError     string
+	}{
+		{
+			"options of different types",
+			map[string]interface{}{
+				"label":         float64(720),
+				"video_bitrate": float64(2500),
+				"speed":         float64(3),
+				"upscale":       true,
+				"formats":       []interface{}{"mp4", "webm"},
+				"metadata":      map[string]interface{}{"team": "video"},
+				"region":        "europe",
+			},
+			nativeSettings{
+				Label:              "720",
+				Bitrate:            2500,
+				Speed:              &speed,
+				Upscale:            true,
+				Formats:            []string{"mp4", "webm"},
+				Metadata:           map[string]string{"team": "video"},
+				NativeBaseSettings: NativeBaseSettings{Region: "europe"},
+			},
+			nil,
+			"",
+		},
+		{
+			"no options",
+			nil,
+			nativeSettings{Label: "original", Bitrate: 1000},
+			nil,
+			"",
+		},
+		{
+			"unknown options",
+			map[string]interface{}{"Ignored": "yes", "speed": float64(3), "quality": float64(4)},
+			nativeSettings{Label: "original", Bitrate: 1000, Speed: &speed},
+			map[string]interface{}{"Ignored": "yes", "quality": float64(4)},
+			"",
+		},
+		{
+			"invalid value",
+			map[string]interface{}{"video_bitrate": "high"},
+			nativeSettings{Label: "original", Bitrate: 1000},
+			nil,
+			`invalid fake option "video_bitrate": can't use "high" as int32`,
+		},
+	}
+	for _, test := range tests {
+		settings := nativeSettings{Label: "original", Bitrate: 1000}
+		unknown, err := ApplyOptions("fake", &settings, "json", test.givenOptions)
+		if test.wantError != "" {
+			if _, ok := err.(*InvalidProviderOptionError); !ok {
+				t.Errorf("%s: wrong error returned. Want *InvalidProviderOptionError. Got %#v", test.givenTestCase, err)
+			} else if err.Error() != test.wantError {
+				t.Errorf("%s: wrong error message. Want %q. Got %q", test.givenTestCase, test.wantError, err.Error())
+			}
+		} else if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.givenTestCase, err)
+		}
+		if !reflect.DeepEqual(settings, test.wantSettings) {
+			t.Errorf("%s: wrong settings\nWant %#v\nGot  %#v", test.givenTestCase, test.wantSettings, settings)
+		}
+		if !reflect.DeepEqual(unknown, test.wantUnknown) {
+			t.Errorf("%s: wrong unknown options\nWant %#v\nGot  %#v", test.givenTestCase, test.wantUnknown, unknown)
+		}
+	}
+}
+
+func TestApplyOptionsFieldNames(t *testing.T) {
+	var settings nativeSettings
+	options := map[string]interface{}{"Bitrate": float64(2500), "Region": "europe"}
+	if _, err := ApplyOptions("fake", &settings, "", options); err != nil {
+		t.Fatal(err)
+	}
+	expected := nativeSettings{Bitrate: 2500, NativeBaseSettings: NativeBaseSettings{Region: "europe"}}
+	if !reflect.DeepEqual(settings, expected) {
+		t.Errorf("wrong settings\nWant %#v\nGot  %#v", expected, settings)
+	}
+	unknown, err := ApplyOptions("fake", &settings, "", map[string]interface{}{"video_bitrate": float64(2500)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expectedUnknown := map[string]interface{}{"video_bitrate": float64(2500)}; !reflect.DeepEqual(unknown, expectedUnknown) {
+		t.Errorf("wrong unknown options\nWant %#v\nGot  %#v", expectedUnknown, unknown)
+	}
+}
+
+func TestMergeOptions(t *testing.T) {
+	tests := []struct {
+		givenTestCase string
+		givenData     string
+		givenOptions  map[string]interface{}
+		want          string
+		wantError     bool
+	}{
+		{
+			"new and existing fields",
+			`{"label":"720p","video_bitrate":2500}`,
+			map[string]interface{}{"label": "1080p", "speed": float64(3), "metadata": map[string]interface{}{"team": "video"}},
+			`{"label":"1080p","metadata":{"team":"video"},"speed":3,"video_bitrate":2500}`,
+			false,
+		},
+		{
+			"no options",
+			`{"label":"720p"}`,
+			nil,
+			`{"label":"720p"}`,
+			false,
+		},
+		{
+			"empty object",
+			`null`,
+			map[string]interface{}{"speed": float64(3)},
+			`{"speed":3}`,
+			false,
+		},
+		{
+			"not an object",
+			`["720p"]`,
+			map[string]interface{}{"speed": float64(3)},
+			"",
+			true,
+		},
+	}
+	for _, test := range tests {
+		got, err := MergeOptions([]byte(test.givenData), test.givenOptions)
+		if test.wantError {
+			if err == nil {
+				t.Errorf("%s: unexpected <nil> error", test.givenTestCase)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.givenTestCase, err)
+			continue
+		}
+		if string(got) != test.want {
+			t.Errorf("%s: wrong data. Want %s. Got %s", test.givenTestCase, test.want, got)
+		}
+	}
+}
+
+func TestOptionString(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		expected string
+	}{
+		{"Auto", "Auto"},
+		{float64(5000000), "5000000"},
+		{float64(1.5), "1.5"},
+		{true, "true"},
+	}
+	for _, test := range tests {
+		if got := OptionString(test.value); got != test.expected {
+			t.Errorf("OptionString(%#v): wrong value. Want %q. Got %q", test.value, test.expected, got)
+		}
+	}
+}
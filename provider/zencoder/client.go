@@ -0,0 +1,57 @@
+package zencoder
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/brandscreen/zencoder"
+)
+
+const apiBaseURL = "https://app.zencoder.com/api/v2/"
+
+// apiClient extends the Zencoder client with the creation of jobs from raw
+// JSON, used for provider options that the job settings of the client don't
+// include.
+type apiClient struct {
+	*zencoder.Zencoder
+	apiKey     string
+	baseURL    string
+	httpClient *http.Client
+}
+
+// CreateJobJSON creates a job from its JSON representation.
+func (c *apiClient) CreateJobJSON(data []byte) (*zencoder.CreateJobResponse, error) {
+	baseURL := c.baseURL
+	if baseURL == "" {
+		baseURL = apiBaseURL
+	}
+	req, err := http.NewRequest("POST", baseURL+"jobs", bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Zencoder-Api-Key", c.apiKey)
+	httpClient := c.httpClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed to create job: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	var result zencoder.CreateJobResponse
+	if err = json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
@@ -0,0 +1,60 @@
+package zencoder
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/brandscreen/zencoder"
+)
+
+func TestCreateJobJSON(t *testing.T) {
+	var req *http.Request
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = r
+		body, _ = ioutil.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id": 1234, "outputs": [{"id": 4321}]}`))
+	}))
+	defer server.Close()
+	client := apiClient{Zencoder: zencoder.NewZencoder("api-key"), apiKey: "api-key", baseURL: server.URL + "/api/v2/"}
+	data := []byte(`{"input":"s3://bucket/video.mov","outputs":[{"label":"mp4_720p","speed":4}]}`)
+	resp, err := client.CreateJobJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Id != 1234 {
+		t.Errorf("wrong job id. Want 1234. Got %d", resp.Id)
+	}
+	if string(body) != string(data) {
+		t.Errorf("wrong job sent. Want %s. Got %s", data, body)
+	}
+	if req.Method != "POST" || req.URL.Path != "/api/v2/jobs" {
+		t.Errorf("wrong request. Want POST /api/v2/jobs. Got %s %s", req.Method, req.URL.Path)
+	}
+	if key := req.Header.Get("Zencoder-Api-Key"); key != "api-key" {
+		t.Errorf("wrong API key. Want %q. Got %q", "api-key", key)
+	}
+	if contentType := req.Header.Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("wrong content type. Want %q. Got %q", "application/json", contentType)
+	}
+}
+
+func TestCreateJobJSONError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"errors": ["Outputs speed must be between 1 and 5"]}`, http.StatusUnprocessableEntity)
+	}))
+	defer server.Close()
+	client := apiClient{Zencoder: zencoder.NewZencoder("api-key"), apiKey: "api-key", baseURL: server.URL + "/"}
+	resp, err := client.CreateJobJSON([]byte(`{"outputs":[{"speed":9}]}`))
+	if resp != nil {
+		t.Errorf("unexpected non-nil response: %#v", resp)
+	}
+	expectedMsg := `failed to create job: 422 Unprocessable Entity: {"errors": ["Outputs speed must be between 1 and 5"]}`
+	if err == nil || err.Error() != expectedMsg {
+		t.Errorf("wrong error returned. Want %q. Got %v", expectedMsg, err)
+	}
+}
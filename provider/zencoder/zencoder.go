@@ -16,13 +16,21 @@
 package zencoder
 
 import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/url"
+	"path"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/NYTimes/video-transcoding-api/config"
 	"github.com/NYTimes/video-transcoding-api/db"
 	"github.com/NYTimes/video-transcoding-api/db/redis"
+	"github.com/NYTimes/video-transcoding-api/mediaurl"
 	"github.com/NYTimes/video-transcoding-api/provider"
 	"github.com/brandscreen/zencoder"
 )
@@ -31,7 +39,25 @@ import (
 // registry of providers.
 const Name = "zencoder"
 
-var errZencoderInvalidConfig = provider.InvalidConfigError("missing Zencoder API key. Please define the environment variables ZENCODER_API_KEY or set these values in the configuration file")
+var (
+	errZencoderInvalidConfig = provider.InvalidConfigError("missing Zencoder API key. Please define the environment variables ZENCODER_API_KEY or set these values in the configuration file")
+	errMultipleCaptions      = errors.New("zencoder supports only one caption source per job")
+
+	sourceSchemes      = []mediaurl.Scheme{mediaurl.S3, mediaurl.GCS, mediaurl.HTTP, mediaurl.HTTPS, mediaurl.FTP}
+	destinationSchemes = []mediaurl.Scheme{mediaurl.S3, mediaurl.GCS, mediaurl.FTP, mediaurl.Akamai}
+
+	// captionFormats maps the containers of the outputs that carry
+	// captions to the format of the embedded captions: CEA-608 in MP4
+	// outputs and WebVTT subtitle renditions in HLS outputs.
+	captionFormats = map[string]string{
+		"mp4":  "cea-608",
+		"m3u8": "webvtt",
+	}
+
+	// videoCodecs maps the video codecs of presets to the values of the
+	// video_codec setting of Zencoder outputs.
+	videoCodecs = provider.VideoCodecs{"h264": "h264", "h265": "hevc", "vp8": "vp8", "vp9": "vp9"}
+)
 
 func init() {
 	provider.Register(Name, zencoderFactory)
@@ -41,6 +67,7 @@ func init() {
 // brandscreen/zencoder and fakeZencoder implements
 type Client interface {
 	CreateJob(*zencoder.EncodingSettings) (*zencoder.CreateJobResponse, error)
+	CreateJobJSON(data []byte) (*zencoder.CreateJobResponse, error)
 	ListJobs() ([]*zencoder.JobDetails, error)
 	CancelJob(id int64) error
 	GetJobProgress(id int64) (*zencoder.JobProgress, error)
@@ -54,17 +81,34 @@ type zencoderProvider struct {
 }
 
 func (z *zencoderProvider) Transcode(job *db.Job, transcodeProfile provider.TranscodeProfile) (*provider.JobStatus, error) {
-	outputs, err := z.buildOutputs(transcodeProfile)
+	source, err := z.mediaURL(transcodeProfile.SourceMedia, sourceSchemes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid source %q: %s", transcodeProfile.SourceMedia, err)
+	}
+	outputs, outputOptions, err := z.buildOutputs(job, transcodeProfile)
 	if err != nil {
 		return nil, err
 	}
 	encodingSettings := zencoder.EncodingSettings{
-		Input:      transcodeProfile.SourceMedia,
+		Input:      source,
 		Outputs:    outputs,
 		LiveStream: false,
 		Region:     "US",
 	}
-	response, err := z.client.CreateJob(&encodingSettings)
+	jobOptions, err := provider.ApplyOptions(Name, &encodingSettings, "json", transcodeProfile.ProviderOptions[Name])
+	if err != nil {
+		return nil, err
+	}
+	var response *zencoder.CreateJobResponse
+	if len(jobOptions) > 0 || outputOptions != nil {
+		var data []byte
+		if data, err = jobJSON(&encodingSettings, jobOptions, outputOptions); err != nil {
+			return nil, err
+		}
+		response, err = z.client.CreateJobJSON(data)
+	} else {
+		response, err = z.client.CreateJob(&encodingSettings)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -75,62 +119,181 @@ func (z *zencoderProvider) Transcode(job *db.Job, transcodeProfile provider.Tran
 	}, nil
 }
 
-func (z *zencoderProvider) buildOutputs(transcodeProfile provider.TranscodeProfile) ([]*zencoder.OutputSettings, error) {
+// jobJSON returns the JSON representation of the given job settings, with
+// the provider options that the settings don't include. outputOptions holds
+// the options of each output.
+func jobJSON(settings *zencoder.EncodingSettings, jobOptions map[string]interface{}, outputOptions []map[string]interface{}) ([]byte, error) {
+	outputs := make([]json.RawMessage, len(settings.Outputs))
+	for i, output := range settings.Outputs {
+		data, err := json.Marshal(output)
+		if err != nil {
+			return nil, err
+		}
+		if i < len(outputOptions) {
+			if data, err = provider.MergeOptions(data, outputOptions[i]); err != nil {
+				return nil, err
+			}
+		}
+		outputs[i] = data
+	}
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return nil, err
+	}
+	options := map[string]interface{}{"outputs": outputs}
+	for name, value := range jobOptions {
+		options[name] = value
+	}
+	return provider.MergeOptions(data, options)
+}
+
+// buildOutputs returns the outputs of the job along with the provider
+// options of each output that the output settings don't include, or nil when
+// there are no such options.
+func (z *zencoderProvider) buildOutputs(job *db.Job, transcodeProfile provider.TranscodeProfile) ([]*zencoder.OutputSettings, []map[string]interface{}, error) {
+	if len(transcodeProfile.Captions) > 1 {
+		return nil, nil, errMultipleCaptions
+	}
+	baseURL, err := z.outputBaseURL(job)
+	if err != nil {
+		return nil, nil, err
+	}
+	var captionURL, overlayImage string
+	if len(transcodeProfile.Captions) > 0 {
+		caption := transcodeProfile.Captions[0].Source
+		if captionURL, err = z.mediaURL(caption, sourceSchemes); err != nil {
+			return nil, nil, fmt.Errorf("invalid caption source %q: %s", caption, err)
+		}
+	}
+	if transcodeProfile.OverlayImage != "" {
+		if overlayImage, err = z.mediaURL(transcodeProfile.OverlayImage, sourceSchemes); err != nil {
+			return nil, nil, fmt.Errorf("invalid overlay image %q: %s", transcodeProfile.OverlayImage, err)
+		}
+	}
 	zencoderOutputs := make([]*zencoder.OutputSettings, 0, len(transcodeProfile.Outputs))
+	var outputOptions []map[string]interface{}
 	for _, output := range transcodeProfile.Outputs {
 		localPresetOutput, err := z.GetPreset(output.Preset.Name)
 		if err != nil {
-			return nil, fmt.Errorf("Error getting localpreset: %s", err.Error())
+			return nil, nil, fmt.Errorf("Error getting localpreset: %s", err.Error())
 		}
 		localPresetStruct := localPresetOutput.(*db.LocalPreset)
-		zencoderOutput, err := z.buildOutput(localPresetStruct.Preset)
+		zencoderOutput, options, err := z.buildOutput(localPresetStruct.Preset)
 		if err != nil {
-			return nil, fmt.Errorf("Error building output: %s", err.Error())
+			return nil, nil, fmt.Errorf("Error building output: %s", err.Error())
+		}
+		if baseURL != nil {
+			zencoderOutput.BaseUrl = z.zencoderURL(baseURL) + "/"
+			zencoderOutput.Filename = output.FileName
+		}
+		if zencoderOutput.Type == "segmented" {
+			zencoderOutput.SegmentSeconds = int32(transcodeProfile.StreamingParams.SegmentDuration)
+		}
+		if encryption := transcodeProfile.StreamingParams.Encryption; encryption != nil && localPresetStruct.Preset.Container == "m3u8" {
+			key, err := base64.StdEncoding.DecodeString(encryption.Key)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid encryption key: %s", err)
+			}
+			zencoderOutput.EncryptionMethod = encryption.Method
+			zencoderOutput.EncryptionKey = hex.EncodeToString(key)
+			zencoderOutput.EncryptionKeyUrl = encryption.KeyURI
+		}
+		if _, ok := captionFormats[localPresetStruct.Preset.Container]; ok {
+			zencoderOutput.CaptionUrl = captionURL
+		}
+		if overlayImage != "" && localPresetStruct.Preset.Overlay.Enabled() {
+			watermark, err := z.buildWatermark(localPresetStruct.Preset.Overlay, overlayImage)
+			if err != nil {
+				return nil, nil, fmt.Errorf("Error building output: %s", err.Error())
+			}
+			zencoderOutput.Watermarks = []*zencoder.WatermarkSettings{watermark}
 		}
 		zencoderOutputs = append(zencoderOutputs, &zencoderOutput)
+		if len(options) > 0 {
+			if outputOptions == nil {
+				outputOptions = make([]map[string]interface{}, len(transcodeProfile.Outputs))
+			}
+			outputOptions[len(zencoderOutputs)-1] = options
+		}
 	}
-	return zencoderOutputs, nil
+	return zencoderOutputs, outputOptions, nil
 }
 
-func (z *zencoderProvider) buildOutput(preset db.Preset) (zencoder.OutputSettings, error) {
+// buildOutput returns the output settings for the given preset, along with
+// the provider options of the preset that the settings don't include.
+func (z *zencoderProvider) buildOutput(preset db.Preset) (zencoder.OutputSettings, map[string]interface{}, error) {
 	zencoderOutput := zencoder.OutputSettings{
 		Label:      preset.Name + ":" + preset.Description,
 		Format:     preset.Container,
-		VideoCodec: preset.Video.Codec,
+		VideoCodec: videoCodecs.ProviderCodec(preset.Video.Codec),
 		AudioCodec: preset.Audio.Codec,
 	}
 
 	width, err := strconv.ParseInt(preset.Video.Width, 10, 32)
 	if err != nil {
-		return zencoder.OutputSettings{}, fmt.Errorf("error converting preset width (%q): %s", preset.Video.Width, err)
+		return zencoder.OutputSettings{}, nil, fmt.Errorf("error converting preset width (%q): %s", preset.Video.Width, err)
 	}
 	zencoderOutput.Width = int32(width)
 
 	height, err := strconv.ParseInt(preset.Video.Height, 10, 32)
 	if err != nil {
-		return zencoder.OutputSettings{}, fmt.Errorf("error converting preset height (%q): %s", preset.Video.Height, err)
+		return zencoder.OutputSettings{}, nil, fmt.Errorf("error converting preset height (%q): %s", preset.Video.Height, err)
 	}
 	zencoderOutput.Height = int32(height)
 
 	videoBitrate, err := strconv.ParseInt(preset.Video.Bitrate, 10, 32)
 	if err != nil {
-		return zencoder.OutputSettings{}, fmt.Errorf("error converting preset video bitrate (%q): %s", preset.Video.Bitrate, err)
+		return zencoder.OutputSettings{}, nil, fmt.Errorf("error converting preset video bitrate (%q): %s", preset.Video.Bitrate, err)
 	}
 	zencoderOutput.VideoBitrate = int32(videoBitrate) / 1000
 
 	keyframeInterval, err := strconv.ParseInt(preset.Video.GopSize, 10, 32)
 	if err != nil {
-		return zencoder.OutputSettings{}, fmt.Errorf("error converting preset keyframe interval (%q): %s", preset.Video.GopSize, err)
+		return zencoder.OutputSettings{}, nil, fmt.Errorf("error converting preset keyframe interval (%q): %s", preset.Video.GopSize, err)
 	}
 	zencoderOutput.KeyframeInterval = int32(keyframeInterval)
 
 	audioBitrate, err := strconv.ParseInt(preset.Audio.Bitrate, 10, 32)
 	if err != nil {
-		return zencoder.OutputSettings{}, fmt.Errorf("error converting preset audio bitrate (%q): %s", preset.Audio.Bitrate, err)
+		return zencoder.OutputSettings{}, nil, fmt.Errorf("error converting preset audio bitrate (%q): %s", preset.Audio.Bitrate, err)
 	}
 	zencoderOutput.AudioBitrate = int32(audioBitrate) / 1000
 
-	if preset.Video.GopMode == "fixed" {
+	if preset.Video.FrameRate != "" {
+		frameRate, err := strconv.ParseFloat(preset.Video.FrameRate, 64)
+		if err != nil {
+			return zencoder.OutputSettings{}, nil, fmt.Errorf("error converting preset frame rate (%q): %s", preset.Video.FrameRate, err)
+		}
+		zencoderOutput.FrameRate = frameRate
+	}
+
+	optionalInts := []struct {
+		name   string
+		value  string
+		target *int32
+		scale  int64
+	}{
+		{"reference frames", preset.Video.ReferenceFrames, &zencoderOutput.H264ReferenceFrames, 1},
+		{"B-frames", preset.Video.BFrames, &zencoderOutput.H264Bframes, 1},
+		{"max bitrate", preset.Video.MaxBitrate, &zencoderOutput.DecoderBitrateCap, 1000},
+		{"buffer size", preset.Video.BufferSize, &zencoderOutput.DecoderBufferSize, 1000},
+		{"audio channels", preset.Audio.Channels, &zencoderOutput.AudioChannels, 1},
+		{"audio sample rate", preset.Audio.SampleRate, &zencoderOutput.AudioSampleRate, 1},
+	}
+	for _, field := range optionalInts {
+		if field.value == "" {
+			continue
+		}
+		value, err := strconv.ParseInt(field.value, 10, 32)
+		if err != nil {
+			return zencoder.OutputSettings{}, nil, fmt.Errorf("error converting preset %s (%q): %s", field.name, field.value, err)
+		}
+		*field.target = int32(value / field.scale)
+	}
+
+	// Zencoder runs multiple passes by default when targeting a bitrate,
+	// so there's nothing to do for presets with TwoPass enabled.
+	if preset.Video.GopMode == "fixed" || preset.Video.AlignKeyframes {
 		zencoderOutput.FixedKeyframeInterval = true
 	}
 	if preset.Video.Codec == "h264" {
@@ -140,8 +303,89 @@ func (z *zencoderProvider) buildOutput(preset db.Preset) (zencoder.OutputSetting
 	if preset.RateControl == "CBR" {
 		zencoderOutput.ConstantBitrate = true
 	}
+	if preset.Container == "mpd" {
+		zencoderOutput.Type = "segmented"
+		zencoderOutput.Format = "mp4"
+		zencoderOutput.StreamingDeliveryFormat = "dash"
+	}
 	zencoderOutput.Deinterlace = "on"
-	return zencoderOutput, nil
+	options, err := provider.ApplyOptions(Name, &zencoderOutput, "json", preset.ProviderOptions[Name])
+	if err != nil {
+		return zencoder.OutputSettings{}, nil, err
+	}
+	return zencoderOutput, options, nil
+}
+
+func (z *zencoderProvider) buildWatermark(overlay db.OverlayPreset, image string) (*zencoder.WatermarkSettings, error) {
+	if overlay.Opacity != "" && overlay.Opacity != "100" {
+		return nil, errors.New("zencoder does not support overlay opacity")
+	}
+	if overlay.StartTime != "" || overlay.EndTime != "" {
+		return nil, errors.New("zencoder does not support overlay time range")
+	}
+	x, y := overlay.OffsetX, overlay.OffsetY
+	if x == "" {
+		x = "0"
+	}
+	if y == "" {
+		y = "0"
+	}
+	switch overlay.Position {
+	case "top-left":
+	case "top-right":
+		x = "-" + x
+	case "bottom-left":
+		y = "-" + y
+	case "bottom-right":
+		x, y = "-"+x, "-"+y
+	default:
+		return nil, fmt.Errorf("invalid overlay position: %q", overlay.Position)
+	}
+	return &zencoder.WatermarkSettings{Url: image, X: x, Y: y}, nil
+}
+
+// outputBaseURL returns the location of the directory that holds the
+// outputs of the job. It returns nil when neither the job nor the provider
+// configuration define a destination, in which case outputs are stored in
+// the default Zencoder storage.
+func (z *zencoderProvider) outputBaseURL(job *db.Job) (*mediaurl.Location, error) {
+	destination := job.Destination
+	if destination == "" {
+		destination = z.config.Zencoder.Destination
+	}
+	if destination == "" {
+		return nil, nil
+	}
+	loc, err := mediaurl.Parse(destination)
+	if err == nil {
+		err = loc.Require(destinationSchemes...)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid destination %q: %s", destination, err)
+	}
+	return loc.Join(job.OutputDirectory()), nil
+}
+
+// mediaURL parses the given URL, making sure that it uses one of the given
+// schemes, and translates it to the format expected by Zencoder.
+func (z *zencoderProvider) mediaURL(rawURL string, schemes []mediaurl.Scheme) (string, error) {
+	loc, err := mediaurl.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	if err = loc.Require(schemes...); err != nil {
+		return "", err
+	}
+	return z.zencoderURL(loc), nil
+}
+
+// zencoderURL returns the URL of the given location in the format expected
+// by Zencoder, which uses the gcs scheme for Google Cloud Storage.
+func (z *zencoderProvider) zencoderURL(loc *mediaurl.Location) string {
+	if loc.Scheme == mediaurl.GCS {
+		return "gcs" + strings.TrimPrefix(loc.String(), string(mediaurl.GCS))
+	}
+	return loc.String()
 }
 
 func (z *zencoderProvider) JobStatus(job *db.Job) (*provider.JobStatus, error) {
@@ -149,10 +393,17 @@ func (z *zencoderProvider) JobStatus(job *db.Job) (*provider.JobStatus, error) {
 	if err != nil {
 		return nil, fmt.Errorf("error converting job ID (%q): %s", job.ID, err)
 	}
-	jobOutputs, err := z.getJobOutputs(jobID)
+	jobOutputs, err := z.getJobOutputs(job, jobID)
 	if err != nil {
 		return nil, fmt.Errorf("error getting job outputs: %s", err)
 	}
+	baseURL, err := z.outputBaseURL(job)
+	if err != nil {
+		return nil, err
+	}
+	if baseURL != nil {
+		jobOutputs.Destination = baseURL.WithoutCredentials().String()
+	}
 	progress, err := z.client.GetJobProgress(jobID)
 	if err != nil {
 		return nil, fmt.Errorf("error getting job progress: %s", err)
@@ -185,12 +436,13 @@ func (z *zencoderProvider) getSourceInfo(jobID int64) (provider.SourceInfo, erro
 	}, nil
 }
 
-func (z *zencoderProvider) getJobOutputs(jobID int64) (provider.JobOutput, error) {
+func (z *zencoderProvider) getJobOutputs(job *db.Job, jobID int64) (provider.JobOutput, error) {
 	jobDetails, err := z.client.GetJobDetails(jobID)
 	if err != nil {
 		return provider.JobOutput{}, fmt.Errorf("error getting job details: %s", err)
 	}
 	files := make([]provider.OutputFile, 0, len(jobDetails.Job.OutputMediaFiles))
+	var captions []provider.CaptionFile
 	for _, mediaFile := range jobDetails.Job.OutputMediaFiles {
 		file := provider.OutputFile{
 			Path:       mediaFile.Url,
@@ -200,12 +452,36 @@ func (z *zencoderProvider) getJobOutputs(jobID int64) (provider.JobOutput, error
 			Height:     int64(mediaFile.Height),
 		}
 		files = append(files, file)
+		captions = append(captions, z.getCaptionFiles(job, mediaFile.Url)...)
 	}
 	return provider.JobOutput{
-		Files: files,
+		Files:    files,
+		Captions: captions,
 	}, nil
 }
 
+// getCaptionFiles returns the captions embedded in the output with the
+// given URL. Zencoder doesn't generate sidecar files, so captions are
+// reported as part of the outputs that carry them.
+func (z *zencoderProvider) getCaptionFiles(job *db.Job, outputURL string) []provider.CaptionFile {
+	if len(job.CaptionLanguages) == 0 {
+		return nil
+	}
+	filePath := outputURL
+	if u, err := url.Parse(outputURL); err == nil {
+		filePath = u.Path
+	}
+	format, ok := captionFormats[strings.TrimPrefix(path.Ext(filePath), ".")]
+	if !ok {
+		return nil
+	}
+	files := make([]provider.CaptionFile, len(job.CaptionLanguages))
+	for i, language := range job.CaptionLanguages {
+		files[i] = provider.CaptionFile{Path: outputURL, Format: format, Language: language}
+	}
+	return files
+}
+
 func (z *zencoderProvider) CancelJob(id string) error {
 	jobID, err := strconv.ParseInt(id, 10, 64)
 	if err != nil {
@@ -220,6 +496,9 @@ func (z *zencoderProvider) Healthcheck() error {
 }
 
 func (z *zencoderProvider) CreatePreset(preset db.Preset) (string, error) {
+	if err := z.checkPreset(preset); err != nil {
+		return "", err
+	}
 	err := z.db.CreateLocalPreset(&db.LocalPreset{
 		Name:   preset.Name,
 		Preset: preset,
@@ -230,10 +509,45 @@ func (z *zencoderProvider) CreatePreset(preset db.Preset) (string, error) {
 	return preset.Name, nil
 }
 
+func (z *zencoderProvider) UpdatePreset(presetID string, preset db.Preset) error {
+	if err := z.checkPreset(preset); err != nil {
+		return err
+	}
+	return z.db.UpdateLocalPreset(&db.LocalPreset{
+		Name:   presetID,
+		Preset: preset,
+	})
+}
+
+// checkPreset returns an error if the preset uses features that can't be
+// represented in Zencoder outputs, or values that can't be converted to the
+// output settings. Presets are only converted when creating jobs, so the
+// conversion runs here as well, rejecting invalid presets upfront.
+func (z *zencoderProvider) checkPreset(preset db.Preset) error {
+	if err := provider.CheckVideoCodec(Name, z.Capabilities(), preset); err != nil {
+		return err
+	}
+	if preset.Overlay.Enabled() {
+		if _, err := z.buildWatermark(preset.Overlay, ""); err != nil {
+			return err
+		}
+	}
+	_, _, err := z.buildOutput(preset)
+	return err
+}
+
 func (z *zencoderProvider) GetPreset(presetID string) (interface{}, error) {
 	return z.db.GetLocalPreset(presetID)
 }
 
+func (z *zencoderProvider) GetNormalizedPreset(presetID string) (*db.Preset, error) {
+	localPreset, err := z.db.GetLocalPreset(presetID)
+	if err != nil {
+		return nil, err
+	}
+	return &localPreset.Preset, nil
+}
+
 func (z *zencoderProvider) DeletePreset(presetID string) error {
 	preset, err := z.GetPreset(presetID)
 	if err != nil {
@@ -244,9 +558,13 @@ func (z *zencoderProvider) DeletePreset(presetID string) error {
 
 func (z *zencoderProvider) Capabilities() provider.Capabilities {
 	return provider.Capabilities{
-		InputFormats:  []string{"prores", "h264"},
-		OutputFormats: []string{"mp4", "hls", "webm"},
-		Destinations:  []string{"akamai", "s3"},
+		InputFormats:         []string{"prores", "h264"},
+		OutputFormats:        []string{"mp4", "hls", "dash", "webm"},
+		VideoCodecs:          videoCodecs.Codecs(),
+		Destinations:         []string{"akamai", "ftp", "gs", "s3"},
+		CaptionFormats:       provider.CaptionFormats,
+		HLSSegmentFormats:    []string{"ts"},
+		HLSEncryptionMethods: provider.HLSEncryptionMethods,
 	}
 }
 
@@ -254,7 +572,7 @@ func zencoderFactory(cfg *config.Config) (provider.TranscodingProvider, error) {
 	if cfg.Zencoder.APIKey == "" {
 		return nil, errZencoderInvalidConfig
 	}
-	client := zencoder.NewZencoder(cfg.Zencoder.APIKey)
+	client := &apiClient{Zencoder: zencoder.NewZencoder(cfg.Zencoder.APIKey), apiKey: cfg.Zencoder.APIKey}
 	dbRepo, err := redis.NewRepository(cfg)
 	if err != nil {
 		return nil, fmt.Errorf("Error initializing zencoder wrapper: %s", err)
@@ -5,9 +5,19 @@ import (
 )
 
 type FakeZencoder struct {
+	lastJob     *zencoderClient.EncodingSettings
+	lastJobJSON []byte
 }
 
 func (z *FakeZencoder) CreateJob(settings *zencoderClient.EncodingSettings) (*zencoderClient.CreateJobResponse, error) {
+	z.lastJob = settings
+	return &zencoderClient.CreateJobResponse{
+		Id: 123,
+	}, nil
+}
+
+func (z *FakeZencoder) CreateJobJSON(data []byte) (*zencoderClient.CreateJobResponse, error) {
+	z.lastJobJSON = data
 	return &zencoderClient.CreateJobResponse{
 		Id: 123,
 	}, nil
@@ -70,9 +70,13 @@ func TestZencoderFactoryValidation(t *testing.T) {
 func TestZencoderCapabilities(t *testing.T) {
 	var prov zencoderProvider
 	expected := provider.Capabilities{
-		InputFormats:  []string{"prores", "h264"},
-		OutputFormats: []string{"mp4", "hls", "webm"},
-		Destinations:  []string{"akamai", "s3"},
+		InputFormats:         []string{"prores", "h264"},
+		OutputFormats:        []string{"mp4", "hls", "dash", "webm"},
+		VideoCodecs:          []string{"h264", "h265", "vp8", "vp9"},
+		Destinations:         []string{"akamai", "ftp", "gs", "s3"},
+		CaptionFormats:       []string{"srt", "webvtt", "scc", "dfxp"},
+		HLSSegmentFormats:    []string{"ts"},
+		HLSEncryptionMethods: []string{"aes-128", "sample-aes"},
 	}
 	cap := prov.Capabilities()
 	if !reflect.DeepEqual(cap, expected) {
@@ -103,6 +107,7 @@ func TestZencoderCreatePreset(t *testing.T) {
 			GopMode: "fixed",
 			GopSize: "90",
 			Height:  "1080",
+			Width:   "1920",
 		},
 	}
 	provider, err := zencoderFactory(&cfg)
@@ -134,7 +139,11 @@ func TestCreatePresetError(t *testing.T) {
 		Zencoder: &config.Zencoder{APIKey: "api-key-here"},
 		Redis:    new(storage.Config),
 	}
-	preset := db.Preset{}
+	preset := db.Preset{
+		Container: "mp4",
+		Video:     db.VideoPreset{Bitrate: "3500000", Codec: "h264", GopSize: "90", Height: "1080", Width: "1920"},
+		Audio:     db.AudioPreset{Bitrate: "128000", Codec: "aac"},
+	}
 	provider, err := zencoderFactory(&cfg)
 
 	_, err = provider.CreatePreset(preset)
@@ -143,6 +152,161 @@ func TestCreatePresetError(t *testing.T) {
 	}
 }
 
+func TestCreatePresetInvalidValues(t *testing.T) {
+	cleanLocalPresets()
+	cfg := config.Config{
+		Zencoder: &config.Zencoder{APIKey: "api-key-here"},
+		Redis:    new(storage.Config),
+	}
+	prov, err := zencoderFactory(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tests = []struct {
+		givenTestCase string
+		givenVideo    db.VideoPreset
+		wantErrMsg    string
+	}{
+		{
+			"missing width",
+			db.VideoPreset{Bitrate: "3500000", Codec: "h264", GopSize: "90", Height: "1080"},
+			`error converting preset width (""): strconv.ParseInt: parsing "": invalid syntax`,
+		},
+		{
+			"missing gop size",
+			db.VideoPreset{Bitrate: "3500000", Codec: "h264", Height: "1080", Width: "1920"},
+			`error converting preset keyframe interval (""): strconv.ParseInt: parsing "": invalid syntax`,
+		},
+	}
+	for _, test := range tests {
+		preset := db.Preset{
+			Name:      "mp4_1080p",
+			Container: "mp4",
+			Video:     test.givenVideo,
+			Audio:     db.AudioPreset{Bitrate: "128000", Codec: "aac"},
+		}
+		_, err = prov.CreatePreset(preset)
+		if err == nil || err.Error() != test.wantErrMsg {
+			t.Errorf("%s: CreatePreset: wrong error returned. Want %q. Got %v", test.givenTestCase, test.wantErrMsg, err)
+		}
+		err = prov.(*zencoderProvider).UpdatePreset("mp4_1080p", preset)
+		if err == nil || err.Error() != test.wantErrMsg {
+			t.Errorf("%s: UpdatePreset: wrong error returned. Want %q. Got %v", test.givenTestCase, test.wantErrMsg, err)
+		}
+	}
+	if _, err = prov.GetPreset("mp4_1080p"); err != db.ErrLocalPresetNotFound {
+		t.Errorf("invalid preset should not be stored. Got error %#v", err)
+	}
+}
+
+func TestCreatePresetVideoCodecs(t *testing.T) {
+	cleanLocalPresets()
+	cfg := config.Config{
+		Zencoder: &config.Zencoder{APIKey: "api-key-here"},
+		Redis:    new(storage.Config),
+	}
+	prov, err := zencoderFactory(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tests = []struct {
+		givenCodec    string
+		expectedCodec string
+		expectedErr   string
+	}{
+		{"h264", "h264", ""},
+		{"h265", "hevc", ""},
+		{"vp8", "vp8", ""},
+		{"vp9", "vp9", ""},
+		{"av1", "", `video codec "av1" is not supported by zencoder. Supported codecs are: h264, h265, vp8, vp9`},
+	}
+	for _, test := range tests {
+		preset := db.Preset{
+			Name:      "preset_" + test.givenCodec,
+			Container: "mp4",
+			Video:     db.VideoPreset{Bitrate: "3500000", Codec: test.givenCodec, GopSize: "90", Height: "1080", Width: "1920"},
+			Audio:     db.AudioPreset{Bitrate: "128000", Codec: "aac"},
+		}
+		_, err = prov.CreatePreset(preset)
+		if test.expectedErr != "" {
+			if err == nil || err.Error() != test.expectedErr {
+				t.Errorf("%s: wrong error returned. Want %q. Got %v", test.givenCodec, test.expectedErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.givenCodec, err)
+			continue
+		}
+		output, _, err := prov.(*zencoderProvider).buildOutput(preset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if output.VideoCodec != test.expectedCodec {
+			t.Errorf("%s: wrong video codec. Want %q. Got %q", test.givenCodec, test.expectedCodec, output.VideoCodec)
+		}
+	}
+}
+
+func TestCreatePresetLadderRung(t *testing.T) {
+	cleanLocalPresets()
+	cfg := config.Config{
+		Zencoder: &config.Zencoder{APIKey: "api-key-here"},
+		Redis:    new(storage.Config),
+	}
+	prov, err := zencoderFactory(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	preset := db.Preset{
+		Name:        "mp4_h264_854x480_1500k_gop60",
+		Description: "Generated for adaptive bitrate ladders",
+		Container:   "mp4",
+		Video:       db.VideoPreset{Codec: "h264", Width: "854", Height: "480", Bitrate: "1500000", GopSize: "60", AlignKeyframes: true},
+		Audio:       db.AudioPreset{Codec: "aac", Bitrate: "96000"},
+	}
+	presetID, err := prov.CreatePreset(preset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if presetID != preset.Name {
+		t.Errorf("wrong preset id. Want %q. Got %q", preset.Name, presetID)
+	}
+	output, _, err := prov.(*zencoderProvider).buildOutput(preset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output.Width != 854 || output.Height != 480 {
+		t.Errorf("wrong dimensions. Want 854x480. Got %dx%d", output.Width, output.Height)
+	}
+	if output.KeyframeInterval != 60 || !output.FixedKeyframeInterval {
+		t.Errorf("wrong keyframe interval. Want fixed interval of 60. Got %d (fixed: %v)", output.KeyframeInterval, output.FixedKeyframeInterval)
+	}
+}
+
+func TestCreatePresetInvalidProviderOptions(t *testing.T) {
+	cleanLocalPresets()
+	cfg := config.Config{
+		Zencoder: &config.Zencoder{APIKey: "api-key-here"},
+		Redis:    new(storage.Config),
+	}
+	preset := db.Preset{
+		Name:            "mp4_1080p",
+		Container:       "mp4",
+		Video:           db.VideoPreset{Bitrate: "3500000", Codec: "h264", GopSize: "90", Height: "1080", Width: "1920"},
+		Audio:           db.AudioPreset{Bitrate: "128000", Codec: "aac"},
+		ProviderOptions: db.ProviderOptions{Name: {"speed": "fast"}},
+	}
+	prov, err := zencoderFactory(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = prov.CreatePreset(preset)
+	if _, ok := err.(*provider.InvalidProviderOptionError); !ok {
+		t.Errorf("Got wrong error. Want *provider.InvalidProviderOptionError. Got %#v", err)
+	}
+}
+
 func TestGetPreset(t *testing.T) {
 	cleanLocalPresets()
 	cfg := config.Config{
@@ -157,6 +321,7 @@ func TestGetPreset(t *testing.T) {
 			GopMode: "fixed",
 			GopSize: "90",
 			Height:  "1080",
+			Width:   "1920",
 		},
 		Audio: db.AudioPreset{
 			Bitrate: "128000",
@@ -185,6 +350,87 @@ func TestGetPreset(t *testing.T) {
 	}
 }
 
+func TestGetNormalizedPreset(t *testing.T) {
+	cleanLocalPresets()
+	cfg := config.Config{
+		Zencoder: &config.Zencoder{APIKey: "api-key-here"},
+		Redis:    new(storage.Config),
+	}
+	preset := db.Preset{
+		Name:      "get_preset",
+		Container: "mp4",
+		Video: db.VideoPreset{
+			Bitrate: "3500000",
+			Codec:   "h264",
+			GopSize: "90",
+			Height:  "1080",
+			Width:   "1920",
+		},
+		Audio: db.AudioPreset{
+			Bitrate: "128000",
+			Codec:   "aac",
+		},
+	}
+	provider, err := zencoderFactory(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	presetName, err := provider.CreatePreset(preset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := provider.GetNormalizedPreset(presetName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*res, preset) {
+		t.Errorf("Got wrong preset. Want %#v. Got %#v", preset, *res)
+	}
+}
+
+func TestZencoderUpdatePreset(t *testing.T) {
+	cleanLocalPresets()
+	cfg := config.Config{
+		Zencoder: &config.Zencoder{APIKey: "api-key-here"},
+		Redis:    new(storage.Config),
+	}
+	preset := db.Preset{
+		Name:      "update_preset",
+		Container: "mp4",
+		Video: db.VideoPreset{
+			Bitrate: "3500000",
+			Codec:   "h264",
+			GopSize: "90",
+			Height:  "1080",
+			Width:   "1920",
+		},
+		Audio: db.AudioPreset{
+			Bitrate: "128000",
+			Codec:   "aac",
+		},
+	}
+	prov, err := zencoderFactory(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	presetName, err := prov.CreatePreset(preset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	preset.Video.Bitrate = "2000000"
+	err = prov.(*zencoderProvider).UpdatePreset(presetName, preset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := prov.GetNormalizedPreset(presetName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*res, preset) {
+		t.Errorf("Got wrong preset. Want %#v. Got %#v", preset, *res)
+	}
+}
+
 func TestZencoderDeletePreset(t *testing.T) {
 	cleanLocalPresets()
 	cfg := config.Config{
@@ -199,6 +445,7 @@ func TestZencoderDeletePreset(t *testing.T) {
 			GopMode: "fixed",
 			GopSize: "90",
 			Height:  "1080",
+			Width:   "1920",
 		},
 		Audio: db.AudioPreset{
 			Bitrate: "128000",
@@ -277,7 +524,7 @@ func TestZencoderTranscode(t *testing.T) {
 		},
 	}
 	transcodeProfile := provider.TranscodeProfile{
-		SourceMedia:     "dir/file.mov",
+		SourceMedia:     "gs://bucket/dir/file.mov",
 		Outputs:         outputs,
 		StreamingParams: provider.StreamingParams{},
 	}
@@ -288,6 +535,359 @@ func TestZencoderTranscode(t *testing.T) {
 	if jobStatus.ProviderJobID != "123" {
 		t.Errorf("Got wrong jobStatus ID. Expected 123, got %#v", jobStatus.ProviderJobID)
 	}
+	if input := fakeZencoder.lastJob.Input; input != "gcs://bucket/dir/file.mov" {
+		t.Errorf("Got wrong input. Want %q. Got %q", "gcs://bucket/dir/file.mov", input)
+	}
+	transcodeProfile.ProviderOptions = db.ProviderOptions{Name: {"region": "europe", "test": true}}
+	_, err = prov.Transcode(&db.Job{ID: "job-123"}, transcodeProfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if region := fakeZencoder.lastJob.Region; region != "europe" {
+		t.Errorf("Got wrong region. Want %q. Got %q", "europe", region)
+	}
+	if !fakeZencoder.lastJob.Test {
+		t.Error("Provider option test was not applied to the job")
+	}
+	transcodeProfile.ProviderOptions = db.ProviderOptions{Name: {"regions": "europe"}}
+	_, err = prov.Transcode(&db.Job{ID: "job-123"}, transcodeProfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var job map[string]interface{}
+	if err = json.Unmarshal(fakeZencoder.lastJobJSON, &job); err != nil {
+		t.Fatal(err)
+	}
+	if job["regions"] != "europe" {
+		t.Errorf("Provider option regions was not merged into the job. Got %s", fakeZencoder.lastJobJSON)
+	}
+	if job["input"] != "gcs://bucket/dir/file.mov" {
+		t.Errorf("Got wrong input. Want %q. Got %v", "gcs://bucket/dir/file.mov", job["input"])
+	}
+	if outputs, ok := job["outputs"].([]interface{}); !ok || len(outputs) != 1 {
+		t.Errorf("Got wrong outputs in the job. Got %#v", job["outputs"])
+	}
+	transcodeProfile.ProviderOptions = db.ProviderOptions{Name: {"test": "yes"}}
+	_, err = prov.Transcode(&db.Job{ID: "job-123"}, transcodeProfile)
+	if _, ok := err.(*provider.InvalidProviderOptionError); !ok {
+		t.Errorf("Got wrong error. Want *provider.InvalidProviderOptionError. Got %#v", err)
+	}
+	transcodeProfile.ProviderOptions = nil
+	transcodeProfile.SourceMedia = "dir/file.mov"
+	_, err = prov.Transcode(&db.Job{ID: "job-123"}, transcodeProfile)
+	if expected := `invalid source "dir/file.mov": missing scheme`; err == nil || err.Error() != expected {
+		t.Errorf("Got wrong error. Want %q. Got %v", expected, err)
+	}
+}
+
+func TestZencoderJobJSON(t *testing.T) {
+	settings := zencoder.EncodingSettings{
+		Input: "gcs://bucket/dir/file.mov",
+		Outputs: []*zencoder.OutputSettings{
+			{Label: "mp4_720p", Format: "mp4"},
+			{Label: "webm_720p", Format: "webm"},
+		},
+	}
+	jobOptions := map[string]interface{}{"regions": "europe"}
+	outputOptions := []map[string]interface{}{nil, {"tuning": "film"}}
+	data, err := jobJSON(&settings, jobOptions, outputOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var job struct {
+		Input   string                   `json:"input"`
+		Regions string                   `json:"regions"`
+		Outputs []map[string]interface{} `json:"outputs"`
+	}
+	if err = json.Unmarshal(data, &job); err != nil {
+		t.Fatal(err)
+	}
+	if job.Input != settings.Input {
+		t.Errorf("Got wrong input. Want %q. Got %q", settings.Input, job.Input)
+	}
+	if job.Regions != "europe" {
+		t.Errorf("Got wrong regions. Want %q. Got %q", "europe", job.Regions)
+	}
+	if len(job.Outputs) != 2 {
+		t.Fatalf("Got wrong number of outputs. Want 2. Got %d", len(job.Outputs))
+	}
+	if _, ok := job.Outputs[0]["tuning"]; ok {
+		t.Errorf("Unexpected tuning option in the first output: %#v", job.Outputs[0])
+	}
+	if job.Outputs[1]["tuning"] != "film" || job.Outputs[1]["format"] != "webm" {
+		t.Errorf("Provider options were not merged into the second output: %#v", job.Outputs[1])
+	}
+}
+
+func TestZencoderBuildOutputsCaptions(t *testing.T) {
+	cleanLocalPresets()
+	cfg := config.Config{
+		Zencoder: &config.Zencoder{APIKey: "api-key-here"},
+		Redis:    new(storage.Config),
+	}
+	dbRepo, err := redis.NewRepository(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prov := &zencoderProvider{config: &cfg, client: &FakeZencoder{}, db: dbRepo}
+	_, err = prov.CreatePreset(db.Preset{
+		Name:      "mp4_720p",
+		Container: "mp4",
+		Audio:     db.AudioPreset{Bitrate: "128000", Codec: "aac"},
+		Video: db.VideoPreset{
+			Bitrate: "2500000",
+			Codec:   "h264",
+			GopSize: "90",
+			Height:  "720",
+			Width:   "1280",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = prov.CreatePreset(db.Preset{
+		Name:      "webm_720p",
+		Container: "webm",
+		Audio:     db.AudioPreset{Bitrate: "128000", Codec: "vorbis"},
+		Video: db.VideoPreset{
+			Bitrate: "2500000",
+			Codec:   "vp8",
+			GopSize: "90",
+			Height:  "720",
+			Width:   "1280",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	transcodeProfile := provider.TranscodeProfile{
+		SourceMedia: "dir/file.mov",
+		Outputs: []provider.TranscodeOutput{
+			{FileName: "output-720p.mp4", Preset: db.PresetMap{Name: "mp4_720p"}},
+			{FileName: "output-720p.webm", Preset: db.PresetMap{Name: "webm_720p"}},
+		},
+		Captions: []provider.Caption{
+			{Source: "http://nyt.net/captions/en.srt", Format: "srt", Language: "en"},
+		},
+	}
+	outputs, _, err := prov.buildOutputs(&db.Job{ID: "job-123"}, transcodeProfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outputs) != 2 {
+		t.Fatalf("wrong number of outputs. Want 2. Got %d", len(outputs))
+	}
+	if outputs[0].CaptionUrl != "http://nyt.net/captions/en.srt" {
+		t.Errorf("wrong caption url. Want %q. Got %q", "http://nyt.net/captions/en.srt", outputs[0].CaptionUrl)
+	}
+	if outputs[1].CaptionUrl != "" {
+		t.Errorf("unexpected caption url in webm output: %q", outputs[1].CaptionUrl)
+	}
+	transcodeProfile.Captions = append(transcodeProfile.Captions, provider.Caption{
+		Source:   "http://nyt.net/captions/es.srt",
+		Format:   "srt",
+		Language: "es",
+	})
+	_, _, err = prov.buildOutputs(&db.Job{ID: "job-123"}, transcodeProfile)
+	if err != errMultipleCaptions {
+		t.Errorf("wrong error returned. Want %#v. Got %#v", errMultipleCaptions, err)
+	}
+}
+
+func TestZencoderBuildOutputsEncryption(t *testing.T) {
+	cleanLocalPresets()
+	cfg := config.Config{
+		Zencoder: &config.Zencoder{APIKey: "api-key-here"},
+		Redis:    new(storage.Config),
+	}
+	dbRepo, err := redis.NewRepository(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prov := &zencoderProvider{config: &cfg, client: &FakeZencoder{}, db: dbRepo}
+	for _, container := range []string{"mp4", "m3u8"} {
+		_, err = prov.CreatePreset(db.Preset{
+			Name:      container + "_720p",
+			Container: container,
+			Audio:     db.AudioPreset{Bitrate: "128000", Codec: "aac"},
+			Video: db.VideoPreset{
+				Bitrate: "2500000",
+				Codec:   "h264",
+				GopSize: "90",
+				Height:  "720",
+				Width:   "1280",
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	transcodeProfile := provider.TranscodeProfile{
+		SourceMedia: "dir/file.mov",
+		Outputs: []provider.TranscodeOutput{
+			{FileName: "output-720p.mp4", Preset: db.PresetMap{Name: "mp4_720p"}},
+			{FileName: "hls/output-720p.m3u8", Preset: db.PresetMap{Name: "m3u8_720p"}},
+		},
+		StreamingParams: provider.StreamingParams{
+			Protocol: "hls",
+			Encryption: &provider.Encryption{
+				Method: "sample-aes",
+				Key:    "MDEyMzQ1Njc4OWFiY2RlZg==",
+				KeyURI: "https://keys.example.com/job-123",
+			},
+		},
+	}
+	outputs, _, err := prov.buildOutputs(&db.Job{ID: "job-123"}, transcodeProfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if outputs[0].EncryptionMethod != "" || outputs[0].EncryptionKey != "" {
+		t.Errorf("unexpected encryption in non-HLS output: %#v", outputs[0])
+	}
+	if outputs[1].EncryptionMethod != "sample-aes" {
+		t.Errorf("wrong encryption method. Want %q. Got %q", "sample-aes", outputs[1].EncryptionMethod)
+	}
+	if expected := "30313233343536373839616263646566"; outputs[1].EncryptionKey != expected {
+		t.Errorf("wrong encryption key. Want %q. Got %q", expected, outputs[1].EncryptionKey)
+	}
+	if outputs[1].EncryptionKeyUrl != "https://keys.example.com/job-123" {
+		t.Errorf("wrong encryption key url. Want %q. Got %q", "https://keys.example.com/job-123", outputs[1].EncryptionKeyUrl)
+	}
+}
+
+func TestZencoderBuildOutputsDestination(t *testing.T) {
+	cleanLocalPresets()
+	cfg := config.Config{
+		Zencoder: &config.Zencoder{APIKey: "api-key-here"},
+		Redis:    new(storage.Config),
+	}
+	dbRepo, err := redis.NewRepository(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prov := &zencoderProvider{config: &cfg, client: &FakeZencoder{}, db: dbRepo}
+	_, err = prov.CreatePreset(db.Preset{
+		Name:      "mp4_720p",
+		Container: "mp4",
+		Audio:     db.AudioPreset{Bitrate: "128000", Codec: "aac"},
+		Video: db.VideoPreset{
+			Bitrate: "2500000",
+			Codec:   "h264",
+			GopSize: "90",
+			Height:  "720",
+			Width:   "1280",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	transcodeProfile := provider.TranscodeProfile{
+		SourceMedia: "dir/file.mov",
+		Outputs: []provider.TranscodeOutput{
+			{FileName: "output-720p.mp4", Preset: db.PresetMap{Name: "mp4_720p"}},
+		},
+	}
+	outputs, _, err := prov.buildOutputs(&db.Job{ID: "job-123"}, transcodeProfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if outputs[0].BaseUrl != "" || outputs[0].Filename != "" {
+		t.Errorf("unexpected destination in job without destination: %#v", outputs[0])
+	}
+	job := db.Job{ID: "job-123", Destination: "s3://team-bucket/videos"}
+	outputs, _, err = prov.buildOutputs(&job, transcodeProfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if outputs[0].BaseUrl != "s3://team-bucket/videos/" {
+		t.Errorf("wrong base url. Want %q. Got %q", "s3://team-bucket/videos/", outputs[0].BaseUrl)
+	}
+	if outputs[0].Filename != "output-720p.mp4" {
+		t.Errorf("wrong filename. Want %q. Got %q", "output-720p.mp4", outputs[0].Filename)
+	}
+	cfg.Zencoder.Destination = "s3://default-bucket/"
+	job = db.Job{ID: "job-123", OutputPath: "2016-10-18/job-123"}
+	outputs, _, err = prov.buildOutputs(&job, transcodeProfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "s3://default-bucket/2016-10-18/job-123/"; outputs[0].BaseUrl != expected {
+		t.Errorf("wrong base url. Want %q. Got %q", expected, outputs[0].BaseUrl)
+	}
+	job = db.Job{ID: "job-123", Destination: "gs://team-bucket/videos"}
+	outputs, _, err = prov.buildOutputs(&job, transcodeProfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "gcs://team-bucket/videos/"; outputs[0].BaseUrl != expected {
+		t.Errorf("wrong base url. Want %q. Got %q", expected, outputs[0].BaseUrl)
+	}
+	job = db.Job{ID: "job-123", Destination: "http://nyt.net/videos"}
+	_, _, err = prov.buildOutputs(&job, transcodeProfile)
+	expectedErr := `invalid destination "http://nyt.net/videos": unsupported scheme "http". Supported schemes are: s3, gs, ftp, akamai`
+	if err == nil || err.Error() != expectedErr {
+		t.Errorf("wrong error returned. Want %q. Got %v", expectedErr, err)
+	}
+}
+
+func TestZencoderBuildWatermark(t *testing.T) {
+	var tests = []struct {
+		givenTestCase     string
+		overlay           db.OverlayPreset
+		expectedWatermark *zencoder.WatermarkSettings
+		expectedErr       string
+	}{
+		{
+			"top-left overlay",
+			db.OverlayPreset{Position: "top-left", OffsetX: "10", OffsetY: "20"},
+			&zencoder.WatermarkSettings{Url: "http://nyt.net/logo.png", X: "10", Y: "20"},
+			"",
+		},
+		{
+			"bottom-right overlay",
+			db.OverlayPreset{Position: "bottom-right", OffsetX: "10", OffsetY: "20", Opacity: "100"},
+			&zencoder.WatermarkSettings{Url: "http://nyt.net/logo.png", X: "-10", Y: "-20"},
+			"",
+		},
+		{
+			"top-right overlay with default offsets",
+			db.OverlayPreset{Position: "top-right"},
+			&zencoder.WatermarkSettings{Url: "http://nyt.net/logo.png", X: "-0", Y: "0"},
+			"",
+		},
+		{
+			"invalid position",
+			db.OverlayPreset{Position: "middle"},
+			nil,
+			`invalid overlay position: "middle"`,
+		},
+		{
+			"opacity",
+			db.OverlayPreset{Position: "top-left", Opacity: "50"},
+			nil,
+			"zencoder does not support overlay opacity",
+		},
+		{
+			"time range",
+			db.OverlayPreset{Position: "top-left", EndTime: "30"},
+			nil,
+			"zencoder does not support overlay time range",
+		},
+	}
+	var prov zencoderProvider
+	for _, test := range tests {
+		watermark, err := prov.buildWatermark(test.overlay, "http://nyt.net/logo.png")
+		var errMsg string
+		if err != nil {
+			errMsg = err.Error()
+		}
+		if errMsg != test.expectedErr {
+			t.Errorf("%s: wrong error. Want %q. Got %q", test.givenTestCase, test.expectedErr, errMsg)
+		}
+		if !reflect.DeepEqual(watermark, test.expectedWatermark) {
+			t.Errorf("%s: wrong watermark\nWant %#v\nGot  %#v", test.givenTestCase, test.expectedWatermark, watermark)
+		}
+	}
 }
 
 func TestZencoderBuildOutput(t *testing.T) {
@@ -336,6 +936,89 @@ func TestZencoderBuildOutput(t *testing.T) {
 				"deinterlace":             "on",
 			},
 		},
+		{
+			"Test with encoding parameters",
+			db.Preset{
+				Name:         "mp4_1080p",
+				Description:  "my nice preset",
+				Container:    "mp4",
+				Profile:      "high",
+				ProfileLevel: "4.1",
+				Video: db.VideoPreset{
+					Bitrate:         "3500000",
+					Codec:           "h264",
+					GopSize:         "60",
+					Height:          "1080",
+					Width:           "1920",
+					FrameRate:       "29.97",
+					AlignKeyframes:  true,
+					BFrames:         "3",
+					ReferenceFrames: "4",
+					TwoPass:         true,
+					MaxBitrate:      "4000000",
+					BufferSize:      "8000000",
+				},
+				Audio: db.AudioPreset{
+					Bitrate:    "128000",
+					Codec:      "aac",
+					Channels:   "2",
+					SampleRate: "48000",
+				},
+			},
+			map[string]interface{}{
+				"label":                   "mp4_1080p:my nice preset",
+				"format":                  "mp4",
+				"video_codec":             "h264",
+				"h264_profile":            "high",
+				"h264_level":              "4.1",
+				"h264_reference_frames":   float64(4),
+				"h264_bframes":            float64(3),
+				"audio_codec":             "aac",
+				"audio_channels":          float64(2),
+				"audio_sample_rate":       float64(48000),
+				"width":                   float64(1920),
+				"height":                  float64(1080),
+				"frame_rate":              29.97,
+				"video_bitrate":           float64(3500),
+				"decoder_bitrate_cap":     float64(4000),
+				"decoder_buffer_size":     float64(8000),
+				"audio_bitrate":           float64(128),
+				"keyframe_interval":       float64(60),
+				"fixed_keyframe_interval": true,
+				"deinterlace":             "on",
+			},
+		},
+		{
+			"Test with hevc preset",
+			db.Preset{
+				Name:        "hls_2160p",
+				Description: "my hevc preset",
+				Container:   "m3u8",
+				Video: db.VideoPreset{
+					Bitrate: "15000000",
+					Codec:   "h265",
+					GopSize: "90",
+					Height:  "2160",
+					Width:   "3840",
+				},
+				Audio: db.AudioPreset{
+					Bitrate: "128000",
+					Codec:   "aac",
+				},
+			},
+			map[string]interface{}{
+				"label":             "hls_2160p:my hevc preset",
+				"format":            "m3u8",
+				"video_codec":       "hevc",
+				"audio_codec":       "aac",
+				"width":             float64(3840),
+				"height":            float64(2160),
+				"video_bitrate":     float64(15000),
+				"audio_bitrate":     float64(128),
+				"keyframe_interval": float64(90),
+				"deinterlace":       "on",
+			},
+		},
 		{
 			"Test with webm preset",
 			db.Preset{
@@ -367,10 +1050,79 @@ func TestZencoderBuildOutput(t *testing.T) {
 				"deinterlace":       "on",
 			},
 		},
+		{
+			"Test with dash preset",
+			db.Preset{
+				Name:        "dash_1080p",
+				Description: "my dash preset",
+				Container:   "mpd",
+				Video: db.VideoPreset{
+					Bitrate: "3500000",
+					Codec:   "h264",
+					GopSize: "90",
+					Height:  "1080",
+					Width:   "1920",
+				},
+				Audio: db.AudioPreset{
+					Bitrate: "128000",
+					Codec:   "aac",
+				},
+			},
+			map[string]interface{}{
+				"label":                     "dash_1080p:my dash preset",
+				"type":                      "segmented",
+				"format":                    "mp4",
+				"streaming_delivery_format": "dash",
+				"video_codec":               "h264",
+				"audio_codec":               "aac",
+				"width":                     float64(1920),
+				"height":                    float64(1080),
+				"video_bitrate":             float64(3500),
+				"audio_bitrate":             float64(128),
+				"keyframe_interval":         float64(90),
+				"deinterlace":               "on",
+			},
+		},
+		{
+			"Test with provider options",
+			db.Preset{
+				Name:        "webm_1080p",
+				Description: "my vp8 preset",
+				Container:   "webm",
+				Video: db.VideoPreset{
+					Bitrate: "3500000",
+					Codec:   "vp8",
+					GopSize: "90",
+					Height:  "1080",
+					Width:   "1920",
+				},
+				Audio: db.AudioPreset{
+					Bitrate: "128000",
+					Codec:   "aac",
+				},
+				ProviderOptions: db.ProviderOptions{
+					Name:                {"speed": float64(4), "deinterlace": "detect"},
+					"elastictranscoder": {"Preset": "veryslow"},
+				},
+			},
+			map[string]interface{}{
+				"label":             "webm_1080p:my vp8 preset",
+				"format":            "webm",
+				"video_codec":       "vp8",
+				"audio_codec":       "aac",
+				"width":             float64(1920),
+				"height":            float64(1080),
+				"video_bitrate":     float64(3500),
+				"audio_bitrate":     float64(128),
+				"keyframe_interval": float64(90),
+				"deinterlace":       "detect",
+				"speed":             float64(4),
+			},
+		},
 	}
 
 	for _, test := range tests {
-		res, err := prov.buildOutput(test.Preset)
+		res, _, err := prov.buildOutput(test.Preset)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -500,6 +1252,31 @@ func TestZencoderJobStatus(t *testing.T) {
 	}
 }
 
+func TestZencoderJobStatusCaptions(t *testing.T) {
+	cfg := config.Config{
+		Zencoder: &config.Zencoder{APIKey: "api-key-here"},
+		Redis:    new(storage.Config),
+	}
+	dbRepo, err := redis.NewRepository(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prov := &zencoderProvider{config: &cfg, client: &FakeZencoder{}, db: dbRepo}
+	jobStatus, err := prov.JobStatus(&db.Job{
+		ProviderJobID:    "1234567890",
+		CaptionLanguages: []string{"en"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []provider.CaptionFile{
+		{Path: "http://nyt.net/output1.mp4", Format: "cea-608", Language: "en"},
+	}
+	if !reflect.DeepEqual(jobStatus.Output.Captions, expected) {
+		t.Errorf("wrong caption files\nWant %#v\nGot  %#v", expected, jobStatus.Output.Captions)
+	}
+}
+
 func cleanLocalPresets() error {
 	client := redisDriver.NewClient(&redisDriver.Options{Addr: "127.0.0.1:6379"})
 	defer client.Close()
@@ -1,6 +1,8 @@
 package service
 
 import (
+	"errors"
+
 	"github.com/NYTimes/video-transcoding-api/config"
 	"github.com/NYTimes/video-transcoding-api/db"
 	"github.com/NYTimes/video-transcoding-api/provider"
@@ -8,21 +10,36 @@ import (
 
 func init() {
 	provider.Register("fake", fakeProviderFactory)
+	provider.Register("fakeupdater", fakePresetUpdaterFactory)
 }
 
 type fakeProvider struct {
-	jobs         []provider.TranscodeProfile
-	canceledJobs []string
+	jobs           []provider.TranscodeProfile
+	canceledJobs   []string
+	deletedPresets []string
 }
 
 var fprovider fakeProvider
 
+// fakePresetUpdater is a fake provider that updates presets in place,
+// failing to update presets with the description "fail update". It doesn't
+// support the av1 codec.
+type fakePresetUpdater struct {
+	fakeProvider
+	presets map[string]db.Preset
+}
+
+var fupdater = fakePresetUpdater{presets: make(map[string]db.Preset)}
+
 func (p *fakeProvider) Transcode(job *db.Job, transcodeProfile provider.TranscodeProfile) (*provider.JobStatus, error) {
 	for _, output := range transcodeProfile.Outputs {
 		if _, ok := output.Preset.ProviderMapping["fake"]; !ok {
 			return nil, provider.ErrPresetMapNotFound
 		}
 	}
+	if _, ok := transcodeProfile.ProviderOptions["fake"]["invalid"]; ok {
+		return nil, &provider.InvalidProviderOptionError{Provider: "fake", Option: "invalid", Reason: "unknown option"}
+	}
 	p.jobs = append(p.jobs, transcodeProfile)
 	return &provider.JobStatus{
 		ProviderJobID: "provider-preset-job-123",
@@ -43,7 +60,17 @@ func (*fakeProvider) GetPreset(presetID string) (interface{}, error) {
 	return struct{ presetID string }{"presetID_here"}, nil
 }
 
-func (*fakeProvider) DeletePreset(presetID string) error {
+func (*fakeProvider) GetNormalizedPreset(presetID string) (*db.Preset, error) {
+	return &db.Preset{
+		Name:      presetID,
+		Container: "mp4",
+		Video:     db.VideoPreset{Codec: "h264", Height: "720"},
+		Audio:     db.AudioPreset{Codec: "aac", Bitrate: "64000"},
+	}, nil
+}
+
+func (p *fakeProvider) DeletePreset(presetID string) error {
+	p.deletedPresets = append(p.deletedPresets, presetID)
 	return nil
 }
 
@@ -71,9 +98,44 @@ func (p *fakeProvider) JobStatus(job *db.Job) (*provider.JobStatus, error) {
 			},
 			Output: provider.JobOutput{
 				Destination: "s3://mybucket/some/dir/job-123",
+				Files: []provider.OutputFile{
+					{
+						Path:       "s3://mybucket/some/dir/job-123/video_1080p.mp4",
+						Container:  "mp4",
+						VideoCodec: "H.264",
+						Height:     1080,
+						Width:      1920,
+					},
+					{
+						Path:       "ftp://ftp.example.com/job-123/video_1080p.webm",
+						Container:  "webm",
+						VideoCodec: "VP8",
+						Height:     1080,
+						Width:      1920,
+					},
+				},
+				Captions: []provider.CaptionFile{
+					{Path: "s3://mybucket/some/dir/job-123/video_en.vtt", Format: "webvtt", Language: "en"},
+				},
+			},
+		}, nil
+	}
+	if id == "provider-job-456" {
+		return &provider.JobStatus{
+			ProviderJobID: id,
+			Status:        provider.StatusFinished,
+			Progress:      100,
+			Output: provider.JobOutput{
+				Destination: job.Destination,
+				Files: []provider.OutputFile{
+					{Path: job.Destination + "/video.mp4", Container: "mp4"},
+				},
 			},
 		}, nil
 	}
+	if id == "provider-job-active" {
+		return &provider.JobStatus{ProviderJobID: id, Status: provider.StatusStarted, Progress: 50}, nil
+	}
 	return nil, provider.JobNotFoundError{ID: id}
 }
 
@@ -91,12 +153,42 @@ func (p *fakeProvider) Healthcheck() error {
 
 func (p *fakeProvider) Capabilities() provider.Capabilities {
 	return provider.Capabilities{
-		InputFormats:  []string{"prores", "h264"},
-		OutputFormats: []string{"mp4", "webm", "hls"},
-		Destinations:  []string{"akamai", "s3"},
+		InputFormats:         []string{"prores", "h264"},
+		OutputFormats:        []string{"mp4", "webm", "hls", "dash"},
+		VideoCodecs:          []string{"h264", "h265", "vp8", "vp9", "av1"},
+		Destinations:         []string{"akamai", "s3"},
+		CaptionFormats:       []string{"srt", "webvtt"},
+		HLSSegmentFormats:    []string{"ts", "fmp4"},
+		HLSEncryptionMethods: []string{"aes-128"},
 	}
 }
 
 func fakeProviderFactory(cfg *config.Config) (provider.TranscodingProvider, error) {
 	return &fprovider, nil
 }
+
+func (p *fakePresetUpdater) GetNormalizedPreset(presetID string) (*db.Preset, error) {
+	preset, ok := p.presets[presetID]
+	if !ok {
+		return nil, errors.New("preset not found")
+	}
+	return &preset, nil
+}
+
+func (p *fakePresetUpdater) UpdatePreset(presetID string, preset db.Preset) error {
+	if preset.Description == "fail update" {
+		return errors.New("failed to update preset")
+	}
+	p.presets[presetID] = preset
+	return nil
+}
+
+func (p *fakePresetUpdater) Capabilities() provider.Capabilities {
+	capabilities := p.fakeProvider.Capabilities()
+	capabilities.VideoCodecs = []string{"h264", "h265", "vp8", "vp9"}
+	return capabilities
+}
+
+func fakePresetUpdaterFactory(cfg *config.Config) (provider.TranscodingProvider, error) {
+	return &fupdater, nil
+}
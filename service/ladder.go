@@ -0,0 +1,335 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/probe"
+	"github.com/NYTimes/video-transcoding-api/provider"
+	"github.com/NYTimes/video-transcoding-api/swagger"
+)
+
+// ladderRung is a rung of an adaptive bitrate ladder. Bitrates are in bits
+// per second.
+type ladderRung struct {
+	Height       uint `json:"height"`
+	VideoBitrate uint `json:"videoBitrate"`
+	AudioBitrate uint `json:"audioBitrate"`
+}
+
+type ladderRungsByHeight []ladderRung
+
+func (r ladderRungsByHeight) Len() int           { return len(r) }
+func (r ladderRungsByHeight) Less(i, j int) bool { return r[i].Height > r[j].Height }
+func (r ladderRungsByHeight) Swap(i, j int)      { r[i], r[j] = r[j], r[i] }
+
+// ladderKeyframeInterval is the interval between keyframes in the presets of
+// the ladder, in seconds. defaultLadderFrameRate is used for computing the
+// GOP size of sources whose frame rate is unknown.
+const (
+	ladderKeyframeInterval = 2
+	defaultLadderFrameRate = 30
+)
+
+// swagger:route POST /ladders ladders generateLadder
+//
+// Generates an adaptive bitrate ladder for the given source, picking the
+// rungs from the configured table without ever upscaling the source. The
+// presets for the rungs are created in the given providers when they
+// don't exist yet.
+//
+//     Responses:
+//       200: ladder
+//       400: invalidPreset
+//       500: genericError
+func (s *TranscodingService) generateLadder(r *http.Request) swagger.GizmoJSONResponse {
+	defer r.Body.Close()
+	var params generateLadderInput
+	if err := params.loadParams(r.Body); err != nil {
+		return newInvalidPresetResponse(err)
+	}
+	rungs, err := parseLadderRungs(s.config.ABRLadder)
+	if err != nil {
+		return swagger.NewErrorResponse(fmt.Errorf("invalid ABR ladder configuration: %s", err))
+	}
+	sourceInfo, err := s.ladderSourceInfo(params.Payload)
+	if err != nil {
+		return newInvalidPresetResponse(err)
+	}
+	presets := make([]ladderPreset, 0, len(rungs))
+	for _, rung := range buildLadder(rungs, uint(sourceInfo.Height), sourceInfo.Duration) {
+		preset := ladderRungPreset(params.Payload, sourceInfo, rung)
+		if err = preset.Validate(); err != nil {
+			return newInvalidPresetFieldsResponse(err)
+		}
+		output := ladderOutput{ladderRung: rung, Width: ladderRungWidth(sourceInfo, rung.Height), Preset: preset.Name}
+		presets = append(presets, ladderPreset{output: output, preset: preset})
+	}
+	if err = s.createLadderPresets(presets, params.Payload.Providers); err != nil {
+		return swagger.NewErrorResponse(err)
+	}
+	output := ladder{SourceInfo: sourceInfo, Rungs: make([]ladderOutput, len(presets))}
+	author := r.Header.Get(authorHeader)
+	for i := range presets {
+		if err = s.saveLadderPreset(&presets[i], author); err != nil {
+			for _, p := range presets[i:] {
+				if p.creation != nil {
+					deleteCreatedPresets(p.creation.providers, p.creation.mapping)
+				}
+			}
+			return swagger.NewErrorResponse(err)
+		}
+		output.Rungs[i] = presets[i].output
+	}
+	return &ladderResponse{
+		baseResponse: baseResponse{
+			payload: output,
+			status:  http.StatusOK,
+		},
+	}
+}
+
+// ladderPreset holds the preset of a rung while the ladder is generated.
+// presetMap is nil for presets that don't exist yet, and creation holds the
+// presets created in the providers, if any.
+type ladderPreset struct {
+	output    ladderOutput
+	preset    db.Preset
+	presetMap *db.PresetMap
+	creation  *presetCreation
+}
+
+// createLadderPresets creates the presets of the ladder in the providers
+// where they don't exist yet. All presets created are deleted if the
+// creation fails in any provider.
+func (s *TranscodingService) createLadderPresets(presets []ladderPreset, providerNames []string) error {
+	for i := range presets {
+		p := &presets[i]
+		presetMap, err := s.db.GetPresetMap(p.preset.Name)
+		missingProviders := providerNames
+		switch err {
+		case nil:
+			p.presetMap = presetMap
+			missingProviders = nil
+			for _, name := range providerNames {
+				if _, ok := presetMap.ProviderMapping[name]; !ok {
+					missingProviders = append(missingProviders, name)
+				}
+			}
+		case db.ErrPresetMapNotFound:
+		default:
+			return err
+		}
+		if len(missingProviders) == 0 {
+			p.output.Status = "reused"
+			continue
+		}
+		p.creation = s.createProviderPresets(p.preset, missingProviders, true)
+		if p.creation.failed {
+			var failures []string
+			for _, name := range missingProviders {
+				if result, ok := p.creation.results[name]; ok && result.Error != "" {
+					failures = append(failures, name+": "+result.Error)
+				}
+			}
+			for _, created := range presets[:i+1] {
+				if created.creation != nil {
+					deleteCreatedPresets(created.creation.providers, created.creation.mapping)
+				}
+			}
+			return fmt.Errorf("failed to create preset %q: %s", p.preset.Name, strings.Join(failures, ", "))
+		}
+	}
+	return nil
+}
+
+// saveLadderPreset saves the preset map of a rung whose preset was created
+// in any provider.
+func (s *TranscodingService) saveLadderPreset(p *ladderPreset, author string) error {
+	if p.creation == nil {
+		return nil
+	}
+	if p.presetMap == nil {
+		p.output.Status = "created"
+		return s.savePresetMap(&db.PresetMap{
+			Name:            p.preset.Name,
+			Preset:          &p.preset,
+			ProviderMapping: p.creation.mapping,
+			OutputOpts:      db.OutputOptions{Extension: p.preset.Container},
+		}, author, s.db.CreatePresetMap)
+	}
+	p.output.Status = "updated"
+	if p.presetMap.ProviderMapping == nil {
+		p.presetMap.ProviderMapping = make(map[string]string)
+	}
+	for name, presetID := range p.creation.mapping {
+		p.presetMap.ProviderMapping[name] = presetID
+	}
+	return s.savePresetMap(p.presetMap, author, s.db.UpdatePresetMap)
+}
+
+// ladderSourceInfo returns the source info given in the payload, probing the
+// source when it's not provided.
+func (s *TranscodingService) ladderSourceInfo(payload generateLadderPayload) (provider.SourceInfo, error) {
+	if payload.SourceInfo != nil {
+		if payload.SourceInfo.Height <= 0 {
+			return provider.SourceInfo{}, errors.New("missing height from sourceInfo")
+		}
+		if payload.SourceInfo.Width <= 0 {
+			return provider.SourceInfo{}, errors.New("missing width from sourceInfo")
+		}
+		return *payload.SourceInfo, nil
+	}
+	info, err := probe.Probe(payload.Source)
+	if err != nil {
+		return provider.SourceInfo{}, fmt.Errorf("failed to probe source media %q: %s", payload.Source, err)
+	}
+	if info.Height == 0 || info.Width == 0 {
+		return provider.SourceInfo{}, fmt.Errorf("source media %q has no video", payload.Source)
+	}
+	return provider.SourceInfo{
+		Duration:   info.Duration,
+		Height:     int64(info.Height),
+		Width:      int64(info.Width),
+		VideoCodec: info.VideoCodec,
+		FrameRate:  info.FrameRate,
+	}, nil
+}
+
+// parseLadderRungs parses the rungs in the format
+// height:videoBitrate:audioBitrate, returning them from the tallest to the
+// shortest. Taller rungs can't have lower video bitrates.
+func parseLadderRungs(values []string) ([]ladderRung, error) {
+	if len(values) == 0 {
+		return nil, errors.New("no rungs configured")
+	}
+	rungs := make([]ladderRung, len(values))
+	heights := make(map[uint]bool, len(values))
+	for i, value := range values {
+		parts := strings.Split(value, ":")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("invalid rung %q: expected height:videoBitrate:audioBitrate", value)
+		}
+		var numbers [3]uint
+		for j, part := range parts {
+			n, err := strconv.ParseUint(part, 10, 0)
+			if err != nil || n == 0 {
+				return nil, fmt.Errorf("invalid rung %q: %q is not a positive integer", value, part)
+			}
+			numbers[j] = uint(n)
+		}
+		if heights[numbers[0]] {
+			return nil, fmt.Errorf("duplicate rung for height %d", numbers[0])
+		}
+		heights[numbers[0]] = true
+		rungs[i] = ladderRung{Height: numbers[0], VideoBitrate: numbers[1], AudioBitrate: numbers[2]}
+	}
+	sort.Sort(ladderRungsByHeight(rungs))
+	for i := 1; i < len(rungs); i++ {
+		if rungs[i].VideoBitrate > rungs[i-1].VideoBitrate {
+			return nil, fmt.Errorf("rung for height %d has a higher video bitrate than the rung for height %d", rungs[i].Height, rungs[i-1].Height)
+		}
+	}
+	return rungs, nil
+}
+
+// buildLadder picks the rungs, sorted from the tallest to the shortest, that
+// don't upscale a source with the given height. When the source is shorter
+// than some rung, the shortest of those rungs is capped at the height of the
+// source, with its video bitrate interpolated between the bitrates of the
+// neighbouring rungs.
+//
+// Players can only switch renditions at keyframes, so a clip with fewer
+// keyframe intervals than rungs can't make use of all of them. The ladder of
+// such clips keeps one rung per keyframe interval, dropping the tallest
+// rungs, which are the most expensive to encode and the last ones a player
+// would reach. Sources with unknown duration keep all rungs.
+func buildLadder(rungs []ladderRung, sourceHeight uint, sourceDuration time.Duration) []ladderRung {
+	var ladder []ladderRung
+	for i, rung := range rungs {
+		if rung.Height <= sourceHeight {
+			ladder = append(ladder, rung)
+			continue
+		}
+		var lower ladderRung
+		if i+1 < len(rungs) {
+			lower = rungs[i+1]
+		}
+		capped := ladderRung{Height: sourceHeight &^ 1, AudioBitrate: rung.AudioBitrate}
+		if lower.Height > sourceHeight || lower.Height >= capped.Height {
+			continue
+		}
+		capped.VideoBitrate = lower.VideoBitrate + (rung.VideoBitrate-lower.VideoBitrate)*(capped.Height-lower.Height)/(rung.Height-lower.Height)
+		capped.VideoBitrate = capped.VideoBitrate / 1000 * 1000
+		ladder = append(ladder, capped)
+	}
+	if maxRungs := ladderMaxRungs(sourceDuration); maxRungs > 0 && len(ladder) > maxRungs {
+		ladder = ladder[len(ladder)-maxRungs:]
+	}
+	return ladder
+}
+
+// ladderMaxRungs returns the number of keyframe intervals in a source with
+// the given duration, with a minimum of one, or 0 when the duration is
+// unknown.
+func ladderMaxRungs(duration time.Duration) int {
+	if duration <= 0 {
+		return 0
+	}
+	interval := ladderKeyframeInterval * time.Second
+	return int((duration + interval - 1) / interval)
+}
+
+// ladderRungPreset returns the preset for the given rung. Presets are named
+// after their parameters, so rungs with the same parameters share presets.
+func ladderRungPreset(payload generateLadderPayload, sourceInfo provider.SourceInfo, rung ladderRung) db.Preset {
+	width := ladderRungWidth(sourceInfo, rung.Height)
+	gopSize := ladderGopSize(sourceInfo.FrameRate)
+	preset := db.Preset{
+		Description: "Generated for adaptive bitrate ladders",
+		Container:   payload.Container,
+		Video: db.VideoPreset{
+			Codec:          payload.VideoCodec,
+			Width:          strconv.FormatUint(uint64(width), 10),
+			Height:         strconv.FormatUint(uint64(rung.Height), 10),
+			Bitrate:        strconv.FormatUint(uint64(rung.VideoBitrate), 10),
+			GopSize:        strconv.FormatUint(uint64(gopSize), 10),
+			AlignKeyframes: true,
+		},
+		Audio: db.AudioPreset{
+			Codec:   payload.AudioCodec,
+			Bitrate: strconv.FormatUint(uint64(rung.AudioBitrate), 10),
+		},
+	}
+	preset.NormalizeVideoCodec()
+	preset.Name = fmt.Sprintf("%s_%s_%dx%d_%dk_gop%d", preset.Container, preset.Video.Codec, width, rung.Height, rung.VideoBitrate/1000, gopSize)
+	return preset
+}
+
+// ladderRungWidth returns the width of a rung with the given height, keeping
+// the aspect ratio of the source. The width is rounded to an even number and
+// never exceeds the width of the source.
+func ladderRungWidth(sourceInfo provider.SourceInfo, height uint) uint {
+	sourceWidth := uint(sourceInfo.Width)
+	sourceHeight := uint(sourceInfo.Height)
+	width := 2 * ((height*sourceWidth + sourceHeight) / (2 * sourceHeight))
+	if width > sourceWidth&^1 {
+		width = sourceWidth &^ 1
+	}
+	return width
+}
+
+// ladderGopSize returns the number of frames between keyframes for the given
+// frame rate, so keyframes are ladderKeyframeInterval seconds apart.
+func ladderGopSize(frameRate float64) uint {
+	if frameRate <= 0 {
+		frameRate = defaultLadderFrameRate
+	}
+	return uint(frameRate*ladderKeyframeInterval + 0.5)
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/NYTimes/video-transcoding-api/provider"
+)
+
+// generateLadderPayload contains the parameters for generating an adaptive
+// bitrate ladder.
+type generateLadderPayload struct {
+	// source media, probed for its dimensions, duration and frame rate
+	// when sourceInfo is not provided
+	Source string `json:"source,omitempty"`
+
+	// dimensions and duration of the source media, as reported in the
+	// sourceInfo of finished jobs. The height and the width are required,
+	// and sources without a frame rate are assumed to have 30 frames per
+	// second
+	SourceInfo *provider.SourceInfo `json:"sourceInfo,omitempty"`
+
+	// container of the outputs. Defaults to mp4
+	Container string `json:"container,omitempty"`
+
+	// video and audio codecs of the outputs. Default to h264 and aac
+	VideoCodec string `json:"videoCodec,omitempty"`
+	AudioCodec string `json:"audioCodec,omitempty"`
+
+	// providers where the presets of the ladder should be available
+	Providers []string `json:"providers"`
+}
+
+// swagger:parameters generateLadder
+type generateLadderInput struct {
+	// in: body
+	// required: true
+	Payload generateLadderPayload
+}
+
+func (p *generateLadderInput) loadParams(body io.Reader) error {
+	err := json.NewDecoder(body).Decode(&p.Payload)
+	if err != nil {
+		return err
+	}
+	if p.Payload.Source == "" && p.Payload.SourceInfo == nil {
+		return errors.New("missing source media from request")
+	}
+	if len(p.Payload.Providers) == 0 {
+		return errors.New("missing providers from request")
+	}
+	if p.Payload.Container == "" {
+		p.Payload.Container = "mp4"
+	}
+	if p.Payload.VideoCodec == "" {
+		p.Payload.VideoCodec = "h264"
+	}
+	if p.Payload.AudioCodec == "" {
+		p.Payload.AudioCodec = "aac"
+	}
+	return nil
+}
+
+// adaptive bitrate ladder generated for a source, from the tallest to the
+// shortest rung.
+//
+// swagger:response ladder
+type ladder struct {
+	// in: body
+	// required: true
+	SourceInfo provider.SourceInfo `json:"sourceInfo"`
+	Rungs      []ladderOutput      `json:"rungs"`
+}
+
+// ladderOutput describes a rung of a generated ladder along with the preset
+// that should be used for it. Width keeps the aspect ratio of the source.
+// Status is one of "created", "reused" or "updated", the latter meaning that
+// an existing preset was created in additional providers.
+type ladderOutput struct {
+	ladderRung
+	Width  uint   `json:"width"`
+	Preset string `json:"preset"`
+	Status string `json:"status"`
+}
@@ -0,0 +1,422 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NYTimes/gizmo/server"
+	"github.com/NYTimes/video-transcoding-api/config"
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/db/dbtest"
+	"github.com/NYTimes/video-transcoding-api/provider"
+	"github.com/Sirupsen/logrus"
+)
+
+var testLadder = []string{"720:3000000:128000", "1080:5000000:128000", "360:800000:96000", "480:1500000:96000"}
+
+func TestBuildLadder(t *testing.T) {
+	rungs, err := parseLadderRungs(testLadder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tests = []struct {
+		testCase       string
+		sourceHeight   uint
+		sourceDuration time.Duration
+		expected       []ladderRung
+	}{
+		{
+			"source taller than all rungs",
+			2160,
+			0,
+			[]ladderRung{
+				{Height: 1080, VideoBitrate: 5000000, AudioBitrate: 128000},
+				{Height: 720, VideoBitrate: 3000000, AudioBitrate: 128000},
+				{Height: 480, VideoBitrate: 1500000, AudioBitrate: 96000},
+				{Height: 360, VideoBitrate: 800000, AudioBitrate: 96000},
+			},
+		},
+		{
+			"source matching a rung",
+			480,
+			time.Minute,
+			[]ladderRung{
+				{Height: 480, VideoBitrate: 1500000, AudioBitrate: 96000},
+				{Height: 360, VideoBitrate: 800000, AudioBitrate: 96000},
+			},
+		},
+		{
+			"source between rungs",
+			600,
+			time.Minute,
+			[]ladderRung{
+				{Height: 600, VideoBitrate: 2250000, AudioBitrate: 128000},
+				{Height: 480, VideoBitrate: 1500000, AudioBitrate: 96000},
+				{Height: 360, VideoBitrate: 800000, AudioBitrate: 96000},
+			},
+		},
+		{
+			"source with odd height",
+			481,
+			time.Minute,
+			[]ladderRung{
+				{Height: 480, VideoBitrate: 1500000, AudioBitrate: 96000},
+				{Height: 360, VideoBitrate: 800000, AudioBitrate: 96000},
+			},
+		},
+		{
+			"source shorter than all rungs",
+			240,
+			time.Minute,
+			[]ladderRung{
+				{Height: 240, VideoBitrate: 533000, AudioBitrate: 96000},
+			},
+		},
+		{
+			"very short source",
+			2160,
+			3 * time.Second,
+			[]ladderRung{
+				{Height: 480, VideoBitrate: 1500000, AudioBitrate: 96000},
+				{Height: 360, VideoBitrate: 800000, AudioBitrate: 96000},
+			},
+		},
+		{
+			"source shorter than a keyframe interval",
+			600,
+			time.Second,
+			[]ladderRung{
+				{Height: 360, VideoBitrate: 800000, AudioBitrate: 96000},
+			},
+		},
+		{
+			"source with as many keyframe intervals as rungs",
+			2160,
+			8 * time.Second,
+			[]ladderRung{
+				{Height: 1080, VideoBitrate: 5000000, AudioBitrate: 128000},
+				{Height: 720, VideoBitrate: 3000000, AudioBitrate: 128000},
+				{Height: 480, VideoBitrate: 1500000, AudioBitrate: 96000},
+				{Height: 360, VideoBitrate: 800000, AudioBitrate: 96000},
+			},
+		},
+	}
+	for _, test := range tests {
+		ladder := buildLadder(rungs, test.sourceHeight, test.sourceDuration)
+		if !reflect.DeepEqual(ladder, test.expected) {
+			t.Errorf("%s: wrong ladder\nWant %#v\nGot  %#v", test.testCase, test.expected, ladder)
+		}
+	}
+}
+
+func TestParseLadderRungsInvalid(t *testing.T) {
+	var tests = []struct {
+		values      []string
+		expectedErr string
+	}{
+		{nil, "no rungs configured"},
+		{[]string{"720:3000000"}, `invalid rung "720:3000000": expected height:videoBitrate:audioBitrate`},
+		{[]string{"720:3M:128000"}, `invalid rung "720:3M:128000": "3M" is not a positive integer`},
+		{[]string{"720:3000000:128000", "720:2500000:128000"}, "duplicate rung for height 720"},
+		{[]string{"720:3000000:128000", "480:4000000:96000"}, "rung for height 480 has a higher video bitrate than the rung for height 720"},
+	}
+	for _, test := range tests {
+		_, err := parseLadderRungs(test.values)
+		if err == nil || err.Error() != test.expectedErr {
+			t.Errorf("%#v: wrong error. Want %q. Got %v", test.values, test.expectedErr, err)
+		}
+	}
+}
+
+func TestLadderRungPreset(t *testing.T) {
+	payload := generateLadderPayload{Container: "mp4", VideoCodec: "h264", AudioCodec: "aac"}
+	rung := ladderRung{Height: 360, VideoBitrate: 800000, AudioBitrate: 96000}
+	var tests = []struct {
+		testCase        string
+		sourceInfo      provider.SourceInfo
+		expectedWidth   string
+		expectedGopSize string
+		expectedName    string
+	}{
+		{
+			"widescreen source at 29.97 fps",
+			provider.SourceInfo{Height: 1080, Width: 1920, FrameRate: 29.97},
+			"640",
+			"60",
+			"mp4_h264_640x360_800k_gop60",
+		},
+		{
+			"4:3 source at 25 fps",
+			provider.SourceInfo{Height: 1080, Width: 1440, FrameRate: 25},
+			"480",
+			"50",
+			"mp4_h264_480x360_800k_gop50",
+		},
+		{
+			"vertical source at 59.94 fps",
+			provider.SourceInfo{Height: 1920, Width: 1080, FrameRate: 59.94},
+			"202",
+			"120",
+			"mp4_h264_202x360_800k_gop120",
+		},
+		{
+			"source with unknown frame rate",
+			provider.SourceInfo{Height: 480, Width: 854},
+			"640",
+			"60",
+			"mp4_h264_640x360_800k_gop60",
+		},
+		{
+			"source with an odd width",
+			provider.SourceInfo{Height: 360, Width: 641},
+			"640",
+			"60",
+			"mp4_h264_640x360_800k_gop60",
+		},
+	}
+	for _, test := range tests {
+		preset := ladderRungPreset(payload, test.sourceInfo, rung)
+		if preset.Video.Width != test.expectedWidth {
+			t.Errorf("%s: wrong width. Want %q. Got %q", test.testCase, test.expectedWidth, preset.Video.Width)
+		}
+		if preset.Video.GopSize != test.expectedGopSize {
+			t.Errorf("%s: wrong GOP size. Want %q. Got %q", test.testCase, test.expectedGopSize, preset.Video.GopSize)
+		}
+		if preset.Name != test.expectedName {
+			t.Errorf("%s: wrong name. Want %q. Got %q", test.testCase, test.expectedName, preset.Name)
+		}
+		if err := preset.Validate(); err != nil {
+			t.Errorf("%s: invalid preset: %s", test.testCase, err)
+		}
+	}
+}
+
+func TestGenerateLadder(t *testing.T) {
+	var tests = []struct {
+		givenTestCase    string
+		givenLadder      []string
+		givenRequestData string
+		wantCode         int
+		wantBody         map[string]interface{}
+		wantDeleted      []string
+	}{
+		{
+			"source matching a rung",
+			testLadder,
+			`{"sourceInfo": {"height": 480, "width": 854}, "providers": ["fake"]}`,
+			http.StatusOK,
+			map[string]interface{}{
+				"sourceInfo": map[string]interface{}{"height": float64(480), "width": float64(854)},
+				"rungs": []interface{}{
+					map[string]interface{}{
+						"height":       float64(480),
+						"videoBitrate": float64(1500000),
+						"audioBitrate": float64(96000),
+						"width":        float64(854),
+						"preset":       "mp4_h264_854x480_1500k_gop60",
+						"status":       "created",
+					},
+					map[string]interface{}{
+						"height":       float64(360),
+						"videoBitrate": float64(800000),
+						"audioBitrate": float64(96000),
+						"width":        float64(640),
+						"preset":       "mp4_h264_640x360_800k_gop60",
+						"status":       "reused",
+					},
+				},
+			},
+			nil,
+		},
+		{
+			"source between rungs in additional providers",
+			testLadder,
+			`{"sourceInfo": {"height": 600, "width": 1066}, "providers": ["fake", "fakeupdater"]}`,
+			http.StatusOK,
+			map[string]interface{}{
+				"sourceInfo": map[string]interface{}{"height": float64(600), "width": float64(1066)},
+				"rungs": []interface{}{
+					map[string]interface{}{
+						"height":       float64(600),
+						"videoBitrate": float64(2250000),
+						"audioBitrate": float64(128000),
+						"width":        float64(1066),
+						"preset":       "mp4_h264_1066x600_2250k_gop60",
+						"status":       "created",
+					},
+					map[string]interface{}{
+						"height":       float64(480),
+						"videoBitrate": float64(1500000),
+						"audioBitrate": float64(96000),
+						"width":        float64(852),
+						"preset":       "mp4_h264_852x480_1500k_gop60",
+						"status":       "created",
+					},
+					map[string]interface{}{
+						"height":       float64(360),
+						"videoBitrate": float64(800000),
+						"audioBitrate": float64(96000),
+						"width":        float64(640),
+						"preset":       "mp4_h264_640x360_800k_gop60",
+						"status":       "updated",
+					},
+				},
+			},
+			nil,
+		},
+		{
+			"codec not supported by a provider",
+			testLadder,
+			`{"sourceInfo": {"height": 480, "width": 854}, "videoCodec": "av1", "providers": ["fake", "fakeupdater"]}`,
+			http.StatusInternalServerError,
+			map[string]interface{}{
+				"error": `failed to create preset "mp4_av1_854x480_1500k_gop60": fakeupdater: video codec "av1" is not supported by fakeupdater. Supported codecs are: h264, h265, vp8, vp9`,
+			},
+			[]string{"presetID_here"},
+		},
+		{
+			"invalid container",
+			testLadder,
+			`{"sourceInfo": {"height": 480, "width": 854}, "container": "avi", "providers": ["fake"]}`,
+			http.StatusBadRequest,
+			map[string]interface{}{
+				"error": `invalid preset: container: unsupported container "avi". Supported containers are: m3u8, mp4, mpd, ts, webm`,
+				"fields": []interface{}{
+					map[string]interface{}{"field": "container", "message": `unsupported container "avi". Supported containers are: m3u8, mp4, mpd, ts, webm`},
+				},
+			},
+			nil,
+		},
+		{
+			"source without height",
+			testLadder,
+			`{"sourceInfo": {"width": 854}, "providers": ["fake"]}`,
+			http.StatusBadRequest,
+			map[string]interface{}{"error": "missing height from sourceInfo"},
+			nil,
+		},
+		{
+			"source without width",
+			testLadder,
+			`{"sourceInfo": {"height": 480}, "providers": ["fake"]}`,
+			http.StatusBadRequest,
+			map[string]interface{}{"error": "missing width from sourceInfo"},
+			nil,
+		},
+		{
+			"source that can't be probed",
+			testLadder,
+			`{"source": "s3://bucket/video.mov", "providers": ["fake"]}`,
+			http.StatusBadRequest,
+			map[string]interface{}{"error": `failed to probe source media "s3://bucket/video.mov": source scheme is not supported for probing`},
+			nil,
+		},
+		{
+			"missing providers",
+			testLadder,
+			`{"sourceInfo": {"height": 480, "width": 854}}`,
+			http.StatusBadRequest,
+			map[string]interface{}{"error": "missing providers from request"},
+			nil,
+		},
+		{
+			"ladder not configured",
+			nil,
+			`{"sourceInfo": {"height": 480, "width": 854}, "providers": ["fake"]}`,
+			http.StatusInternalServerError,
+			map[string]interface{}{"error": "invalid ABR ladder configuration: no rungs configured"},
+			nil,
+		},
+	}
+	for _, test := range tests {
+		fprovider.deletedPresets = nil
+		srvr := server.NewSimpleServer(&server.Config{RouterType: "fast"})
+		fakeDB := dbtest.NewFakeRepository(false)
+		fakeDB.CreatePresetMap(&db.PresetMap{
+			Name:            "mp4_h264_640x360_800k_gop60",
+			ProviderMapping: map[string]string{"fake": "existing-360p"},
+			OutputOpts:      db.OutputOptions{Extension: "mp4"},
+		})
+		service, err := NewTranscodingService(&config.Config{ABRLadder: test.givenLadder}, logrus.New())
+		if err != nil {
+			t.Fatal(err)
+		}
+		service.db = fakeDB
+		srvr.Register(service)
+		r, _ := http.NewRequest("POST", "/ladders", strings.NewReader(test.givenRequestData))
+		w := httptest.NewRecorder()
+		srvr.ServeHTTP(w, r)
+		if w.Code != test.wantCode {
+			t.Errorf("%s: wrong response code. Want %d. Got %d", test.givenTestCase, test.wantCode, w.Code)
+		}
+		var got map[string]interface{}
+		err = json.NewDecoder(w.Body).Decode(&got)
+		if err != nil {
+			t.Errorf("%s: unable to JSON decode response body: %s", test.givenTestCase, err)
+		}
+		if !reflect.DeepEqual(got, test.wantBody) {
+			t.Errorf("%s: wrong response body\nWant %#v\nGot  %#v", test.givenTestCase, test.wantBody, got)
+		}
+		if !reflect.DeepEqual(fprovider.deletedPresets, test.wantDeleted) {
+			t.Errorf("%s: wrong deleted presets. Want %#v. Got %#v", test.givenTestCase, test.wantDeleted, fprovider.deletedPresets)
+		}
+	}
+}
+
+func TestGenerateLadderSavesPresetMaps(t *testing.T) {
+	srvr := server.NewSimpleServer(&server.Config{RouterType: "fast"})
+	fakeDB := dbtest.NewFakeRepository(false)
+	fakeDB.CreatePresetMap(&db.PresetMap{
+		Name:            "mp4_h264_640x360_800k_gop60",
+		ProviderMapping: map[string]string{"fake": "existing-360p"},
+		OutputOpts:      db.OutputOptions{Extension: "mp4"},
+	})
+	service, err := NewTranscodingService(&config.Config{ABRLadder: testLadder}, logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.db = fakeDB
+	srvr.Register(service)
+	body := `{"sourceInfo": {"height": 480, "width": 854}, "providers": ["fake", "fakeupdater"]}`
+	r, _ := http.NewRequest("POST", "/ladders", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	srvr.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("wrong response code. Want %d. Got %d", http.StatusOK, w.Code)
+	}
+	expectedPresetMaps := []db.PresetMap{
+		{
+			Name: "mp4_h264_854x480_1500k_gop60",
+			Preset: &db.Preset{
+				Name:        "mp4_h264_854x480_1500k_gop60",
+				Description: "Generated for adaptive bitrate ladders",
+				Container:   "mp4",
+				Video:       db.VideoPreset{Codec: "h264", Width: "854", Height: "480", Bitrate: "1500000", GopSize: "60", AlignKeyframes: true},
+				Audio:       db.AudioPreset{Codec: "aac", Bitrate: "96000"},
+			},
+			ProviderMapping: map[string]string{"fake": "presetID_here", "fakeupdater": "presetID_here"},
+			OutputOpts:      db.OutputOptions{Extension: "mp4"},
+			Version:         1,
+		},
+		{
+			Name:            "mp4_h264_640x360_800k_gop60",
+			ProviderMapping: map[string]string{"fake": "existing-360p", "fakeupdater": "presetID_here"},
+			OutputOpts:      db.OutputOptions{Extension: "mp4"},
+			Version:         1,
+		},
+	}
+	for _, expected := range expectedPresetMaps {
+		presetMap, err := fakeDB.GetPresetMap(expected.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(*presetMap, expected) {
+			t.Errorf("wrong preset map saved\nWant %#v\nGot  %#v", expected, *presetMap)
+		}
+	}
+}
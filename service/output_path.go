@@ -0,0 +1,128 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultOutputPathTemplate is the template for the directory that
+	// holds the outputs of jobs, relative to the destination configured
+	// in the provider.
+	defaultOutputPathTemplate = "{jobId}"
+
+	// defaultFileNameTemplate is the template for the name of outputs
+	// that don't specify one.
+	defaultFileNameTemplate = "{sourceBase}_{preset}.{ext}"
+
+	defaultDateLayout = "2006-01-02"
+)
+
+// pathTemplateValues contains the values available for the placeholders in
+// output path and file name templates.
+//
+// The preset and the extension are available only in file name templates.
+type pathTemplateValues struct {
+	jobID      string
+	sourceBase string
+	preset     string
+	ext        string
+	date       time.Time
+	labels     map[string]string
+}
+
+func newPathTemplateValues(jobID, source string, labels map[string]string) pathTemplateValues {
+	sourceBase := path.Base(source)
+	return pathTemplateValues{
+		jobID:      jobID,
+		sourceBase: sourceBase[:len(sourceBase)-len(filepath.Ext(sourceBase))],
+		date:       time.Now().UTC(),
+		labels:     labels,
+	}
+}
+
+func (v pathTemplateValues) placeholder(name string) (string, error) {
+	switch {
+	case name == "jobId":
+		return v.jobID, nil
+	case name == "sourceBase":
+		return v.sourceBase, nil
+	case name == "preset" || name == "ext":
+		value := v.preset
+		if name == "ext" {
+			value = v.ext
+		}
+		if value == "" {
+			return "", fmt.Errorf("placeholder {%s} is only available in file name templates", name)
+		}
+		return value, nil
+	case name == "date":
+		return v.date.Format(defaultDateLayout), nil
+	case strings.HasPrefix(name, "date:"):
+		return v.date.Format(strings.TrimPrefix(name, "date:")), nil
+	case strings.HasPrefix(name, "label."):
+		label := strings.TrimPrefix(name, "label.")
+		value, ok := v.labels[label]
+		if !ok {
+			return "", fmt.Errorf("missing label %q", label)
+		}
+		return value, nil
+	}
+	return "", fmt.Errorf("unknown placeholder {%s}", name)
+}
+
+// renderPathTemplate replaces the placeholders in the given template,
+// returning the resulting path without leading or trailing slashes.
+func renderPathTemplate(template string, values pathTemplateValues) (string, error) {
+	rendered, err := walkPathTemplate(template, values.placeholder)
+	if err != nil {
+		return "", err
+	}
+	rendered = strings.Trim(rendered, "/")
+	for _, segment := range strings.Split(rendered, "/") {
+		if segment == ".." {
+			return "", fmt.Errorf("invalid output path %q, rendered from template %q", rendered, template)
+		}
+	}
+	return rendered, nil
+}
+
+// validatePathTemplate checks the syntax of the given template, along with
+// the names of its placeholders.
+func validatePathTemplate(template string) error {
+	values := pathTemplateValues{preset: "preset", ext: "ext"}
+	_, err := walkPathTemplate(template, func(name string) (string, error) {
+		if strings.HasPrefix(name, "label.") && name != "label." {
+			return "", nil
+		}
+		return values.placeholder(name)
+	})
+	return err
+}
+
+func walkPathTemplate(template string, fn func(placeholder string) (string, error)) (string, error) {
+	var result bytes.Buffer
+	remaining := template
+	for {
+		start := strings.Index(remaining, "{")
+		if start < 0 {
+			result.WriteString(remaining)
+			return result.String(), nil
+		}
+		end := strings.Index(remaining[start:], "}")
+		if end < 0 {
+			return "", fmt.Errorf("invalid template %q: unclosed placeholder", template)
+		}
+		result.WriteString(remaining[:start])
+		value, err := fn(remaining[start+1 : start+end])
+		if err != nil {
+			return "", fmt.Errorf("invalid template %q: %s", template, err)
+		}
+		result.WriteString(value)
+		remaining = remaining[start+end+1:]
+	}
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRenderPathTemplate(t *testing.T) {
+	values := pathTemplateValues{
+		jobID:      "job-123",
+		sourceBase: "video",
+		preset:     "mp4_720p",
+		ext:        "mp4",
+		date:       time.Date(2016, 10, 18, 15, 4, 5, 0, time.UTC),
+		labels:     map[string]string{"team": "video", "show": "../secret"},
+	}
+	var tests = []struct {
+		testCase    string
+		template    string
+		expected    string
+		expectedErr string
+	}{
+		{"job ID", "{jobId}", "job-123", ""},
+		{"default file name", defaultFileNameTemplate, "video_mp4_720p.mp4", ""},
+		{"date", "{date}/{jobId}", "2016-10-18/job-123", ""},
+		{"date with layout", "{date:2006/01/02}/{jobId}", "2016/10/18/job-123", ""},
+		{"label", "{label.team}/{jobId}/", "video/job-123", ""},
+		{"leading and trailing slashes", "/videos/{jobId}/", "videos/job-123", ""},
+		{"no placeholders", "videos", "videos", ""},
+		{
+			"missing label",
+			"{label.owner}/{jobId}",
+			"",
+			`invalid template "{label.owner}/{jobId}": missing label "owner"`,
+		},
+		{
+			"unknown placeholder",
+			"{user}/{jobId}",
+			"",
+			`invalid template "{user}/{jobId}": unknown placeholder {user}`,
+		},
+		{
+			"unclosed placeholder",
+			"{jobId",
+			"",
+			`invalid template "{jobId": unclosed placeholder`,
+		},
+		{
+			"parent directory",
+			"{label.show}/{jobId}",
+			"",
+			`invalid output path "../secret/job-123", rendered from template "{label.show}/{jobId}"`,
+		},
+	}
+	for _, test := range tests {
+		got, err := renderPathTemplate(test.template, values)
+		if test.expectedErr != "" {
+			if err == nil || err.Error() != test.expectedErr {
+				t.Errorf("%s: wrong error returned. Want %q. Got %v", test.testCase, test.expectedErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.testCase, err)
+			continue
+		}
+		if got != test.expected {
+			t.Errorf("%s: wrong path. Want %q. Got %q", test.testCase, test.expected, got)
+		}
+	}
+}
+
+func TestRenderPathTemplateDirectory(t *testing.T) {
+	values := newPathTemplateValues("job-123", "s3://bucket/dir/video.mov", nil)
+	if values.sourceBase != "video" {
+		t.Errorf("wrong source base. Want %q. Got %q", "video", values.sourceBase)
+	}
+	expectedErr := `invalid template "{jobId}/{preset}": placeholder {preset} is only available in file name templates`
+	_, err := renderPathTemplate("{jobId}/{preset}", values)
+	if err == nil || err.Error() != expectedErr {
+		t.Errorf("wrong error returned. Want %q. Got %v", expectedErr, err)
+	}
+}
+
+func TestValidatePathTemplate(t *testing.T) {
+	var tests = []struct {
+		template    string
+		expectedErr string
+	}{
+		{"", ""},
+		{"{jobId}", ""},
+		{"{label.team}/{date:2006}/{sourceBase}-{preset}.{ext}", ""},
+		{"{label.}", `invalid template "{label.}": missing label ""`},
+		{"{jobid}", `invalid template "{jobid}": unknown placeholder {jobid}`},
+		{"{jobId}/{date", `invalid template "{jobId}/{date": unclosed placeholder`},
+	}
+	for _, test := range tests {
+		err := validatePathTemplate(test.template)
+		if test.expectedErr == "" {
+			if err != nil {
+				t.Errorf("validatePathTemplate(%q): unexpected error: %s", test.template, err)
+			}
+			continue
+		}
+		if err == nil || err.Error() != test.expectedErr {
+			t.Errorf("validatePathTemplate(%q): wrong error returned. Want %q. Got %v", test.template, test.expectedErr, err)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package service
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/mediaurl"
+	"github.com/NYTimes/video-transcoding-api/provider"
+	"github.com/NYTimes/video-transcoding-api/urlsigner"
+	"github.com/NYTimes/video-transcoding-api/verify"
+)
+
+const (
+	outputVerificationTimeout = 30 * time.Second
+
+	// outputVerificationURLExpiration is the expiration of the signed
+	// URLs used for reading outputs stored in private buckets.
+	outputVerificationURLExpiration = 5 * time.Minute
+)
+
+// verifyJobOutput starts the verification of the outputs of finished jobs
+// in the background, and includes the verification in the status of the
+// job. The result is recorded in the job, so each job is verified only
+// once, unless the destination can't be reached, in which case the
+// verification is retried in the next request.
+func (s *TranscodingService) verifyJobOutput(job *db.Job, status *provider.JobStatus) {
+	if s.config.VerifyOutputs && status.Status == provider.StatusFinished && job.Verification.Status == "" {
+		if s.startVerification(job.ID) {
+			s.verifications.Add(1)
+			go s.runVerification(job.ID, status.Output)
+		}
+		status.Verification = &db.OutputVerification{Status: db.VerificationPending}
+		return
+	}
+	if job.Verification.Status != "" {
+		verification := job.Verification
+		status.Verification = &verification
+	}
+}
+
+// startVerification marks the outputs of the given job as being verified,
+// returning false when there's a verification of the job in progress.
+func (s *TranscodingService) startVerification(jobID string) bool {
+	s.verifyingMtx.Lock()
+	defer s.verifyingMtx.Unlock()
+	if s.verifying[jobID] {
+		return false
+	}
+	if s.verifying == nil {
+		s.verifying = make(map[string]bool)
+	}
+	s.verifying[jobID] = true
+	return true
+}
+
+func (s *TranscodingService) runVerification(jobID string, output provider.JobOutput) {
+	defer s.verifications.Done()
+	defer func() {
+		s.verifyingMtx.Lock()
+		delete(s.verifying, jobID)
+		s.verifyingMtx.Unlock()
+	}()
+	verification, err := s.outputVerification(output)
+	if err != nil {
+		s.logger.Warnf("unable to verify the outputs of job %q: %s", jobID, err)
+		return
+	}
+	job, err := s.db.GetJob(jobID)
+	if err != nil {
+		s.logger.Warnf("unable to save the verification of the outputs of job %q: %s", jobID, err)
+		return
+	}
+	job.Verification = *verification
+	if err = s.db.UpdateJob(job); err != nil {
+		s.logger.Warnf("unable to save the verification of the outputs of job %q: %s", jobID, err)
+	}
+}
+
+func (s *TranscodingService) outputVerification(output provider.JobOutput) (*db.OutputVerification, error) {
+	paths := make([]string, 0, len(output.Files)+len(output.Captions))
+	for _, file := range output.Files {
+		paths = append(paths, file.Path)
+	}
+	for _, caption := range output.Captions {
+		paths = append(paths, caption.Path)
+	}
+	var signer urlsigner.Signer
+	if s.config.URLSigner != nil && s.config.URLSigner.Backend != "" {
+		var err error
+		signer, err = s.urlSigner()
+		if err != nil {
+			return nil, err
+		}
+	}
+	verifier := verify.Verifier{
+		Client: &http.Client{Timeout: outputVerificationTimeout},
+		URL:    verificationURL(signer),
+	}
+	result, err := verifier.Verify(paths)
+	if err != nil {
+		return nil, err
+	}
+	verification := db.OutputVerification{
+		Status:       db.VerificationPassed,
+		Errors:       result.Errors,
+		Unverifiable: result.Skipped,
+	}
+	if len(result.Errors) > 0 {
+		verification.Status = db.VerificationFailed
+	} else if len(result.Skipped) == len(paths) {
+		verification.Status = db.VerificationSkipped
+	}
+	return &verification, nil
+}
+
+// verificationURL returns the function used for reading outputs. Buckets
+// may be private, so outputs in S3 and Google Cloud Storage are only read
+// through signed URLs, and are reported as unverifiable when the signer
+// can't handle them.
+func verificationURL(signer urlsigner.Signer) func(*mediaurl.Location) (string, error) {
+	return func(loc *mediaurl.Location) (string, error) {
+		bucket := loc.Require(mediaurl.S3, mediaurl.GCS) == nil
+		if signer == nil {
+			if bucket {
+				return "", verify.ErrUnverifiable
+			}
+			return loc.HTTP().String(), nil
+		}
+		signedURL, err := signer.Sign(loc, outputVerificationURLExpiration)
+		if err == urlsigner.ErrUnsupportedLocation {
+			if bucket {
+				return "", verify.ErrUnverifiable
+			}
+			return loc.HTTP().String(), nil
+		}
+		return signedURL, err
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"sort"
 
 	"github.com/NYTimes/gizmo/web"
 	"github.com/NYTimes/video-transcoding-api/db"
@@ -12,22 +13,156 @@ import (
 	"github.com/NYTimes/video-transcoding-api/swagger"
 )
 
+// swagger:route GET /presets/{name} presets getPresetDetails
+//
+// Finds a preset using its name, returning the canonical preset along with
+// the normalized version of the preset stored in each provider.
+//
+//     Responses:
+//       200: presetDetails
+//       404: presetNotFound
+//       500: genericError
+func (s *TranscodingService) getPresetDetails(r *http.Request) swagger.GizmoJSONResponse {
+	var params getPresetMapInput
+	params.loadParams(web.Vars(r))
+	presetMap, err := s.db.GetPresetMap(params.Name)
+	switch err {
+	case nil:
+	case db.ErrPresetMapNotFound:
+		return newPresetMapNotFoundResponse(err)
+	default:
+		return swagger.NewErrorResponse(err)
+	}
+	output := presetDetails{
+		Name:            presetMap.Name,
+		Preset:          presetMap.Preset,
+		ProviderMapping: presetMap.ProviderMapping,
+		OutputOpts:      presetMap.OutputOpts,
+		Providers:       make(map[string]providerPresetDetails),
+	}
+	for p, presetID := range presetMap.ProviderMapping {
+		providerFactory, ierr := provider.GetProviderFactory(p)
+		if ierr != nil {
+			output.Providers[p] = providerPresetDetails{PresetID: presetID, Error: "getting factory: " + ierr.Error()}
+			continue
+		}
+		providerObj, ierr := providerFactory(s.config)
+		if ierr != nil {
+			output.Providers[p] = providerPresetDetails{PresetID: presetID, Error: "initializing provider: " + ierr.Error()}
+			continue
+		}
+		preset, ierr := providerObj.GetNormalizedPreset(presetID)
+		if ierr != nil {
+			output.Providers[p] = providerPresetDetails{PresetID: presetID, Error: "getting preset: " + ierr.Error()}
+			continue
+		}
+		output.Providers[p] = providerPresetDetails{PresetID: presetID, Preset: preset}
+	}
+	return &presetDetailsResponse{
+		baseResponse: baseResponse{
+			payload: output,
+			status:  http.StatusOK,
+		},
+	}
+}
+
+// swagger:route PUT /presets/{name} presets updatePresetDetails
+//
+// Updates a preset in all providers of its preset map.
+//
+// Providers that can't update presets in place get a new preset, replacing
+// the old one in the preset map. If any provider fails, changes made to
+// other providers are rolled back and the preset map is left untouched.
+//
+// With propagate=true, presets derived from the preset are updated as well,
+// keeping the fields they override.
+//
+//     Responses:
+//       200: preset
+//       400: invalidPresetFields
+//       404: presetNotFound
+//       409: genericError
+//       500: genericError
+func (s *TranscodingService) updatePreset(r *http.Request) swagger.GizmoJSONResponse {
+	defer r.Body.Close()
+	var input updatePresetInput
+	preset, err := input.Preset(web.Vars(r), r.URL.Query(), r.Body)
+	if err != nil {
+		return newInvalidPresetResponse(err)
+	}
+	preset.NormalizeVideoCodec()
+	overrides := presetOverrides(preset)
+	if preset, err = s.resolvePreset(preset); err != nil {
+		return newInvalidPresetResponse(err)
+	}
+	if err = preset.Validate(); err != nil {
+		return newInvalidPresetFieldsResponse(err)
+	}
+	presetMap, err := s.db.GetPresetMap(input.Name)
+	switch err {
+	case nil:
+	case db.ErrPresetMapNotFound:
+		return newPresetMapNotFoundResponse(err)
+	default:
+		return swagger.NewErrorResponse(err)
+	}
+	updated := *presetMap
+	updated.Preset = &preset
+	updated.Overrides = overrides
+	updated.OutputOpts.Extension = preset.Container
+	if err = updated.OutputOpts.Validate(); err != nil {
+		return newInvalidPresetResponse(fmt.Errorf("invalid outputOptions: %s", err))
+	}
+	author := r.Header.Get(authorHeader)
+	err = s.replacePreset(presetMap, &updated, author)
+	if err == db.ErrPresetMapChanged {
+		return swagger.NewErrorResponse(err).WithStatus(http.StatusConflict)
+	}
+	if err != nil {
+		return swagger.NewErrorResponse(err)
+	}
+	if !input.Propagate {
+		return newPresetMapResponse(&updated)
+	}
+	derived, err := s.propagatePreset(&updated, author)
+	if err != nil {
+		return swagger.NewErrorResponse(fmt.Errorf("preset updated, but unable to propagate the update: %s", err))
+	}
+	return &propagatedPresetResponse{
+		baseResponse: baseResponse{
+			payload: propagatedPreset{PresetMap: &updated, Derived: derived},
+			status:  http.StatusOK,
+		},
+	}
+}
+
 // swagger:route DELETE /presets/{name} presets deletePreset
 //
 // Deletes a preset by name.
 //
+// Presets used by queued or running jobs are only deleted with force=true.
+//
 //     Responses:
 //       200: deletePresetOutputs
+//       400: invalidPreset
 //       404: presetNotFound
+//       409: presetInUse
 //       500: genericError
 func (s *TranscodingService) deletePreset(r *http.Request) swagger.GizmoJSONResponse {
 	var output deletePresetOutputs
-	var params getPresetMapInput
-	params.loadParams(web.Vars(r))
+	var params deletePresetInput
+	if err := params.loadParams(web.Vars(r), r.URL.Query()); err != nil {
+		return newInvalidPresetResponse(err)
+	}
 
 	output.Results = make(map[string]deletePresetOutput)
 
 	presetmap, err := s.db.GetPresetMap(params.Name)
+	if err == nil && !params.Force {
+		if resp := s.presetInUse(params.Name); resp != nil {
+			return resp
+		}
+	}
 	if err != nil {
 		output.PresetMap = "couldn't retrieve: " + err.Error()
 	} else {
@@ -67,9 +202,16 @@ func (s *TranscodingService) deletePreset(r *http.Request) swagger.GizmoJSONResp
 // swagger:route POST /presets presets Output
 //
 // Creates a new preset on given providers.
+//
+// In all-or-nothing mode, presets that were already created are deleted
+// when the preset can't be created on any of the providers, or when the
+// preset map can't be saved.
+//
+// Presets that declare a parent preset only need to define the fields that
+// differ from it.
 //     Responses:
 //       200: newPresetOutputs
-//       400: invalidPreset
+//       400: invalidPresetFields
 //       500: genericError
 func (s *TranscodingService) newPreset(r *http.Request) swagger.GizmoJSONResponse {
 	defer r.Body.Close()
@@ -86,43 +228,44 @@ func (s *TranscodingService) newPreset(r *http.Request) swagger.GizmoJSONRespons
 	if err != nil {
 		return swagger.NewErrorResponse(err)
 	}
+	if err = validatePresetName(input.Preset.Name); err != nil {
+		return newInvalidPresetResponse(err)
+	}
+	input.Preset.NormalizeVideoCodec()
+	overrides := presetOverrides(input.Preset)
+	if input.Preset, err = s.resolvePreset(input.Preset); err != nil {
+		return newInvalidPresetResponse(err)
+	}
+	if err = input.Preset.Validate(); err != nil {
+		return newInvalidPresetFieldsResponse(err)
+	}
 	presetMap.OutputOpts = input.OutputOptions
-
-	presetMap.ProviderMapping = make(map[string]string)
-	output.Results = make(map[string]newPresetOutput)
-	for _, p := range input.Providers {
-		providerFactory, ierr := provider.GetProviderFactory(p)
-		if ierr != nil {
-			output.Results[p] = newPresetOutput{PresetID: "", Error: "getting factory: " + ierr.Error()}
-			continue
-		}
-		providerObj, ierr := providerFactory(s.config)
-		if ierr != nil {
-			output.Results[p] = newPresetOutput{PresetID: "", Error: "initializing provider: " + ierr.Error()}
-			continue
-		}
-		presetID, ierr := providerObj.CreatePreset(input.Preset)
-		if ierr != nil {
-			output.Results[p] = newPresetOutput{PresetID: "", Error: "creating preset: " + ierr.Error()}
-			continue
-		}
-		presetMap.ProviderMapping[p] = presetID
-		output.Results[p] = newPresetOutput{PresetID: presetID, Error: ""}
+	presetMap.OutputOpts.Extension = input.Preset.Container
+	if err = presetMap.OutputOpts.Validate(); err != nil {
+		return newInvalidPresetResponse(fmt.Errorf("invalid outputOptions: %s", err))
 	}
 
+	creation := s.createProviderPresets(input.Preset, input.Providers, input.AllOrNothing)
+	presetMap.ProviderMapping = creation.mapping
+	output.Results = creation.results
+
 	status := http.StatusOK
 	output.PresetMap = ""
-	if len(presetMap.ProviderMapping) > 0 {
+	if creation.failed && input.AllOrNothing {
+		output.Compensations = deleteCreatedPresets(creation.providers, presetMap.ProviderMapping)
+		status = http.StatusInternalServerError
+	} else if len(presetMap.ProviderMapping) > 0 {
 		presetMap.Name = input.Preset.Name
-		presetMap.OutputOpts.Extension = input.Preset.Container
+		presetMap.Preset = &input.Preset
+		presetMap.Overrides = overrides
 
-		if err = presetMap.OutputOpts.Validate(); err != nil {
-			return newInvalidPresetResponse(fmt.Errorf("invalid outputOptions: %s", err))
-		}
-
-		err = s.db.CreatePresetMap(&presetMap)
+		err = s.savePresetMap(&presetMap, r.Header.Get(authorHeader), s.db.CreatePresetMap)
 		if err == nil {
 			output.PresetMap = presetMap.Name
+		} else if input.AllOrNothing {
+			output.PresetMap = "error: " + err.Error()
+			output.Compensations = deleteCreatedPresets(creation.providers, presetMap.ProviderMapping)
+			status = http.StatusInternalServerError
 		}
 	} else {
 		status = http.StatusInternalServerError
@@ -135,3 +278,77 @@ func (s *TranscodingService) newPreset(r *http.Request) swagger.GizmoJSONRespons
 		},
 	}
 }
+
+// presetCreation holds the results of creating a preset in multiple
+// providers.
+type presetCreation struct {
+	results   map[string]newPresetOutput
+	providers map[string]provider.TranscodingProvider
+	mapping   map[string]string
+	failed    bool
+}
+
+// createProviderPresets creates the given preset in each of the given
+// providers. In all-or-nothing mode, it stops at the first failure, leaving
+// the cleanup of the presets that were already created to the caller.
+func (s *TranscodingService) createProviderPresets(preset db.Preset, providerNames []string, allOrNothing bool) *presetCreation {
+	creation := presetCreation{
+		results:   make(map[string]newPresetOutput),
+		providers: make(map[string]provider.TranscodingProvider),
+		mapping:   make(map[string]string),
+	}
+	for _, p := range providerNames {
+		if creation.failed && allOrNothing {
+			break
+		}
+		providerFactory, err := provider.GetProviderFactory(p)
+		if err != nil {
+			creation.results[p] = newPresetOutput{PresetID: "", Error: "getting factory: " + err.Error()}
+			creation.failed = true
+			continue
+		}
+		providerObj, err := providerFactory(s.config)
+		if err != nil {
+			creation.results[p] = newPresetOutput{PresetID: "", Error: "initializing provider: " + err.Error()}
+			creation.failed = true
+			continue
+		}
+		if err = provider.CheckVideoCodec(p, providerObj.Capabilities(), preset); err != nil {
+			creation.results[p] = newPresetOutput{PresetID: "", Error: err.Error()}
+			creation.failed = true
+			continue
+		}
+		presetID, err := providerObj.CreatePreset(preset)
+		if err != nil {
+			creation.results[p] = newPresetOutput{PresetID: "", Error: "creating preset: " + err.Error()}
+			creation.failed = true
+			continue
+		}
+		creation.providers[p] = providerObj
+		creation.mapping[p] = presetID
+		creation.results[p] = newPresetOutput{PresetID: presetID, Error: ""}
+	}
+	return &creation
+}
+
+// deleteCreatedPresets deletes the presets created while creating a new
+// preset in all-or-nothing mode, reporting the result of each deletion.
+func deleteCreatedPresets(providers map[string]provider.TranscodingProvider, mapping map[string]string) []presetCompensation {
+	names := make([]string, 0, len(mapping))
+	for name := range mapping {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	compensations := make([]presetCompensation, len(names))
+	for i, name := range names {
+		compensations[i] = presetCompensation{
+			Provider: name,
+			PresetID: mapping[name],
+			Action:   "delete preset",
+		}
+		if err := providers[name].DeletePreset(mapping[name]); err != nil {
+			compensations[i].Error = err.Error()
+		}
+	}
+	return compensations
+}
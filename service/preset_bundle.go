@@ -0,0 +1,326 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"sort"
+
+	"github.com/NYTimes/gizmo/server"
+	"github.com/NYTimes/gizmo/web"
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/provider"
+	"github.com/NYTimes/video-transcoding-api/swagger"
+	"github.com/ghodss/yaml"
+)
+
+// serveJSON serves the given handler the same way JSON endpoints are
+// served, for error responses of routes that serve other content types.
+func (s *TranscodingService) serveJSON(w http.ResponseWriter, r *http.Request, h swagger.Handler) {
+	server.JSONToHTTP(s.JSONMiddleware(swagger.HandlerToJSONEndpoint(h))).ServeHTTP(w, r)
+}
+
+const (
+	// exportPresetsName and importPresetsName are the names that take the
+	// place of the name of the preset in the routes for exporting and
+	// importing presets, as the router doesn't allow static segments
+	// alongside the name of the preset. Presets can't use these names.
+	exportPresetsName = "export"
+	importPresetsName = "import"
+)
+
+// validatePresetName makes sure that the name of a preset doesn't clash with
+// the routes for exporting and importing presets.
+func validatePresetName(name string) error {
+	if name == exportPresetsName || name == importPresetsName {
+		return fmt.Errorf("preset name %q is reserved", name)
+	}
+	return nil
+}
+
+// getPreset serves GET /presets/{name}, exporting the presets when the name
+// is export.
+func (s *TranscodingService) getPreset(w http.ResponseWriter, r *http.Request) {
+	if web.Vars(r)["name"] == exportPresetsName {
+		s.exportPresets(w, r)
+		return
+	}
+	s.serveJSON(w, r, s.getPresetDetails)
+}
+
+// swagger:route GET /presets/export presets exportPresets
+//
+// Exports the full definition of all presets as a bundle that can be
+// imported in another environment. Presets whose definition can't be found
+// are listed in the skipped field of the bundle.
+//
+//     Produces:
+//     - application/json
+//     - application/x-yaml
+//
+//     Responses:
+//       200: presetBundle
+//       400: invalidPreset
+//       500: genericError
+func (s *TranscodingService) exportPresets(w http.ResponseWriter, r *http.Request) {
+	var params exportPresetsInput
+	params.Format = r.URL.Query().Get("format")
+	var marshal func(interface{}) ([]byte, error)
+	var contentType string
+	switch params.Format {
+	case "", "json":
+		marshal, contentType = json.Marshal, "application/json"
+	case "yaml":
+		marshal, contentType = yaml.Marshal, "application/x-yaml"
+	default:
+		err := fmt.Errorf("unsupported bundle format %q. Supported formats are: json, yaml", params.Format)
+		s.serveJSON(w, r, func(*http.Request) swagger.GizmoJSONResponse {
+			return newInvalidPresetResponse(err)
+		})
+		return
+	}
+	bundle, err := s.presetBundle()
+	var data []byte
+	if err == nil {
+		data, err = marshal(bundle)
+	}
+	if err != nil {
+		s.serveJSON(w, r, func(*http.Request) swagger.GizmoJSONResponse {
+			return swagger.NewErrorResponse(err)
+		})
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Write(data)
+}
+
+// presetBundle returns the definitions of all presets, sorted by name.
+// Preset maps created before canonical presets were stored are exported
+// using the normalized preset of one of their providers, and derived
+// presets are exported with only the fields they override. Presets that
+// can't be found in any provider are listed as skipped.
+func (s *TranscodingService) presetBundle() (*presetBundle, error) {
+	presetMaps, err := s.db.ListPresetMaps()
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]db.PresetMap, len(presetMaps))
+	names := make([]string, 0, len(presetMaps))
+	for _, presetMap := range presetMaps {
+		byName[presetMap.Name] = presetMap
+		names = append(names, presetMap.Name)
+	}
+	sort.Strings(names)
+	bundle := presetBundle{Presets: make([]bundledPreset, 0, len(presetMaps))}
+	for _, presetName := range names {
+		presetMap := byName[presetName]
+		preset, ierr := s.canonicalPreset(&presetMap)
+		if ierr != nil {
+			s.logger.Warnf("unable to export preset %q: preset not found in any provider", presetMap.Name)
+			bundle.Skipped = append(bundle.Skipped, presetMap.Name)
+			continue
+		}
+		if presetMap.Overrides != nil {
+			preset = presetMap.Overrides
+		}
+		bundle.Presets = append(bundle.Presets, bundledPreset{
+			Preset:        *preset,
+			OutputOptions: presetMap.OutputOpts,
+			Providers:     sortedProviderNames(presetMap.ProviderMapping),
+		})
+	}
+	return &bundle, nil
+}
+
+// normalizedPreset returns the normalized preset from the first of the
+// given providers that is able to return it, or nil if none of them are.
+func (s *TranscodingService) normalizedPreset(presetMap db.PresetMap, providerNames []string) *db.Preset {
+	for _, name := range providerNames {
+		providerFactory, err := provider.GetProviderFactory(name)
+		if err != nil {
+			continue
+		}
+		providerObj, err := providerFactory(s.config)
+		if err != nil {
+			continue
+		}
+		preset, err := providerObj.GetNormalizedPreset(presetMap.ProviderMapping[name])
+		if err != nil {
+			continue
+		}
+		preset.Name = presetMap.Name
+		return preset
+	}
+	return nil
+}
+
+// sortedProviderNames returns the names of the providers in the given
+// provider mapping, sorted alphabetically.
+func sortedProviderNames(mapping map[string]string) []string {
+	names := make([]string, 0, len(mapping))
+	for name := range mapping {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// swagger:route POST /presets/import presets importPresets
+//
+// Imports a bundle of presets in JSON or YAML format, creating each preset
+// in its providers. Presets that already exist with a different definition
+// are reported as conflicts and left untouched.
+//
+//     Consumes:
+//     - application/json
+//     - application/x-yaml
+//
+//     Responses:
+//       200: importPresetsOutputs
+//       400: invalidPreset
+//       500: genericError
+func (s *TranscodingService) importPresets(r *http.Request) swagger.GizmoJSONResponse {
+	defer r.Body.Close()
+	if web.Vars(r)["name"] != importPresetsName {
+		return swagger.NewErrorResponse(errors.New("route not found")).WithStatus(http.StatusNotFound)
+	}
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return swagger.NewErrorResponse(err)
+	}
+	var bundle presetBundle
+	if err = yaml.Unmarshal(data, &bundle); err != nil {
+		return newInvalidPresetResponse(fmt.Errorf("invalid bundle: %s", err))
+	}
+	occurrences := make(map[string]int, len(bundle.Presets))
+	for _, entry := range bundle.Presets {
+		occurrences[entry.Preset.Name]++
+	}
+	sortByInheritance(bundle.Presets)
+	output := importPresetsOutputs{Results: make(map[string]importPresetResult, len(bundle.Presets))}
+	for _, entry := range bundle.Presets {
+		if occurrences[entry.Preset.Name] > 1 {
+			output.Results[entry.Preset.Name] = importPresetResult{Status: "conflict", Error: "preset defined more than once in the bundle"}
+			continue
+		}
+		output.Results[entry.Preset.Name] = s.importPreset(entry, r.Header.Get(authorHeader))
+	}
+	return &importPresetsResponse{
+		baseResponse: baseResponse{
+			payload: output,
+			status:  http.StatusOK,
+		},
+	}
+}
+
+// importPreset creates the given preset in all of its providers, undoing the
+// creation if it fails in any of them.
+func (s *TranscodingService) importPreset(entry bundledPreset, author string) importPresetResult {
+	preset := entry.Preset
+	preset.NormalizeVideoCodec()
+	overrides := presetOverrides(preset)
+	if err := validatePresetName(preset.Name); err != nil {
+		return importPresetResult{Status: "invalid", Error: err.Error()}
+	}
+	preset, err := s.resolvePreset(preset)
+	if err != nil {
+		return importPresetResult{Status: "invalid", Error: err.Error()}
+	}
+	if err = preset.Validate(); err != nil {
+		return importPresetResult{Status: "invalid", Error: err.Error()}
+	}
+	outputOpts := entry.OutputOptions
+	outputOpts.Extension = preset.Container
+	if err := outputOpts.Validate(); err != nil {
+		return importPresetResult{Status: "invalid", Error: "invalid outputOptions: " + err.Error()}
+	}
+	existing, err := s.db.GetPresetMap(preset.Name)
+	switch err {
+	case nil:
+		if s.samePresetDefinition(existing, preset, outputOpts, entry.Providers) {
+			return importPresetResult{Status: "unchanged"}
+		}
+		return importPresetResult{Status: "conflict", Error: "a different preset with this name already exists"}
+	case db.ErrPresetMapNotFound:
+	default:
+		return importPresetResult{Status: "failed", Error: err.Error()}
+	}
+	creation := s.createProviderPresets(preset, entry.Providers, true)
+	result := importPresetResult{Status: "created", Providers: creation.results}
+	if creation.failed || len(creation.mapping) == 0 {
+		result.Status = "failed"
+		result.Compensations = deleteCreatedPresets(creation.providers, creation.mapping)
+		return result
+	}
+	err = s.savePresetMap(&db.PresetMap{
+		Name:            preset.Name,
+		Preset:          &preset,
+		Overrides:       overrides,
+		ProviderMapping: creation.mapping,
+		OutputOpts:      outputOpts,
+	}, author, s.db.CreatePresetMap)
+	if err != nil {
+		result.Status = "failed"
+		result.Error = err.Error()
+		result.Compensations = deleteCreatedPresets(creation.providers, creation.mapping)
+	}
+	return result
+}
+
+// samePresetDefinition reports whether the given preset map matches the
+// definition of a preset in a bundle.
+func (s *TranscodingService) samePresetDefinition(presetMap *db.PresetMap, preset db.Preset, outputOpts db.OutputOptions, providerNames []string) bool {
+	if presetMap.Preset == nil || !reflect.DeepEqual(*presetMap.Preset, preset) || !reflect.DeepEqual(presetMap.OutputOpts, outputOpts) {
+		return false
+	}
+	if len(presetMap.ProviderMapping) != len(providerNames) {
+		return false
+	}
+	for _, name := range providerNames {
+		if _, ok := presetMap.ProviderMapping[name]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// sortByInheritance sorts the presets of a bundle so that parent presets
+// are imported before the presets derived from them.
+func sortByInheritance(presets []bundledPreset) {
+	parents := make(map[string]string, len(presets))
+	for _, entry := range presets {
+		parents[entry.Preset.Name] = entry.Preset.Parent
+	}
+	depths := make(map[string]int, len(parents))
+	for name := range parents {
+		depth := 0
+		for parent := parents[name]; depth < len(parents); parent = parents[parent] {
+			if _, ok := parents[parent]; !ok {
+				break
+			}
+			depth++
+		}
+		depths[name] = depth
+	}
+	sort.Stable(bundledPresetsByDepth{presets: presets, depths: depths})
+}
+
+type bundledPresetsByDepth struct {
+	presets []bundledPreset
+	depths  map[string]int
+}
+
+func (p bundledPresetsByDepth) Len() int {
+	return len(p.presets)
+}
+
+func (p bundledPresetsByDepth) Less(i, j int) bool {
+	return p.depths[p.presets[i].Preset.Name] < p.depths[p.presets[j].Preset.Name]
+}
+
+func (p bundledPresetsByDepth) Swap(i, j int) {
+	p.presets[i], p.presets[j] = p.presets[j], p.presets[i]
+}
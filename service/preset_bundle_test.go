@@ -0,0 +1,406 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/NYTimes/gizmo/server"
+	"github.com/NYTimes/video-transcoding-api/config"
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/db/dbtest"
+	"github.com/Sirupsen/logrus"
+	"github.com/ghodss/yaml"
+)
+
+func TestExportPresets(t *testing.T) {
+	expectedBundle := presetBundle{
+		Presets: []bundledPreset{
+			{
+				Preset: db.Preset{
+					Name:      "mp4_720p",
+					Container: "mp4",
+					Video:     db.VideoPreset{Codec: "h264", Height: "720"},
+					Audio:     db.AudioPreset{Codec: "aac", Bitrate: "64000"},
+				},
+				OutputOptions: db.OutputOptions{Extension: "mp4"},
+				Providers:     []string{"fake", "fakeupdater"},
+			},
+			{
+				Preset: db.Preset{
+					Name:      "webm_720p",
+					Container: "webm",
+					Video:     db.VideoPreset{Codec: "vp8", Height: "720"},
+				},
+				OutputOptions: db.OutputOptions{Extension: "webm"},
+				Providers:     []string{"fake"},
+			},
+		},
+	}
+	var tests = []struct {
+		testCase        string
+		format          string
+		wantContentType string
+		unmarshal       func([]byte, interface{}) error
+	}{
+		{"default format", "", "application/json", json.Unmarshal},
+		{"json", "json", "application/json", json.Unmarshal},
+		{"yaml", "yaml", "application/x-yaml", yaml.Unmarshal},
+	}
+	for _, test := range tests {
+		srvr := server.NewSimpleServer(&server.Config{RouterType: "fast"})
+		fakeDB := dbtest.NewFakeRepository(false)
+		for i := len(expectedBundle.Presets) - 1; i >= 0; i-- {
+			entry := expectedBundle.Presets[i]
+			preset := entry.Preset
+			mapping := make(map[string]string)
+			for _, name := range entry.Providers {
+				mapping[name] = preset.Name + "-" + name
+			}
+			fakeDB.CreatePresetMap(&db.PresetMap{
+				Name:            preset.Name,
+				Preset:          &preset,
+				ProviderMapping: mapping,
+				OutputOpts:      entry.OutputOptions,
+			})
+		}
+		service, err := NewTranscodingService(&config.Config{}, logrus.New())
+		if err != nil {
+			t.Fatal(err)
+		}
+		service.db = fakeDB
+		srvr.Register(service)
+		r, _ := http.NewRequest("GET", "/presets/export?format="+test.format, nil)
+		w := httptest.NewRecorder()
+		srvr.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: wrong response code. Want %d. Got %d", test.testCase, http.StatusOK, w.Code)
+		}
+		if contentType := w.Header().Get("Content-Type"); contentType != test.wantContentType {
+			t.Errorf("%s: wrong content type. Want %q. Got %q", test.testCase, test.wantContentType, contentType)
+		}
+		var bundle presetBundle
+		err = test.unmarshal(w.Body.Bytes(), &bundle)
+		if err != nil {
+			t.Errorf("%s: unable to decode response body: %s", test.testCase, err)
+		}
+		if !reflect.DeepEqual(bundle, expectedBundle) {
+			t.Errorf("%s: wrong bundle returned\nWant %#v\nGot  %#v", test.testCase, expectedBundle, bundle)
+		}
+	}
+}
+
+func TestExportPresetsSkipped(t *testing.T) {
+	srvr := server.NewSimpleServer(&server.Config{RouterType: "fast"})
+	fakeDB := dbtest.NewFakeRepository(false)
+	fakeDB.CreatePresetMap(&db.PresetMap{
+		Name:            "legacy_720p",
+		ProviderMapping: map[string]string{"nonexistent-provider": "legacy-id"},
+		OutputOpts:      db.OutputOptions{Extension: "mp4"},
+	})
+	service, err := NewTranscodingService(&config.Config{}, logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.db = fakeDB
+	srvr.Register(service)
+	r, _ := http.NewRequest("GET", "/presets/export", nil)
+	w := httptest.NewRecorder()
+	srvr.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("wrong response code. Want %d. Got %d", http.StatusOK, w.Code)
+	}
+	var bundle presetBundle
+	err = json.NewDecoder(w.Body).Decode(&bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedBundle := presetBundle{Presets: []bundledPreset{}, Skipped: []string{"legacy_720p"}}
+	if !reflect.DeepEqual(bundle, expectedBundle) {
+		t.Errorf("wrong bundle returned\nWant %#v\nGot  %#v", expectedBundle, bundle)
+	}
+}
+
+func TestExportPresetsInvalidFormat(t *testing.T) {
+	srvr := server.NewSimpleServer(&server.Config{RouterType: "fast"})
+	service, err := NewTranscodingService(&config.Config{}, logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.db = dbtest.NewFakeRepository(false)
+	srvr.Register(service)
+	r, _ := http.NewRequest("GET", "/presets/export?format=xml", nil)
+	w := httptest.NewRecorder()
+	srvr.ServeHTTP(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("wrong response code. Want %d. Got %d", http.StatusBadRequest, w.Code)
+	}
+	var got map[string]interface{}
+	err = json.NewDecoder(w.Body).Decode(&got)
+	if err != nil {
+		t.Fatalf("unable to JSON decode response body: %s", err)
+	}
+	expected := map[string]interface{}{"error": `unsupported bundle format "xml". Supported formats are: json, yaml`}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("wrong response body\nWant %#v\nGot  %#v", expected, got)
+	}
+}
+
+func TestImportPresets(t *testing.T) {
+	fprovider.deletedPresets = nil
+	srvr := server.NewSimpleServer(&server.Config{RouterType: "fast"})
+	fakeDB := dbtest.NewFakeRepository(false)
+	fakeDB.CreatePresetMap(&db.PresetMap{
+		Name:            "existing",
+		Preset:          &db.Preset{Name: "existing", Container: "mp4"},
+		ProviderMapping: map[string]string{"fake": "existing-id"},
+		OutputOpts:      db.OutputOptions{Extension: "mp4"},
+	})
+	fakeDB.CreatePresetMap(&db.PresetMap{
+		Name:            "changed",
+		Preset:          &db.Preset{Name: "changed", Container: "mp4"},
+		ProviderMapping: map[string]string{"fake": "changed-id"},
+		OutputOpts:      db.OutputOptions{Extension: "mp4"},
+	})
+	service, err := NewTranscodingService(&config.Config{}, logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.db = fakeDB
+	srvr.Register(service)
+	bundle := `presets:
+- preset:
+    name: hls_1080p
+    container: m3u8
+    video:
+      codec: hevc
+      height: 1080
+      bitrate: 5000000
+  outputOptions:
+    extension: m3u8
+  providers: [fake]
+- preset:
+    name: existing
+    container: mp4
+  providers: [fake]
+- preset:
+    name: changed
+    container: webm
+  providers: [fake]
+- preset:
+    name: invalid
+    container: avi
+  providers: [fake]
+- preset:
+    name: twice
+    container: mp4
+  providers: [fake]
+- preset:
+    name: twice
+    container: mp4
+  providers: [fakeupdater]
+- preset:
+    name: mp4_av1
+    container: mp4
+    video:
+      codec: av1
+  providers: [fake, fakeupdater]
+`
+	r, _ := http.NewRequest("POST", "/presets/import", strings.NewReader(bundle))
+	r.Header.Set("Content-Type", "application/x-yaml")
+	w := httptest.NewRecorder()
+	srvr.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("wrong response code. Want %d. Got %d", http.StatusOK, w.Code)
+	}
+	var got map[string]interface{}
+	err = json.NewDecoder(w.Body).Decode(&got)
+	if err != nil {
+		t.Fatalf("unable to JSON decode response body: %s", err)
+	}
+	expected := map[string]interface{}{
+		"results": map[string]interface{}{
+			"hls_1080p": map[string]interface{}{
+				"status": "created",
+				"providers": map[string]interface{}{
+					"fake": map[string]interface{}{"PresetID": "presetID_here", "Error": ""},
+				},
+			},
+			"existing": map[string]interface{}{"status": "unchanged"},
+			"changed": map[string]interface{}{
+				"status": "conflict",
+				"error":  "a different preset with this name already exists",
+			},
+			"twice": map[string]interface{}{
+				"status": "conflict",
+				"error":  "preset defined more than once in the bundle",
+			},
+			"invalid": map[string]interface{}{
+				"status": "invalid",
+				"error":  `invalid preset: container: unsupported container "avi". Supported containers are: m3u8, mp4, mpd, ts, webm`,
+			},
+			"mp4_av1": map[string]interface{}{
+				"status": "failed",
+				"providers": map[string]interface{}{
+					"fake": map[string]interface{}{"PresetID": "presetID_here", "Error": ""},
+					"fakeupdater": map[string]interface{}{
+						"PresetID": "",
+						"Error":    `video codec "av1" is not supported by fakeupdater. Supported codecs are: h264, h265, vp8, vp9`,
+					},
+				},
+				"compensations": []interface{}{
+					map[string]interface{}{"Provider": "fake", "PresetID": "presetID_here", "Action": "delete preset"},
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("wrong response body\nWant %#v\nGot  %#v", expected, got)
+	}
+	presetMap, err := fakeDB.GetPresetMap("hls_1080p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedPresetMap := db.PresetMap{
+		Name: "hls_1080p",
+		Preset: &db.Preset{
+			Name:      "hls_1080p",
+			Container: "m3u8",
+			Video:     db.VideoPreset{Codec: "h265", Height: "1080", Bitrate: "5000000"},
+		},
+		ProviderMapping: map[string]string{"fake": "presetID_here"},
+		OutputOpts:      db.OutputOptions{Extension: "m3u8"},
+		Version:         1,
+	}
+	if !reflect.DeepEqual(*presetMap, expectedPresetMap) {
+		t.Errorf("wrong preset map saved\nWant %#v\nGot  %#v", expectedPresetMap, *presetMap)
+	}
+	if _, err = fakeDB.GetPresetMap("mp4_av1"); err != db.ErrPresetMapNotFound {
+		t.Errorf("wrong error when getting the preset map. Want ErrPresetMapNotFound. Got %#v", err)
+	}
+	expectedDeleted := []string{"presetID_here"}
+	if !reflect.DeepEqual(fprovider.deletedPresets, expectedDeleted) {
+		t.Errorf("wrong deleted presets. Want %#v. Got %#v", expectedDeleted, fprovider.deletedPresets)
+	}
+}
+
+func TestImportPresetsInvalidBundle(t *testing.T) {
+	srvr := server.NewSimpleServer(&server.Config{RouterType: "fast"})
+	service, err := NewTranscodingService(&config.Config{}, logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.db = dbtest.NewFakeRepository(false)
+	srvr.Register(service)
+	r, _ := http.NewRequest("POST", "/presets/import", strings.NewReader("presets: {"))
+	w := httptest.NewRecorder()
+	srvr.ServeHTTP(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("wrong response code. Want %d. Got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestImportPresetsReservedName(t *testing.T) {
+	srvr := server.NewSimpleServer(&server.Config{RouterType: "fast"})
+	service, err := NewTranscodingService(&config.Config{}, logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.db = dbtest.NewFakeRepository(false)
+	srvr.Register(service)
+	bundle := `{"presets": [{"preset": {"name": "export", "container": "mp4"}, "providers": ["fake"]}]}`
+	r, _ := http.NewRequest("POST", "/presets/import", strings.NewReader(bundle))
+	w := httptest.NewRecorder()
+	srvr.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("wrong response code. Want %d. Got %d", http.StatusOK, w.Code)
+	}
+	var output importPresetsOutputs
+	err = json.NewDecoder(w.Body).Decode(&output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := importPresetResult{Status: "invalid", Error: `preset name "export" is reserved`}
+	if result := output.Results["export"]; !reflect.DeepEqual(result, expected) {
+		t.Errorf("wrong result\nWant %#v\nGot  %#v", expected, result)
+	}
+}
+
+func TestImportPresetsUnknownRoute(t *testing.T) {
+	srvr := server.NewSimpleServer(&server.Config{RouterType: "fast"})
+	service, err := NewTranscodingService(&config.Config{}, logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.db = dbtest.NewFakeRepository(false)
+	srvr.Register(service)
+	r, _ := http.NewRequest("POST", "/presets/mp4_720p", strings.NewReader(`{"presets": []}`))
+	w := httptest.NewRecorder()
+	srvr.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("wrong response code. Want %d. Got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestImportPresetsWithParents(t *testing.T) {
+	srvr := server.NewSimpleServer(&server.Config{RouterType: "fast"})
+	fakeDB := dbtest.NewFakeRepository(false)
+	service, err := NewTranscodingService(&config.Config{}, logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.db = fakeDB
+	srvr.Register(service)
+	bundle := `presets:
+- preset:
+    name: 720p_high
+    parent: 720p_base
+    video:
+      bitrate: 4000000
+  providers: [fake]
+- preset:
+    name: 720p_base
+    container: mp4
+    video:
+      codec: h264
+      height: 720
+      bitrate: 2500000
+  providers: [fake]
+`
+	r, _ := http.NewRequest("POST", "/presets/import", strings.NewReader(bundle))
+	w := httptest.NewRecorder()
+	srvr.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("wrong response code. Want %d. Got %d", http.StatusOK, w.Code)
+	}
+	var got importPresetsOutputs
+	err = json.NewDecoder(w.Body).Decode(&got)
+	if err != nil {
+		t.Fatalf("unable to JSON decode response body: %s", err)
+	}
+	for _, name := range []string{"720p_base", "720p_high"} {
+		if status := got.Results[name].Status; status != "created" {
+			t.Errorf("wrong status for %s. Want %q. Got %q", name, "created", status)
+		}
+	}
+	presetMap, err := fakeDB.GetPresetMap("720p_high")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedPreset := db.Preset{
+		Name:      "720p_high",
+		Parent:    "720p_base",
+		Container: "mp4",
+		Video:     db.VideoPreset{Codec: "h264", Height: "720", Bitrate: "4000000"},
+	}
+	if !reflect.DeepEqual(*presetMap.Preset, expectedPreset) {
+		t.Errorf("wrong canonical preset\nWant %#v\nGot  %#v", expectedPreset, *presetMap.Preset)
+	}
+	expectedOverrides := db.Preset{Name: "720p_high", Parent: "720p_base", Video: db.VideoPreset{Bitrate: "4000000"}}
+	if !reflect.DeepEqual(*presetMap.Overrides, expectedOverrides) {
+		t.Errorf("wrong overrides\nWant %#v\nGot  %#v", expectedOverrides, *presetMap.Overrides)
+	}
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/NYTimes/gizmo/web"
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/provider"
+	"github.com/NYTimes/video-transcoding-api/swagger"
+)
+
+var errNoCanonicalPreset = errors.New("preset has no canonical version to compare with")
+
+// swagger:route GET /presets/{name}/drift presets getPresetDrift
+//
+// Compares the canonical version of a preset with the preset stored in each
+// provider, reporting the fields that were changed directly in the
+// providers.
+//
+//     Responses:
+//       200: presetDrift
+//       404: presetNotFound
+//       409: genericError
+//       500: genericError
+func (s *TranscodingService) getPresetDrift(r *http.Request) swagger.GizmoJSONResponse {
+	var params getPresetMapInput
+	params.loadParams(web.Vars(r))
+	presetMap, err := s.db.GetPresetMap(params.Name)
+	switch err {
+	case nil:
+	case db.ErrPresetMapNotFound:
+		return newPresetMapNotFoundResponse(err)
+	default:
+		return swagger.NewErrorResponse(err)
+	}
+	if presetMap.Preset == nil {
+		return swagger.NewErrorResponse(errNoCanonicalPreset).WithStatus(http.StatusConflict)
+	}
+	return &presetDriftResponse{
+		baseResponse: baseResponse{
+			payload: s.presetDrift(presetMap),
+			status:  http.StatusOK,
+		},
+	}
+}
+
+// presetDrift fetches the preset from each provider in the preset map and
+// compares it with the canonical preset, which must not be nil. Fields that
+// the provider doesn't report are not compared.
+func (s *TranscodingService) presetDrift(presetMap *db.PresetMap) presetDrift {
+	drift := presetDrift{
+		Name:      presetMap.Name,
+		Providers: make(map[string]providerPresetDrift, len(presetMap.ProviderMapping)),
+	}
+	for p, presetID := range presetMap.ProviderMapping {
+		providerFactory, err := provider.GetProviderFactory(p)
+		if err != nil {
+			drift.Providers[p] = providerPresetDrift{PresetID: presetID, Error: "getting factory: " + err.Error()}
+			continue
+		}
+		providerObj, err := providerFactory(s.config)
+		if err != nil {
+			drift.Providers[p] = providerPresetDrift{PresetID: presetID, Error: "initializing provider: " + err.Error()}
+			continue
+		}
+		preset, err := providerObj.GetNormalizedPreset(presetID)
+		if err != nil {
+			drift.Providers[p] = providerPresetDrift{PresetID: presetID, Error: "getting preset: " + err.Error()}
+			continue
+		}
+		expected := *presetMap.Preset
+		if normalizer, ok := providerObj.(provider.PresetNormalizer); ok {
+			expected = normalizer.NormalizePreset(expected)
+		}
+		differences := expected.Diff(*preset, providerObj.Capabilities().UnreportedPresetFields...)
+		if len(differences) > 0 {
+			drift.Drifted = true
+		}
+		drift.Providers[p] = providerPresetDrift{PresetID: presetID, Differences: differences}
+	}
+	return drift
+}
+
+// MonitorPresetDrift checks all presets for drift at the given interval,
+// logging the differences found in each provider. It never returns, so it
+// should be started in its own goroutine.
+func (s *TranscodingService) MonitorPresetDrift(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.checkPresetsDrift()
+	}
+}
+
+// checkPresetsDrift checks all presets that have a canonical version for
+// drift, logging and returning the presets that drifted.
+func (s *TranscodingService) checkPresetsDrift() []presetDrift {
+	presetMaps, err := s.db.ListPresetMaps()
+	if err != nil {
+		s.logger.Warnf("unable to check presets for drift: %s", err)
+		return nil
+	}
+	var drifted []presetDrift
+	for i := range presetMaps {
+		if presetMaps[i].Preset == nil {
+			continue
+		}
+		drift := s.presetDrift(&presetMaps[i])
+		if !drift.Drifted {
+			continue
+		}
+		drifted = append(drifted, drift)
+		for p, providerDrift := range drift.Providers {
+			if len(providerDrift.Differences) == 0 {
+				continue
+			}
+			fields := make([]string, len(providerDrift.Differences))
+			for j, diff := range providerDrift.Differences {
+				fields[j] = diff.Field
+			}
+			s.logger.Warnf("preset %q drifted in %s (preset %q): %s", drift.Name, p, providerDrift.PresetID, strings.Join(fields, ", "))
+		}
+	}
+	sort.Sort(presetDriftsByName(drifted))
+	return drifted
+}
+
+type presetDriftsByName []presetDrift
+
+func (d presetDriftsByName) Len() int           { return len(d) }
+func (d presetDriftsByName) Less(i, j int) bool { return d[i].Name < d[j].Name }
+func (d presetDriftsByName) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
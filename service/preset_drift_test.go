@@ -0,0 +1,139 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/NYTimes/gizmo/server"
+	"github.com/NYTimes/video-transcoding-api/config"
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/db/dbtest"
+	"github.com/Sirupsen/logrus"
+)
+
+func driftTestPresetMaps() []db.PresetMap {
+	canonical := db.Preset{
+		Name:      "mp4_720p",
+		Container: "mp4",
+		Video:     db.VideoPreset{Codec: "h264", Height: "720"},
+		Audio:     db.AudioPreset{Codec: "aac", Bitrate: "128000"},
+	}
+	return []db.PresetMap{
+		{
+			Name:   "mp4_720p",
+			Preset: &canonical,
+			ProviderMapping: map[string]string{
+				"fake":        "presetID_here",
+				"fakeupdater": "updater-id",
+				"encodingcom": "12345",
+			},
+			OutputOpts: db.OutputOptions{Extension: "mp4"},
+		},
+		{
+			Name:            "legacy",
+			ProviderMapping: map[string]string{"fake": "legacy-id"},
+			OutputOpts:      db.OutputOptions{Extension: "mp4"},
+		},
+	}
+}
+
+func TestGetPresetDrift(t *testing.T) {
+	tests := []struct {
+		givenTestCase   string
+		givenPresetName string
+		wantBody        map[string]interface{}
+		wantCode        int
+	}{
+		{
+			"Preset changed in a provider",
+			"mp4_720p",
+			map[string]interface{}{
+				"name":    "mp4_720p",
+				"drifted": true,
+				"providers": map[string]interface{}{
+					"fake": map[string]interface{}{
+						"presetId": "presetID_here",
+						"differences": []interface{}{
+							map[string]interface{}{"field": "audio.bitrate", "expected": "128000", "actual": "64000"},
+						},
+					},
+					"fakeupdater": map[string]interface{}{"presetId": "updater-id"},
+					"encodingcom": map[string]interface{}{
+						"presetId": "12345",
+						"error":    "getting factory: provider not found",
+					},
+				},
+			},
+			http.StatusOK,
+		},
+		{
+			"Preset without canonical version",
+			"legacy",
+			map[string]interface{}{"error": "preset has no canonical version to compare with"},
+			http.StatusConflict,
+		},
+		{
+			"Preset not found",
+			"preset-unknown",
+			map[string]interface{}{"error": "presetmap not found"},
+			http.StatusNotFound,
+		},
+	}
+	for _, test := range tests {
+		fupdater.presets = map[string]db.Preset{"updater-id": *driftTestPresetMaps()[0].Preset}
+		srvr := server.NewSimpleServer(&server.Config{RouterType: "fast"})
+		fakeDB := dbtest.NewFakeRepository(false)
+		presetMaps := driftTestPresetMaps()
+		for i := range presetMaps {
+			fakeDB.CreatePresetMap(&presetMaps[i])
+		}
+		service, err := NewTranscodingService(&config.Config{}, logrus.New())
+		if err != nil {
+			t.Fatal(err)
+		}
+		service.db = fakeDB
+		srvr.Register(service)
+		r, _ := http.NewRequest("GET", "/presets/"+test.givenPresetName+"/drift", nil)
+		w := httptest.NewRecorder()
+		srvr.ServeHTTP(w, r)
+		if w.Code != test.wantCode {
+			t.Errorf("%s: wrong response code. Want %d. Got %d", test.givenTestCase, test.wantCode, w.Code)
+		}
+		var got map[string]interface{}
+		err = json.NewDecoder(w.Body).Decode(&got)
+		if err != nil {
+			t.Errorf("%s: unable to JSON decode response body: %s", test.givenTestCase, err)
+		}
+		if !reflect.DeepEqual(got, test.wantBody) {
+			t.Errorf("%s: expected response body of\n%#v;\ngot\n%#v", test.givenTestCase, test.wantBody, got)
+		}
+	}
+}
+
+func TestCheckPresetsDrift(t *testing.T) {
+	fupdater.presets = map[string]db.Preset{"updater-id": *driftTestPresetMaps()[0].Preset}
+	fakeDB := dbtest.NewFakeRepository(false)
+	presetMaps := driftTestPresetMaps()
+	for i := range presetMaps {
+		fakeDB.CreatePresetMap(&presetMaps[i])
+	}
+	service, err := NewTranscodingService(&config.Config{}, logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.db = fakeDB
+	drifted := service.checkPresetsDrift()
+	if len(drifted) != 1 {
+		t.Fatalf("wrong number of drifted presets. Want 1. Got %d: %#v", len(drifted), drifted)
+	}
+	expectedDiffs := []db.FieldDiff{{Field: "audio.bitrate", Expected: "128000", Actual: "64000"}}
+	if diffs := drifted[0].Providers["fake"].Differences; !reflect.DeepEqual(diffs, expectedDiffs) {
+		t.Errorf("wrong differences. Want %#v. Got %#v", expectedDiffs, diffs)
+	}
+	if diffs := drifted[0].Providers["fakeupdater"].Differences; len(diffs) > 0 {
+		t.Errorf("unexpected differences in fakeupdater: %#v", diffs)
+	}
+}
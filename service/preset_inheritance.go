@@ -0,0 +1,122 @@
+package service
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/NYTimes/video-transcoding-api/db"
+)
+
+// resolvePreset returns the canonical version of the given preset. Presets
+// that declare a parent get the fields of the canonical preset of the
+// parent, overridden by the fields they define. Other presets are returned
+// as is.
+func (s *TranscodingService) resolvePreset(preset db.Preset) (db.Preset, error) {
+	if preset.Parent == "" {
+		return preset, nil
+	}
+	parent, err := s.parentPreset(preset.Name, preset.Parent)
+	if err != nil {
+		return preset, err
+	}
+	resolved := parent.Inherit(preset)
+	resolved.NormalizeVideoCodec()
+	return resolved, nil
+}
+
+// parentPreset returns the canonical preset of the given parent, walking up
+// its ancestors to make sure the preset doesn't end up inheriting from
+// itself.
+func (s *TranscodingService) parentPreset(name, parentName string) (*db.Preset, error) {
+	var parent *db.Preset
+	visited := make(map[string]bool)
+	for ancestor := parentName; ancestor != "" && !visited[ancestor]; {
+		if ancestor == name {
+			return nil, fmt.Errorf("preset %q can't inherit from %q: it would inherit from itself", name, parentName)
+		}
+		visited[ancestor] = true
+		presetMap, err := s.db.GetPresetMap(ancestor)
+		if err == db.ErrPresetMapNotFound {
+			return nil, fmt.Errorf("parent preset %q not found", ancestor)
+		}
+		if err != nil {
+			return nil, err
+		}
+		preset, err := s.canonicalPreset(presetMap)
+		if err != nil {
+			return nil, fmt.Errorf("parent preset %q has no canonical version", ancestor)
+		}
+		if parent == nil {
+			parent = preset
+		}
+		ancestor = preset.Parent
+	}
+	return parent, nil
+}
+
+// presetOverrides returns the overrides to store in the preset map of the
+// given preset, which is nil for presets without a parent.
+func presetOverrides(preset db.Preset) *db.Preset {
+	if preset.Parent == "" {
+		return nil
+	}
+	return &preset
+}
+
+// propagatePreset updates the presets derived from the given preset, and
+// the presets derived from them, so they get the new values of the fields
+// they inherit. Derived presets that fail to update are reported and left
+// untouched, along with their own derived presets.
+func (s *TranscodingService) propagatePreset(base *db.PresetMap, author string) (map[string]derivedPresetUpdate, error) {
+	presetMaps, err := s.db.ListPresetMaps()
+	if err != nil {
+		return nil, err
+	}
+	results := make(map[string]derivedPresetUpdate)
+	parents := []db.PresetMap{*base}
+	for len(parents) > 0 {
+		parent := parents[0]
+		parents = parents[1:]
+		for i := range presetMaps {
+			derived := &presetMaps[i]
+			if derived.Overrides == nil || derived.Overrides.Parent != parent.Name {
+				continue
+			}
+			if _, ok := results[derived.Name]; ok {
+				continue
+			}
+			updated, err := s.inheritPreset(derived, parent.Preset, author)
+			switch {
+			case err != nil:
+				results[derived.Name] = derivedPresetUpdate{Status: "failed", Error: err.Error()}
+			case updated == nil:
+				results[derived.Name] = derivedPresetUpdate{Status: "unchanged", Version: derived.Version}
+			default:
+				results[derived.Name] = derivedPresetUpdate{Status: "updated", Version: updated.Version}
+				parents = append(parents, *updated)
+			}
+		}
+	}
+	return results, nil
+}
+
+// inheritPreset applies the overrides of the given derived preset to the
+// new version of its parent, updating the preset in all providers. It
+// returns nil when the derived preset doesn't change.
+func (s *TranscodingService) inheritPreset(presetMap *db.PresetMap, parent *db.Preset, author string) (*db.PresetMap, error) {
+	preset := parent.Inherit(*presetMap.Overrides)
+	preset.NormalizeVideoCodec()
+	if presetMap.Preset != nil && reflect.DeepEqual(preset, *presetMap.Preset) {
+		return nil, nil
+	}
+	if err := preset.Validate(); err != nil {
+		return nil, err
+	}
+	updated := *presetMap
+	updated.Preset = &preset
+	updated.OutputOpts.Extension = preset.Container
+	if err := s.replacePreset(presetMap, &updated, author); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
@@ -0,0 +1,278 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/NYTimes/gizmo/server"
+	"github.com/NYTimes/video-transcoding-api/config"
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/db/dbtest"
+	"github.com/Sirupsen/logrus"
+)
+
+// inheritanceTestPresetMaps returns a base preset along with presets derived
+// from it, all of them stored in the fakeupdater provider.
+func inheritanceTestPresetMaps() []db.PresetMap {
+	base := db.Preset{
+		Name:      "720p_base",
+		Container: "mp4",
+		Video:     db.VideoPreset{Codec: "h264", Height: "720", Bitrate: "2500000"},
+		Audio:     db.AudioPreset{Codec: "aac", Bitrate: "128000"},
+	}
+	presetMaps := []db.PresetMap{{Name: base.Name, Preset: &base}}
+	derived := []db.Preset{
+		{Name: "720p_high", Parent: "720p_base", Video: db.VideoPreset{Bitrate: "4000000"}},
+		{Name: "720p_high_hevc", Parent: "720p_high", Video: db.VideoPreset{Codec: "h265"}},
+		{Name: "720p_loud", Parent: "720p_base", Audio: db.AudioPreset{Bitrate: "192000"}},
+		{Name: "720p_broken", Parent: "720p_base", Description: "fail update"},
+		{Name: "720p_broken_child", Parent: "720p_broken"},
+	}
+	parents := map[string]*db.Preset{base.Name: &base}
+	for i := range derived {
+		overrides := derived[i]
+		preset := parents[overrides.Parent].Inherit(overrides)
+		parents[preset.Name] = &preset
+		presetMaps = append(presetMaps, db.PresetMap{Name: preset.Name, Preset: &preset, Overrides: &overrides})
+	}
+	for i := range presetMaps {
+		presetMaps[i].ProviderMapping = map[string]string{"fakeupdater": presetMaps[i].Name + "-id"}
+		presetMaps[i].OutputOpts = db.OutputOptions{Extension: "mp4"}
+	}
+	return presetMaps
+}
+
+func inheritanceTestService(t *testing.T) (*server.SimpleServer, db.Repository) {
+	fupdater.presets = make(map[string]db.Preset)
+	fakeDB := dbtest.NewFakeRepository(false)
+	presetMaps := inheritanceTestPresetMaps()
+	for i := range presetMaps {
+		fupdater.presets[presetMaps[i].Name+"-id"] = *presetMaps[i].Preset
+		fakeDB.CreatePresetMap(&presetMaps[i])
+	}
+	service, err := NewTranscodingService(&config.Config{}, logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.db = fakeDB
+	srvr := server.NewSimpleServer(&server.Config{RouterType: "fast"})
+	srvr.Register(service)
+	return srvr, fakeDB
+}
+
+func TestNewPresetWithParent(t *testing.T) {
+	tests := []struct {
+		givenTestCase string
+		givenPreset   map[string]interface{}
+		wantCode      int
+		wantPreset    *db.Preset
+		wantError     string
+	}{
+		{
+			"Preset derived from a derived preset",
+			map[string]interface{}{
+				"name":   "720p_low",
+				"parent": "720p_high_hevc",
+				"video":  map[string]string{"codec": "hevc", "bitrate": "1000000"},
+			},
+			http.StatusOK,
+			&db.Preset{
+				Name:      "720p_low",
+				Parent:    "720p_high_hevc",
+				Container: "mp4",
+				Video:     db.VideoPreset{Codec: "h265", Height: "720", Bitrate: "1000000"},
+				Audio:     db.AudioPreset{Codec: "aac", Bitrate: "128000"},
+			},
+			"",
+		},
+		{
+			"Preset with invalid overrides",
+			map[string]interface{}{
+				"name":   "720p_low",
+				"parent": "720p_base",
+				"video":  map[string]string{"bitrate": "1M"},
+			},
+			http.StatusBadRequest,
+			nil,
+			`invalid preset: video.bitrate: invalid value "1M". It must be a positive integer`,
+		},
+		{
+			"Parent not found",
+			map[string]interface{}{"name": "720p_low", "parent": "720p_unknown"},
+			http.StatusBadRequest,
+			nil,
+			`parent preset "720p_unknown" not found`,
+		},
+	}
+	for _, test := range tests {
+		srvr, fakeDB := inheritanceTestService(t)
+		body, _ := json.Marshal(map[string]interface{}{
+			"providers": []string{"fake"},
+			"preset":    test.givenPreset,
+		})
+		r, _ := http.NewRequest("POST", "/presets", bytes.NewReader(body))
+		w := httptest.NewRecorder()
+		srvr.ServeHTTP(w, r)
+		if w.Code != test.wantCode {
+			t.Errorf("%s: wrong response code. Want %d. Got %d", test.givenTestCase, test.wantCode, w.Code)
+		}
+		if test.wantError != "" {
+			var got map[string]interface{}
+			json.NewDecoder(w.Body).Decode(&got)
+			if got["error"] != test.wantError {
+				t.Errorf("%s: wrong error. Want %q. Got %#v", test.givenTestCase, test.wantError, got["error"])
+			}
+			continue
+		}
+		presetMap, err := fakeDB.GetPresetMap("720p_low")
+		if err != nil {
+			t.Fatalf("%s: %s", test.givenTestCase, err)
+		}
+		if !reflect.DeepEqual(presetMap.Preset, test.wantPreset) {
+			t.Errorf("%s: wrong canonical preset\nWant %#v\nGot  %#v", test.givenTestCase, test.wantPreset, presetMap.Preset)
+		}
+		wantOverrides := &db.Preset{
+			Name:   "720p_low",
+			Parent: "720p_high_hevc",
+			Video:  db.VideoPreset{Codec: "h265", Bitrate: "1000000"},
+		}
+		if !reflect.DeepEqual(presetMap.Overrides, wantOverrides) {
+			t.Errorf("%s: wrong overrides\nWant %#v\nGot  %#v", test.givenTestCase, wantOverrides, presetMap.Overrides)
+		}
+	}
+}
+
+func TestUpdatePresetPropagate(t *testing.T) {
+	baseUpdate := map[string]interface{}{
+		"container": "mp4",
+		"video":     map[string]string{"codec": "h264", "height": "720", "bitrate": "2500000"},
+		"audio":     map[string]string{"codec": "aac", "bitrate": "160000"},
+	}
+	tests := []struct {
+		givenTestCase    string
+		givenPresetName  string
+		givenQuery       string
+		givenRequestData map[string]interface{}
+		wantCode         int
+		wantBody         map[string]interface{}
+		wantAudioBitrate map[string]string
+	}{
+		{
+			"Update propagated to derived presets",
+			"720p_base",
+			"?propagate=true",
+			baseUpdate,
+			http.StatusOK,
+			map[string]interface{}{
+				"720p_high":      map[string]interface{}{"status": "updated", "version": float64(1)},
+				"720p_high_hevc": map[string]interface{}{"status": "updated", "version": float64(1)},
+				"720p_loud":      map[string]interface{}{"status": "unchanged"},
+				"720p_broken": map[string]interface{}{
+					"status": "failed",
+					"error":  "updating preset in fakeupdater: failed to update preset",
+				},
+			},
+			map[string]string{
+				"720p_base":         "160000",
+				"720p_high":         "160000",
+				"720p_high_hevc":    "160000",
+				"720p_loud":         "192000",
+				"720p_broken":       "128000",
+				"720p_broken_child": "128000",
+			},
+		},
+		{
+			"Update not propagated",
+			"720p_base",
+			"",
+			baseUpdate,
+			http.StatusOK,
+			nil,
+			map[string]string{
+				"720p_base":      "160000",
+				"720p_high":      "128000",
+				"720p_high_hevc": "128000",
+			},
+		},
+		{
+			"Derived preset changing its overrides",
+			"720p_high",
+			"?propagate=true",
+			map[string]interface{}{
+				"parent": "720p_base",
+				"audio":  map[string]string{"bitrate": "96000"},
+			},
+			http.StatusOK,
+			map[string]interface{}{
+				"720p_high_hevc": map[string]interface{}{"status": "updated", "version": float64(1)},
+			},
+			map[string]string{
+				"720p_base":      "128000",
+				"720p_high":      "96000",
+				"720p_high_hevc": "96000",
+			},
+		},
+		{
+			"Inheritance cycle",
+			"720p_base",
+			"",
+			map[string]interface{}{"parent": "720p_high_hevc"},
+			http.StatusBadRequest,
+			map[string]interface{}{
+				"error": `preset "720p_base" can't inherit from "720p_high_hevc": it would inherit from itself`,
+			},
+			map[string]string{"720p_base": "128000"},
+		},
+		{
+			"Invalid propagate flag",
+			"720p_base",
+			"?propagate=maybe",
+			baseUpdate,
+			http.StatusBadRequest,
+			map[string]interface{}{"error": `invalid value for propagate: "maybe"`},
+			map[string]string{"720p_base": "128000"},
+		},
+	}
+	for _, test := range tests {
+		srvr, fakeDB := inheritanceTestService(t)
+		body, _ := json.Marshal(test.givenRequestData)
+		r, _ := http.NewRequest("PUT", "/presets/"+test.givenPresetName+test.givenQuery, bytes.NewReader(body))
+		w := httptest.NewRecorder()
+		srvr.ServeHTTP(w, r)
+		if w.Code != test.wantCode {
+			t.Errorf("%s: wrong response code. Want %d. Got %d", test.givenTestCase, test.wantCode, w.Code)
+		}
+		var got map[string]interface{}
+		err := json.NewDecoder(w.Body).Decode(&got)
+		if err != nil {
+			t.Errorf("%s: unable to JSON decode response body: %s", test.givenTestCase, err)
+		}
+		if test.wantCode == http.StatusOK {
+			if got["name"] != test.givenPresetName {
+				t.Errorf("%s: wrong preset returned. Want %q. Got %#v", test.givenTestCase, test.givenPresetName, got["name"])
+			}
+			derived, _ := got["derived"].(map[string]interface{})
+			if !reflect.DeepEqual(derived, test.wantBody) {
+				t.Errorf("%s: wrong derived presets\nWant %#v\nGot  %#v", test.givenTestCase, test.wantBody, derived)
+			}
+		} else if !reflect.DeepEqual(got, test.wantBody) {
+			t.Errorf("%s: wrong response body\nWant %#v\nGot  %#v", test.givenTestCase, test.wantBody, got)
+		}
+		for name, bitrate := range test.wantAudioBitrate {
+			presetMap, err := fakeDB.GetPresetMap(name)
+			if err != nil {
+				t.Fatalf("%s: %s", test.givenTestCase, err)
+			}
+			if presetMap.Preset.Audio.Bitrate != bitrate {
+				t.Errorf("%s: wrong audio bitrate in the canonical preset of %s. Want %q. Got %q", test.givenTestCase, name, bitrate, presetMap.Preset.Audio.Bitrate)
+			}
+			if providerBitrate := fupdater.presets[name+"-id"].Audio.Bitrate; providerBitrate != bitrate {
+				t.Errorf("%s: wrong audio bitrate in the provider preset of %s. Want %q. Got %q", test.givenTestCase, name, bitrate, providerBitrate)
+			}
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/provider"
+	"github.com/NYTimes/video-transcoding-api/swagger"
+)
+
+// presetInUse returns the error response for deleting a preset that is used
+// by queued or running jobs, or nil when the preset can be deleted.
+func (s *TranscodingService) presetInUse(name string) swagger.GizmoJSONResponse {
+	jobIDs, err := s.activePresetJobs(name)
+	if err != nil {
+		return swagger.NewErrorResponse(err)
+	}
+	if len(jobIDs) > 0 {
+		return newPresetInUseResponse(jobIDs)
+	}
+	return nil
+}
+
+// activePresetJobs returns the IDs of the jobs that use the given preset and
+// haven't finished, failed or been canceled yet. Only jobs whose recorded
+// status isn't final are checked against the provider, and jobs whose status
+// can't be retrieved from the provider are considered active.
+func (s *TranscodingService) activePresetJobs(name string) ([]string, error) {
+	jobs, err := s.db.ListActiveJobs(name)
+	if err != nil {
+		return nil, err
+	}
+	var jobIDs []string
+	for i := range jobs {
+		if s.jobActive(&jobs[i]) {
+			jobIDs = append(jobIDs, jobs[i].ID)
+		}
+	}
+	return jobIDs, nil
+}
+
+func (s *TranscodingService) jobActive(job *db.Job) bool {
+	providerFactory, err := provider.GetProviderFactory(job.ProviderName)
+	if err != nil {
+		return true
+	}
+	providerObj, err := providerFactory(s.config)
+	if err != nil {
+		return true
+	}
+	status, err := providerObj.JobStatus(job)
+	if err != nil {
+		_, notFound := err.(provider.JobNotFoundError)
+		return !notFound
+	}
+	s.recordJobStatus(job, status.Status)
+	return job.Active()
+}
+
+// recordJobStatus stores the given status in the job, so jobs that are done
+// can be skipped without querying the provider.
+func (s *TranscodingService) recordJobStatus(job *db.Job, status provider.Status) {
+	if status == "" || job.Status == string(status) {
+		return
+	}
+	job.Status = string(status)
+	if err := s.db.UpdateJob(job); err != nil {
+		s.logger.Warnf("unable to record the status of job %q: %s", job.ID, err)
+	}
+}
@@ -1,6 +1,12 @@
 package service
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+
 	"github.com/NYTimes/video-transcoding-api/db"
 )
 
@@ -8,6 +14,49 @@ type newPresetInput struct {
 	Providers     []string         `json:"providers"`
 	Preset        db.Preset        `json:"preset"`
 	OutputOptions db.OutputOptions `json:"outputOptions"`
+
+	// AllOrNothing indicates that the preset should either be created on
+	// all providers or on none of them.
+	AllOrNothing bool `json:"allOrNothing"`
+}
+
+// swagger:parameters updatePresetDetails
+type updatePresetInput struct {
+	// in: path
+	// required: true
+	Name string `json:"name"`
+
+	// in: body
+	// required: true
+	Payload db.Preset
+
+	// update the presets derived from this preset as well
+	//
+	// in: query
+	Propagate bool `json:"propagate"`
+}
+
+// Preset loads the input from the request, returning the updated preset. The
+// name of the preset may be omitted from the body.
+func (p *updatePresetInput) Preset(paramsMap map[string]string, query url.Values, body io.Reader) (db.Preset, error) {
+	p.Name = paramsMap["name"]
+	if value := query.Get("propagate"); value != "" {
+		propagate, err := strconv.ParseBool(value)
+		if err != nil {
+			return p.Payload, fmt.Errorf("invalid value for propagate: %q", value)
+		}
+		p.Propagate = propagate
+	}
+	err := json.NewDecoder(body).Decode(&p.Payload)
+	if err != nil {
+		return p.Payload, err
+	}
+	if p.Payload.Name == "" {
+		p.Payload.Name = p.Name
+	} else if p.Payload.Name != p.Name {
+		return p.Payload, fmt.Errorf("preset name %q doesn't match the name in the URL (%q)", p.Payload.Name, p.Name)
+	}
+	return p.Payload, nil
 }
 
 // list of the results of the attempt to create a preset
@@ -19,6 +68,10 @@ type newPresetOutputs struct {
 	// required: true
 	Results   map[string]newPresetOutput
 	PresetMap string
+
+	// actions taken to undo the creation of presets when creating the
+	// preset fails in all-or-nothing mode
+	Compensations []presetCompensation `json:",omitempty"`
 }
 
 type newPresetOutput struct {
@@ -26,6 +79,13 @@ type newPresetOutput struct {
 	Error    string
 }
 
+type presetCompensation struct {
+	Provider string
+	PresetID string
+	Action   string
+	Error    string `json:",omitempty"`
+}
+
 // list of the results of the attempt to delete a preset
 // in each provider.
 //
@@ -41,3 +101,173 @@ type deletePresetOutput struct {
 	PresetID string `json:"presetId"`
 	Error    string `json:"error,omitempty"`
 }
+
+// details of a preset, including the normalized version of the preset in
+// each provider.
+//
+// swagger:response presetDetails
+type presetDetails struct {
+	// in: body
+	// required: true
+	Name            string                           `json:"name"`
+	Preset          *db.Preset                       `json:"preset,omitempty"`
+	ProviderMapping map[string]string                `json:"providerMapping"`
+	OutputOpts      db.OutputOptions                 `json:"output"`
+	Providers       map[string]providerPresetDetails `json:"providers"`
+}
+
+type providerPresetDetails struct {
+	PresetID string     `json:"presetId"`
+	Preset   *db.Preset `json:"preset,omitempty"`
+	Error    string     `json:"error,omitempty"`
+}
+
+// bundle of preset definitions, used for moving presets between
+// environments.
+//
+// swagger:response presetBundle
+type presetBundle struct {
+	// in: body
+	// required: true
+	Presets []bundledPreset `json:"presets"`
+
+	// names of the presets that couldn't be exported, as their definition
+	// wasn't found in any provider
+	Skipped []string `json:"skipped,omitempty"`
+}
+
+// bundledPreset is the full definition of a preset: the canonical preset,
+// its output options and the providers where it should be created.
+type bundledPreset struct {
+	Preset        db.Preset        `json:"preset"`
+	OutputOptions db.OutputOptions `json:"outputOptions"`
+	Providers     []string         `json:"providers"`
+}
+
+// swagger:parameters exportPresets
+type exportPresetsInput struct {
+	// format of the bundle, either json (the default) or yaml
+	//
+	// in: query
+	Format string `json:"format"`
+}
+
+// swagger:parameters importPresets
+type importPresetsInput struct {
+	// bundle in JSON or YAML format
+	//
+	// in: body
+	// required: true
+	Payload presetBundle
+}
+
+// result of the import of each preset in a bundle, indexed by the name of
+// the preset.
+//
+// swagger:response importPresetsOutputs
+type importPresetsOutputs struct {
+	// in: body
+	// required: true
+	Results map[string]importPresetResult `json:"results"`
+}
+
+// importPresetResult describes what happened to a preset during an import.
+// Status is one of "created", "unchanged", "conflict", "invalid" or
+// "failed".
+type importPresetResult struct {
+	Status        string                     `json:"status"`
+	Providers     map[string]newPresetOutput `json:"providers,omitempty"`
+	Compensations []presetCompensation       `json:"compensations,omitempty"`
+	Error         string                     `json:"error,omitempty"`
+}
+
+// differences between the canonical version of a preset and the preset
+// stored in each provider.
+//
+// swagger:response presetDrift
+type presetDrift struct {
+	// in: body
+	// required: true
+	Name      string                         `json:"name"`
+	Drifted   bool                           `json:"drifted"`
+	Providers map[string]providerPresetDrift `json:"providers"`
+}
+
+type providerPresetDrift struct {
+	PresetID    string         `json:"presetId"`
+	Differences []db.FieldDiff `json:"differences,omitempty"`
+	Error       string         `json:"error,omitempty"`
+}
+
+// swagger:parameters addPresetProvider
+type addPresetProviderInput struct {
+	// in: path
+	// required: true
+	Name string `json:"name"`
+
+	// name of the provider where the preset should be created
+	//
+	// in: path
+	// required: true
+	Provider string `json:"provider"`
+}
+
+func (p *addPresetProviderInput) loadParams(paramsMap map[string]string) {
+	p.Name = paramsMap["name"]
+	p.Provider = paramsMap["provider"]
+}
+
+// versions of a preset, from the oldest to the newest.
+//
+// swagger:response presetVersions
+type presetVersions struct {
+	// in: body
+	// required: true
+	Name     string             `json:"name"`
+	Versions []db.PresetVersion `json:"versions"`
+}
+
+// swagger:parameters rollbackPreset
+type rollbackPresetInput struct {
+	// in: path
+	// required: true
+	Name string `json:"name"`
+
+	// number of the version to roll back to
+	//
+	// in: path
+	// required: true
+	Version uint `json:"version"`
+}
+
+func (p *rollbackPresetInput) loadParams(paramsMap map[string]string) error {
+	p.Name = paramsMap["name"]
+	version, err := strconv.ParseUint(paramsMap["version"], 10, 0)
+	if err != nil || version == 0 {
+		return fmt.Errorf("invalid version %q", paramsMap["version"])
+	}
+	p.Version = uint(version)
+	return nil
+}
+
+// preset updated along with the presets derived from it, when the update
+// is propagated.
+//
+// swagger:response propagatedPreset
+type propagatedPreset struct {
+	// in: body
+	// required: true
+	*db.PresetMap
+
+	// result of the update of each derived preset
+	Derived map[string]derivedPresetUpdate `json:"derived"`
+}
+
+// derivedPresetUpdate describes what happened to a derived preset when the
+// update of its parent was propagated. Status is one of "updated",
+// "unchanged" or "failed".
+type derivedPresetUpdate struct {
+	Status  string `json:"status"`
+	Version uint   `json:"version,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/NYTimes/gizmo/web"
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/provider"
+	"github.com/NYTimes/video-transcoding-api/swagger"
+)
+
+// swagger:route POST /presets/{name}/providers/{provider} presets addPresetProvider
+//
+// Creates the canonical version of an existing preset on an additional
+// provider, adding the new preset to the preset map.
+//
+//     Responses:
+//       200: preset
+//       404: presetNotFound
+//       409: genericError
+//       500: genericError
+func (s *TranscodingService) addPresetProvider(r *http.Request) swagger.GizmoJSONResponse {
+	var params addPresetProviderInput
+	params.loadParams(web.Vars(r))
+	presetMap, err := s.db.GetPresetMap(params.Name)
+	switch err {
+	case nil:
+	case db.ErrPresetMapNotFound:
+		return newPresetMapNotFoundResponse(err)
+	default:
+		return swagger.NewErrorResponse(err)
+	}
+	if _, err = provider.GetProviderFactory(params.Provider); err != nil {
+		return newProviderNotFoundResponse(err)
+	}
+	if presetID, ok := presetMap.ProviderMapping[params.Provider]; ok {
+		err = fmt.Errorf("preset already exists in %s with id %q", params.Provider, presetID)
+		return swagger.NewErrorResponse(err).WithStatus(http.StatusConflict)
+	}
+	preset, err := s.canonicalPreset(presetMap)
+	if err != nil {
+		return swagger.NewErrorResponse(err).WithStatus(http.StatusConflict)
+	}
+	creation := s.createProviderPresets(*preset, []string{params.Provider}, true)
+	if creation.failed {
+		return swagger.NewErrorResponse(errors.New(creation.results[params.Provider].Error))
+	}
+	if presetMap.ProviderMapping == nil {
+		presetMap.ProviderMapping = make(map[string]string)
+	}
+	presetMap.ProviderMapping[params.Provider] = creation.mapping[params.Provider]
+	err = s.savePresetMap(presetMap, r.Header.Get(authorHeader), s.db.UpdatePresetMap)
+	if err != nil {
+		deleteCreatedPresets(creation.providers, creation.mapping)
+		return swagger.NewErrorResponse(err)
+	}
+	return newPresetMapResponse(presetMap)
+}
+
+// canonicalPreset returns the canonical version of the preset in the given
+// preset map. Preset maps created before canonical presets were stored get
+// the normalized preset of one of their providers, which is also set in the
+// preset map so it can be saved.
+func (s *TranscodingService) canonicalPreset(presetMap *db.PresetMap) (*db.Preset, error) {
+	if presetMap.Preset != nil {
+		return presetMap.Preset, nil
+	}
+	preset := s.normalizedPreset(*presetMap, sortedProviderNames(presetMap.ProviderMapping))
+	if preset == nil {
+		return nil, errNoCanonicalPreset
+	}
+	presetMap.Preset = preset
+	return preset, nil
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/NYTimes/gizmo/server"
+	"github.com/NYTimes/video-transcoding-api/config"
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/db/dbtest"
+	"github.com/Sirupsen/logrus"
+)
+
+func TestAddPresetProvider(t *testing.T) {
+	canonical := db.Preset{
+		Name:      "mp4_720p",
+		Container: "mp4",
+		Video:     db.VideoPreset{Codec: "h264", Height: "720"},
+		Audio:     db.AudioPreset{Codec: "aac", Bitrate: "128000"},
+	}
+	tests := []struct {
+		givenTestCase   string
+		givenPresetName string
+		givenProvider   string
+
+		wantCode      int
+		wantError     string
+		wantPresetMap *db.PresetMap
+	}{
+		{
+			"Add preset to provider",
+			"mp4_720p",
+			"fakeupdater",
+
+			http.StatusOK,
+			"",
+			&db.PresetMap{
+				Name:            "mp4_720p",
+				Preset:          &canonical,
+				ProviderMapping: map[string]string{"fake": "mp4-fake", "fakeupdater": "presetID_here"},
+				OutputOpts:      db.OutputOptions{Extension: "mp4"},
+				Version:         1,
+			},
+		},
+		{
+			"Preset without canonical version",
+			"legacy",
+			"fakeupdater",
+
+			http.StatusOK,
+			"",
+			&db.PresetMap{
+				Name: "legacy",
+				Preset: &db.Preset{
+					Name:      "legacy",
+					Container: "mp4",
+					Video:     db.VideoPreset{Codec: "h264", Height: "720"},
+					Audio:     db.AudioPreset{Codec: "aac", Bitrate: "64000"},
+				},
+				ProviderMapping: map[string]string{"fake": "legacy-id", "fakeupdater": "presetID_here"},
+				OutputOpts:      db.OutputOptions{Extension: "mp4"},
+				Version:         1,
+			},
+		},
+		{
+			"Codec not supported by the provider",
+			"mp4_av1",
+			"fakeupdater",
+
+			http.StatusInternalServerError,
+			`video codec "av1" is not supported by fakeupdater. Supported codecs are: h264, h265, vp8, vp9`,
+			nil,
+		},
+		{
+			"Preset already in the provider",
+			"mp4_720p",
+			"fake",
+
+			http.StatusConflict,
+			`preset already exists in fake with id "mp4-fake"`,
+			nil,
+		},
+		{
+			"Provider not found",
+			"mp4_720p",
+			"unknown",
+
+			http.StatusNotFound,
+			"provider not found",
+			nil,
+		},
+		{
+			"Preset not found",
+			"preset-unknown",
+			"fakeupdater",
+
+			http.StatusNotFound,
+			"presetmap not found",
+			nil,
+		},
+	}
+	for _, test := range tests {
+		srvr := server.NewSimpleServer(&server.Config{RouterType: "fast"})
+		fakeDB := dbtest.NewFakeRepository(false)
+		presetMaps := []db.PresetMap{
+			{
+				Name:            "mp4_720p",
+				Preset:          &canonical,
+				ProviderMapping: map[string]string{"fake": "mp4-fake"},
+				OutputOpts:      db.OutputOptions{Extension: "mp4"},
+			},
+			{
+				Name:            "mp4_av1",
+				Preset:          &db.Preset{Name: "mp4_av1", Container: "mp4", Video: db.VideoPreset{Codec: "av1"}},
+				ProviderMapping: map[string]string{"fake": "av1-fake"},
+				OutputOpts:      db.OutputOptions{Extension: "mp4"},
+			},
+			{
+				Name:            "legacy",
+				ProviderMapping: map[string]string{"fake": "legacy-id"},
+				OutputOpts:      db.OutputOptions{Extension: "mp4"},
+			},
+		}
+		for i := range presetMaps {
+			fakeDB.CreatePresetMap(&presetMaps[i])
+		}
+		service, err := NewTranscodingService(&config.Config{}, logrus.New())
+		if err != nil {
+			t.Fatal(err)
+		}
+		service.db = fakeDB
+		srvr.Register(service)
+		r, _ := http.NewRequest("POST", "/presets/"+test.givenPresetName+"/providers/"+test.givenProvider, nil)
+		w := httptest.NewRecorder()
+		srvr.ServeHTTP(w, r)
+		if w.Code != test.wantCode {
+			t.Errorf("%s: wrong response code. Want %d. Got %d", test.givenTestCase, test.wantCode, w.Code)
+		}
+		if test.wantPresetMap == nil {
+			var got map[string]interface{}
+			err = json.NewDecoder(w.Body).Decode(&got)
+			if err != nil {
+				t.Errorf("%s: unable to JSON decode response body: %s", test.givenTestCase, err)
+			}
+			if got["error"] != test.wantError {
+				t.Errorf("%s: wrong error. Want %q. Got %q", test.givenTestCase, test.wantError, got["error"])
+			}
+			continue
+		}
+		var gotPresetMap db.PresetMap
+		err = json.NewDecoder(w.Body).Decode(&gotPresetMap)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(gotPresetMap, *test.wantPresetMap) {
+			t.Errorf("%s: wrong body\nWant %#v\nGot  %#v", test.givenTestCase, *test.wantPresetMap, gotPresetMap)
+		}
+		presetMap, err := fakeDB.GetPresetMap(test.givenPresetName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(*presetMap, *test.wantPresetMap) {
+			t.Errorf("%s: didn't update the preset map in the database\nWant %#v\nGot  %#v", test.givenTestCase, *test.wantPresetMap, *presetMap)
+		}
+	}
+}
@@ -1,8 +1,11 @@
 package service
 
 import (
+	"fmt"
 	"net/http"
+	"strings"
 
+	"github.com/NYTimes/video-transcoding-api/db"
 	"github.com/NYTimes/video-transcoding-api/swagger"
 )
 
@@ -14,6 +17,30 @@ type deletePresetResponse struct {
 	baseResponse
 }
 
+type presetDetailsResponse struct {
+	baseResponse
+}
+
+type importPresetsResponse struct {
+	baseResponse
+}
+
+type presetDriftResponse struct {
+	baseResponse
+}
+
+type presetVersionsResponse struct {
+	baseResponse
+}
+
+type ladderResponse struct {
+	baseResponse
+}
+
+type propagatedPresetResponse struct {
+	baseResponse
+}
+
 // error returned when the given preset data is not valid.
 //
 // swagger:response invalidPreset
@@ -29,3 +56,62 @@ func newInvalidPresetResponse(err error) *invalidPresetResponse {
 func (r *invalidPresetResponse) Result() (int, interface{}, error) {
 	return r.Error.Result()
 }
+
+// error returned when the fields of the given preset are not valid, listing
+// the problem found in each field.
+//
+// swagger:response invalidPresetFields
+type invalidPresetFieldsResponse struct {
+	// in: body
+	Payload *invalidPresetFields
+
+	baseResponse
+}
+
+type invalidPresetFields struct {
+	Error  string          `json:"error"`
+	Fields []db.FieldError `json:"fields"`
+}
+
+// newInvalidPresetFieldsResponse returns the response for errors returned by
+// db.Preset.Validate, including field-level details when available.
+func newInvalidPresetFieldsResponse(err error) swagger.GizmoJSONResponse {
+	verr, ok := err.(*db.PresetValidationError)
+	if !ok {
+		return newInvalidPresetResponse(err)
+	}
+	return &invalidPresetFieldsResponse{
+		baseResponse: baseResponse{
+			payload: &invalidPresetFields{Error: verr.Error(), Fields: verr.Fields},
+			status:  http.StatusBadRequest,
+		},
+	}
+}
+
+// error returned when deleting a preset that is used by queued or running
+// jobs, listing the IDs of the jobs.
+//
+// swagger:response presetInUse
+type presetInUseResponse struct {
+	// in: body
+	Payload *presetInUse
+
+	baseResponse
+}
+
+type presetInUse struct {
+	Error string   `json:"error"`
+	Jobs  []string `json:"jobs"`
+}
+
+func newPresetInUseResponse(jobIDs []string) *presetInUseResponse {
+	return &presetInUseResponse{
+		baseResponse: baseResponse{
+			payload: &presetInUse{
+				Error: fmt.Sprintf("preset is used by active jobs: %s. Use force=true to delete it anyway", strings.Join(jobIDs, ", ")),
+				Jobs:  jobIDs,
+			},
+			status: http.StatusConflict,
+		},
+	}
+}
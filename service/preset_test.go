@@ -7,6 +7,7 @@ import (
 	"net/http/httptest"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/NYTimes/gizmo/server"
 	"github.com/NYTimes/video-transcoding-api/config"
@@ -113,6 +114,92 @@ func TestNewPreset(t *testing.T) {
 			},
 			http.StatusInternalServerError,
 		},
+		{
+			"Create hevc preset",
+			map[string]interface{}{
+				"providers": []string{"fake"},
+				"preset": map[string]interface{}{
+					"name":      "nyt_test_here_hevc",
+					"container": "m3u8",
+					"video":     map[string]string{"codec": "hevc", "height": "2160"},
+					"audio":     map[string]string{"codec": "aac"},
+				},
+			},
+			db.OutputOptions{Extension: "m3u8"},
+			map[string]interface{}{
+				"Results": map[string]interface{}{
+					"fake": map[string]interface{}{
+						"PresetID": "presetID_here",
+						"Error":    "",
+					},
+				},
+				"PresetMap": "nyt_test_here_hevc",
+			},
+			http.StatusOK,
+		},
+		{
+			"Create av1 preset with a provider that doesn't support the codec",
+			map[string]interface{}{
+				"providers": []string{"fake", "fakeupdater"},
+				"preset": map[string]interface{}{
+					"name":      "nyt_test_here_av1",
+					"container": "mp4",
+					"video":     map[string]string{"codec": "av1", "height": "1080"},
+				},
+			},
+			db.OutputOptions{Extension: "mp4"},
+			map[string]interface{}{
+				"Results": map[string]interface{}{
+					"fake": map[string]interface{}{
+						"PresetID": "presetID_here",
+						"Error":    "",
+					},
+					"fakeupdater": map[string]interface{}{
+						"PresetID": "",
+						"Error":    `video codec "av1" is not supported by fakeupdater. Supported codecs are: h264, h265, vp8, vp9`,
+					},
+				},
+				"PresetMap": "nyt_test_here_av1",
+			},
+			http.StatusOK,
+		},
+		{
+			"All-or-nothing creation failing in a provider",
+			map[string]interface{}{
+				"providers":    []string{"fake", "encodingcom"},
+				"allOrNothing": true,
+				"preset": map[string]interface{}{
+					"name":      "nyt_test_here_4wq",
+					"container": "mp4",
+					"video": map[string]string{
+						"height": "720",
+						"codec":  "h264",
+					},
+				},
+			},
+			db.OutputOptions{},
+			map[string]interface{}{
+				"Results": map[string]interface{}{
+					"fake": map[string]interface{}{
+						"PresetID": "presetID_here",
+						"Error":    "",
+					},
+					"encodingcom": map[string]interface{}{
+						"PresetID": "",
+						"Error":    "getting factory: provider not found",
+					},
+				},
+				"PresetMap": "",
+				"Compensations": []interface{}{
+					map[string]interface{}{
+						"Provider": "fake",
+						"PresetID": "presetID_here",
+						"Action":   "delete preset",
+					},
+				},
+			},
+			http.StatusInternalServerError,
+		},
 	}
 
 	for _, test := range tests {
@@ -149,18 +236,414 @@ func TestNewPreset(t *testing.T) {
 			if !reflect.DeepEqual(presetMap.OutputOpts, test.wantOutputOpts) {
 				t.Errorf("%s: wrong output options saved.\nWant %#v\nGot  %#v", test.givenTestCase, test.wantOutputOpts, presetMap.OutputOpts)
 			}
+			if presetMap.Preset == nil || presetMap.Preset.Name != name {
+				t.Errorf("%s: canonical preset not saved. Got %#v", test.givenTestCase, presetMap.Preset)
+			} else if codec := presetMap.Preset.Video.Codec; codec == "hevc" {
+				t.Errorf("%s: video codec alias not normalized. Got %q", test.givenTestCase, codec)
+			}
+		} else if _, err := fakeDB.GetPresetMap(name); err != db.ErrPresetMapNotFound {
+			t.Errorf("%s: wrong error when getting the preset map. Want ErrPresetMapNotFound. Got %#v", test.givenTestCase, err)
 		}
 	}
 }
 
+func TestNewPresetInvalidFields(t *testing.T) {
+	srvr := server.NewSimpleServer(&server.Config{RouterType: "fast"})
+	fakeDB := dbtest.NewFakeRepository(false)
+	service, err := NewTranscodingService(&config.Config{}, logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.db = fakeDB
+	srvr.Register(service)
+	body, _ := json.Marshal(map[string]interface{}{
+		"providers": []string{"fake"},
+		"preset": map[string]interface{}{
+			"name":      "webm_720p",
+			"container": "webm",
+			"video":     map[string]string{"codec": "h264", "height": "720p"},
+		},
+	})
+	r, _ := http.NewRequest("POST", "/presets", bytes.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	srvr.ServeHTTP(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("wrong response code. Want %d. Got %d", http.StatusBadRequest, w.Code)
+	}
+	var got map[string]interface{}
+	err = json.NewDecoder(w.Body).Decode(&got)
+	if err != nil {
+		t.Fatalf("unable to JSON decode response body: %s", err)
+	}
+	expected := map[string]interface{}{
+		"error": `invalid preset: video.codec: codec "h264" can't be used in webm outputs; video.height: invalid value "720p". It must be a positive integer`,
+		"fields": []interface{}{
+			map[string]interface{}{"field": "video.codec", "message": `codec "h264" can't be used in webm outputs`},
+			map[string]interface{}{"field": "video.height", "message": `invalid value "720p". It must be a positive integer`},
+		},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("wrong response body\nWant %#v\nGot  %#v", expected, got)
+	}
+	if _, err = fakeDB.GetPresetMap("webm_720p"); err != db.ErrPresetMapNotFound {
+		t.Errorf("wrong error when getting the preset map. Want ErrPresetMapNotFound. Got %#v", err)
+	}
+}
+
+func TestNewPresetAllOrNothingPresetMapError(t *testing.T) {
+	fprovider.deletedPresets = nil
+	srvr := server.NewSimpleServer(&server.Config{RouterType: "fast"})
+	fakeDB := dbtest.NewFakeRepository(false)
+	fakeDB.CreatePresetMap(&db.PresetMap{Name: "nyt_test_here_5wq"})
+	service, err := NewTranscodingService(&config.Config{}, logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.db = fakeDB
+	srvr.Register(service)
+	body, _ := json.Marshal(map[string]interface{}{
+		"providers":    []string{"fake"},
+		"allOrNothing": true,
+		"preset":       map[string]interface{}{"name": "nyt_test_here_5wq", "container": "mp4"},
+	})
+	r, _ := http.NewRequest("POST", "/presets", bytes.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	srvr.ServeHTTP(w, r)
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("wrong response code. Want %d. Got %d", http.StatusInternalServerError, w.Code)
+	}
+	var got map[string]interface{}
+	err = json.NewDecoder(w.Body).Decode(&got)
+	if err != nil {
+		t.Fatalf("unable to JSON decode response body: %s", err)
+	}
+	if got["PresetMap"] != "error: presetmap already exists" {
+		t.Errorf("wrong presetMap in the response. Want %q. Got %#v", "error: presetmap already exists", got["PresetMap"])
+	}
+	expectedDeleted := []string{"presetID_here"}
+	if !reflect.DeepEqual(fprovider.deletedPresets, expectedDeleted) {
+		t.Errorf("wrong deleted presets. Want %#v. Got %#v", expectedDeleted, fprovider.deletedPresets)
+	}
+}
+
+func TestGetPresetDetails(t *testing.T) {
+	tests := []struct {
+		givenTestCase   string
+		givenPresetName string
+		wantBody        map[string]interface{}
+		wantCode        int
+	}{
+		{
+			"Get preset",
+			"abc-321",
+			map[string]interface{}{
+				"name": "abc-321",
+				"preset": map[string]interface{}{
+					"name":      "abc-321",
+					"container": "mp4",
+					"video":     map[string]interface{}{"codec": "h264", "height": "720"},
+					"audio":     map[string]interface{}{"codec": "aac", "bitrate": "64000"},
+					"overlay":   map[string]interface{}{},
+				},
+				"providerMapping": map[string]interface{}{
+					"fake":        "presetID_here",
+					"encodingcom": "12345",
+				},
+				"output": map[string]interface{}{"extension": "mp4"},
+				"providers": map[string]interface{}{
+					"fake": map[string]interface{}{
+						"presetId": "presetID_here",
+						"preset": map[string]interface{}{
+							"name":      "presetID_here",
+							"container": "mp4",
+							"video":     map[string]interface{}{"codec": "h264", "height": "720"},
+							"audio":     map[string]interface{}{"codec": "aac", "bitrate": "64000"},
+							"overlay":   map[string]interface{}{},
+						},
+					},
+					"encodingcom": map[string]interface{}{
+						"presetId": "12345",
+						"error":    "getting factory: provider not found",
+					},
+				},
+			},
+			http.StatusOK,
+		},
+		{
+			"Get preset not found",
+			"preset-unknown",
+			map[string]interface{}{"error": "presetmap not found"},
+			http.StatusNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		srvr := server.NewSimpleServer(&server.Config{RouterType: "fast"})
+		fakeDB := dbtest.NewFakeRepository(false)
+		fakeDB.CreatePresetMap(&db.PresetMap{
+			Name:            "abc-321",
+			ProviderMapping: map[string]string{"fake": "presetID_here", "encodingcom": "12345"},
+			OutputOpts:      db.OutputOptions{Extension: "mp4"},
+			Preset: &db.Preset{
+				Name:      "abc-321",
+				Container: "mp4",
+				Video:     db.VideoPreset{Codec: "h264", Height: "720"},
+				Audio:     db.AudioPreset{Codec: "aac", Bitrate: "64000"},
+			},
+		})
+		service, err := NewTranscodingService(&config.Config{}, logrus.New())
+		if err != nil {
+			t.Fatal(err)
+		}
+		service.db = fakeDB
+		srvr.Register(service)
+		r, _ := http.NewRequest("GET", "/presets/"+test.givenPresetName, nil)
+		w := httptest.NewRecorder()
+		srvr.ServeHTTP(w, r)
+		if w.Code != test.wantCode {
+			t.Errorf("%s: wrong response code. Want %d. Got %d", test.givenTestCase, test.wantCode, w.Code)
+		}
+		var got map[string]interface{}
+		err = json.NewDecoder(w.Body).Decode(&got)
+		if err != nil {
+			t.Errorf("%s: unable to JSON decode response body: %s", test.givenTestCase, err)
+		}
+		if !reflect.DeepEqual(got, test.wantBody) {
+			t.Errorf("%s: expected response body of\n%#v;\ngot\n%#v", test.givenTestCase, test.wantBody, got)
+		}
+	}
+}
+
+func TestUpdatePreset(t *testing.T) {
+	tests := []struct {
+		givenTestCase    string
+		givenPresetName  string
+		givenRequestData map[string]interface{}
+		wantCode         int
+		wantMapping      map[string]string
+		wantDeleted      []string
+		wantBitrate      string
+	}{
+		{
+			"Update preset",
+			"abc-321",
+			map[string]interface{}{
+				"container": "mp4",
+				"video":     map[string]string{"codec": "h264", "bitrate": "2000000"},
+			},
+			http.StatusOK,
+			map[string]string{"fake": "presetID_here", "fakeupdater": "updater-id"},
+			[]string{"old-fake-id"},
+			"2000000",
+		},
+		{
+			"Update preset failing in a provider",
+			"abc-321",
+			map[string]interface{}{
+				"description": "fail update",
+				"container":   "mp4",
+				"video":       map[string]string{"codec": "h264", "bitrate": "2000000"},
+			},
+			http.StatusInternalServerError,
+			map[string]string{"fake": "old-fake-id", "fakeupdater": "updater-id"},
+			[]string{"presetID_here"},
+			"1000000",
+		},
+		{
+			"Update preset with invalid fields",
+			"abc-321",
+			map[string]interface{}{
+				"container": "mp4",
+				"video":     map[string]string{"codec": "h264", "bitrate": "2M"},
+			},
+			http.StatusBadRequest,
+			map[string]string{"fake": "old-fake-id", "fakeupdater": "updater-id"},
+			nil,
+			"1000000",
+		},
+		{
+			"Update preset with mismatching name",
+			"abc-321",
+			map[string]interface{}{
+				"name":      "other-preset",
+				"container": "mp4",
+			},
+			http.StatusBadRequest,
+			map[string]string{"fake": "old-fake-id", "fakeupdater": "updater-id"},
+			nil,
+			"1000000",
+		},
+		{
+			"Update preset not found",
+			"preset-unknown",
+			map[string]interface{}{"container": "mp4"},
+			http.StatusNotFound,
+			map[string]string{"fake": "old-fake-id", "fakeupdater": "updater-id"},
+			nil,
+			"1000000",
+		},
+	}
+
+	for _, test := range tests {
+		oldPreset := db.Preset{
+			Name:      "abc-321",
+			Container: "mp4",
+			Video:     db.VideoPreset{Codec: "h264", Bitrate: "1000000"},
+		}
+		fprovider.deletedPresets = nil
+		fupdater.presets = map[string]db.Preset{"updater-id": oldPreset}
+		srvr := server.NewSimpleServer(&server.Config{RouterType: "fast"})
+		fakeDB := dbtest.NewFakeRepository(false)
+		fakeDB.CreatePresetMap(&db.PresetMap{
+			Name:            "abc-321",
+			ProviderMapping: map[string]string{"fake": "old-fake-id", "fakeupdater": "updater-id"},
+			OutputOpts:      db.OutputOptions{Extension: "mp4"},
+			Preset:          &oldPreset,
+		})
+		service, err := NewTranscodingService(&config.Config{}, logrus.New())
+		if err != nil {
+			t.Fatal(err)
+		}
+		service.db = fakeDB
+		srvr.Register(service)
+		body, _ := json.Marshal(test.givenRequestData)
+		r, _ := http.NewRequest("PUT", "/presets/"+test.givenPresetName, bytes.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		srvr.ServeHTTP(w, r)
+		if w.Code != test.wantCode {
+			t.Errorf("%s: wrong response code. Want %d. Got %d", test.givenTestCase, test.wantCode, w.Code)
+		}
+		presetMap, err := fakeDB.GetPresetMap("abc-321")
+		if err != nil {
+			t.Fatalf("%s: %s", test.givenTestCase, err)
+		}
+		if !reflect.DeepEqual(presetMap.ProviderMapping, test.wantMapping) {
+			t.Errorf("%s: wrong provider mapping.\nWant %#v\nGot  %#v", test.givenTestCase, test.wantMapping, presetMap.ProviderMapping)
+		}
+		if presetMap.Preset.Video.Bitrate != test.wantBitrate {
+			t.Errorf("%s: wrong bitrate in the canonical preset. Want %q. Got %q", test.givenTestCase, test.wantBitrate, presetMap.Preset.Video.Bitrate)
+		}
+		if !reflect.DeepEqual(fprovider.deletedPresets, test.wantDeleted) {
+			t.Errorf("%s: wrong deleted presets. Want %#v. Got %#v", test.givenTestCase, test.wantDeleted, fprovider.deletedPresets)
+		}
+		if bitrate := fupdater.presets["updater-id"].Video.Bitrate; bitrate != test.wantBitrate {
+			t.Errorf("%s: wrong bitrate in the provider preset. Want %q. Got %q", test.givenTestCase, test.wantBitrate, bitrate)
+		}
+	}
+}
+
+// racingRepository saves the given preset map right before replacing a
+// preset map, simulating a concurrent update of the preset.
+type racingRepository struct {
+	db.Repository
+	winner *db.PresetMap
+}
+
+func (r *racingRepository) ReplacePresetMap(presetMap *db.PresetMap, version uint) error {
+	if err := r.Repository.UpdatePresetMap(r.winner); err != nil {
+		return err
+	}
+	return r.Repository.ReplacePresetMap(presetMap, version)
+}
+
+func TestUpdatePresetConcurrentUpdate(t *testing.T) {
+	oldPreset := db.Preset{
+		Name:      "abc-321",
+		Container: "mp4",
+		Video:     db.VideoPreset{Codec: "h264", Bitrate: "1000000"},
+	}
+	winnerPreset := oldPreset
+	winnerPreset.Video.Bitrate = "3000000"
+	fprovider.deletedPresets = nil
+	fupdater.presets = map[string]db.Preset{"updater-id": oldPreset}
+	srvr := server.NewSimpleServer(&server.Config{RouterType: "fast"})
+	fakeDB := dbtest.NewFakeRepository(false)
+	fakeDB.CreatePresetMap(&db.PresetMap{
+		Name:            "abc-321",
+		ProviderMapping: map[string]string{"fake": "old-fake-id", "fakeupdater": "updater-id"},
+		OutputOpts:      db.OutputOptions{Extension: "mp4"},
+		Preset:          &oldPreset,
+		Version:         1,
+	})
+	winner := db.PresetMap{
+		Name:            "abc-321",
+		ProviderMapping: map[string]string{"fake": "winner-id", "fakeupdater": "updater-id"},
+		OutputOpts:      db.OutputOptions{Extension: "mp4"},
+		Preset:          &winnerPreset,
+		Version:         2,
+	}
+	service, err := NewTranscodingService(&config.Config{}, logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.db = &racingRepository{Repository: fakeDB, winner: &winner}
+	srvr.Register(service)
+	body, _ := json.Marshal(map[string]interface{}{
+		"container": "mp4",
+		"video":     map[string]string{"codec": "h264", "bitrate": "2000000"},
+	})
+	r, _ := http.NewRequest("PUT", "/presets/abc-321", bytes.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	srvr.ServeHTTP(w, r)
+	if w.Code != http.StatusConflict {
+		t.Errorf("wrong response code. Want %d. Got %d", http.StatusConflict, w.Code)
+	}
+	presetMap, err := fakeDB.GetPresetMap("abc-321")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(presetMap.ProviderMapping, winner.ProviderMapping) {
+		t.Errorf("wrong provider mapping.\nWant %#v\nGot  %#v", winner.ProviderMapping, presetMap.ProviderMapping)
+	}
+	wantDeleted := []string{"presetID_here"}
+	if !reflect.DeepEqual(fprovider.deletedPresets, wantDeleted) {
+		t.Errorf("wrong deleted presets. Want %#v. Got %#v", wantDeleted, fprovider.deletedPresets)
+	}
+	if bitrate := fupdater.presets["updater-id"].Video.Bitrate; bitrate != "3000000" {
+		t.Errorf("wrong bitrate in the provider preset. Want %q. Got %q", "3000000", bitrate)
+	}
+}
+
 func TestDeletePreset(t *testing.T) {
 	tests := []struct {
 		givenTestCase string
+		givenQuery    string
+		givenJobs     []db.Job
 		wantBody      map[string]interface{}
 		wantCode      int
 	}{
 		{
 			"Delete a preset",
+			"",
+			nil,
+			map[string]interface{}{
+				"results": map[string]interface{}{
+					"fake": map[string]interface{}{
+						"presetId": "presetID_here",
+					},
+				},
+				"presetMap": "removed successfully",
+			},
+			http.StatusOK,
+		},
+		{
+			"Delete a preset used by active jobs",
+			"",
+			presetJobs(),
+			map[string]interface{}{
+				"error": "preset is used by active jobs: job-active, job-unknown-provider, job-old. Use force=true to delete it anyway",
+				"jobs":  []interface{}{"job-active", "job-unknown-provider", "job-old"},
+			},
+			http.StatusConflict,
+		},
+		{
+			"Force the deletion of a preset used by active jobs",
+			"?force=true",
+			presetJobs(),
 			map[string]interface{}{
 				"results": map[string]interface{}{
 					"fake": map[string]interface{}{
@@ -171,6 +654,15 @@ func TestDeletePreset(t *testing.T) {
 			},
 			http.StatusOK,
 		},
+		{
+			"Delete a preset with invalid force parameter",
+			"?force=maybe",
+			nil,
+			map[string]interface{}{
+				"error": `invalid value for force: "maybe"`,
+			},
+			http.StatusBadRequest,
+		},
 	}
 
 	for _, test := range tests {
@@ -179,13 +671,16 @@ func TestDeletePreset(t *testing.T) {
 		fakeProviderMapping := make(map[string]string)
 		fakeProviderMapping["fake"] = "presetID_here"
 		fakeDB.CreatePresetMap(&db.PresetMap{Name: "abc-321", ProviderMapping: fakeProviderMapping})
+		for i := range test.givenJobs {
+			fakeDB.CreateJob(&test.givenJobs[i])
+		}
 		service, err := NewTranscodingService(&config.Config{}, logrus.New())
 		if err != nil {
 			t.Fatal(err)
 		}
 		service.db = fakeDB
 		srvr.Register(service)
-		r, _ := http.NewRequest("DELETE", "/presets/abc-321", nil)
+		r, _ := http.NewRequest("DELETE", "/presets/abc-321"+test.givenQuery, nil)
 		r.Header.Set("Content-Type", "application/json")
 		w := httptest.NewRecorder()
 		srvr.ServeHTTP(w, r)
@@ -200,5 +695,62 @@ func TestDeletePreset(t *testing.T) {
 		if !reflect.DeepEqual(got, test.wantBody) {
 			t.Errorf("%s: expected response body of\n%#v;\ngot\n%#v", test.givenTestCase, test.wantBody, got)
 		}
+		_, err = fakeDB.GetPresetMap("abc-321")
+		if deleted := err == db.ErrPresetMapNotFound; deleted != (test.wantCode == http.StatusOK) {
+			t.Errorf("%s: wrong state of the preset map. Deleted: %v", test.givenTestCase, deleted)
+		}
+	}
+}
+
+func TestDeletePresetRecordsJobStatus(t *testing.T) {
+	srvr := server.NewSimpleServer(&server.Config{RouterType: "fast"})
+	fakeDB := dbtest.NewFakeRepository(false)
+	fakeDB.CreatePresetMap(&db.PresetMap{Name: "abc-321", ProviderMapping: map[string]string{"fake": "presetID_here"}})
+	jobs := presetJobs()
+	for i := range jobs {
+		fakeDB.CreateJob(&jobs[i])
+	}
+	service, err := NewTranscodingService(&config.Config{}, logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.db = fakeDB
+	srvr.Register(service)
+	r, _ := http.NewRequest("DELETE", "/presets/abc-321", nil)
+	w := httptest.NewRecorder()
+	srvr.ServeHTTP(w, r)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("wrong response code. Want %d. Got %d", http.StatusConflict, w.Code)
+	}
+	expectedStatuses := map[string]string{
+		"job-active":         "started",
+		"job-finished":       "finished",
+		"job-gone":           "",
+		"job-recorded-final": "finished",
+		"job-other-preset":   "",
+		"job-old":            "started",
+	}
+	for id, expected := range expectedStatuses {
+		job, err := fakeDB.GetJob(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.Status != expected {
+			t.Errorf("%s: wrong recorded status. Want %q. Got %q", id, expected, job.Status)
+		}
+	}
+}
+
+// presetJobs returns jobs using the preset abc-321 in different states,
+// along with an active job that uses another preset.
+func presetJobs() []db.Job {
+	return []db.Job{
+		{ID: "job-active", ProviderName: "fake", ProviderJobID: "provider-job-active", Presets: []string{"mp4_720p", "abc-321"}},
+		{ID: "job-finished", ProviderName: "fake", ProviderJobID: "provider-job-123", Presets: []string{"abc-321"}},
+		{ID: "job-gone", ProviderName: "fake", ProviderJobID: "provider-job-gone", Presets: []string{"abc-321"}},
+		{ID: "job-unknown-provider", ProviderName: "unknown", ProviderJobID: "provider-job-active", Presets: []string{"abc-321"}},
+		{ID: "job-other-preset", ProviderName: "fake", ProviderJobID: "provider-job-active", Presets: []string{"mp4_720p"}},
+		{ID: "job-old", ProviderName: "fake", ProviderJobID: "provider-job-active", Presets: []string{"abc-321"}, CreationTime: time.Now().Add(-30 * 24 * time.Hour)},
+		{ID: "job-recorded-final", ProviderName: "fake", ProviderJobID: "provider-job-active", Presets: []string{"abc-321"}, Status: "finished"},
 	}
 }
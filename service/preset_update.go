@@ -0,0 +1,190 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/provider"
+)
+
+// presetRestore holds what's needed to restore a preset that was updated
+// in place.
+type presetRestore struct {
+	updater  provider.PresetUpdater
+	presetID string
+	previous db.Preset
+}
+
+// presetUpdate keeps track of the changes made to the presets of each
+// provider while updating a preset, so they can be undone if the update
+// fails in any of the providers.
+type presetUpdate struct {
+	mapping   map[string]string
+	replaced  map[string]string
+	created   map[string]string
+	updated   map[string]presetRestore
+	providers map[string]provider.TranscodingProvider
+	undo      []func() error
+}
+
+// updateProviderPresets updates the given preset in all providers of the
+// preset map, returning the resulting provider mapping. In case of failure,
+// changes that were already made are rolled back.
+func (s *TranscodingService) updateProviderPresets(presetMap *db.PresetMap, preset db.Preset) (*presetUpdate, error) {
+	update := presetUpdate{
+		mapping:   make(map[string]string, len(presetMap.ProviderMapping)),
+		replaced:  make(map[string]string),
+		created:   make(map[string]string),
+		updated:   make(map[string]presetRestore),
+		providers: make(map[string]provider.TranscodingProvider),
+	}
+	names := make([]string, 0, len(presetMap.ProviderMapping))
+	for name := range presetMap.ProviderMapping {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		err := update.updateProvider(s, name, presetMap.ProviderMapping[name], preset, presetMap.Preset)
+		if err != nil {
+			s.rollbackPresetUpdate(&update)
+			return nil, fmt.Errorf("updating preset in %s: %s", name, err)
+		}
+	}
+	return &update, nil
+}
+
+// replacePreset updates the presets of all providers in the given preset
+// map to match the canonical preset of updated, which is then saved as a new
+// version of the preset. The providers are rolled back if the preset map
+// can't be saved. The preset map is only saved if it wasn't saved by another
+// request since it was read, otherwise db.ErrPresetMapChanged is returned
+// and the presets created by this update are discarded.
+func (s *TranscodingService) replacePreset(presetMap, updated *db.PresetMap, author string) error {
+	update, err := s.updateProviderPresets(presetMap, *updated.Preset)
+	if err != nil {
+		return err
+	}
+	updated.ProviderMapping = update.mapping
+	err = s.savePresetMap(updated, author, func(p *db.PresetMap) error {
+		return s.db.ReplacePresetMap(p, presetMap.Version)
+	})
+	if err == db.ErrPresetMapChanged {
+		s.discardPresetUpdate(presetMap.Name, update)
+		return err
+	}
+	if err != nil {
+		s.rollbackPresetUpdate(update)
+		return err
+	}
+	s.cleanupPresetUpdate(updated.Name, update)
+	return nil
+}
+
+func (u *presetUpdate) updateProvider(s *TranscodingService, name, presetID string, preset db.Preset, previous *db.Preset) error {
+	providerFactory, err := provider.GetProviderFactory(name)
+	if err != nil {
+		return fmt.Errorf("getting factory: %s", err)
+	}
+	providerObj, err := providerFactory(s.config)
+	if err != nil {
+		return fmt.Errorf("initializing provider: %s", err)
+	}
+	u.providers[name] = providerObj
+	if err = provider.CheckVideoCodec(name, providerObj.Capabilities(), preset); err != nil {
+		return err
+	}
+	if updater, ok := providerObj.(provider.PresetUpdater); ok {
+		if previous == nil {
+			previous, err = providerObj.GetNormalizedPreset(presetID)
+			if err != nil {
+				return fmt.Errorf("getting preset: %s", err)
+			}
+		}
+		old := *previous
+		err = updater.UpdatePreset(presetID, preset)
+		if err != nil {
+			return err
+		}
+		u.mapping[name] = presetID
+		u.updated[name] = presetRestore{updater: updater, presetID: presetID, previous: old}
+		u.undo = append(u.undo, func() error {
+			return updater.UpdatePreset(presetID, old)
+		})
+		return nil
+	}
+	newPresetID, err := providerObj.CreatePreset(preset)
+	if err != nil {
+		return fmt.Errorf("creating preset: %s", err)
+	}
+	u.mapping[name] = newPresetID
+	u.replaced[name] = presetID
+	u.created[name] = newPresetID
+	u.undo = append(u.undo, func() error {
+		return providerObj.DeletePreset(newPresetID)
+	})
+	return nil
+}
+
+// rollbackPresetUpdate undoes the changes made by the given update, in
+// reverse order.
+func (s *TranscodingService) rollbackPresetUpdate(update *presetUpdate) {
+	for i := len(update.undo) - 1; i >= 0; i-- {
+		if err := update.undo[i](); err != nil {
+			s.logger.Warnf("unable to roll back preset update: %s", err)
+		}
+	}
+}
+
+// discardPresetUpdate undoes the given update after another request saved
+// the preset map first. Presets created by the update are deleted, as they're
+// not referenced by the saved preset map, while presets updated in place are
+// updated again to match the canonical preset that was saved, or their
+// previous settings when it's not available, so they don't keep the settings
+// of the discarded update.
+func (s *TranscodingService) discardPresetUpdate(name string, update *presetUpdate) {
+	for providerName, presetID := range update.created {
+		if err := update.providers[providerName].DeletePreset(presetID); err != nil {
+			s.logger.Warnf("unable to delete discarded preset %q from %s: %s", presetID, providerName, err)
+		}
+	}
+	if len(update.updated) == 0 {
+		return
+	}
+	current, err := s.db.GetPresetMap(name)
+	if err != nil {
+		s.logger.Warnf("unable to load preset %q, restoring the previous version of its presets: %s", name, err)
+	}
+	for providerName, restore := range update.updated {
+		preset := restore.previous
+		if current != nil && current.Preset != nil {
+			preset = *current.Preset
+		}
+		if err := restore.updater.UpdatePreset(restore.presetID, preset); err != nil {
+			s.logger.Warnf("unable to restore preset %q in %s: %s", restore.presetID, providerName, err)
+		}
+	}
+}
+
+// cleanupPresetUpdate deletes the presets that were replaced by new ones in
+// the given update, except for the presets referenced by versions of the
+// preset that can only be rolled back to by restoring their provider
+// mapping. It should be called only after the preset map is saved.
+func (s *TranscodingService) cleanupPresetUpdate(name string, update *presetUpdate) {
+	if len(update.replaced) == 0 {
+		return
+	}
+	versioned, err := s.versionedPresetIDs(name)
+	if err != nil {
+		s.logger.Warnf("unable to list versions of preset %q, keeping replaced presets: %s", name, err)
+		return
+	}
+	for providerName, presetID := range update.replaced {
+		if versioned[providerName][presetID] {
+			continue
+		}
+		if err := update.providers[providerName].DeletePreset(presetID); err != nil {
+			s.logger.Warnf("unable to delete replaced preset %q from %s: %s", presetID, providerName, err)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/NYTimes/gizmo/web"
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/swagger"
+)
+
+// authorHeader is the header that identifies the author of changes to
+// presets. It's expected to be set by an authenticating proxy in front of
+// the API.
+const authorHeader = "X-Author"
+
+// swagger:route GET /presets/{name}/versions presets listPresetVersions
+//
+// Lists all versions of a preset, from the oldest to the newest. Versions
+// are kept after the preset is deleted.
+//
+//     Responses:
+//       200: presetVersions
+//       404: presetNotFound
+//       500: genericError
+func (s *TranscodingService) listPresetVersions(r *http.Request) swagger.GizmoJSONResponse {
+	var params getPresetMapInput
+	params.loadParams(web.Vars(r))
+	versions, err := s.db.ListPresetVersions(params.Name)
+	if err != nil {
+		return swagger.NewErrorResponse(err)
+	}
+	if len(versions) == 0 {
+		_, err = s.db.GetPresetMap(params.Name)
+		switch err {
+		case nil:
+		case db.ErrPresetMapNotFound:
+			return newPresetMapNotFoundResponse(err)
+		default:
+			return swagger.NewErrorResponse(err)
+		}
+	}
+	return &presetVersionsResponse{
+		baseResponse: baseResponse{
+			payload: presetVersions{Name: params.Name, Versions: versions},
+			status:  http.StatusOK,
+		},
+	}
+}
+
+// swagger:route POST /presets/{name}/rollback/{version} presets rollbackPreset
+//
+// Rolls a preset back to one of its previous versions, which is saved as a
+// new version of the preset.
+//
+// The canonical preset of the version is restored in the providers of the
+// current preset map, the same way the preset is updated, recreating the
+// provider presets that were replaced since. Versions without a canonical
+// preset have their provider mapping restored as is, as their provider
+// presets are never deleted.
+//
+//     Responses:
+//       200: preset
+//       400: genericError
+//       404: genericError
+//       409: genericError
+//       500: genericError
+func (s *TranscodingService) rollbackPreset(r *http.Request) swagger.GizmoJSONResponse {
+	var params rollbackPresetInput
+	if err := params.loadParams(web.Vars(r)); err != nil {
+		return swagger.NewErrorResponse(err).WithStatus(http.StatusBadRequest)
+	}
+	presetMap, err := s.db.GetPresetMap(params.Name)
+	switch err {
+	case nil:
+	case db.ErrPresetMapNotFound:
+		return newPresetMapNotFoundResponse(err)
+	default:
+		return swagger.NewErrorResponse(err)
+	}
+	version, err := s.db.GetPresetVersion(params.Name, params.Version)
+	switch err {
+	case nil:
+	case db.ErrPresetVersionNotFound:
+		return swagger.NewErrorResponse(err).WithStatus(http.StatusNotFound)
+	default:
+		return swagger.NewErrorResponse(err)
+	}
+	if version.Version == presetMap.Version {
+		return newPresetMapResponse(presetMap)
+	}
+	updated := *presetMap
+	updated.OutputOpts = version.OutputOpts
+	updated.Overrides = version.Overrides
+	author := r.Header.Get(authorHeader)
+	if version.Preset == nil {
+		updated.Preset = nil
+		updated.ProviderMapping = copyMapping(version.ProviderMapping)
+		err = s.savePresetMap(&updated, author, s.db.UpdatePresetMap)
+	} else {
+		preset := *version.Preset
+		updated.Preset = &preset
+		err = s.replacePreset(presetMap, &updated, author)
+	}
+	if err == db.ErrPresetMapChanged {
+		return swagger.NewErrorResponse(err).WithStatus(http.StatusConflict)
+	}
+	if err != nil {
+		return swagger.NewErrorResponse(err)
+	}
+	return newPresetMapResponse(&updated)
+}
+
+// savePresetMap saves the given preset map using the given function, which
+// either creates or updates it, and records it as a new version of the
+// preset. The version is recorded first and removed if the preset map can't
+// be saved, so every saved preset map has a version.
+func (s *TranscodingService) savePresetMap(presetMap *db.PresetMap, author string, save func(*db.PresetMap) error) error {
+	version, err := s.db.NextPresetVersion(presetMap.Name)
+	if err != nil {
+		return err
+	}
+	presetMap.Version = version
+	err = s.db.CreatePresetVersion(&db.PresetVersion{
+		Name:            presetMap.Name,
+		Version:         presetMap.Version,
+		Preset:          presetMap.Preset,
+		Overrides:       presetMap.Overrides,
+		ProviderMapping: copyMapping(presetMap.ProviderMapping),
+		OutputOpts:      presetMap.OutputOpts,
+		Author:          author,
+	})
+	if err != nil {
+		return fmt.Errorf("recording version %d of preset %q: %s", version, presetMap.Name, err)
+	}
+	if err = save(presetMap); err != nil {
+		if derr := s.db.DeletePresetVersion(presetMap.Name, version); derr != nil {
+			s.logger.Warnf("unable to remove version %d of preset %q: %s", version, presetMap.Name, derr)
+		}
+		return err
+	}
+	return nil
+}
+
+// versionedPresetIDs returns the provider presets referenced by the versions
+// of the given preset that don't have a canonical preset, indexed by the name
+// of the provider. Rolling back to these versions restores their provider
+// mapping as is, so their presets must be kept in the providers.
+func (s *TranscodingService) versionedPresetIDs(name string) (map[string]map[string]bool, error) {
+	versions, err := s.db.ListPresetVersions(name)
+	if err != nil {
+		return nil, err
+	}
+	presetIDs := make(map[string]map[string]bool)
+	for _, version := range versions {
+		if version.Preset != nil {
+			continue
+		}
+		for providerName, presetID := range version.ProviderMapping {
+			if presetIDs[providerName] == nil {
+				presetIDs[providerName] = make(map[string]bool)
+			}
+			presetIDs[providerName][presetID] = true
+		}
+	}
+	return presetIDs, nil
+}
+
+// copyMapping returns a copy of the given provider mapping, so changes to
+// the mapping of a preset map don't affect the versions recorded for it.
+func copyMapping(mapping map[string]string) map[string]string {
+	mappingCopy := make(map[string]string, len(mapping))
+	for name, presetID := range mapping {
+		mappingCopy[name] = presetID
+	}
+	return mappingCopy
+}
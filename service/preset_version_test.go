@@ -0,0 +1,448 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/NYTimes/gizmo/server"
+	"github.com/NYTimes/video-transcoding-api/config"
+	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/db/dbtest"
+	"github.com/Sirupsen/logrus"
+)
+
+func TestListPresetVersions(t *testing.T) {
+	fprovider.deletedPresets = nil
+	srvr := server.NewSimpleServer(&server.Config{RouterType: "fast"})
+	fakeDB := dbtest.NewFakeRepository(false)
+	service, err := NewTranscodingService(&config.Config{}, logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.db = fakeDB
+	srvr.Register(service)
+	requests := []struct {
+		method string
+		path   string
+		author string
+		body   map[string]interface{}
+	}{
+		{
+			"POST",
+			"/presets",
+			"gopher",
+			map[string]interface{}{
+				"providers": []string{"fake"},
+				"preset":    map[string]interface{}{"name": "mp4_720p", "container": "mp4", "video": map[string]string{"codec": "h264", "bitrate": "1000000"}},
+			},
+		},
+		{
+			"PUT",
+			"/presets/mp4_720p",
+			"gopher2",
+			map[string]interface{}{"container": "mp4", "video": map[string]string{"codec": "h264", "bitrate": "2000000"}},
+		},
+	}
+	for _, req := range requests {
+		body, _ := json.Marshal(req.body)
+		r, _ := http.NewRequest(req.method, req.path, bytes.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set("X-Author", req.author)
+		w := httptest.NewRecorder()
+		srvr.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s %s: wrong response code. Want %d. Got %d", req.method, req.path, http.StatusOK, w.Code)
+		}
+	}
+	r, _ := http.NewRequest("GET", "/presets/mp4_720p/versions", nil)
+	w := httptest.NewRecorder()
+	srvr.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("wrong response code. Want %d. Got %d", http.StatusOK, w.Code)
+	}
+	var got presetVersions
+	err = json.NewDecoder(w.Body).Decode(&got)
+	if err != nil {
+		t.Fatalf("unable to JSON decode response body: %s", err)
+	}
+	if got.Name != "mp4_720p" || len(got.Versions) != 2 {
+		t.Fatalf("wrong versions returned: %#v", got)
+	}
+	for i, expected := range []struct {
+		author  string
+		bitrate string
+	}{{"gopher", "1000000"}, {"gopher2", "2000000"}} {
+		version := got.Versions[i]
+		if version.Version != uint(i+1) {
+			t.Errorf("wrong number of version %d. Got %d", i+1, version.Version)
+		}
+		if version.Author != expected.author {
+			t.Errorf("wrong author of version %d. Want %q. Got %q", i+1, expected.author, version.Author)
+		}
+		if version.Preset == nil || version.Preset.Video.Bitrate != expected.bitrate {
+			t.Errorf("wrong preset in version %d. Want bitrate %q. Got %#v", i+1, expected.bitrate, version.Preset)
+		}
+		expectedMapping := map[string]string{"fake": "presetID_here"}
+		if !reflect.DeepEqual(version.ProviderMapping, expectedMapping) {
+			t.Errorf("wrong provider mapping in version %d. Want %#v. Got %#v", i+1, expectedMapping, version.ProviderMapping)
+		}
+		if version.CreationTime.IsZero() {
+			t.Errorf("missing creation time in version %d", i+1)
+		}
+	}
+	presetMap, err := fakeDB.GetPresetMap("mp4_720p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if presetMap.Version != 2 {
+		t.Errorf("wrong version in the preset map. Want 2. Got %d", presetMap.Version)
+	}
+}
+
+func TestListPresetVersionsNotFound(t *testing.T) {
+	srvr := server.NewSimpleServer(&server.Config{RouterType: "fast"})
+	service, err := NewTranscodingService(&config.Config{}, logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.db = dbtest.NewFakeRepository(false)
+	srvr.Register(service)
+	r, _ := http.NewRequest("GET", "/presets/preset-unknown/versions", nil)
+	w := httptest.NewRecorder()
+	srvr.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("wrong response code. Want %d. Got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestRollbackPreset(t *testing.T) {
+	tests := []struct {
+		givenTestCase   string
+		givenPresetName string
+		givenVersion    string
+
+		wantCode      int
+		wantError     string
+		wantPresetMap *db.PresetMap
+	}{
+		{
+			"Roll back to a previous version",
+			"abc-321",
+			"1",
+
+			http.StatusOK,
+			"",
+			&db.PresetMap{
+				Name:            "abc-321",
+				Preset:          &db.Preset{Name: "abc-321", Container: "mp4", Video: db.VideoPreset{Codec: "h264", Bitrate: "1000000"}},
+				ProviderMapping: map[string]string{"fakeupdater": "updater-id"},
+				OutputOpts:      db.OutputOptions{Extension: "mp4"},
+				Version:         3,
+			},
+		},
+		{
+			"Roll back to the current version",
+			"abc-321",
+			"2",
+
+			http.StatusOK,
+			"",
+			&db.PresetMap{
+				Name:            "abc-321",
+				Preset:          &db.Preset{Name: "abc-321", Container: "mp4", Video: db.VideoPreset{Codec: "h264", Bitrate: "2000000"}},
+				ProviderMapping: map[string]string{"fakeupdater": "updater-id"},
+				OutputOpts:      db.OutputOptions{Extension: "mp4"},
+				Version:         2,
+			},
+		},
+		{
+			"Roll back to a version without canonical preset",
+			"legacy",
+			"1",
+
+			http.StatusOK,
+			"",
+			&db.PresetMap{
+				Name:            "legacy",
+				ProviderMapping: map[string]string{"fake": "legacy-id"},
+				OutputOpts:      db.OutputOptions{Extension: "mp4"},
+				Version:         3,
+			},
+		},
+		{
+			"Version not found",
+			"abc-321",
+			"5",
+
+			http.StatusNotFound,
+			"preset version not found",
+			nil,
+		},
+		{
+			"Invalid version",
+			"abc-321",
+			"latest",
+
+			http.StatusBadRequest,
+			`invalid version "latest"`,
+			nil,
+		},
+		{
+			"Preset not found",
+			"preset-unknown",
+			"1",
+
+			http.StatusNotFound,
+			"presetmap not found",
+			nil,
+		},
+	}
+	for _, test := range tests {
+		fakeDB := dbtest.NewFakeRepository(false)
+		versions := []db.PresetVersion{
+			{
+				Name:            "abc-321",
+				Version:         1,
+				Preset:          &db.Preset{Name: "abc-321", Container: "mp4", Video: db.VideoPreset{Codec: "h264", Bitrate: "1000000"}},
+				ProviderMapping: map[string]string{"fakeupdater": "updater-id"},
+				OutputOpts:      db.OutputOptions{Extension: "mp4"},
+			},
+			{
+				Name:            "abc-321",
+				Version:         2,
+				Preset:          &db.Preset{Name: "abc-321", Container: "mp4", Video: db.VideoPreset{Codec: "h264", Bitrate: "2000000"}},
+				ProviderMapping: map[string]string{"fakeupdater": "updater-id"},
+				OutputOpts:      db.OutputOptions{Extension: "mp4"},
+			},
+			{
+				Name:            "legacy",
+				Version:         1,
+				ProviderMapping: map[string]string{"fake": "legacy-id"},
+				OutputOpts:      db.OutputOptions{Extension: "mp4"},
+			},
+			{
+				Name:            "legacy",
+				Version:         2,
+				ProviderMapping: map[string]string{"fake": "new-id"},
+				OutputOpts:      db.OutputOptions{Extension: "mp4"},
+			},
+		}
+		for i := range versions {
+			fakeDB.NextPresetVersion(versions[i].Name)
+			fakeDB.CreatePresetVersion(&versions[i])
+		}
+		fakeDB.CreatePresetMap(&db.PresetMap{
+			Name:            "abc-321",
+			Preset:          versions[1].Preset,
+			ProviderMapping: map[string]string{"fakeupdater": "updater-id"},
+			OutputOpts:      db.OutputOptions{Extension: "mp4"},
+			Version:         2,
+		})
+		fakeDB.CreatePresetMap(&db.PresetMap{
+			Name:            "legacy",
+			ProviderMapping: map[string]string{"fake": "new-id"},
+			OutputOpts:      db.OutputOptions{Extension: "mp4"},
+			Version:         2,
+		})
+		fupdater.presets = map[string]db.Preset{"updater-id": *versions[1].Preset}
+		srvr := server.NewSimpleServer(&server.Config{RouterType: "fast"})
+		service, err := NewTranscodingService(&config.Config{}, logrus.New())
+		if err != nil {
+			t.Fatal(err)
+		}
+		service.db = fakeDB
+		srvr.Register(service)
+		r, _ := http.NewRequest("POST", "/presets/"+test.givenPresetName+"/rollback/"+test.givenVersion, nil)
+		r.Header.Set("X-Author", "gopher")
+		w := httptest.NewRecorder()
+		srvr.ServeHTTP(w, r)
+		if w.Code != test.wantCode {
+			t.Errorf("%s: wrong response code. Want %d. Got %d", test.givenTestCase, test.wantCode, w.Code)
+		}
+		if test.wantPresetMap == nil {
+			var got map[string]interface{}
+			err = json.NewDecoder(w.Body).Decode(&got)
+			if err != nil {
+				t.Errorf("%s: unable to JSON decode response body: %s", test.givenTestCase, err)
+			}
+			if got["error"] != test.wantError {
+				t.Errorf("%s: wrong error. Want %q. Got %q", test.givenTestCase, test.wantError, got["error"])
+			}
+			continue
+		}
+		presetMap, err := fakeDB.GetPresetMap(test.givenPresetName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(*presetMap, *test.wantPresetMap) {
+			t.Errorf("%s: wrong preset map saved\nWant %#v\nGot  %#v", test.givenTestCase, *test.wantPresetMap, *presetMap)
+		}
+		if presetMap.Preset != nil {
+			if bitrate := fupdater.presets["updater-id"].Video.Bitrate; bitrate != presetMap.Preset.Video.Bitrate {
+				t.Errorf("%s: preset not restored in the provider. Want bitrate %q. Got %q", test.givenTestCase, presetMap.Preset.Video.Bitrate, bitrate)
+			}
+		}
+		version, err := fakeDB.GetPresetVersion(test.givenPresetName, presetMap.Version)
+		if err != nil {
+			t.Fatalf("%s: %s", test.givenTestCase, err)
+		}
+		if presetMap.Version == 3 && version.Author != "gopher" {
+			t.Errorf("%s: wrong author of the new version. Want %q. Got %q", test.givenTestCase, "gopher", version.Author)
+		}
+	}
+}
+
+func TestRollbackPresetRecreatesProviderPresets(t *testing.T) {
+	fprovider.deletedPresets = nil
+	fakeDB := dbtest.NewFakeRepository(false)
+	versions := []db.PresetVersion{
+		{
+			Name:            "mp4_720p",
+			Version:         1,
+			Preset:          &db.Preset{Name: "mp4_720p", Container: "mp4", Video: db.VideoPreset{Codec: "h264", Bitrate: "1000000"}},
+			ProviderMapping: map[string]string{"fake": "deleted-id"},
+			OutputOpts:      db.OutputOptions{Extension: "mp4"},
+		},
+		{
+			Name:            "mp4_720p",
+			Version:         2,
+			Preset:          &db.Preset{Name: "mp4_720p", Container: "mp4", Video: db.VideoPreset{Codec: "h264", Bitrate: "2000000"}},
+			ProviderMapping: map[string]string{"fake": "current-id"},
+			OutputOpts:      db.OutputOptions{Extension: "mp4"},
+		},
+	}
+	for i := range versions {
+		fakeDB.NextPresetVersion(versions[i].Name)
+		fakeDB.CreatePresetVersion(&versions[i])
+	}
+	fakeDB.CreatePresetMap(&db.PresetMap{
+		Name:            "mp4_720p",
+		Preset:          versions[1].Preset,
+		ProviderMapping: map[string]string{"fake": "current-id"},
+		OutputOpts:      db.OutputOptions{Extension: "mp4"},
+		Version:         2,
+	})
+	srvr := server.NewSimpleServer(&server.Config{RouterType: "fast"})
+	service, err := NewTranscodingService(&config.Config{}, logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.db = fakeDB
+	srvr.Register(service)
+	r, _ := http.NewRequest("POST", "/presets/mp4_720p/rollback/1", nil)
+	w := httptest.NewRecorder()
+	srvr.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("wrong response code. Want %d. Got %d", http.StatusOK, w.Code)
+	}
+	presetMap, err := fakeDB.GetPresetMap("mp4_720p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedMapping := map[string]string{"fake": "presetID_here"}
+	if !reflect.DeepEqual(presetMap.ProviderMapping, expectedMapping) {
+		t.Errorf("wrong provider mapping. Want %#v. Got %#v", expectedMapping, presetMap.ProviderMapping)
+	}
+	expectedDeleted := []string{"current-id"}
+	if !reflect.DeepEqual(fprovider.deletedPresets, expectedDeleted) {
+		t.Errorf("wrong deleted presets. Want %#v. Got %#v", expectedDeleted, fprovider.deletedPresets)
+	}
+}
+
+func TestUpdatePresetKeepsVersionedProviderPresets(t *testing.T) {
+	fprovider.deletedPresets = nil
+	fakeDB := dbtest.NewFakeRepository(false)
+	version := db.PresetVersion{
+		Name:            "legacy",
+		Version:         1,
+		ProviderMapping: map[string]string{"fake": "legacy-id"},
+		OutputOpts:      db.OutputOptions{Extension: "mp4"},
+	}
+	fakeDB.NextPresetVersion(version.Name)
+	fakeDB.CreatePresetVersion(&version)
+	fakeDB.CreatePresetMap(&db.PresetMap{
+		Name:            "legacy",
+		ProviderMapping: map[string]string{"fake": "legacy-id"},
+		OutputOpts:      db.OutputOptions{Extension: "mp4"},
+		Version:         1,
+	})
+	srvr := server.NewSimpleServer(&server.Config{RouterType: "fast"})
+	service, err := NewTranscodingService(&config.Config{}, logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.db = fakeDB
+	srvr.Register(service)
+	body, _ := json.Marshal(map[string]interface{}{"container": "mp4", "video": map[string]string{"codec": "h264", "bitrate": "2000000"}})
+	r, _ := http.NewRequest("PUT", "/presets/legacy", bytes.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	srvr.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("wrong response code. Want %d. Got %d", http.StatusOK, w.Code)
+	}
+	if len(fprovider.deletedPresets) > 0 {
+		t.Errorf("presets referenced by versions without canonical preset should be kept. Deleted %#v", fprovider.deletedPresets)
+	}
+}
+
+// versionFailureRepository fails to record the versions of presets.
+type versionFailureRepository struct {
+	db.Repository
+}
+
+func (r *versionFailureRepository) CreatePresetVersion(*db.PresetVersion) error {
+	return errors.New("database error")
+}
+
+func TestSavePresetMapVersionError(t *testing.T) {
+	service, err := NewTranscodingService(&config.Config{}, logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fakeDB := &versionFailureRepository{Repository: dbtest.NewFakeRepository(false)}
+	service.db = fakeDB
+	err = service.savePresetMap(&db.PresetMap{Name: "mp4_720p"}, "gopher", fakeDB.CreatePresetMap)
+	expectedMsg := `recording version 1 of preset "mp4_720p": database error`
+	if err == nil || err.Error() != expectedMsg {
+		t.Errorf("wrong error returned. Want %q. Got %v", expectedMsg, err)
+	}
+	if _, err = fakeDB.GetPresetMap("mp4_720p"); err != db.ErrPresetMapNotFound {
+		t.Errorf("preset map should not be saved. Got error %#v", err)
+	}
+}
+
+func TestSavePresetMapError(t *testing.T) {
+	service, err := NewTranscodingService(&config.Config{}, logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fakeDB := dbtest.NewFakeRepository(false)
+	service.db = fakeDB
+	saveErr := errors.New("unable to save")
+	err = service.savePresetMap(&db.PresetMap{Name: "mp4_720p"}, "gopher", func(*db.PresetMap) error {
+		return saveErr
+	})
+	if err != saveErr {
+		t.Errorf("wrong error returned. Want %#v. Got %#v", saveErr, err)
+	}
+	versions, err := fakeDB.ListPresetVersions("mp4_720p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) > 0 {
+		t.Errorf("version should be removed when the preset map isn't saved. Got %#v", versions)
+	}
+	version, err := fakeDB.NextPresetVersion("mp4_720p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 2 {
+		t.Errorf("version numbers should not be reused. Want 2. Got %d", version)
+	}
+}
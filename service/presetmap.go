@@ -24,7 +24,10 @@ func (s *TranscodingService) newPresetMap(r *http.Request) swagger.GizmoJSONResp
 	if err != nil {
 		return newInvalidPresetMapResponse(err)
 	}
-	err = s.db.CreatePresetMap(&preset)
+	// the canonical preset is taken from one of the providers when it's not
+	// given, preset maps without it just can't be compared or copied later.
+	s.canonicalPreset(&preset)
+	err = s.savePresetMap(&preset, r.Header.Get(authorHeader), s.db.CreatePresetMap)
 	switch err {
 	case nil:
 		return newPresetMapResponse(&preset)
@@ -74,7 +77,15 @@ func (s *TranscodingService) updatePresetMap(r *http.Request) swagger.GizmoJSONR
 	if err != nil {
 		return newInvalidPresetMapResponse(err)
 	}
-	err = s.db.UpdatePresetMap(&presetMap)
+	// keep the canonical preset, along with the fields it overrides from
+	// its parent, when the request doesn't replace it.
+	if presetMap.Preset == nil {
+		if current, gerr := s.db.GetPresetMap(presetMap.Name); gerr == nil {
+			presetMap.Preset = current.Preset
+			presetMap.Overrides = current.Overrides
+		}
+	}
+	err = s.savePresetMap(&presetMap, r.Header.Get(authorHeader), s.db.UpdatePresetMap)
 
 	switch err {
 	case nil:
@@ -91,13 +102,25 @@ func (s *TranscodingService) updatePresetMap(r *http.Request) swagger.GizmoJSONR
 //
 // Deletes a presetmap by name.
 //
+// Presetmaps used by queued or running jobs are only deleted with
+// force=true.
+//
 //     Responses:
 //       200: emptyResponse
+//       400: invalidPreset
 //       404: presetNotFound
+//       409: presetInUse
 //       500: genericError
 func (s *TranscodingService) deletePresetMap(r *http.Request) swagger.GizmoJSONResponse {
-	var params getPresetMapInput
-	params.loadParams(web.Vars(r))
+	var params deletePresetInput
+	if err := params.loadParams(web.Vars(r), r.URL.Query()); err != nil {
+		return newInvalidPresetMapResponse(err)
+	}
+	if !params.Force {
+		if resp := s.presetInUse(params.Name); resp != nil {
+			return resp
+		}
+	}
 	err := s.db.DeletePresetMap(&db.PresetMap{Name: params.Name})
 
 	switch err {
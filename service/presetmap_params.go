@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
 
 	"github.com/NYTimes/video-transcoding-api/db"
 	"github.com/NYTimes/video-transcoding-api/swagger"
@@ -21,13 +23,23 @@ type presetMapResponse struct {
 	baseResponse
 }
 
-// swagger:parameters getPreset deletePreset deletePresetMap
+// swagger:parameters getPreset getPresetDetails getPresetDrift listPresetVersions
 type getPresetMapInput struct {
 	// in: path
 	// required: true
 	Name string `json:"name"`
 }
 
+// swagger:parameters deletePreset deletePresetMap
+type deletePresetInput struct {
+	getPresetMapInput
+
+	// delete the preset even if it's used by queued or running jobs
+	//
+	// in: query
+	Force bool `json:"force"`
+}
+
 // swagger:parameters updatePreset
 type updatePresetMapInput struct {
 	// in: path
@@ -153,6 +165,18 @@ func (p *getPresetMapInput) loadParams(paramsMap map[string]string) {
 	p.Name = paramsMap["name"]
 }
 
+func (p *deletePresetInput) loadParams(paramsMap map[string]string, query url.Values) error {
+	p.getPresetMapInput.loadParams(paramsMap)
+	if value := query.Get("force"); value != "" {
+		force, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid value for force: %q", value)
+		}
+		p.Force = force
+	}
+	return nil
+}
+
 func (p *updatePresetMapInput) PresetMap(paramsMap map[string]string, body io.Reader) (db.PresetMap, error) {
 	p.Name = paramsMap["name"]
 	err := json.NewDecoder(body).Decode(&p.Payload)
@@ -174,5 +198,5 @@ func validatePresetMap(p *db.PresetMap) error {
 	if len(p.ProviderMapping) == 0 {
 		return errors.New("missing field providerMapping from the request")
 	}
-	return nil
+	return validatePathTemplate(p.OutputOpts.FileNameTemplate)
 }
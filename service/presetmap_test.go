@@ -48,6 +48,7 @@ func TestNewPresetMap(t *testing.T) {
 				"output": map[string]interface{}{
 					"extension": "mp4",
 				},
+				"version": float64(1),
 			},
 		},
 		{
@@ -113,6 +114,25 @@ func TestNewPresetMap(t *testing.T) {
 				"error": "missing field providerMapping from the request",
 			},
 		},
+		{
+			"New preset with invalid file name template",
+			map[string]interface{}{
+				"name": "mypreset",
+				"providerMapping": map[string]string{
+					"elastictranscoder": "18384284-0002",
+				},
+				"output": map[string]string{
+					"extension":        "mp4",
+					"fileNameTemplate": "{sourceBase}-{resolution}.{ext}",
+				},
+			},
+			false,
+
+			http.StatusBadRequest,
+			map[string]interface{}{
+				"error": `invalid template "{sourceBase}-{resolution}.{ext}": unknown placeholder {resolution}`,
+			},
+		},
 		{
 			"New preset DB failure",
 			map[string]interface{}{
@@ -242,6 +262,7 @@ func TestUpdatePresetMap(t *testing.T) {
 					"elementalconductor": "abc-123",
 					"elastictranscoder":  "def-345",
 				},
+				Version: 1,
 			},
 			http.StatusOK,
 		},
@@ -303,30 +324,53 @@ func TestDeletePresetMap(t *testing.T) {
 	tests := []struct {
 		givenTestCase      string
 		givenPresetMapName string
+		givenQuery         string
+		givenJobs          []db.Job
 		wantCode           int
 	}{
 		{
 			"Delete preset",
 			"preset-1",
+			"",
+			nil,
 			http.StatusOK,
 		},
 		{
 			"Delete presetmap not found",
 			"preset-unknown",
+			"",
+			nil,
 			http.StatusNotFound,
 		},
+		{
+			"Delete presetmap used by active jobs",
+			"preset-1",
+			"",
+			[]db.Job{{ID: "job-1", ProviderName: "fake", ProviderJobID: "provider-job-active", Presets: []string{"preset-1"}}},
+			http.StatusConflict,
+		},
+		{
+			"Force the deletion of a presetmap used by active jobs",
+			"preset-1",
+			"?force=1",
+			[]db.Job{{ID: "job-1", ProviderName: "fake", ProviderJobID: "provider-job-active", Presets: []string{"preset-1"}}},
+			http.StatusOK,
+		},
 	}
 	for _, test := range tests {
 		srvr := server.NewSimpleServer(&server.Config{RouterType: "fast"})
 		fakeDB := dbtest.NewFakeRepository(false)
 		fakeDB.CreatePresetMap(&db.PresetMap{Name: "preset-1"})
+		for i := range test.givenJobs {
+			fakeDB.CreateJob(&test.givenJobs[i])
+		}
 		service, err := NewTranscodingService(&config.Config{}, logrus.New())
 		if err != nil {
 			t.Fatal(err)
 		}
 		service.db = fakeDB
 		srvr.Register(service)
-		r, _ := http.NewRequest("DELETE", "/presetmaps/"+test.givenPresetMapName, nil)
+		r, _ := http.NewRequest("DELETE", "/presetmaps/"+test.givenPresetMapName+test.givenQuery, nil)
 		w := httptest.NewRecorder()
 		srvr.ServeHTTP(w, r)
 		if w.Code != test.wantCode {
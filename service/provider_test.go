@@ -30,7 +30,7 @@ func TestListProviders(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	expected := []string{"fake"}
+	expected := []string{"fake", "fakeupdater"}
 	if !reflect.DeepEqual(providers, expected) {
 		t.Errorf("listProviders: wrong body. Want %#v. Got %#v", expected, providers)
 	}
@@ -52,9 +52,13 @@ func TestGetProvider(t *testing.T) {
 				"name":   "fake",
 				"health": map[string]interface{}{"ok": true},
 				"capabilities": map[string]interface{}{
-					"input":        []interface{}{"prores", "h264"},
-					"output":       []interface{}{"mp4", "webm", "hls"},
-					"destinations": []interface{}{"akamai", "s3"},
+					"input":         []interface{}{"prores", "h264"},
+					"output":        []interface{}{"mp4", "webm", "hls", "dash"},
+					"videoCodecs":   []interface{}{"h264", "h265", "vp8", "vp9", "av1"},
+					"destinations":  []interface{}{"akamai", "s3"},
+					"captions":      []interface{}{"srt", "webvtt"},
+					"hlsSegments":   []interface{}{"ts", "fmp4"},
+					"hlsEncryption": []interface{}{"aes-128"},
 				},
 				"enabled": true,
 			},
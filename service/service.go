@@ -3,6 +3,7 @@ package service
 import (
 	"fmt"
 	"net/http"
+	"sync"
 
 	"github.com/NYTimes/gizmo/server"
 	"github.com/NYTimes/gziphandler"
@@ -20,6 +21,11 @@ type TranscodingService struct {
 	config *config.Config
 	db     db.Repository
 	logger *logrus.Logger
+
+	// jobs whose outputs are being verified in the background
+	verifyingMtx  sync.Mutex
+	verifying     map[string]bool
+	verifications sync.WaitGroup
 }
 
 // NewTranscodingService will instantiate a JSONService
@@ -69,12 +75,32 @@ func (s *TranscodingService) JSONEndpoints() map[string]map[string]server.JSONEn
 		"/jobs/:jobId/cancel": {
 			"POST": swagger.HandlerToJSONEndpoint(s.cancelTranscodeJob),
 		},
+		"/jobs/:jobId/key": {
+			"GET": swagger.HandlerToJSONEndpoint(s.getJobKey),
+		},
 		"/presets": {
 			"POST": swagger.HandlerToJSONEndpoint(s.newPreset),
 		},
+		"/presets/:name/drift": {
+			"GET": swagger.HandlerToJSONEndpoint(s.getPresetDrift),
+		},
+		"/presets/:name/versions": {
+			"GET": swagger.HandlerToJSONEndpoint(s.listPresetVersions),
+		},
+		"/presets/:name/rollback/:version": {
+			"POST": swagger.HandlerToJSONEndpoint(s.rollbackPreset),
+		},
+		"/presets/:name/providers/:provider": {
+			"POST": swagger.HandlerToJSONEndpoint(s.addPresetProvider),
+		},
 		"/presets/:name": {
+			"POST":   swagger.HandlerToJSONEndpoint(s.importPresets),
+			"PUT":    swagger.HandlerToJSONEndpoint(s.updatePreset),
 			"DELETE": swagger.HandlerToJSONEndpoint(s.deletePreset),
 		},
+		"/ladders": {
+			"POST": swagger.HandlerToJSONEndpoint(s.generateLadder),
+		},
 		"/presetmaps": {
 			"POST": swagger.HandlerToJSONEndpoint(s.newPresetMap),
 			"GET":  swagger.HandlerToJSONEndpoint(s.listPresetMaps),
@@ -99,5 +125,8 @@ func (s *TranscodingService) Endpoints() map[string]map[string]http.HandlerFunc
 		"/swagger.json": {
 			"GET": s.swaggerManifest,
 		},
+		"/presets/:name": {
+			"GET": s.getPreset,
+		},
 	}
 }
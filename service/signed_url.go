@@ -0,0 +1,72 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/NYTimes/video-transcoding-api/mediaurl"
+	"github.com/NYTimes/video-transcoding-api/provider"
+	"github.com/NYTimes/video-transcoding-api/urlsigner"
+)
+
+var errSignedURLsDisabled = errors.New("signed URLs are not enabled in this API")
+
+// signedURLExpiration returns the expiration of signed URLs requested with
+// the given expiresIn parameter (in seconds), falling back to the expiration
+// in the configuration when it's zero.
+func (s *TranscodingService) signedURLExpiration(expiresIn uint) (time.Duration, error) {
+	cfg := s.config.URLSigner
+	if cfg == nil || cfg.Backend == "" {
+		return 0, errSignedURLsDisabled
+	}
+	if expiresIn == 0 {
+		expiresIn = cfg.Expiration
+	}
+	if cfg.MaxExpiration > 0 && expiresIn > cfg.MaxExpiration {
+		return 0, fmt.Errorf("invalid value for expiresIn: %d. Signed URLs may not be valid for more than %d seconds", expiresIn, cfg.MaxExpiration)
+	}
+	return time.Duration(expiresIn) * time.Second, nil
+}
+
+func (s *TranscodingService) urlSigner() (urlsigner.Signer, error) {
+	factory, err := urlsigner.GetSignerFactory(s.config.URLSigner.Backend)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load url signer %q: %s", s.config.URLSigner.Backend, err)
+	}
+	return factory(s.config)
+}
+
+// signJobOutput sets the signed URL of the files and captions generated by
+// a job. Files that the signer can't handle are left without a signed URL.
+func signJobOutput(signer urlsigner.Signer, output *provider.JobOutput, expiration time.Duration) error {
+	var err error
+	for i := range output.Files {
+		output.Files[i].SignedURL, err = signURL(signer, output.Files[i].Path, expiration)
+		if err != nil {
+			return err
+		}
+	}
+	for i := range output.Captions {
+		output.Captions[i].SignedURL, err = signURL(signer, output.Captions[i].Path, expiration)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func signURL(signer urlsigner.Signer, path string, expiration time.Duration) (string, error) {
+	loc, err := mediaurl.Parse(path)
+	if err != nil {
+		return "", nil
+	}
+	signedURL, err := signer.Sign(loc, expiration)
+	if err == urlsigner.ErrUnsupportedLocation {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("unable to sign URL of %q: %s", path, err)
+	}
+	return signedURL, nil
+}
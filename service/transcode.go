@@ -2,18 +2,36 @@ package service
 
 import (
 	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
-	"path"
-	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/NYTimes/gizmo/web"
 	"github.com/NYTimes/video-transcoding-api/db"
+	"github.com/NYTimes/video-transcoding-api/mediaurl"
+	"github.com/NYTimes/video-transcoding-api/probe"
 	"github.com/NYTimes/video-transcoding-api/provider"
 	"github.com/NYTimes/video-transcoding-api/swagger"
+	"github.com/NYTimes/video-transcoding-api/urlsigner"
 )
 
+// encryptionKeySize is the size, in bytes, of the keys used for encrypting
+// HLS segments.
+const encryptionKeySize = 16
+
+// defaultPlaylistFileNames contains the name of the playlist generated by
+// adaptive streaming jobs that don't specify one, by protocol.
+var defaultPlaylistFileNames = map[string]string{
+	"hls":  "hls/index.m3u8",
+	"dash": "dash/index.mpd",
+}
+
 // swagger:route POST /jobs jobs newJob
 //
 // Creates a new transcoding job.
@@ -37,11 +55,40 @@ func (s *TranscodingService) newTranscodeJob(r *http.Request) swagger.GizmoJSONR
 		}
 		return swagger.NewErrorResponse(formattedErr)
 	}
+	if err = s.validateCaptionSupport(input.Payload.Provider, providerObj, input.Payload.Captions); err != nil {
+		return newInvalidJobResponse(err)
+	}
+	if err = s.validateStreamingSupport(input.Payload.Provider, providerObj.Capabilities(), input.Payload.StreamingParams); err != nil {
+		return newInvalidJobResponse(err)
+	}
+	if err = s.validateSourceMedia(input.Payload.Provider, providerObj.Capabilities(), input.Payload.Source); err != nil {
+		return newInvalidJobResponse(err)
+	}
+	if err = s.validateDestination(input.Payload.Destination); err != nil {
+		return newInvalidJobResponse(err)
+	}
+	if err = s.validateMediaURLs(input.Payload.Provider, providerObj.Capabilities(), input.Payload.Source, input.Payload.Destination); err != nil {
+		return newInvalidJobResponse(err)
+	}
 	transcodeProfile := provider.TranscodeProfile{
 		SourceMedia:     input.Payload.Source,
 		StreamingParams: input.Payload.StreamingParams,
+		Captions:        input.Payload.Captions,
+		OverlayImage:    input.Payload.OverlayImage,
+		ProviderOptions: input.Payload.ProviderOptions,
+	}
+	jobID, err := s.genID()
+	if err != nil {
+		return swagger.NewErrorResponse(err)
+	}
+	templateValues := newPathTemplateValues(jobID, input.Payload.Source, input.Payload.Labels)
+	outputPath, err := s.outputPath(input.Payload, templateValues)
+	if err != nil {
+		return newInvalidJobResponse(err)
 	}
 	outputs := make([]provider.TranscodeOutput, len(input.Payload.Outputs))
+	var presets []string
+	var presetVersions map[string]string
 	for i, output := range input.Payload.Outputs {
 		presetMap, presetErr := s.db.GetPresetMap(output.Preset)
 		if presetErr != nil {
@@ -52,26 +99,54 @@ func (s *TranscodingService) newTranscodeJob(r *http.Request) swagger.GizmoJSONR
 		}
 		fileName := output.FileName
 		if fileName == "" {
-			fileName = s.defaultFileName(input.Payload.Source, presetMap)
+			fileName, err = s.defaultFileName(input.Payload.FileNameTemplate, presetMap, templateValues)
+			if err != nil {
+				return newInvalidJobResponse(err)
+			}
 		}
 		outputs[i] = provider.TranscodeOutput{FileName: fileName, Preset: *presetMap}
+		if !s.contains(presets, presetMap.Name) {
+			presets = append(presets, presetMap.Name)
+		}
+		if presetMap.Version > 0 {
+			if presetVersions == nil {
+				presetVersions = make(map[string]string)
+			}
+			presetVersions[presetMap.Name] = strconv.FormatUint(uint64(presetMap.Version), 10)
+		}
 	}
 	transcodeProfile.Outputs = outputs
-	jobID, err := s.genID()
-	if err != nil {
-		return swagger.NewErrorResponse(err)
-	}
-	if transcodeProfile.StreamingParams.Protocol == "hls" {
+	if defaultPlaylist, ok := defaultPlaylistFileNames[transcodeProfile.StreamingParams.Protocol]; ok {
 		if transcodeProfile.StreamingParams.PlaylistFileName == "" {
-			transcodeProfile.StreamingParams.PlaylistFileName = "hls/index.m3u8"
+			transcodeProfile.StreamingParams.PlaylistFileName = defaultPlaylist
 		}
 		if transcodeProfile.StreamingParams.SegmentDuration == 0 {
 			transcodeProfile.StreamingParams.SegmentDuration = s.config.DefaultSegmentDuration
 		}
 	}
-	job := db.Job{ID: jobID}
+	if encryption := transcodeProfile.StreamingParams.Encryption; encryption != nil {
+		if err = s.saveEncryptionKey(jobID, encryption); err != nil {
+			return swagger.NewErrorResponse(err)
+		}
+	}
+	job := db.Job{
+		ID:             jobID,
+		Destination:    input.Payload.Destination,
+		OutputPath:     outputPath,
+		Presets:        presets,
+		PresetVersions: presetVersions,
+	}
+	for _, caption := range input.Payload.Captions {
+		job.CaptionLanguages = append(job.CaptionLanguages, caption.Language)
+	}
+	if options, ok := input.Payload.ProviderOptions[input.Payload.Provider]; ok {
+		job.ProviderOptions = db.ProviderOptions{input.Payload.Provider: options}
+	}
 	jobStatus, err := providerObj.Transcode(&job, transcodeProfile)
-	if err == provider.ErrPresetMapNotFound {
+	if err != nil {
+		s.deleteEncryptionKey(jobID, transcodeProfile.StreamingParams.Encryption)
+	}
+	if _, ok := err.(*provider.InvalidProviderOptionError); ok || err == provider.ErrPresetMapNotFound {
 		return newInvalidJobResponse(err)
 	}
 	if err != nil {
@@ -81,19 +156,194 @@ func (s *TranscodingService) newTranscodeJob(r *http.Request) swagger.GizmoJSONR
 	jobStatus.ProviderName = input.Payload.Provider
 	job.ProviderName = jobStatus.ProviderName
 	job.ProviderJobID = jobStatus.ProviderJobID
+	job.Status = string(jobStatus.Status)
 	if transcodeProfile.StreamingParams.Protocol != "" {
 		job.StreamingParams = db.StreamingParams{
 			SegmentDuration: transcodeProfile.StreamingParams.SegmentDuration,
 			Protocol:        transcodeProfile.StreamingParams.Protocol,
+			SegmentFormat:   transcodeProfile.StreamingParams.SegmentFormat,
+		}
+		if transcodeProfile.StreamingParams.Encryption != nil {
+			job.StreamingParams.EncryptionMethod = transcodeProfile.StreamingParams.Encryption.Method
 		}
 	}
 	err = s.db.CreateJob(&job)
 	if err != nil {
+		s.deleteEncryptionKey(jobID, transcodeProfile.StreamingParams.Encryption)
 		return swagger.NewErrorResponse(err)
 	}
 	return newJobResponse(job.ID)
 }
 
+func (s *TranscodingService) validateCaptionSupport(providerName string, p provider.TranscodingProvider, captions []provider.Caption) error {
+	if len(captions) == 0 {
+		return nil
+	}
+	supportedFormats := p.Capabilities().CaptionFormats
+	for _, caption := range captions {
+		var supported bool
+		for _, format := range supportedFormats {
+			if caption.Format == format {
+				supported = true
+				break
+			}
+		}
+		if !supported {
+			return fmt.Errorf("caption format %q is not supported by provider %q", caption.Format, providerName)
+		}
+	}
+	return nil
+}
+
+func (s *TranscodingService) validateStreamingSupport(providerName string, capabilities provider.Capabilities, params provider.StreamingParams) error {
+	if params.Protocol == "" {
+		return nil
+	}
+	if !s.contains(capabilities.OutputFormats, params.Protocol) {
+		return fmt.Errorf("streaming protocol %q is not supported by provider %q", params.Protocol, providerName)
+	}
+	if params.SegmentFormat != "" && !s.contains(capabilities.HLSSegmentFormats, params.SegmentFormat) {
+		return fmt.Errorf("segment format %q is not supported by provider %q", params.SegmentFormat, providerName)
+	}
+	if params.Encryption != nil && !s.contains(capabilities.HLSEncryptionMethods, params.Encryption.Method) {
+		return fmt.Errorf("encryption method %q is not supported by provider %q", params.Encryption.Method, providerName)
+	}
+	return nil
+}
+
+// validateSourceMedia probes the source media, making sure that it can be
+// read and that its format is supported by the provider. Sources that can't
+// be probed, like files in S3 buckets, are not validated.
+func (s *TranscodingService) validateSourceMedia(providerName string, capabilities provider.Capabilities, source string) error {
+	if !s.config.ProbeSources {
+		return nil
+	}
+	info, err := probe.Probe(source)
+	if err == probe.ErrUnsupportedScheme {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to probe source media %q: %s", source, err)
+	}
+	if info.VideoCodec != "" && !s.contains(capabilities.InputFormats, info.VideoCodec) {
+		return fmt.Errorf("input format %q is not supported by provider %q", info.VideoCodec, providerName)
+	}
+	return nil
+}
+
+// validateDestination makes sure that the custom destination of the job is
+// under one of the destinations allowed in the configuration. Destinations
+// must use the same scheme and bucket (or host) of an allowed destination,
+// and be in its directory.
+func (s *TranscodingService) validateDestination(destination string) error {
+	if destination == "" {
+		return nil
+	}
+	if len(s.config.AllowedDestinations) == 0 {
+		return errors.New("custom destinations are not enabled in this API")
+	}
+	loc, err := mediaurl.Parse(destination)
+	if err != nil {
+		return fmt.Errorf("invalid destination %q: %s", destination, err)
+	}
+	for _, segment := range strings.Split(loc.Path, "/") {
+		if segment == ".." {
+			return fmt.Errorf("invalid destination %q", destination)
+		}
+	}
+	for _, allowed := range s.config.AllowedDestinations {
+		allowedLoc, err := mediaurl.Parse(allowed)
+		if err != nil {
+			continue
+		}
+		if destinationWithin(loc, allowedLoc) {
+			return nil
+		}
+	}
+	return fmt.Errorf("destination %q is not allowed. Allowed destinations are: %s", destination, strings.Join(s.config.AllowedDestinations, ", "))
+}
+
+// destinationWithin returns whether the destination is the allowed
+// destination or one of its subdirectories. S3 locations given as HTTP URLs
+// are compared as S3 URLs.
+func destinationWithin(destination, allowed *mediaurl.Location) bool {
+	if s3Loc, ok := destination.S3(); ok {
+		destination = s3Loc
+	}
+	if s3Loc, ok := allowed.S3(); ok {
+		allowed = s3Loc
+	}
+	if destination.Scheme != allowed.Scheme || destination.Host != allowed.Host {
+		return false
+	}
+	prefix := strings.Trim(allowed.Path, "/")
+	path := strings.Trim(destination.Path, "/")
+	return prefix == "" || path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// validateMediaURLs makes sure that the source and the custom destination of
+// the job use known schemes, and that the destination is supported by the
+// provider. Sources without a scheme are left for the provider to handle, as
+// some providers accept paths relative to their input storage.
+func (s *TranscodingService) validateMediaURLs(providerName string, capabilities provider.Capabilities, source, destination string) error {
+	if _, err := mediaurl.Parse(source); err != nil && err != mediaurl.ErrMissingScheme {
+		return fmt.Errorf("invalid source %q: %s", source, err)
+	}
+	if destination == "" {
+		return nil
+	}
+	loc, err := mediaurl.Parse(destination)
+	if err != nil {
+		return fmt.Errorf("invalid destination %q: %s", destination, err)
+	}
+	scheme := string(loc.Scheme)
+	if _, ok := loc.S3(); ok {
+		scheme = string(mediaurl.S3)
+	}
+	if !s.contains(capabilities.Destinations, scheme) {
+		return fmt.Errorf("destination scheme %q is not supported by provider %q", scheme, providerName)
+	}
+	return nil
+}
+
+// saveEncryptionKey stores the encryption key of the job, generating a random
+// key when the request doesn't include one.
+func (s *TranscodingService) saveEncryptionKey(jobID string, encryption *provider.Encryption) error {
+	if encryption.Key == "" {
+		key := make([]byte, encryptionKeySize)
+		if _, err := rand.Read(key); err != nil {
+			return err
+		}
+		encryption.Key = base64.StdEncoding.EncodeToString(key)
+	}
+	return s.db.CreateEncryptionKey(&db.EncryptionKey{
+		JobID:  jobID,
+		Method: encryption.Method,
+		Key:    encryption.Key,
+		KeyURI: encryption.KeyURI,
+	})
+}
+
+// deleteEncryptionKey removes the key stored by saveEncryptionKey, so jobs
+// that fail to start don't leave orphaned keys behind.
+func (s *TranscodingService) deleteEncryptionKey(jobID string, encryption *provider.Encryption) {
+	if encryption == nil {
+		return
+	}
+	if err := s.db.DeleteEncryptionKey(jobID); err != nil && err != db.ErrEncryptionKeyNotFound {
+		s.logger.Warnf("unable to delete the encryption key of job %q: %s", jobID, err)
+	}
+}
+
+func (s *TranscodingService) contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *TranscodingService) genID() (string, error) {
 	var data [8]byte
 	n, err := rand.Read(data[:])
@@ -106,31 +356,105 @@ func (s *TranscodingService) genID() (string, error) {
 	return fmt.Sprintf("%x", data), nil
 }
 
-func (s *TranscodingService) defaultFileName(source string, preset *db.PresetMap) string {
-	sourceExtension := filepath.Ext(source)
-	_, source = path.Split(source)
-	source = source[:len(source)-len(sourceExtension)]
-	pattern := "%s_%s.%s"
-	if preset.OutputOpts.Extension == "m3u8" {
-		pattern = "hls/" + pattern
+// outputPath renders the path of the directory that holds the outputs of
+// the job, relative to its destination. Jobs with a custom destination store
+// their outputs in a directory named after the job ID, unless they define a
+// template for the output path, as the template in the configuration is meant
+// for the destination of the provider.
+func (s *TranscodingService) outputPath(payload NewTranscodeJobInputPayload, values pathTemplateValues) (string, error) {
+	template := payload.OutputPath
+	if template == "" && payload.Destination == "" {
+		template = s.config.OutputPathTemplate
+	}
+	if template == "" {
+		template = defaultOutputPathTemplate
+	}
+	outputPath, err := renderPathTemplate(template, values)
+	if err != nil {
+		return "", err
+	}
+	if outputPath == "" && payload.Destination == "" {
+		return "", fmt.Errorf("invalid template %q: output path can't be empty", template)
+	}
+	return outputPath, nil
+}
+
+// defaultFileName renders the name of an output that doesn't specify one,
+// using the template defined in the job, in the preset map or in the
+// configuration, in this order.
+func (s *TranscodingService) defaultFileName(jobTemplate string, preset *db.PresetMap, values pathTemplateValues) (string, error) {
+	template := jobTemplate
+	if template == "" {
+		template = preset.OutputOpts.FileNameTemplate
+	}
+	if template == "" {
+		template = s.config.FileNameTemplate
+	}
+	if template == "" {
+		template = defaultFileNameTemplate
+	}
+	values.preset = preset.Name
+	values.ext = preset.OutputOpts.Extension
+	fileName, err := renderPathTemplate(template, values)
+	if err != nil {
+		return "", err
+	}
+	if fileName == "" {
+		return "", fmt.Errorf("invalid template %q: file name can't be empty", template)
 	}
-	return fmt.Sprintf(pattern, source, preset.Name, preset.OutputOpts.Extension)
+	switch preset.OutputOpts.Extension {
+	case "m3u8":
+		fileName = "hls/" + fileName
+	case "mpd":
+		fileName = "dash/" + fileName
+	}
+	return fileName, nil
 }
 
 // swagger:route GET /jobs/{jobId} jobs getJob
 //
 // Finds a trancode job using its ID.
-// It also queries the provider to get the status of the job.
+// It also queries the provider to get the status of the job, verifying
+// the outputs of finished jobs.
+// When signedUrls is true, the output files include time-limited
+// HTTPS URLs for downloading them.
 //
 //     Responses:
 //       200: jobStatus
+//       400: invalidJob
 //       404: jobNotFound
 //       410: jobNotFoundInTheProvider
 //       500: genericError
 func (s *TranscodingService) getTranscodeJob(r *http.Request) swagger.GizmoJSONResponse {
-	var params getTranscodeJobInput
-	params.loadParams(web.Vars(r))
-	return s.getJobStatusResponse(s.getTranscodeJobByID(params.JobID))
+	var params getTranscodeJobStatusInput
+	err := params.loadParams(web.Vars(r), r.URL.Query())
+	if err != nil {
+		return newInvalidJobResponse(err)
+	}
+	var signer urlsigner.Signer
+	var expiration time.Duration
+	if params.SignedURLs {
+		expiration, err = s.signedURLExpiration(params.ExpiresIn)
+		if err != nil {
+			return newInvalidJobResponse(err)
+		}
+		signer, err = s.urlSigner()
+		if err != nil {
+			return swagger.NewErrorResponse(err)
+		}
+	}
+	job, status, p, err := s.getTranscodeJobByID(params.JobID)
+	if err != nil {
+		return s.getJobStatusResponse(job, status, p, err)
+	}
+	s.verifyJobOutput(job, status)
+	if signer != nil {
+		err = signJobOutput(signer, &status.Output, expiration)
+		if err != nil {
+			return swagger.NewErrorResponse(fmt.Errorf("error signing the output URLs of job %q: %s", job.ID, err))
+		}
+	}
+	return newJobStatusResponse(status)
 }
 
 func (s *TranscodingService) getJobStatusResponse(job *db.Job, status *provider.JobStatus, p provider.TranscodingProvider, err error) swagger.GizmoJSONResponse {
@@ -170,7 +494,9 @@ func (s *TranscodingService) getTranscodeJobByID(jobID string) (*db.Job, *provid
 	if err != nil {
 		return job, nil, providerObj, err
 	}
+	s.recordJobStatus(job, jobStatus.Status)
 	jobStatus.ProviderName = job.ProviderName
+	jobStatus.PresetVersions = job.PresetVersions
 	return job, jobStatus, providerObj, nil
 }
 
@@ -204,6 +530,49 @@ func (s *TranscodingService) cancelTranscodeJob(r *http.Request) swagger.GizmoJS
 	if err != nil {
 		return swagger.NewErrorResponse(err)
 	}
+	s.recordJobStatus(job, status.Status)
 	status.ProviderName = job.ProviderName
 	return newJobStatusResponse(status)
 }
+
+// swagger:route GET /jobs/{jobId}/key jobs getJobKey
+//
+// Returns the key used for encrypting the segments of the job. This endpoint
+// is used by the key server, and requests must include the key server token
+// in the Authorization header (Bearer <token>).
+//
+//     Responses:
+//       200: jobKey
+//       401: unauthorized
+//       404: keyNotFound
+//       500: genericError
+func (s *TranscodingService) getJobKey(r *http.Request) swagger.GizmoJSONResponse {
+	if !s.isKeyServer(r) {
+		return newUnauthorizedResponse(errUnauthorizedKeyRequest)
+	}
+	var params getJobKeyInput
+	params.loadParams(web.Vars(r))
+	key, err := s.db.GetEncryptionKey(params.JobID)
+	if err != nil {
+		if err == db.ErrEncryptionKeyNotFound {
+			return newKeyNotFoundResponse(err)
+		}
+		return swagger.NewErrorResponse(err)
+	}
+	return newJobKeyResponse(key)
+}
+
+var errUnauthorizedKeyRequest = errors.New("invalid or missing key server token")
+
+func (s *TranscodingService) isKeyServer(r *http.Request) bool {
+	if s.config.KeyServer == nil || s.config.KeyServer.Token == "" {
+		return false
+	}
+	const prefix = "Bearer "
+	authorization := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authorization, prefix) {
+		return false
+	}
+	token := strings.TrimPrefix(authorization, prefix)
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.config.KeyServer.Token)) == 1
+}
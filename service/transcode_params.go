@@ -1,10 +1,16 @@
 package service
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"net/url"
+	"strconv"
+	"strings"
 
+	"github.com/NYTimes/video-transcoding-api/db"
 	"github.com/NYTimes/video-transcoding-api/provider"
 )
 
@@ -25,6 +31,37 @@ type NewTranscodeJobInputPayload struct {
 
 	// provider Adaptive Streaming parameters
 	StreamingParams provider.StreamingParams `json:"streamingParams,omitempty"`
+
+	// list of caption sources to attach to the outputs of the job
+	Captions []provider.Caption `json:"captions,omitempty"`
+
+	// URL of the image to use as overlay in outputs whose preset defines
+	// an overlay
+	OverlayImage string `json:"overlayImage,omitempty"`
+
+	// base destination for the outputs of the job, overriding the
+	// destination configured for the provider. It must be under one of
+	// the destinations allowed in the configuration of the API
+	Destination string `json:"destination,omitempty"`
+
+	// template for the path of the directory that holds the outputs of
+	// the job, relative to its destination. Supports the placeholders
+	// {jobId}, {sourceBase}, {date:<layout>} and {label.<name>}
+	OutputPath string `json:"outputPath,omitempty"`
+
+	// template for the name of the outputs that don't specify one. Along
+	// with the placeholders supported in outputPath, it supports {preset}
+	// and {ext}
+	FileNameTemplate string `json:"fileNameTemplate,omitempty"`
+
+	// set of labels that may be used in output path and file name
+	// templates
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// options passed verbatim to the request sent to the provider,
+	// indexed by the name of the provider. Options of other providers
+	// are ignored
+	ProviderOptions db.ProviderOptions `json:"providerOptions,omitempty"`
 }
 
 // swagger:parameters newJob
@@ -62,10 +99,90 @@ func (p *newTranscodeJobInput) validate() error {
 	if len(p.Payload.Outputs) == 0 {
 		return errors.New("missing output list from request")
 	}
+	if protocol := p.Payload.StreamingParams.Protocol; protocol != "" {
+		if _, ok := defaultPlaylistFileNames[protocol]; !ok {
+			return fmt.Errorf("invalid streaming protocol %q. Valid protocols are: hls, dash", protocol)
+		}
+	}
+	if err := validateSegmentFormat(p.Payload.StreamingParams); err != nil {
+		return err
+	}
+	if err := validateEncryption(p.Payload.StreamingParams); err != nil {
+		return err
+	}
+	for _, template := range []string{p.Payload.OutputPath, p.Payload.FileNameTemplate} {
+		if err := validatePathTemplate(template); err != nil {
+			return err
+		}
+	}
+	for _, caption := range p.Payload.Captions {
+		if err := validateCaption(caption); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-// swagger:parameters getJob
+func validateSegmentFormat(params provider.StreamingParams) error {
+	if params.SegmentFormat == "" {
+		return nil
+	}
+	if params.Protocol != "hls" {
+		return errors.New("segment format can only be defined for hls jobs")
+	}
+	for _, format := range provider.HLSSegmentFormats {
+		if params.SegmentFormat == format {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid segment format %q. Valid formats are: %s", params.SegmentFormat, strings.Join(provider.HLSSegmentFormats, ", "))
+}
+
+func validateEncryption(params provider.StreamingParams) error {
+	encryption := params.Encryption
+	if encryption == nil {
+		return nil
+	}
+	if params.Protocol != "hls" {
+		return errors.New("encryption can only be defined for hls jobs")
+	}
+	var validMethod bool
+	for _, method := range provider.HLSEncryptionMethods {
+		if encryption.Method == method {
+			validMethod = true
+			break
+		}
+	}
+	if !validMethod {
+		return fmt.Errorf("invalid encryption method %q. Valid methods are: %s", encryption.Method, strings.Join(provider.HLSEncryptionMethods, ", "))
+	}
+	if encryption.KeyURI == "" {
+		return errors.New("missing keyUri from encryption")
+	}
+	if encryption.Key != "" {
+		key, err := base64.StdEncoding.DecodeString(encryption.Key)
+		if err != nil || len(key) != encryptionKeySize {
+			return errors.New("invalid encryption key. Please provide a base64-encoded 128-bit key")
+		}
+	}
+	return nil
+}
+
+func validateCaption(caption provider.Caption) error {
+	if caption.Source == "" {
+		return errors.New("missing source from caption")
+	}
+	if caption.Language == "" {
+		return fmt.Errorf("missing language from caption %q", caption.Source)
+	}
+	for _, format := range provider.CaptionFormats {
+		if caption.Format == format {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid format %q for caption %q. Valid formats are: %s", caption.Format, caption.Source, strings.Join(provider.CaptionFormats, ", "))
+}
+
 type getTranscodeJobInput struct {
 	// in: path
 	// required: true
@@ -76,7 +193,48 @@ func (p *getTranscodeJobInput) loadParams(paramsMap map[string]string) {
 	p.JobID = paramsMap["jobId"]
 }
 
+// swagger:parameters getJob
+type getTranscodeJobStatusInput struct {
+	getTranscodeJobInput
+
+	// include time-limited signed HTTPS URLs for downloading the output
+	// files of the job
+	//
+	// in: query
+	SignedURLs bool `json:"signedUrls"`
+
+	// expiration of the signed URLs, in seconds. Defaults to the
+	// expiration defined in the configuration of the API
+	//
+	// in: query
+	ExpiresIn uint `json:"expiresIn"`
+}
+
+func (p *getTranscodeJobStatusInput) loadParams(paramsMap map[string]string, query url.Values) error {
+	p.getTranscodeJobInput.loadParams(paramsMap)
+	var err error
+	if value := query.Get("signedUrls"); value != "" {
+		p.SignedURLs, err = strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid value for signedUrls: %q", value)
+		}
+	}
+	if value := query.Get("expiresIn"); value != "" {
+		expiresIn, err := strconv.ParseUint(value, 10, 32)
+		if err != nil || expiresIn == 0 {
+			return fmt.Errorf("invalid value for expiresIn: %q. It must be a positive number of seconds", value)
+		}
+		p.ExpiresIn = uint(expiresIn)
+	}
+	return nil
+}
+
 // swagger:parameters cancelJob
 type cancelTranscodeJobInput struct {
 	getTranscodeJobInput
 }
+
+// swagger:parameters getJobKey
+type getJobKeyInput struct {
+	getTranscodeJobInput
+}
@@ -3,6 +3,7 @@ package service
 import (
 	"net/http"
 
+	"github.com/NYTimes/video-transcoding-api/db"
 	"github.com/NYTimes/video-transcoding-api/provider"
 	"github.com/NYTimes/video-transcoding-api/swagger"
 )
@@ -105,3 +106,54 @@ func newJobNotFoundProviderResponse(err error) *jobNotFoundProviderResponse {
 func (r *jobNotFoundProviderResponse) Result() (int, interface{}, error) {
 	return r.Error.Result()
 }
+
+// JSON-encoded key used for encrypting the segments of a job.
+//
+// swagger:response jobKey
+type jobKeyResponse struct {
+	// in: body
+	Payload *db.EncryptionKey
+
+	baseResponse
+}
+
+func newJobKeyResponse(key *db.EncryptionKey) *jobKeyResponse {
+	return &jobKeyResponse{
+		baseResponse: baseResponse{
+			payload: key,
+			status:  http.StatusOK,
+		},
+	}
+}
+
+// error returned when the job doesn't have an encryption key.
+//
+// swagger:response keyNotFound
+type keyNotFoundResponse struct {
+	// in: body
+	Error *swagger.ErrorResponse
+}
+
+func newKeyNotFoundResponse(err error) *keyNotFoundResponse {
+	return &keyNotFoundResponse{Error: swagger.NewErrorResponse(err).WithStatus(http.StatusNotFound)}
+}
+
+func (r *keyNotFoundResponse) Result() (int, interface{}, error) {
+	return r.Error.Result()
+}
+
+// error returned when the request is not authenticated.
+//
+// swagger:response unauthorized
+type unauthorizedResponse struct {
+	// in: body
+	Error *swagger.ErrorResponse
+}
+
+func newUnauthorizedResponse(err error) *unauthorizedResponse {
+	return &unauthorizedResponse{Error: swagger.NewErrorResponse(err).WithStatus(http.StatusUnauthorized)}
+}
+
+func (r *unauthorizedResponse) Result() (int, interface{}, error) {
+	return r.Error.Result()
+}
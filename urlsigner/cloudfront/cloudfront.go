@@ -0,0 +1,60 @@
+// Package cloudfront provides a url signer that generates CloudFront signed
+// URLs (canned policy) for files stored in the S3 origin of a distribution.
+//
+// In order to use it, import this package and set URL_SIGNER to
+// "cloudfront":
+//
+//     import _ "github.com/NYTimes/video-transcoding-api/urlsigner/cloudfront"
+package cloudfront
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/NYTimes/video-transcoding-api/config"
+	"github.com/NYTimes/video-transcoding-api/mediaurl"
+	"github.com/NYTimes/video-transcoding-api/urlsigner"
+	"github.com/aws/aws-sdk-go/service/cloudfront/sign"
+)
+
+// Name is the name used for registering the CloudFront signer in the
+// registry of url signers.
+const Name = "cloudfront"
+
+var errInvalidConfig = urlsigner.InvalidConfigError("missing CloudFront settings for the cloudfront url signer. Please define the environment variables URL_SIGNER_CLOUDFRONT_DOMAIN, URL_SIGNER_CLOUDFRONT_KEY_PAIR_ID and URL_SIGNER_CLOUDFRONT_PRIVATE_KEY_PATH")
+
+func init() {
+	urlsigner.Register(Name, cloudFrontSignerFactory)
+}
+
+type cloudFrontSigner struct {
+	domain string
+	signer *sign.URLSigner
+}
+
+// Sign maps the S3 location to the same key in the CloudFront distribution
+// and signs the resulting URL.
+func (s *cloudFrontSigner) Sign(loc *mediaurl.Location, expiration time.Duration) (string, error) {
+	s3Loc, ok := loc.S3()
+	if !ok {
+		return "", urlsigner.ErrUnsupportedLocation
+	}
+	distributionURL := url.URL{Scheme: "https", Host: s.domain, Path: "/" + s3Loc.Path}
+	return s.signer.Sign(distributionURL.String(), time.Now().Add(expiration))
+}
+
+func cloudFrontSignerFactory(cfg *config.Config) (urlsigner.Signer, error) {
+	signerCfg := cfg.URLSigner
+	if signerCfg.CloudFrontDomain == "" || signerCfg.CloudFrontKeyPairID == "" || signerCfg.CloudFrontPrivateKeyPath == "" {
+		return nil, errInvalidConfig
+	}
+	privateKey, err := sign.LoadPEMPrivKeyFile(signerCfg.CloudFrontPrivateKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load the CloudFront private key: %s", err)
+	}
+	return &cloudFrontSigner{
+		domain: signerCfg.CloudFrontDomain,
+		signer: sign.NewURLSigner(signerCfg.CloudFrontKeyPairID, privateKey),
+	}, nil
+}
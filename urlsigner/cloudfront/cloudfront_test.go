@@ -0,0 +1,142 @@
+package cloudfront
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/NYTimes/video-transcoding-api/config"
+	"github.com/NYTimes/video-transcoding-api/mediaurl"
+	"github.com/NYTimes/video-transcoding-api/urlsigner"
+)
+
+func writePrivateKey(t *testing.T) string {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := ioutil.TempFile("", "cloudfront-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	err = pem.Encode(file, &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return file.Name()
+}
+
+func TestCloudFrontSignerSign(t *testing.T) {
+	keyPath := writePrivateKey(t)
+	defer os.Remove(keyPath)
+	cfg := config.Config{
+		URLSigner: &config.URLSigner{
+			CloudFrontDomain:         "d111111abcdef8.cloudfront.net",
+			CloudFrontKeyPairID:      "APKANOTREALLY",
+			CloudFrontPrivateKeyPath: keyPath,
+		},
+	}
+	signer, err := cloudFrontSignerFactory(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tests = []struct {
+		source       string
+		expectedPath string
+	}{
+		{"s3://my-bucket/job-123/video.mp4", "/job-123/video.mp4"},
+		{"https://my-bucket.s3.amazonaws.com/job-123/some video.mp4", "/job-123/some video.mp4"},
+	}
+	for _, test := range tests {
+		loc, err := mediaurl.Parse(test.source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		signedURL, err := signer.Sign(loc, 10*time.Minute)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.source, err)
+			continue
+		}
+		parsedURL, err := url.Parse(signedURL)
+		if err != nil {
+			t.Errorf("%s: invalid signed URL %q: %s", test.source, signedURL, err)
+			continue
+		}
+		if parsedURL.Scheme != "https" || parsedURL.Host != "d111111abcdef8.cloudfront.net" || parsedURL.Path != test.expectedPath {
+			t.Errorf("%s: wrong signed URL: %s", test.source, signedURL)
+		}
+		query := parsedURL.Query()
+		if keyPairID := query.Get("Key-Pair-Id"); keyPairID != "APKANOTREALLY" {
+			t.Errorf("%s: wrong key pair ID. Want %q. Got %q", test.source, "APKANOTREALLY", keyPairID)
+		}
+		if query.Get("Expires") == "" || query.Get("Signature") == "" {
+			t.Errorf("%s: missing signature parameters in %s", test.source, signedURL)
+		}
+	}
+}
+
+func TestCloudFrontSignerSignUnsupportedLocation(t *testing.T) {
+	keyPath := writePrivateKey(t)
+	defer os.Remove(keyPath)
+	cfg := config.Config{
+		URLSigner: &config.URLSigner{
+			CloudFrontDomain:         "d111111abcdef8.cloudfront.net",
+			CloudFrontKeyPairID:      "APKANOTREALLY",
+			CloudFrontPrivateKeyPath: keyPath,
+		},
+	}
+	signer, err := cloudFrontSignerFactory(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loc, err := mediaurl.Parse("ftp://ftp.example.com/video.mp4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = signer.Sign(loc, time.Minute)
+	if err != urlsigner.ErrUnsupportedLocation {
+		t.Errorf("wrong error returned. Want %#v. Got %#v", urlsigner.ErrUnsupportedLocation, err)
+	}
+}
+
+func TestCloudFrontSignerFactoryValidation(t *testing.T) {
+	var tests = []config.URLSigner{
+		{CloudFrontKeyPairID: "APKANOTREALLY", CloudFrontPrivateKeyPath: "/etc/pk.pem"},
+		{CloudFrontDomain: "d111111abcdef8.cloudfront.net", CloudFrontPrivateKeyPath: "/etc/pk.pem"},
+		{CloudFrontDomain: "d111111abcdef8.cloudfront.net", CloudFrontKeyPairID: "APKANOTREALLY"},
+	}
+	for _, signerCfg := range tests {
+		cfg := config.Config{URLSigner: &signerCfg}
+		signer, err := cloudFrontSignerFactory(&cfg)
+		if signer != nil {
+			t.Errorf("Got unexpected non-nil signer: %#v", signer)
+		}
+		if err != errInvalidConfig {
+			t.Errorf("Wrong error returned. Want %#v. Got %#v", errInvalidConfig, err)
+		}
+	}
+}
+
+func TestCloudFrontSignerFactoryInvalidKey(t *testing.T) {
+	cfg := config.Config{
+		URLSigner: &config.URLSigner{
+			CloudFrontDomain:         "d111111abcdef8.cloudfront.net",
+			CloudFrontKeyPairID:      "APKANOTREALLY",
+			CloudFrontPrivateKeyPath: "/path/to/missing/key.pem",
+		},
+	}
+	signer, err := cloudFrontSignerFactory(&cfg)
+	if signer != nil {
+		t.Errorf("Got unexpected non-nil signer: %#v", signer)
+	}
+	if err == nil {
+		t.Error("Got unexpected <nil> error")
+	}
+}
@@ -0,0 +1,60 @@
+// Package s3signer provides a url signer that generates pre-signed S3 URLs
+// (AWS Signature Version 4).
+//
+// In order to use it, import this package and set URL_SIGNER to "s3":
+//
+//     import _ "github.com/NYTimes/video-transcoding-api/urlsigner/s3signer"
+package s3signer
+
+import (
+	"time"
+
+	"github.com/NYTimes/video-transcoding-api/config"
+	"github.com/NYTimes/video-transcoding-api/mediaurl"
+	"github.com/NYTimes/video-transcoding-api/urlsigner"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Name is the name used for registering the S3 signer in the registry of
+// url signers.
+const Name = "s3"
+
+const defaultAWSRegion = "us-east-1"
+
+var errInvalidConfig = urlsigner.InvalidConfigError("missing AWS credentials for the s3 url signer. Please define the environment variables URL_SIGNER_AWS_ACCESS_KEY_ID and URL_SIGNER_AWS_SECRET_ACCESS_KEY")
+
+func init() {
+	urlsigner.Register(Name, s3SignerFactory)
+}
+
+type s3Signer struct {
+	client *s3.S3
+}
+
+func (s *s3Signer) Sign(loc *mediaurl.Location, expiration time.Duration) (string, error) {
+	s3Loc, ok := loc.S3()
+	if !ok {
+		return "", urlsigner.ErrUnsupportedLocation
+	}
+	req, _ := s.client.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: aws.String(s3Loc.Host),
+		Key:    aws.String(s3Loc.Path),
+	})
+	return req.Presign(expiration)
+}
+
+func s3SignerFactory(cfg *config.Config) (urlsigner.Signer, error) {
+	if cfg.URLSigner.AccessKeyID == "" || cfg.URLSigner.SecretAccessKey == "" {
+		return nil, errInvalidConfig
+	}
+	creds := credentials.NewStaticCredentials(cfg.URLSigner.AccessKeyID, cfg.URLSigner.SecretAccessKey, "")
+	region := cfg.URLSigner.Region
+	if region == "" {
+		region = defaultAWSRegion
+	}
+	awsSession := session.New(aws.NewConfig().WithCredentials(creds).WithRegion(region))
+	return &s3Signer{client: s3.New(awsSession)}, nil
+}
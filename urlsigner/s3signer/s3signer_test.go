@@ -0,0 +1,108 @@
+package s3signer
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NYTimes/video-transcoding-api/config"
+	"github.com/NYTimes/video-transcoding-api/mediaurl"
+	"github.com/NYTimes/video-transcoding-api/urlsigner"
+)
+
+func TestS3SignerFactory(t *testing.T) {
+	cfg := config.Config{
+		URLSigner: &config.URLSigner{
+			AccessKeyID:     "AKIANOTREALLY",
+			SecretAccessKey: "really-secret",
+		},
+	}
+	signer, err := s3SignerFactory(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if region := *signer.(*s3Signer).client.Config.Region; region != defaultAWSRegion {
+		t.Errorf("wrong region. Want %q. Got %q", defaultAWSRegion, region)
+	}
+}
+
+func TestS3SignerFactoryValidation(t *testing.T) {
+	var tests = []config.URLSigner{
+		{AccessKeyID: "AKIANOTREALLY"},
+		{SecretAccessKey: "really-secret"},
+	}
+	for _, signerCfg := range tests {
+		cfg := config.Config{URLSigner: &signerCfg}
+		signer, err := s3SignerFactory(&cfg)
+		if signer != nil {
+			t.Errorf("Got unexpected non-nil signer: %#v", signer)
+		}
+		if err != errInvalidConfig {
+			t.Errorf("Wrong error returned. Want %#v. Got %#v", errInvalidConfig, err)
+		}
+	}
+}
+
+func TestS3SignerSign(t *testing.T) {
+	cfg := config.Config{
+		URLSigner: &config.URLSigner{
+			AccessKeyID:     "AKIANOTREALLY",
+			SecretAccessKey: "really-secret",
+			Region:          "sa-east-1",
+		},
+	}
+	signer, err := s3SignerFactory(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loc, err := mediaurl.Parse("s3://my-bucket/job-123/video.mp4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	signedURL, err := signer.Sign(loc, 10*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsedURL, err := url.Parse(signedURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsedURL.Scheme != "https" {
+		t.Errorf("wrong scheme. Want %q. Got %q", "https", parsedURL.Scheme)
+	}
+	if !strings.Contains(parsedURL.Host+parsedURL.Path, "my-bucket") || !strings.HasSuffix(parsedURL.Path, "/job-123/video.mp4") {
+		t.Errorf("wrong signed URL: %s", signedURL)
+	}
+	query := parsedURL.Query()
+	if expires := query.Get("X-Amz-Expires"); expires != "600" {
+		t.Errorf("wrong expiration. Want %q. Got %q", "600", expires)
+	}
+	if credential := query.Get("X-Amz-Credential"); !strings.HasPrefix(credential, "AKIANOTREALLY/") {
+		t.Errorf("wrong credential in the signed URL: %q", credential)
+	}
+	if query.Get("X-Amz-Signature") == "" {
+		t.Errorf("missing signature in the signed URL: %s", signedURL)
+	}
+}
+
+func TestS3SignerSignUnsupportedLocation(t *testing.T) {
+	cfg := config.Config{
+		URLSigner: &config.URLSigner{
+			AccessKeyID:     "AKIANOTREALLY",
+			SecretAccessKey: "really-secret",
+		},
+	}
+	signer, err := s3SignerFactory(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loc, err := mediaurl.Parse("gs://my-bucket/job-123/video.mp4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = signer.Sign(loc, time.Minute)
+	if err != urlsigner.ErrUnsupportedLocation {
+		t.Errorf("wrong error returned. Want %#v. Got %#v", urlsigner.ErrUnsupportedLocation, err)
+	}
+}
@@ -0,0 +1,74 @@
+// Package urlsigner defines the interface of the backends that generate
+// time-limited download URLs for the outputs of transcoding jobs.
+//
+// Backends are registered in the same way as transcoding providers: the
+// package implementing the backend registers a factory in its init function,
+// and the API looks up the factory using the name configured in the
+// URL_SIGNER environment variable.
+package urlsigner
+
+import (
+	"errors"
+	"time"
+
+	"github.com/NYTimes/video-transcoding-api/config"
+	"github.com/NYTimes/video-transcoding-api/mediaurl"
+)
+
+var (
+	// ErrSignerAlreadyRegistered is the error returned when trying to
+	// register a signer twice.
+	ErrSignerAlreadyRegistered = errors.New("url signer is already registered")
+
+	// ErrSignerNotFound is the error returned when asking for a signer
+	// that is not registered.
+	ErrSignerNotFound = errors.New("url signer not found")
+
+	// ErrUnsupportedLocation is the error returned by signers when asked to
+	// sign a location that they're unable to handle (for example, a file
+	// that is not stored in S3).
+	ErrUnsupportedLocation = errors.New("location is not supported by the url signer")
+)
+
+// Signer generates signed URLs for downloading files.
+type Signer interface {
+	// Sign returns a signed HTTPS URL for downloading the file in the
+	// given location, valid for the given duration.
+	Sign(loc *mediaurl.Location, expiration time.Duration) (string, error)
+}
+
+// Factory is the function responsible for creating the instance of a
+// signer.
+type Factory func(cfg *config.Config) (Signer, error)
+
+// InvalidConfigError is returned if a signer could not be configured
+// properly.
+type InvalidConfigError string
+
+func (err InvalidConfigError) Error() string {
+	return string(err)
+}
+
+var signers map[string]Factory
+
+// Register registers a new signer in the internal list of signers.
+func Register(name string, signer Factory) error {
+	if signers == nil {
+		signers = make(map[string]Factory)
+	}
+	if _, ok := signers[name]; ok {
+		return ErrSignerAlreadyRegistered
+	}
+	signers[name] = signer
+	return nil
+}
+
+// GetSignerFactory looks up the list of registered signers and returns the
+// factory function for the given signer name, if it's available.
+func GetSignerFactory(name string) (Factory, error) {
+	factory, ok := signers[name]
+	if !ok {
+		return nil, ErrSignerNotFound
+	}
+	return factory, nil
+}
@@ -0,0 +1,65 @@
+package urlsigner
+
+import (
+	"testing"
+
+	"github.com/NYTimes/video-transcoding-api/config"
+)
+
+func noopFactory(*config.Config) (Signer, error) {
+	return nil, nil
+}
+
+func TestRegister(t *testing.T) {
+	signers = nil
+	err := Register("noop", noopFactory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := signers["noop"]; !ok {
+		t.Errorf("expected to get the noop factory registered. Got map %#v", signers)
+	}
+}
+
+func TestRegisterDuplicate(t *testing.T) {
+	signers = nil
+	err := Register("noop", noopFactory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Register("noop", noopFactory)
+	if err != ErrSignerAlreadyRegistered {
+		t.Errorf("Got wrong error when registering signer twice. Want %#v. Got %#v", ErrSignerAlreadyRegistered, err)
+	}
+}
+
+func TestGetSignerFactory(t *testing.T) {
+	signers = nil
+	var called bool
+	err := Register("noop", func(*config.Config) (Signer, error) {
+		called = true
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	factory, err := GetSignerFactory("noop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	factory(nil)
+	if !called {
+		t.Errorf("Did not call the expected factory. Got %#v", factory)
+	}
+}
+
+func TestGetSignerFactoryNotRegistered(t *testing.T) {
+	signers = nil
+	factory, err := GetSignerFactory("noop")
+	if factory != nil {
+		t.Errorf("Got unexpected non-nil factory: %#v", factory)
+	}
+	if err != ErrSignerNotFound {
+		t.Errorf("Got wrong error when getting an unregistered signer. Want %#v. Got %#v", ErrSignerNotFound, err)
+	}
+}
@@ -0,0 +1,279 @@
+// Package verify checks that the outputs reported by transcoding providers
+// actually exist at the destination of the job.
+//
+// Files are read through HTTP using range requests, so only outputs stored in
+// locations that can be mapped to HTTP URLs (S3, Google Cloud Storage and
+// HTTP servers) are verified. Master playlists of HLS outputs are also
+// downloaded, and the variant playlists referenced by them are verified as
+// well.
+package verify
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NYTimes/video-transcoding-api/mediaurl"
+)
+
+const (
+	defaultTimeout  = 30 * time.Second
+	maxPlaylistSize = 1 << 20
+)
+
+var (
+	errFileNotFound = errors.New("file not found")
+
+	// ErrUnverifiable may be returned by the URL function of a Verifier
+	// for files that can't be read, like files in private buckets. These
+	// files are reported as skipped.
+	ErrUnverifiable = errors.New("output file can't be read")
+
+	defaultVerifier = Verifier{Client: &http.Client{Timeout: defaultTimeout}}
+)
+
+// Result is the result of the verification of the outputs of a job.
+type Result struct {
+	// Errors describes the problems found in the outputs. The
+	// verification passed when it's empty.
+	Errors []string
+
+	// Skipped contains the outputs that were not verified because they're
+	// not stored in a location that can be read through HTTP, or because
+	// the URL function returned ErrUnverifiable.
+	Skipped []string
+}
+
+// Verifier verifies output files available through HTTP.
+type Verifier struct {
+	// Client is the HTTP client used for reading the files. When nil,
+	// http.DefaultClient is used.
+	Client *http.Client
+
+	// URL returns the URL used for reading the file in the given
+	// location, which may be a signed URL for files in private buckets.
+	// When nil, the public HTTP URL of the location is used.
+	URL func(loc *mediaurl.Location) (string, error)
+}
+
+// Verify verifies the given output files using a Verifier with the default
+// timeout.
+func Verify(paths []string) (*Result, error) {
+	return defaultVerifier.Verify(paths)
+}
+
+// Verify checks that each of the given output files exists and is not empty.
+//
+// Missing and empty files are reported in the result, while failures to
+// reach the storage are returned as errors, so the verification can be
+// retried later.
+func (v *Verifier) Verify(paths []string) (*Result, error) {
+	var result Result
+	verified := make(map[string]bool, len(paths))
+	for _, outputPath := range paths {
+		loc, err := mediaurl.Parse(outputPath)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("invalid output path %q: %s", outputPath, err))
+			continue
+		}
+		if loc.Require(mediaurl.S3, mediaurl.GCS, mediaurl.HTTP, mediaurl.HTTPS) != nil {
+			result.Skipped = append(result.Skipped, loc.WithoutCredentials().String())
+			continue
+		}
+		err = v.verifyFile(loc, verified, &result)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &result, nil
+}
+
+func (v *Verifier) verifyFile(loc *mediaurl.Location, verified map[string]bool, result *Result) error {
+	name := loc.WithoutCredentials().String()
+	if verified[name] {
+		return nil
+	}
+	verified[name] = true
+	size, err := v.size(loc)
+	if err == ErrUnverifiable {
+		result.Skipped = append(result.Skipped, name)
+		return nil
+	}
+	if err == errFileNotFound {
+		result.Errors = append(result.Errors, fmt.Sprintf("output file %q is missing", name))
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if size == 0 {
+		result.Errors = append(result.Errors, fmt.Sprintf("output file %q is empty", name))
+		return nil
+	}
+	if !strings.HasSuffix(strings.ToLower(loc.Path), ".m3u8") {
+		return nil
+	}
+	variants, err := v.variantPlaylists(loc)
+	if err != nil {
+		return err
+	}
+	for _, variant := range variants {
+		variantLoc, err := resolve(loc, variant)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("invalid variant playlist %q in %q: %s", variant, name, err))
+			continue
+		}
+		err = v.verifyFile(variantLoc, verified, result)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// size returns the size of the file in the given location, reading at most
+// one byte of it.
+func (v *Verifier) size(loc *mediaurl.Location) (int64, error) {
+	resp, err := v.get(loc, "bytes=0-0")
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		if size := contentRangeSize(resp.Header.Get("Content-Range")); size > -1 {
+			return size, nil
+		}
+		return 1, nil
+	case http.StatusOK:
+		if resp.ContentLength > -1 {
+			return resp.ContentLength, nil
+		}
+		n, _ := io.ReadFull(resp.Body, make([]byte, 1))
+		return int64(n), nil
+	case http.StatusRequestedRangeNotSatisfiable:
+		return 0, nil
+	case http.StatusNotFound:
+		return 0, errFileNotFound
+	}
+	return 0, fmt.Errorf("failed to read output file %q: %s", loc.WithoutCredentials(), resp.Status)
+}
+
+// variantPlaylists returns the URIs of the playlists referenced by the
+// master playlist in the given location. It returns an empty list for media
+// playlists.
+func (v *Verifier) variantPlaylists(loc *mediaurl.Location) ([]string, error) {
+	resp, err := v.get(loc, "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to read playlist %q: %s", loc.WithoutCredentials(), resp.Status)
+	}
+	var uris []string
+	var streamInf bool
+	scanner := bufio.NewScanner(io.LimitReader(resp.Body, maxPlaylistSize))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#EXT-X-STREAM-INF"):
+			streamInf = true
+		case strings.HasPrefix(line, "#EXT-X-MEDIA:"), strings.HasPrefix(line, "#EXT-X-I-FRAME-STREAM-INF:"):
+			if uri := attribute(line, "URI"); uri != "" {
+				uris = append(uris, uri)
+			}
+		case strings.HasPrefix(line, "#"):
+		case streamInf:
+			uris = append(uris, line)
+			streamInf = false
+		}
+	}
+	return uris, scanner.Err()
+}
+
+func (v *Verifier) get(loc *mediaurl.Location, byteRange string) (*http.Response, error) {
+	rawURL := loc.HTTP().String()
+	if v.URL != nil {
+		var err error
+		rawURL, err = v.URL(loc)
+		if err != nil {
+			return nil, err
+		}
+	}
+	req, err := http.NewRequest("GET", rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if byteRange != "" {
+		req.Header.Set("Range", byteRange)
+	}
+	client := v.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return client.Do(req)
+}
+
+// resolve returns the location of a playlist referenced by the playlist in
+// the given location.
+func resolve(base *mediaurl.Location, uri string) (*mediaurl.Location, error) {
+	if strings.Contains(uri, "://") {
+		return mediaurl.Parse(uri)
+	}
+	loc := *base
+	loc.RawQuery = ""
+	if i := strings.Index(uri, "?"); i > -1 {
+		loc.RawQuery = uri[i+1:]
+		uri = uri[:i]
+	}
+	if strings.HasPrefix(uri, "/") {
+		loc.Path = strings.TrimPrefix(path.Clean(uri), "/")
+	} else {
+		loc.Path = path.Join(path.Dir(base.Path), uri)
+	}
+	if strings.HasPrefix(loc.Path, "..") {
+		return nil, errors.New("playlist is outside of the output directory")
+	}
+	return &loc, nil
+}
+
+// attribute returns the value of the given attribute in a playlist tag.
+func attribute(line, name string) string {
+	i := strings.Index(line, name+"=")
+	if i < 0 {
+		return ""
+	}
+	value := line[i+len(name)+1:]
+	if strings.HasPrefix(value, `"`) {
+		if end := strings.Index(value[1:], `"`); end > -1 {
+			return value[1 : end+1]
+		}
+		return ""
+	}
+	if end := strings.Index(value, ","); end > -1 {
+		return value[:end]
+	}
+	return value
+}
+
+// contentRangeSize extracts the complete length of the file from the value
+// of a Content-Range header, returning -1 when it's unknown.
+func contentRangeSize(contentRange string) int64 {
+	i := strings.LastIndex(contentRange, "/")
+	if i < 0 {
+		return -1
+	}
+	size, err := strconv.ParseInt(contentRange[i+1:], 10, 64)
+	if err != nil {
+		return -1
+	}
+	return size
+}